@@ -5,16 +5,21 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/Use-Tusk/fence/internal/config"
+	"github.com/Use-Tusk/fence/internal/configschema"
 	"github.com/Use-Tusk/fence/internal/importer"
 	"github.com/Use-Tusk/fence/internal/platform"
 	"github.com/Use-Tusk/fence/internal/sandbox"
@@ -39,11 +44,14 @@ var (
 	listTemplates bool
 	cmdString     string
 	exposePorts   []string
+	disableTags   []string
 	shellMode     string
 	shellLogin    bool
 	exitCode      int
 	showVersion   bool
 	linuxFeatures bool
+	showStats     bool
+	statsFormat   string
 )
 
 func main() {
@@ -73,6 +81,7 @@ Examples:
   fence -t ai-coding-agents -- agent-cmd  # Use AI coding agents template
   fence -p 3000 -c "npm run dev"          # Expose port 3000 for inbound connections
   fence --shell user -c "nvim"            # Use validated $SHELL for command execution
+  fence --stats -- npm install            # Print request and violation counts afterwards
   fence --list-templates                  # Show available built-in templates
 
 Configuration file format:
@@ -103,13 +112,19 @@ Configuration file format:
 	rootCmd.Flags().BoolVar(&listTemplates, "list-templates", false, "List available templates")
 	rootCmd.Flags().StringVarP(&cmdString, "c", "c", "", "Run command string directly (like sh -c)")
 	rootCmd.Flags().StringArrayVarP(&exposePorts, "port", "p", nil, "Expose port for inbound connections (can be used multiple times)")
-	rootCmd.Flags().StringVar(&shellMode, "shell", sandbox.ShellModeDefault, "Shell mode for command execution: default (bash) or user ($SHELL)")
+	rootCmd.Flags().StringArrayVar(&disableTags, "disable-tag", nil, "Remove allowed domain entries with this tag (e.g., openai for \"tag:openai api.openai.com\"; can be used multiple times)")
+	rootCmd.Flags().StringVar(&shellMode, "shell", sandbox.ShellModeDefault, "Shell mode for command execution: default (bash), user ($SHELL) or custom (shell.path in the config)")
 	rootCmd.Flags().BoolVar(&shellLogin, "shell-login", false, "Run shell as login shell (-lc). Use with --shell user for shell init compatibility")
 	rootCmd.Flags().BoolVarP(&showVersion, "version", "v", false, "Show version information")
 	rootCmd.Flags().BoolVar(&linuxFeatures, "linux-features", false, "Show available Linux security features and exit")
+	rootCmd.Flags().BoolVar(&showStats, "stats", false, "Print sandbox statistics to stderr after the command exits")
+	rootCmd.Flags().StringVar(&statsFormat, "stats-format", "text", "Format for --stats: text or json")
 
 	rootCmd.Flags().SetInterspersed(true)
 
+	rootCmd.AddCommand(newCheckCmd())
+	rootCmd.AddCommand(newDiffCmd())
+	rootCmd.AddCommand(newSchemaCmd())
 	rootCmd.AddCommand(newImportCmd())
 	rootCmd.AddCommand(newConfigCmd())
 	rootCmd.AddCommand(newCompletionCmd(rootCmd))
@@ -140,6 +155,10 @@ func runCommand(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if statsFormat != "text" && statsFormat != "json" {
+		return fmt.Errorf("unknown --stats-format %q (expected text or json)", statsFormat)
+	}
+
 	var command string
 	switch {
 	case cmdString != "":
@@ -167,11 +186,6 @@ func runCommand(cmd *cobra.Command, args []string) error {
 		fmt.Fprintf(os.Stderr, "[fence] Exposing ports: %v\n", ports)
 	}
 
-	// Validate shell mode early to fail before proxy/sandbox initialization.
-	if _, _, err := sandbox.ResolveExecutionShell(shellMode, shellLogin); err != nil {
-		return fmt.Errorf("invalid shell options: %w", err)
-	}
-
 	// Load config: template > settings file > default path
 	var cfg *config.Config
 	var err error
@@ -190,6 +204,9 @@ func runCommand(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
+		if err := checkConfig(cfg, settingsPath); err != nil {
+			return err
+		}
 		absPath, _ := filepath.Abs(settingsPath)
 		cfg, err = templates.ResolveExtendsWithBaseDir(cfg, filepath.Dir(absPath))
 		if err != nil {
@@ -207,6 +224,9 @@ func runCommand(cmd *cobra.Command, args []string) error {
 			}
 			cfg = config.Default()
 		} else {
+			if err := checkConfig(cfg, configPath); err != nil {
+				return err
+			}
 			cfg, err = templates.ResolveExtendsWithBaseDir(cfg, filepath.Dir(configPath))
 			if err != nil {
 				return fmt.Errorf("failed to resolve extends: %w", err)
@@ -214,11 +234,53 @@ func runCommand(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if cfg != nil {
+		for _, w := range cfg.Warnings() {
+			fmt.Fprintf(os.Stderr, "[fence] Warning: %s\n", w)
+		}
+	}
+
+	// The shell section of the config applies unless the flags override it
+	var customShell string
+	if cfg != nil {
+		if !cmd.Flags().Changed("shell") && cfg.Shell.Mode != "" {
+			shellMode = cfg.Shell.Mode
+		}
+		if !cmd.Flags().Changed("shell-login") {
+			shellLogin = cfg.Shell.Login
+		}
+		customShell = cfg.Shell.Path
+	}
+
+	// Validate shell mode early to fail before proxy/sandbox initialization.
+	if _, _, err := sandbox.ResolveExecutionShell(shellMode, customShell, shellLogin); err != nil {
+		return fmt.Errorf("invalid shell options: %w", err)
+	}
+
+	if cfg != nil && len(disableTags) > 0 {
+		cfg.Network.RemoveDomainTags(disableTags...)
+		if debug {
+			fmt.Fprintf(os.Stderr, "[fence] Disabled domain tags: %v\n", disableTags)
+		}
+	}
+
+	// Checked against the final config and ports, which decide whether the
+	// socat bridges are needed
+	if err := sandbox.VerifyDependencies(cfg, ports); err != nil {
+		return fmt.Errorf("missing sandbox dependencies:\n%w", err)
+	}
+
 	manager := sandbox.NewManager(cfg, debug, monitor)
 	manager.SetExposedPorts(ports)
-	manager.SetShellOptions(shellMode, shellLogin)
+	manager.SetShellOptions(shellMode, customShell, shellLogin)
 	defer manager.Cleanup()
 
+	if showStats {
+		stats := sandbox.NewStatsCollector()
+		manager.SetAuditLogger(stats)
+		defer func() { printStats(os.Stderr, stats.Stats(), statsFormat) }()
+	}
+
 	if err := manager.Initialize(); err != nil {
 		return fmt.Errorf("failed to initialize sandbox: %w", err)
 	}
@@ -255,13 +317,15 @@ func runCommand(cmd *cobra.Command, args []string) error {
 	execCmd.Env = hardenedEnv
 
 	// On Linux, bubblewrap runs with --new-session. That breaks the normal TTY
-	// SIGWINCH delivery behavior for interactive TUIs unless we relay it.
+	// SIGWINCH delivery behavior for interactive TUIs unless we relay it. On
+	// macOS the relay gives the sandboxed command its own terminal, so raw
+	// mode and resizes behave the same way on both platforms.
 	//
 	// PTY relay is only enabled for interactive sessions to avoid surprising
 	// behavior changes (e.g., piping output through fence).
 	usePTY := cfg != nil &&
 		cfg.AllowPty &&
-		platform.Detect() == platform.Linux &&
+		(platform.Detect() == platform.Linux || platform.Detect() == platform.MacOS) &&
 		term.IsTerminal(int(os.Stdin.Fd())) &&
 		term.IsTerminal(int(os.Stdout.Fd()))
 
@@ -279,10 +343,113 @@ func runCommand(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Output matching command.denyOutputPatterns is redacted line by line as
+	// it is written. Debug mode shows it as-is.
+	var redactor *sandbox.OutputRedactor
+	var redactedStdout *sandbox.RedactingWriter
+	if cfg != nil && len(cfg.Command.DenyOutputPatterns) > 0 {
+		switch {
+		case debug:
+			fmt.Fprintf(os.Stderr, "[fence] Debug mode: output redaction disabled\n")
+		case usePTY:
+			fmt.Fprintf(os.Stderr, "[fence] Warning: command.denyOutputPatterns is not applied to PTY sessions\n")
+		default:
+			redactor, err = sandbox.NewOutputRedactor(cfg.Command.DenyOutputPatterns)
+			if err != nil {
+				return fmt.Errorf("invalid command.denyOutputPatterns: %w", err)
+			}
+		}
+	}
+
 	if !usePTY {
 		execCmd.Stdin = os.Stdin
 		execCmd.Stdout = os.Stdout
 		execCmd.Stderr = os.Stderr
+		if redactor != nil {
+			redactedStdout = redactor.NewWriter(os.Stdout)
+			execCmd.Stdout = redactedStdout
+		}
+	}
+
+	// In audit mode nothing is enforced: the watchers below that kill the
+	// command and the resource limits are skipped, and writes the policy
+	// would refuse are logged instead.
+	auditMode := manager.AuditMode()
+	if auditMode {
+		if platform.Detect() == platform.Linux {
+			auditWatcher, err := sandbox.NewAuditWriteWatcher(sandbox.AuditWatchRoots(cfg), debug)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[fence] Warning: filesystem writes not audited: %v\n", err)
+			} else {
+				auditWatcher.Start(manager.AuditWrite)
+				defer auditWatcher.Stop()
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "[fence] Warning: filesystem writes are only audited on Linux\n")
+		}
+	}
+
+	// Watches are added before the command starts so no open is missed.
+	var denyReadWatcher *sandbox.DenyReadWatcher
+	if !auditMode && cfg != nil && cfg.Filesystem.KernelWatchDenyRead && len(cfg.Filesystem.DenyRead) > 0 {
+		denyReadWatcher, err = sandbox.NewDenyReadWatcher(cfg.Filesystem.DenyRead, debug)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[fence] Warning: filesystem.kernelWatchDenyRead disabled: %v\n", err)
+		} else {
+			defer denyReadWatcher.Stop()
+		}
+	}
+
+	// On Linux, atomic:true allowWrite entries are enforced by watching for
+	// in-place writes; on macOS the sandbox profile refuses them.
+	var atomicWriteWatcher *sandbox.AtomicWriteWatcher
+	if !auditMode && cfg != nil && platform.Detect() == platform.Linux {
+		if paths := cfg.Filesystem.AtomicWritePaths(); len(paths) > 0 {
+			atomicWriteWatcher, err = sandbox.NewAtomicWriteWatcher(paths, debug)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[fence] Warning: atomic:true allowWrite entries not enforced: %v\n", err)
+			} else {
+				defer atomicWriteWatcher.Stop()
+			}
+		}
+	}
+
+	// Memory, CPU and (on Linux) process ceilings for the whole command need
+	// a cgroup; rlimits set inside the sandbox only cover single processes.
+	var limits config.ResourceLimits
+	if cfg != nil && !auditMode {
+		limits = cfg.ResourceLimits
+	}
+	cgroupProcesses := limits.MaxProcesses > 0 && platform.Detect() == platform.Linux
+	var resourceCgroup *sandbox.ResourceCgroup
+	if limits.MaxCPUPercent > 0 || limits.MaxMemoryMB > 0 || cgroupProcesses {
+		resourceCgroup, err = sandbox.NewResourceCgroup(limits, debug)
+		if err != nil {
+			if limits.MaxCPUPercent > 0 {
+				fmt.Fprintf(os.Stderr, "[fence] Warning: resourceLimits.maxCPUPercent not enforced: %v\n", err)
+			}
+			if cgroupProcesses {
+				fmt.Fprintf(os.Stderr, "[fence] Warning: resourceLimits.maxProcesses not enforced: %v\n", err)
+			}
+			if limits.MaxMemoryMB > 0 && platform.Detect() == platform.Linux {
+				fmt.Fprintf(os.Stderr, "[fence] Warning: resourceLimits.maxMemoryMB applies per process only: %v\n", err)
+			} else if limits.MaxMemoryMB > 0 {
+				fmt.Fprintf(os.Stderr, "[fence] Warning: resourceLimits.maxMemoryMB not enforced: %v\n", err)
+			}
+		} else {
+			defer resourceCgroup.Close()
+		}
+	}
+
+	// Give the child its own process group so the watchers and the wall-clock
+	// limit can kill everything it started (PTY sessions already get a new
+	// session).
+	needsProcessGroup := denyReadWatcher != nil || atomicWriteWatcher != nil || limits.MaxWallSeconds > 0
+	if needsProcessGroup && execCmd.SysProcAttr == nil && !usePTY {
+		execCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	}
+	if resourceCgroup != nil {
+		resourceCgroup.Apply(execCmd)
 	}
 
 	cleanup, startErr := startCommand(execCmd, usePTY)
@@ -291,6 +458,34 @@ func runCommand(cmd *cobra.Command, args []string) error {
 	}
 	defer cleanup()
 
+	// Kills by the watchers below are also recorded in the audit log, if any
+	logViolation := func(rule, detail string) {
+		if logger := manager.AuditLogger(); logger != nil {
+			logger.LogViolation(rule, detail)
+		}
+	}
+	if denyReadWatcher != nil {
+		denyReadWatcher.Start(execCmd.Process.Pid, func(path string) {
+			fmt.Fprintf(os.Stderr, "[fence:alert] Killed sandboxed command: denied path %s was opened\n", path)
+			logViolation("filesystem.denyRead", path)
+		})
+	}
+	if atomicWriteWatcher != nil {
+		atomicWriteWatcher.Start(execCmd.Process.Pid, func(path string) {
+			fmt.Fprintf(os.Stderr, "[fence:alert] Killed sandboxed command: %s is atomic:true but was written in place; write a temporary file and rename it over the path instead\n", path)
+			logViolation("filesystem.allowWrite atomic:true", path)
+		})
+	}
+	if limits.MaxWallSeconds > 0 {
+		pid := execCmd.Process.Pid
+		timer := time.AfterFunc(time.Duration(limits.MaxWallSeconds)*time.Second, func() {
+			fmt.Fprintf(os.Stderr, "[fence:alert] Killed sandboxed command: exceeded resourceLimits.maxWallSeconds (%ds)\n", limits.MaxWallSeconds)
+			logViolation("resourceLimits.maxWallSeconds", fmt.Sprintf("ran longer than %ds", limits.MaxWallSeconds))
+			_ = syscall.Kill(-pid, syscall.SIGKILL)
+		})
+		defer timer.Stop()
+	}
+
 	// Give the child process group terminal foreground control. We do this
 	// from the parent because only the current foreground process group can
 	// call tcsetpgrp. We ignore SIGTTOU so we don't get stopped when we
@@ -344,7 +539,14 @@ func runCommand(cmd *cobra.Command, args []string) error {
 	// Landlock code exists for future integration (e.g., via a wrapper binary).
 
 	// Wait for command to finish
-	if err := execCmd.Wait(); err != nil {
+	waitErr := execCmd.Wait()
+	if redactedStdout != nil {
+		_ = redactedStdout.Close()
+		if event := redactedStdout.Event(); event.Lines > 0 {
+			fmt.Fprintf(os.Stderr, "[fence:audit] Redacted %d line(s) of output matching %v\n", event.Lines, event.Patterns)
+		}
+	}
+	if err := waitErr; err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			// Set exit code but don't os.Exit() here - let deferred cleanup run
 			exitCode = exitErr.ExitCode()
@@ -356,6 +558,24 @@ func runCommand(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// printStats writes the --stats summary in text or JSON form.
+func printStats(w io.Writer, stats sandbox.Stats, format string) {
+	if format == "json" {
+		data, err := json.Marshal(stats)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, "%s\n", data)
+		return
+	}
+
+	fmt.Fprintf(w, "[fence:stats] Duration: %s\n", stats.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "[fence:stats] Network requests: %d allowed, %d blocked\n", stats.NetworkRequestsAllowed, stats.NetworkRequestsBlocked)
+	fmt.Fprintf(w, "[fence:stats] Filesystem writes: %d allowed, %d blocked\n", stats.FilesystemWritesAllowed, stats.FilesystemWritesBlocked)
+	fmt.Fprintf(w, "[fence:stats] Commands: %d allowed, %d blocked\n", stats.CommandExecsAllowed, stats.CommandExecsBlocked)
+	fmt.Fprintf(w, "[fence:stats] Violations: %d\n", stats.ViolationCount)
+}
+
 func startCommand(execCmd *exec.Cmd, usePTY bool) (func(), error) {
 	if usePTY {
 		return startCommandWithPTY(execCmd)
@@ -411,16 +631,184 @@ func startCommandWithSignalProxy(execCmd *exec.Cmd) (func(), error) {
 	}, nil
 }
 
+// checkConfig prints every config.Validate problem in the config loaded from
+// path and returns an error if any of them is not a warning.
+func checkConfig(cfg *config.Config, path string) error {
+	invalid := 0
+	for _, p := range config.Validate(cfg) {
+		if p.IsWarning() {
+			fmt.Fprintf(os.Stderr, "[fence] Warning: %s: %s [%s]\n", path, p.Error(), p.Code)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[fence] %s: %s [%s]\n", path, p.Error(), p.Code)
+		invalid++
+	}
+	if invalid > 0 {
+		return fmt.Errorf("invalid configuration in %s: %d problem(s)", path, invalid)
+	}
+	return nil
+}
+
+// printDomainTags lists the tagged entries of a domain list, one line per
+// tag, so the groups --disable-tag removes from allowedDomains are easy to
+// see. Nothing is printed when no entry is tagged.
+func printDomainTags(w io.Writer, field string, entries []string) {
+	groups := config.GroupDomainsByTag(entries)
+	delete(groups, "")
+	for _, tag := range slices.Sorted(maps.Keys(groups)) {
+		fmt.Fprintf(w, "%s tag:%s: %s\n", field, tag, strings.Join(groups[tag], ", "))
+	}
+}
+
+// newCheckCmd creates the check subcommand.
+func newCheckCmd() *cobra.Command {
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Validate a fence config without running anything",
+		Long: `Validate a fence config file and report every problem found, such as
+unknown templates in "extends" and out-of-range ports. Duplicate entries and
+paths listed in both allowWrite and denyWrite are reported as warnings.
+
+Examples:
+  fence check                      # Check the default config
+  fence check --config fence.json  # Check a specific file`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := configPath
+			if path == "" {
+				path = config.DefaultConfigPath()
+			}
+
+			cfg, err := config.Load(path)
+			if err != nil {
+				return err
+			}
+			if cfg == nil {
+				return fmt.Errorf("no config found at %s", path)
+			}
+			if err := checkConfig(cfg, path); err != nil {
+				return err
+			}
+
+			absPath, _ := filepath.Abs(path)
+			resolved, err := templates.ResolveExtendsWithBaseDir(cfg, filepath.Dir(absPath))
+			if err != nil {
+				return fmt.Errorf("failed to resolve extends: %w", err)
+			}
+			for _, w := range resolved.Warnings() {
+				fmt.Fprintf(os.Stderr, "[fence] Warning: %s\n", w)
+			}
+
+			fmt.Printf("%s: OK\n", path)
+			printDomainTags(os.Stdout, "allowedDomains", resolved.Network.AllowedDomains)
+			printDomainTags(os.Stdout, "deniedDomains", resolved.Network.DeniedDomains)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to config file (default: OS config path)")
+	return cmd
+}
+
+// newDiffCmd creates the diff subcommand.
+func newDiffCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff OLD NEW",
+		Short: "Show the semantic differences between two fence configs",
+		Long: `Compare two fence config files field by field. Added and removed list
+entries are shown individually, and changes that widen or narrow access
+(allowed domains, write and read rules, command rules) are marked [security].
+
+Examples:
+  fence diff fence.json fence.new.json
+  git show main:fence.json > /tmp/old.json && fence diff /tmp/old.json fence.json`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var cfgs [2]*config.Config
+			for i, path := range args {
+				cfg, err := config.Load(path)
+				if err != nil {
+					return err
+				}
+				if cfg == nil {
+					if _, statErr := os.Stat(path); statErr != nil {
+						return fmt.Errorf("config file not found: %s", path)
+					}
+				}
+				cfgs[i] = cfg
+			}
+
+			changes := config.Diff(cfgs[0], cfgs[1])
+			if len(changes) == 0 {
+				fmt.Println("No changes")
+				return nil
+			}
+			for _, c := range changes {
+				fmt.Println(c.String())
+			}
+			return nil
+		},
+	}
+}
+
+// newSchemaCmd creates the schema subcommand.
+func newSchemaCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema for fence config files",
+		Long: `Print the JSON Schema describing fence config files. The schema applies to
+both fence.json and fence.yaml; use --format yaml to print it as YAML.
+
+Examples:
+  fence schema > fence.schema.json
+  fence schema --format yaml`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := configschema.Generate()
+			if err != nil {
+				return fmt.Errorf("failed to generate schema: %w", err)
+			}
+
+			switch format {
+			case "json":
+				data = append(data, '\n')
+			case "yaml":
+				data, err = config.JSONToYAML(data)
+				if err != nil {
+					return fmt.Errorf("failed to convert schema to YAML: %w", err)
+				}
+			default:
+				return fmt.Errorf("unknown format %q (expected json or yaml)", format)
+			}
+
+			fmt.Print(string(data))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "json", "Output format: json or yaml")
+	return cmd
+}
+
 // newImportCmd creates the import subcommand.
 func newImportCmd() *cobra.Command {
 	var (
 		claudeMode bool
+		cursorMode bool
+		geminiMode bool
+		aiderMode  bool
+		allMode    bool
 		inputFile  string
 		outputFile string
 		saveFlag   bool
 		forceFlag  bool
 		extendTmpl string
 		noExtend   bool
+		dryRun     bool
 	)
 
 	cmd := &cobra.Command{
@@ -430,6 +818,10 @@ func newImportCmd() *cobra.Command {
 
 Currently supported sources:
   --claude    Import from Claude Code settings
+  --cursor    Import from Cursor MCP settings (allowedTools/blockedTools)
+  --gemini    Import from Gemini CLI settings
+  --aider     Import from Aider's .aider.conf.yml
+  --all       Import from every supported tool that has settings, merged
 
 By default, imports extend the "code" template which provides sensible defaults
 for network access (npm, GitHub, LLM providers) and filesystem protections.
@@ -454,10 +846,37 @@ Examples:
   fence import --claude --no-extend --save
 
   # Import and extend a different template
-  fence import --claude --extend local-dev-server --save`,
+  fence import --claude --extend local-dev-server --save
+
+  # Show the config file that --save would write, without writing it
+  fence import --claude --save --dry-run
+
+  # Import from Cursor (~/.cursor/mcp.json or $CURSOR_CONFIG_DIR/mcp.json)
+  fence import --cursor --save
+
+  # Import from the Gemini CLI (~/.gemini/settings.json)
+  fence import --gemini --save
+
+  # Import from Aider (./.aider.conf.yml, then ~/.aider.conf.yml)
+  fence import --aider --save
+
+  # Merge the settings of every supported tool found
+  fence import --all --save
+
+  # Merge the project-level settings found in a directory
+  fence import --all -f ./my-project`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if !claudeMode {
-				return fmt.Errorf("no import source specified. Use --claude to import from Claude Code")
+			sources := 0
+			for _, enabled := range []bool{claudeMode, cursorMode, geminiMode, aiderMode, allMode} {
+				if enabled {
+					sources++
+				}
+			}
+			if sources > 1 {
+				return fmt.Errorf("only one of --claude, --cursor, --gemini, --aider and --all can be used")
+			}
+			if sources == 0 {
+				return fmt.Errorf("no import source specified. Use --claude, --cursor, --gemini, --aider or --all")
 			}
 
 			opts := importer.DefaultImportOptions()
@@ -466,10 +885,36 @@ Examples:
 			} else if extendTmpl != "" {
 				opts.Extends = extendTmpl
 			}
+			opts.DryRun = dryRun
 
-			result, err := importer.ImportFromClaude(inputFile, opts)
-			if err != nil {
-				return fmt.Errorf("failed to import Claude settings: %w", err)
+			var result *importer.ImportResult
+			var err error
+			switch {
+			case cursorMode:
+				result, err = importer.ImportFromCursor(inputFile, opts)
+				if err != nil {
+					return fmt.Errorf("failed to import Cursor settings: %w", err)
+				}
+			case geminiMode:
+				result, err = importer.ImportFromGemini(inputFile, opts)
+				if err != nil {
+					return fmt.Errorf("failed to import Gemini settings: %w", err)
+				}
+			case allMode:
+				result, err = importer.BatchImport(inputFile, opts)
+				if err != nil {
+					return fmt.Errorf("failed to import settings: %w", err)
+				}
+			case aiderMode:
+				result, err = importer.ImportFromAider(inputFile, opts)
+				if err != nil {
+					return fmt.Errorf("failed to import Aider config: %w", err)
+				}
+			default:
+				result, err = importer.ImportFromClaude(inputFile, opts)
+				if err != nil {
+					return fmt.Errorf("failed to import Claude settings: %w", err)
+				}
 			}
 
 			for _, warning := range result.Warnings {
@@ -478,6 +923,12 @@ Examples:
 			if len(result.Warnings) > 0 {
 				fmt.Fprintln(os.Stderr)
 			}
+			fmt.Fprint(os.Stderr, result.Stats.Summary())
+
+			if dryRun {
+				fmt.Print(result.Preview)
+				return nil
+			}
 
 			// Determine output destination
 			var destPath string
@@ -529,11 +980,16 @@ Examples:
 	}
 
 	cmd.Flags().BoolVar(&claudeMode, "claude", false, "Import from Claude Code settings")
-	cmd.Flags().StringVarP(&inputFile, "file", "f", "", "Path to settings file (default: ~/.claude/settings.json for --claude)")
+	cmd.Flags().BoolVar(&cursorMode, "cursor", false, "Import from Cursor MCP settings")
+	cmd.Flags().BoolVar(&geminiMode, "gemini", false, "Import from Gemini CLI settings")
+	cmd.Flags().BoolVar(&aiderMode, "aider", false, "Import from Aider config")
+	cmd.Flags().BoolVar(&allMode, "all", false, "Import from all supported tools and merge the results")
+	cmd.Flags().StringVarP(&inputFile, "file", "f", "", "Path to settings file, or directory to search with --all (default: each tool's default path)")
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file path")
 	cmd.Flags().BoolVar(&saveFlag, "save", false, "Save to the default config path")
 	cmd.Flags().BoolVarP(&forceFlag, "force", "y", false, "Overwrite existing file without prompting")
 	cmd.Flags().StringVar(&extendTmpl, "extend", "", "Template to extend (default: code)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the config file that would be written instead of writing it")
 	cmd.Flags().BoolVar(&noExtend, "no-extend", false, "Don't extend any template (minimal config)")
 	cmd.MarkFlagsMutuallyExclusive("extend", "no-extend")
 	cmd.MarkFlagsMutuallyExclusive("save", "output")
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"os/exec"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Use-Tusk/fence/internal/config"
+	"github.com/Use-Tusk/fence/internal/sandbox"
 )
 
 func TestBuildInitConfig_DefaultTemplate(t *testing.T) {
@@ -185,3 +188,46 @@ func TestStartCommandWithSignalProxy_CleanupIsIdempotent(t *testing.T) {
 	cleanup()
 	cleanup()
 }
+
+func TestPrintStats(t *testing.T) {
+	stats := sandbox.Stats{NetworkRequestsAllowed: 3, NetworkRequestsBlocked: 1, ViolationCount: 2, Duration: 1500 * time.Millisecond}
+
+	var text bytes.Buffer
+	printStats(&text, stats, "text")
+	for _, want := range []string{"Duration: 1.5s", "Network requests: 3 allowed, 1 blocked", "Violations: 2"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text stats missing %q:\n%s", want, text.String())
+		}
+	}
+
+	var out bytes.Buffer
+	printStats(&out, stats, "json")
+	var decoded sandbox.Stats
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("json stats do not parse: %v\n%s", err, out.String())
+	}
+	if decoded != stats {
+		t.Errorf("json stats = %+v, want %+v", decoded, stats)
+	}
+}
+
+func TestPrintDomainTags(t *testing.T) {
+	var out bytes.Buffer
+	printDomainTags(&out, "allowedDomains", []string{
+		"tag:openai api.openai.com",
+		"github.com",
+		"tag:anthropic api.anthropic.com",
+		"tag:openai *.openai.com",
+	})
+	want := "allowedDomains tag:anthropic: api.anthropic.com\n" +
+		"allowedDomains tag:openai: api.openai.com, *.openai.com\n"
+	if out.String() != want {
+		t.Errorf("printDomainTags() = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	printDomainTags(&out, "allowedDomains", []string{"github.com"})
+	if out.Len() != 0 {
+		t.Errorf("printDomainTags() without tags = %q, want no output", out.String())
+	}
+}
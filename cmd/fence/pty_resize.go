@@ -0,0 +1,38 @@
+package main
+
+import "time"
+
+// resizeDebouncer coalesces bursts of SIGWINCH into a single resize once the
+// terminal has been still for delay. It reuses one timer for its lifetime.
+type resizeDebouncer struct {
+	timer *time.Timer
+	ch    <-chan time.Time
+	delay time.Duration
+}
+
+func newResizeDebouncer(delay time.Duration) *resizeDebouncer {
+	return &resizeDebouncer{delay: delay}
+}
+
+func (d *resizeDebouncer) Queue() {
+	if d.timer == nil {
+		d.timer = time.NewTimer(d.delay)
+	} else {
+		d.timer.Reset(d.delay)
+	}
+	d.ch = d.timer.C
+}
+
+func (d *resizeDebouncer) Channel() <-chan time.Time {
+	return d.ch
+}
+
+func (d *resizeDebouncer) MarkHandled() {
+	d.ch = nil
+}
+
+func (d *resizeDebouncer) Stop() {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResizeDebouncer_CoalescesSignals(t *testing.T) {
+	debouncer := newResizeDebouncer(10 * time.Millisecond)
+	defer debouncer.Stop()
+
+	debouncer.Queue()
+	firstCh := debouncer.Channel()
+	if firstCh == nil {
+		t.Fatal("expected debounce channel after first queue")
+	}
+
+	debouncer.Queue()
+	if debouncer.Channel() != firstCh {
+		t.Fatal("expected second queue to reuse pending debounce channel")
+	}
+
+	select {
+	case <-firstCh:
+		debouncer.MarkHandled()
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("timed out waiting for debounced signal")
+	}
+
+	if debouncer.Channel() != nil {
+		t.Fatal("expected debounce channel to reset after mark handled")
+	}
+}
+
+func TestResizeDebouncer_StopBeforeQueue(t *testing.T) {
+	debouncer := newResizeDebouncer(10 * time.Millisecond)
+	debouncer.Stop()
+
+	if debouncer.Channel() != nil {
+		t.Fatal("expected no debounce channel before any queue")
+	}
+
+	// A stopped debouncer must still accept new resizes.
+	debouncer.Queue()
+	defer debouncer.Stop()
+	select {
+	case <-debouncer.Channel():
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("timed out waiting for debounced signal after stop")
+	}
+}
+
+func TestResizeDebouncer_MultipleQueues(t *testing.T) {
+	debouncer := newResizeDebouncer(10 * time.Millisecond)
+	defer debouncer.Stop()
+
+	debouncer.Queue()
+	timer := debouncer.timer
+	for i := 0; i < 100; i++ {
+		debouncer.Queue()
+	}
+	if debouncer.timer != timer {
+		t.Fatal("expected repeated queues to reuse the pending timer")
+	}
+
+	select {
+	case <-debouncer.Channel():
+		debouncer.MarkHandled()
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("timed out waiting for debounced signal")
+	}
+
+	// Only one signal should be delivered for the whole burst.
+	select {
+	case <-timer.C:
+		t.Fatal("expected a single debounced signal for repeated queues")
+	case <-time.After(30 * time.Millisecond):
+	}
+}
@@ -0,0 +1,29 @@
+//go:build darwin
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// processChildrenMap returns the children of each process, and the parent
+// of each, from the kern.proc.all sysctl (macOS has no /proc).
+func processChildrenMap() (map[int][]int, map[int]int) {
+	children := make(map[int][]int)
+	parentPID := make(map[int]int)
+
+	procs, err := unix.SysctlKinfoProcSlice("kern.proc.all")
+	if err != nil {
+		return children, parentPID
+	}
+
+	for _, p := range procs {
+		pid := int(p.Proc.P_pid)
+		ppid := int(p.Eproc.Ppid)
+		if pid <= 0 || ppid <= 0 {
+			continue
+		}
+		parentPID[pid] = ppid
+		children[ppid] = append(children[ppid], pid)
+	}
+
+	return children, parentPID
+}
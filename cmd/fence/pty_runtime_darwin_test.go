@@ -0,0 +1,21 @@
+//go:build darwin
+
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestProcessChildrenMap_IncludesSelf(t *testing.T) {
+	children, parentPID := processChildrenMap()
+
+	pid, ppid := os.Getpid(), os.Getppid()
+	if got := parentPID[pid]; got != ppid {
+		t.Errorf("parentPID[%d] = %d, want %d", pid, got, ppid)
+	}
+	if !slices.Contains(children[ppid], pid) {
+		t.Errorf("children[%d] = %v, want it to contain %d", ppid, children[ppid], pid)
+	}
+}
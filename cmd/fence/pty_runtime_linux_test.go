@@ -3,33 +3,170 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
-	"time"
 )
 
-func TestResizeDebouncer_CoalescesSignals(t *testing.T) {
-	debouncer := newResizeDebouncer(10 * time.Millisecond)
-	defer debouncer.Stop()
+// writeFakeProcStatus creates <procDir>/<pid>/status with the given content.
+func writeFakeProcStatus(t *testing.T, procDir string, pid int, status string) {
+	t.Helper()
+	dir := filepath.Join(procDir, fmt.Sprint(pid))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "status"), []byte(status), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
 
-	debouncer.Queue()
-	firstCh := debouncer.Channel()
-	if firstCh == nil {
-		t.Fatal("expected debounce channel after first queue")
+func TestBuildProcChildrenMap(t *testing.T) {
+	procDir := t.TempDir()
+
+	// 1 (init) -> 100 -> 101, 102; 101 -> 103; 2 (kthreadd) has PPid 0.
+	writeFakeProcStatus(t, procDir, 1, "Name:\tsystemd\nPid:\t1\nPPid:\t0\n")
+	writeFakeProcStatus(t, procDir, 2, "Name:\tkthreadd\nPid:\t2\nPPid:\t0\n")
+	writeFakeProcStatus(t, procDir, 100, "Name:\tbash\nPid:\t100\nPPid:\t1\n")
+	writeFakeProcStatus(t, procDir, 101, "Name:\tvim\nPid:\t101\nPPid:\t100\n")
+	writeFakeProcStatus(t, procDir, 102, "Name:\tless\nPid:\t102\nPPid:\t100\n")
+	writeFakeProcStatus(t, procDir, 103, "Name:\tsh\nPid:\t103\nPPid:\t101\n")
+
+	// Entries that must be ignored.
+	writeFakeProcStatus(t, procDir, 200, "Name:\tbroken\nPid:\t200\n")
+	if err := os.MkdirAll(filepath.Join(procDir, "300"), 0o755); err != nil { // no status file
+		t.Fatal(err)
+	}
+	for _, name := range []string{"self", "sys", "net"} {
+		if err := os.MkdirAll(filepath.Join(procDir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
 	}
 
-	debouncer.Queue()
-	if debouncer.Channel() != firstCh {
-		t.Fatal("expected second queue to reuse pending debounce channel")
+	children, parentPID := buildProcChildrenMap(procDir)
+
+	wantParents := map[int]int{100: 1, 101: 100, 102: 100, 103: 101}
+	if len(parentPID) != len(wantParents) {
+		t.Errorf("parentPID = %v, want %v", parentPID, wantParents)
+	}
+	for pid, want := range wantParents {
+		if got := parentPID[pid]; got != want {
+			t.Errorf("parentPID[%d] = %d, want %d", pid, got, want)
+		}
+	}
+
+	wantChildren := map[int][]int{1: {100}, 100: {101, 102}, 101: {103}}
+	if len(children) != len(wantChildren) {
+		t.Errorf("children = %v, want %v", children, wantChildren)
+	}
+	for pid, want := range wantChildren {
+		got := slices.Clone(children[pid])
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("children[%d] = %v, want %v", pid, got, want)
+		}
 	}
+}
+
+func TestBuildProcChildrenMap_MissingDir(t *testing.T) {
+	children, parentPID := buildProcChildrenMap(filepath.Join(t.TempDir(), "missing"))
+	if len(children) != 0 || len(parentPID) != 0 {
+		t.Errorf("expected empty maps for missing proc dir, got %v, %v", children, parentPID)
+	}
+}
+
+func TestIsDescendantOfRoot(t *testing.T) {
+	// Chain: 10 -> 20 -> 30 -> 40 -> 50, plus an unrelated 60 -> 1 and a
+	// self-parented 70 (as seen with pid reuse races).
+	parentPID := map[int]int{20: 10, 30: 20, 40: 30, 50: 40, 60: 1, 70: 70}
+
+	tests := []struct {
+		name string
+		pid  int
+		root int
+		want bool
+	}{
+		{"direct child", 20, 10, true},
+		{"grandchild", 30, 10, true},
+		{"deepest descendant", 50, 10, true},
+		{"descendant of mid-chain root", 50, 30, true},
+		{"root is not its own descendant", 10, 10, false},
+		{"ancestor is not a descendant", 20, 30, false},
+		{"unrelated process", 60, 10, false},
+		{"self-parented process", 70, 10, false},
+		{"unknown pid", 999, 10, false},
+		{"invalid pid", 0, 10, false},
+		{"invalid root", 50, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDescendantOfRoot(tt.pid, tt.root, parentPID); got != tt.want {
+				t.Errorf("isDescendantOfRoot(%d, %d) = %v, want %v", tt.pid, tt.root, got, tt.want)
+			}
+		})
+	}
+}
 
-	select {
-	case <-firstCh:
-		debouncer.MarkHandled()
-	case <-time.After(500 * time.Millisecond):
-		t.Fatal("timed out waiting for debounced signal")
+func TestParsePPIDFromStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		wantPPID int
+		wantOK   bool
+	}{
+		{
+			name: "user process",
+			status: "Name:\tbash\nUmask:\t0022\nState:\tS (sleeping)\nTgid:\t4242\n" +
+				"Ngid:\t0\nPid:\t4242\nPPid:\t4100\nTracerPid:\t0\nUid:\t1000\t1000\t1000\t1000\n",
+			wantPPID: 4100,
+			wantOK:   true,
+		},
+		{
+			name:     "kernel thread",
+			status:   "Name:\tkworker/0:1-events\nUmask:\t0000\nState:\tI (idle)\nTgid:\t15\nPid:\t15\nPPid:\t2\nTracerPid:\t0\n",
+			wantPPID: 2,
+			wantOK:   true,
+		},
+		{
+			name:     "kthreadd",
+			status:   "Name:\tkthreadd\nState:\tS (sleeping)\nPid:\t2\nPPid:\t0\n",
+			wantPPID: 0,
+			wantOK:   true,
+		},
+		{
+			name:     "TracerPid does not match",
+			status:   "Name:\tx\nTracerPid:\t77\n",
+			wantPPID: 0,
+			wantOK:   false,
+		},
+		{
+			name:     "empty PPid value",
+			status:   "Name:\tx\nPPid:\n",
+			wantPPID: 0,
+			wantOK:   false,
+		},
+		{
+			name:     "non-numeric PPid",
+			status:   "PPid:\tabc\n",
+			wantPPID: 0,
+			wantOK:   false,
+		},
+		{
+			name:     "empty status",
+			status:   "",
+			wantPPID: 0,
+			wantOK:   false,
+		},
 	}
 
-	if debouncer.Channel() != nil {
-		t.Fatal("expected debounce channel to reset after mark handled")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ppid, ok := parsePPIDFromStatus(tt.status)
+			if ppid != tt.wantPPID || ok != tt.wantOK {
+				t.Errorf("parsePPIDFromStatus() = (%d, %v), want (%d, %v)", ppid, ok, tt.wantPPID, tt.wantOK)
+			}
+		})
 	}
 }
@@ -1,4 +1,4 @@
-//go:build !linux
+//go:build !linux && !darwin
 
 package main
 
@@ -8,5 +8,5 @@ import (
 )
 
 func startCommandWithPTY(_ *exec.Cmd) (func(), error) {
-	return nil, fmt.Errorf("PTY relay is only supported on Linux")
+	return nil, fmt.Errorf("PTY relay is only supported on Linux and macOS")
 }
@@ -0,0 +1,160 @@
+// Generated by `go run ./tools/generate-config-types`; do not edit by hand.
+
+export interface Config {
+  /** URL of the JSON schema, for editor validation and completion */
+  $schema?: string;
+  /** Template name or path of a config file to inherit rules from */
+  extends?: string;
+  /** Network restrictions, enforced by the HTTP and SOCKS proxies */
+  network?: NetworkConfig;
+  /** Filesystem read, write and execute restrictions */
+  filesystem?: FilesystemConfig;
+  /** Command allow and deny rules */
+  command?: CommandConfig;
+  /** Restrictions on commands run over SSH */
+  ssh?: SSHConfig;
+  /** Shell that runs the command; the --shell and --shell-login flags take precedence */
+  shell?: ShellConfig;
+  /** Run the command in a pseudo-terminal so interactive terminal apps work */
+  allowPty?: boolean;
+  /** Allow GPU and Neural Engine access through Metal, OpenCL and CoreML on macOS; Linux already exposes /dev */
+  allowGpu?: boolean;
+  /** Allow reading and writing macOS Keychain items, e.g. for git credential-osxkeychain; off by default */
+  allowKeychain?: boolean;
+  /** Memory, CPU, process, file and run-time limits for the sandboxed command */
+  resourceLimits?: ResourceLimits;
+  /** enforce (the default) applies the policy; audit runs the command unsandboxed and logs what would have been blocked */
+  enforcementMode?: string;
+  /** File that network, command and filesystem decisions are appended to as JSON lines; in audit mode, stderr when unset */
+  auditLogPath?: string;
+  /** http or https URL that each policy violation is POSTed to as JSON */
+  webhookUrl?: string;
+  /** Extra macOS sandbox profile rules; (allow ...) rules are added before fence's own deny rules and (deny ...) rules after everything else */
+  sandboxProfileExtra?: string;
+}
+
+export interface NetworkConfig {
+  /** Domains the sandbox may connect to. Supports *.example.com, "*" for all, IP addresses, CIDR blocks and tag:/proto: prefixes */
+  allowedDomains?: string[];
+  /** Domains that are always blocked, checked before allowedDomains */
+  deniedDomains?: string[];
+  /** Unix socket paths the sandbox may connect to */
+  allowUnixSockets?: string[];
+  /** Allow connecting to any Unix socket */
+  allowAllUnixSockets?: boolean;
+  /** Allow listening on localhost ports */
+  allowLocalBinding?: boolean;
+  /** Allow connections to localhost; defaults to allowLocalBinding when unset */
+  allowLocalOutbound?: boolean | null;
+  /** Fixed port for the HTTP proxy; 0 picks a free port */
+  httpProxyPort?: number;
+  /** Fixed port for the SOCKS5 proxy; 0 picks a free port */
+  socksProxyPort?: number;
+  /** Resolve allowed domains through /etc/hosts; defaults to true, false uses DNS only */
+  honorHostsFile?: boolean | null;
+  /** Longest time in seconds the proxies cache a resolved address; shorter DNS TTLs are respected; 0 uses 300 */
+  dnsCacheTTL?: number;
+  /** Report the first allowed connection to each domain */
+  alertOnNewDomain?: boolean;
+  /** Also allow the ACME certificate authority endpoints */
+  allowACMEChallenge?: boolean;
+  /** Terminate and inspect HTTPS traffic in the HTTP proxy */
+  inspectTLS?: boolean;
+  /** PEM CA certificate used to sign intercepted connections */
+  inspectTLSCACert?: string;
+  /** PEM private key for inspectTLSCACert */
+  inspectTLSCAKey?: string;
+  /** Warn when allowed mail servers lack an SPF record */
+  validateSPF?: boolean;
+  /** When set, only these destination ports may be used */
+  allowedPorts?: number[];
+  /** Destination ports that are refused even for allowed domains */
+  deniedPorts?: number[];
+}
+
+export interface FilesystemConfig {
+  /** Deny reads everywhere except system paths and allowRead */
+  defaultDenyRead?: boolean;
+  /** Allow running Windows executables under WSL; auto-detected when unset */
+  wslInterop?: boolean | null;
+  /** Paths that may be read when defaultDenyRead is set */
+  allowRead?: string[];
+  /** Paths that may be executed but not listed when defaultDenyRead is set */
+  allowExecute?: string[];
+  /** Executables (names or paths) blocked at exec time, even inside writable paths */
+  denyExecute?: string[];
+  /** Paths that may not be read */
+  denyRead?: string[];
+  /** Paths that may be written. Supports os:<goos>:<path> entries and an atomic:true suffix */
+  allowWrite?: string[];
+  /** Paths that may not be written, even inside allowWrite */
+  denyWrite?: string[];
+  /** Allow writing .git/config files in the project and its subdirectories; they stay read-only by default */
+  allowGitConfig?: boolean;
+  /** Names of normally write-protected files to leave writable in the project */
+  trustedDangerousFiles?: string[];
+  /** Names of normally write-protected directories to leave writable in the project */
+  trustedDangerousDirectories?: string[];
+  /** Skip paths listed in the project's .fenceignore when protecting dangerous files in subdirectories (Linux); only enable it for projects you trust */
+  useFenceIgnore?: boolean;
+  /** Watch denyRead paths with inotify and kill the command if one is opened (Linux) */
+  kernelWatchDenyRead?: boolean;
+  /** Make executables blocked by command.deny read-only; defaults to true */
+  protectDeniedExecutables?: boolean | null;
+  /** Absolute path of an empty tmpfs mounted in the sandbox and used as TMPDIR (Linux) */
+  scratchDir?: string;
+  /** Size limit of the scratch tmpfs in megabytes; unlimited when unset */
+  scratchDirSizeMB?: number;
+}
+
+export interface CommandConfig {
+  /** Command prefixes that are blocked */
+  deny?: string[];
+  /** Command prefixes allowed even if they match deny or a default rule */
+  allow?: string[];
+  /** Apply the built-in list of denied commands; defaults to true */
+  useDefaults?: boolean | null;
+  /** Report denied commands without blocking them */
+  shadowMode?: boolean;
+  /** Block executables whose SHA-256 changed since first use */
+  verifyBinaryIntegrity?: boolean;
+  /** Regular expressions; matching stdout lines are replaced with [REDACTED] */
+  denyOutputPatterns?: string[];
+}
+
+export interface SSHConfig {
+  /** Hosts that may be reached over SSH; supports *.example.com */
+  allowedHosts?: string[];
+  /** Hosts that may not be reached over SSH, checked before allowedHosts */
+  deniedHosts?: string[];
+  /** Commands allowed over SSH in allowlist mode */
+  allowedCommands?: string[];
+  /** Commands denied over SSH, checked before allowedCommands */
+  deniedCommands?: string[];
+  /** Use denylist mode: allow every SSH command not in deniedCommands */
+  allowAllCommands?: boolean;
+  /** Also apply command.deny to SSH commands */
+  inheritDeny?: boolean;
+}
+
+export interface ShellConfig {
+  /** default runs bash from PATH, user runs $SHELL, custom runs path */
+  mode?: string;
+  /** Absolute path of the shell for custom mode, such as a bash outside PATH */
+  path?: string;
+  /** Run the shell as a login shell (-lc) */
+  login?: boolean;
+}
+
+export interface ResourceLimits {
+  /** Data segment size per process in MB (Linux), and the cgroup memory limit where available; 0 is unlimited */
+  maxMemoryMB?: number;
+  /** CPU share in percent of one core; requires cgroup v2 on Linux; 0 is unlimited */
+  maxCPUPercent?: number;
+  /** Maximum number of processes: cgroup v2 pids.max on Linux, RLIMIT_NPROC on macOS, where every process of the user counts; 0 is unlimited */
+  maxProcesses?: number;
+  /** Maximum open file descriptors per process (RLIMIT_NOFILE); 0 is unlimited */
+  maxOpenFiles?: number;
+  /** Wall-clock run time in seconds, after which the command is killed; 0 is unlimited */
+  maxWallSeconds?: number;
+}
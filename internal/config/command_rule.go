@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CommandRule is a parsed command.allow or command.deny entry.
+// Rules are a command prefix optionally preceded by a working directory and
+// followed by constraints, e.g. "python3 maxArgs:2", "npm run build priority:10"
+// or "workdir:/workspace npm publish". A rule may end with "reason:<text>",
+// where the text runs to the end of the rule and is shown when it blocks.
+type CommandRule struct {
+	Raw      string // Original rule text
+	Prefix   string // Command prefix used for matching
+	Workdir  string // Directory the command must be run from (or under), empty if any
+	MaxArgs  int    // Maximum number of arguments, or -1 if unconstrained
+	Priority int    // Evaluation priority; higher is checked first (default 0)
+	Reason   string // Explanation shown when the rule blocks a command, empty if none
+	When     string // Condition that must hold for the rule to apply, e.g. "git-tag-matches:v*"
+}
+
+// GitTagCondition returns the tag glob of a "git-tag-matches:" condition.
+func (r CommandRule) GitTagCondition() (string, bool) {
+	if !strings.HasPrefix(r.When, gitTagMatchesCondition) {
+		return "", false
+	}
+	return r.When[len(gitTagMatchesCondition):], true
+}
+
+// WithoutReason returns the rule as written, minus any reason suffix.
+func (r CommandRule) WithoutReason() string {
+	if loc := reasonPattern.FindStringIndex(r.Raw); loc != nil {
+		return strings.TrimSpace(r.Raw[:loc[0]])
+	}
+	return r.Raw
+}
+
+// HasMaxArgs reports whether the rule limits the argument count.
+func (r CommandRule) HasMaxArgs() bool {
+	return r.MaxArgs >= 0
+}
+
+const (
+	maxArgsKey  = "maxArgs:"
+	priorityKey = "priority:"
+	workdirKey  = "workdir:"
+	reasonKey   = "reason:"
+	whenKey     = "when:"
+)
+
+// gitTagMatchesCondition is a when: condition that holds when HEAD has an
+// exact tag matching a glob.
+const gitTagMatchesCondition = "git-tag-matches:"
+
+// reasonPattern finds the reason suffix; it must start a token after the command.
+var reasonPattern = regexp.MustCompile(`\s` + reasonKey)
+
+// ParseCommandRule splits a command rule into its prefix and constraints.
+// A workdir constraint is recognized only as the leading token and other
+// constraints only as trailing tokens, before any reason; anything else is
+// part of the prefix.
+func ParseCommandRule(rule string) (CommandRule, error) {
+	parsed := CommandRule{Raw: rule, MaxArgs: -1}
+
+	if loc := reasonPattern.FindStringIndex(rule); loc != nil {
+		parsed.Reason = strings.TrimSpace(rule[loc[1]:])
+		if parsed.Reason == "" {
+			return parsed, fmt.Errorf("invalid %s constraint: text is empty", strings.TrimSuffix(reasonKey, ":"))
+		}
+		rule = strings.TrimSpace(rule[:loc[0]])
+	}
+
+	tokens := strings.Fields(rule)
+	start := 0
+	if len(tokens) > 1 && strings.HasPrefix(tokens[0], workdirKey) {
+		parsed.Workdir = tokens[0][len(workdirKey):]
+		if parsed.Workdir == "" {
+			return parsed, fmt.Errorf("invalid %s constraint %q: path is empty", strings.TrimSuffix(workdirKey, ":"), tokens[0])
+		}
+		start = 1
+	}
+
+	end := len(tokens)
+loop:
+	for end > start+1 {
+		token := tokens[end-1]
+		switch {
+		case strings.HasPrefix(token, maxArgsKey):
+			n, err := strconv.Atoi(token[len(maxArgsKey):])
+			if err != nil || n < 0 {
+				return parsed, fmt.Errorf("invalid %s constraint %q: must be a non-negative integer", strings.TrimSuffix(maxArgsKey, ":"), token)
+			}
+			parsed.MaxArgs = n
+		case strings.HasPrefix(token, priorityKey):
+			n, err := strconv.Atoi(token[len(priorityKey):])
+			if err != nil {
+				return parsed, fmt.Errorf("invalid %s %q: must be an integer", strings.TrimSuffix(priorityKey, ":"), token)
+			}
+			parsed.Priority = n
+		case strings.HasPrefix(token, whenKey):
+			cond := token[len(whenKey):]
+			if err := validateRuleCondition(cond); err != nil {
+				return parsed, fmt.Errorf("invalid %s constraint %q: %w", strings.TrimSuffix(whenKey, ":"), token, err)
+			}
+			parsed.When = cond
+		default:
+			break loop
+		}
+		end--
+	}
+
+	if start == 0 && end == len(tokens) {
+		parsed.Prefix = rule
+	} else {
+		parsed.Prefix = strings.Join(tokens[start:end], " ")
+	}
+	return parsed, nil
+}
+
+// validateRuleCondition checks a when: condition.
+func validateRuleCondition(cond string) error {
+	if !strings.HasPrefix(cond, gitTagMatchesCondition) {
+		return fmt.Errorf("unknown condition (supported: %s<pattern>)", gitTagMatchesCondition)
+	}
+	pattern := cond[len(gitTagMatchesCondition):]
+	if pattern == "" {
+		return fmt.Errorf("%s pattern is empty", strings.TrimSuffix(gitTagMatchesCondition, ":"))
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid tag pattern %q: %w", pattern, err)
+	}
+	return nil
+}
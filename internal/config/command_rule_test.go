@@ -0,0 +1,163 @@
+package config
+
+import "testing"
+
+func TestParseCommandRule(t *testing.T) {
+	tests := []struct {
+		rule        string
+		wantPrefix  string
+		wantMaxArgs int
+		wantErr     bool
+	}{
+		{"git push", "git push", -1, false},
+		{"python3 maxArgs:2", "python3", 2, false},
+		{"npm run   maxArgs:0", "npm run", 0, false},
+		{`git commit -m "a  b"`, `git commit -m "a  b"`, -1, false},
+		{"maxArgs:2", "maxArgs:2", -1, false}, // a lone token is the command itself
+		{"python3 maxArgs:-1", "", -1, true},
+		{"python3 maxArgs:two", "", -1, true},
+		{"npm run build priority:10", "npm run build", -1, false},
+		{"python3 maxArgs:1 priority:-2", "python3", 1, false},
+		{"npm priority:high", "", -1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := ParseCommandRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCommandRule(%q) error = %v, wantErr %v", tt.rule, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Prefix != tt.wantPrefix {
+				t.Errorf("Prefix = %q, want %q", got.Prefix, tt.wantPrefix)
+			}
+			if got.MaxArgs != tt.wantMaxArgs {
+				t.Errorf("MaxArgs = %d, want %d", got.MaxArgs, tt.wantMaxArgs)
+			}
+		})
+	}
+}
+
+func TestParseCommandRulePriority(t *testing.T) {
+	tests := []struct {
+		rule string
+		want int
+	}{
+		{"npm run build", 0},
+		{"npm run build priority:10", 10},
+		{"npm run* priority:5", 5},
+		{"rm priority:-1", -1},
+		{"python3 priority:3 maxArgs:1", 3},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCommandRule(tt.rule)
+		if err != nil {
+			t.Fatalf("ParseCommandRule(%q) error = %v", tt.rule, err)
+		}
+		if got.Priority != tt.want {
+			t.Errorf("ParseCommandRule(%q).Priority = %d, want %d", tt.rule, got.Priority, tt.want)
+		}
+	}
+}
+
+func TestParseCommandRuleWorkdir(t *testing.T) {
+	tests := []struct {
+		rule        string
+		wantPrefix  string
+		wantWorkdir string
+		wantErr     bool
+	}{
+		{"workdir:/workspace npm publish", "npm publish", "/workspace", false},
+		{"workdir:~/code npm publish priority:2", "npm publish", "~/code", false},
+		{"workdir:/workspace python3 maxArgs:1", "python3", "/workspace", false},
+		{"npm publish", "npm publish", "", false},
+		{"workdir:/workspace", "workdir:/workspace", "", false}, // a lone token is the command itself
+		{"workdir: npm publish", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := ParseCommandRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCommandRule(%q) error = %v, wantErr %v", tt.rule, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Prefix != tt.wantPrefix || got.Workdir != tt.wantWorkdir {
+				t.Errorf("ParseCommandRule(%q) = {Prefix:%q Workdir:%q}, want {Prefix:%q Workdir:%q}",
+					tt.rule, got.Prefix, got.Workdir, tt.wantPrefix, tt.wantWorkdir)
+			}
+		})
+	}
+}
+
+func TestParseCommandRuleReason(t *testing.T) {
+	tests := []struct {
+		rule           string
+		wantPrefix     string
+		wantReason     string
+		wantPriority   int
+		wantNoReasonIs string
+		wantErr        bool
+	}{
+		{"curl reason:Use fetch() instead of curl for HTTP requests", "curl", "Use fetch() instead of curl for HTTP requests", 0, "curl", false},
+		{"git push  priority:3 reason:Open a PR instead", "git push", "Open a PR instead", 3, "git push  priority:3", false},
+		{"curl", "curl", "", 0, "curl", false},
+		{"reason:only", "reason:only", "", 0, "reason:only", false}, // a lone token is the command itself
+		{"curl reason:", "", "", 0, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := ParseCommandRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCommandRule(%q) error = %v, wantErr %v", tt.rule, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Prefix != tt.wantPrefix || got.Reason != tt.wantReason || got.Priority != tt.wantPriority {
+				t.Errorf("ParseCommandRule(%q) = {Prefix:%q Reason:%q Priority:%d}, want {Prefix:%q Reason:%q Priority:%d}",
+					tt.rule, got.Prefix, got.Reason, got.Priority, tt.wantPrefix, tt.wantReason, tt.wantPriority)
+			}
+			if w := got.WithoutReason(); w != tt.wantNoReasonIs {
+				t.Errorf("WithoutReason() = %q, want %q", w, tt.wantNoReasonIs)
+			}
+		})
+	}
+}
+
+func TestParseCommandRuleWhen(t *testing.T) {
+	tests := []struct {
+		rule       string
+		wantPrefix string
+		wantWhen   string
+		wantErr    bool
+	}{
+		{"npm publish when:git-tag-matches:v[0-9]*", "npm publish", "git-tag-matches:v[0-9]*", false},
+		{"npm publish priority:2 when:git-tag-matches:release-*", "npm publish", "git-tag-matches:release-*", false},
+		{"npm publish when:branch-is:main", "", "", true},
+		{"npm publish when:git-tag-matches:", "", "", true},
+		{"npm publish when:git-tag-matches:v[", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := ParseCommandRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCommandRule(%q) error = %v, wantErr %v", tt.rule, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Prefix != tt.wantPrefix || got.When != tt.wantWhen {
+				t.Errorf("ParseCommandRule(%q) = {Prefix:%q When:%q}, want {Prefix:%q When:%q}",
+					tt.rule, got.Prefix, got.When, tt.wantPrefix, tt.wantWhen)
+			}
+		})
+	}
+}
@@ -2,67 +2,166 @@
 package config
 
 import (
-	"encoding/json"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"slices"
 	"strings"
-
-	"github.com/tidwall/jsonc"
 )
 
 // Config is the main configuration for fence.
 type Config struct {
-	Extends    string           `json:"extends,omitempty"`
-	Network    NetworkConfig    `json:"network"`
-	Filesystem FilesystemConfig `json:"filesystem"`
-	Command    CommandConfig    `json:"command"`
-	SSH        SSHConfig        `json:"ssh"`
-	AllowPty   bool             `json:"allowPty,omitempty"`
+	Extends       string           `json:"extends,omitempty" fence:"description=Template name or path of a config file to inherit rules from;example=code;example=./base.json"`
+	Network       NetworkConfig    `json:"network" fence:"description=Network restrictions, enforced by the HTTP and SOCKS proxies"`
+	Filesystem    FilesystemConfig `json:"filesystem" fence:"description=Filesystem read, write and execute restrictions"`
+	Command       CommandConfig    `json:"command" fence:"description=Command allow and deny rules"`
+	SSH           SSHConfig        `json:"ssh" fence:"description=Restrictions on commands run over SSH"`
+	Shell         ShellConfig      `json:"shell" fence:"description=Shell that runs the command; the --shell and --shell-login flags take precedence"`
+	AllowPty      bool             `json:"allowPty,omitempty" fence:"description=Run the command in a pseudo-terminal so interactive terminal apps work"`
+	AllowGPU      bool             `json:"allowGpu,omitempty" fence:"description=Allow GPU and Neural Engine access through Metal, OpenCL and CoreML on macOS; Linux already exposes /dev"`
+	AllowKeychain bool             `json:"allowKeychain,omitempty" fence:"description=Allow reading and writing macOS Keychain items, e.g. for git credential-osxkeychain; off by default"`
+
+	ResourceLimits ResourceLimits `json:"resourceLimits" fence:"description=Memory, CPU, process, file and run-time limits for the sandboxed command"`
+
+	// EnforcementMode "audit" runs the command without the sandbox and logs
+	// what the policy would have blocked instead. Empty means "enforce".
+	// AuditLogPath enables the JSON-lines audit log in either mode.
+	EnforcementMode string `json:"enforcementMode,omitempty" fence:"description=enforce (the default) applies the policy; audit runs the command unsandboxed and logs what would have been blocked;example=audit"`
+	AuditLogPath    string `json:"auditLogPath,omitempty" fence:"description=File that network, command and filesystem decisions are appended to as JSON lines; in audit mode, stderr when unset;example=./fence-audit.jsonl"`
+
+	// WebhookURL receives a JSON POST for each policy violation.
+	WebhookURL string `json:"webhookUrl,omitempty" fence:"description=http or https URL that each policy violation is POSTed to as JSON;example=https://hooks.example.com/fence"`
+
+	// SandboxProfileExtra holds extra Seatbelt (allow ...) and (deny ...)
+	// rules for the macOS sandbox profile. It is ignored on Linux.
+	SandboxProfileExtra string `json:"sandboxProfileExtra,omitempty" fence:"description=Extra macOS sandbox profile rules; (allow ...) rules are added before fence's own deny rules and (deny ...) rules after everything else;example=(allow mach-lookup (global-name \"com.apple.pasteboard.1\"))"`
+}
+
+// Enforcement modes for Config.EnforcementMode.
+const (
+	EnforcementModeEnforce = "enforce"
+	EnforcementModeAudit   = "audit"
+)
+
+// AuditMode returns whether the policy is logged rather than enforced.
+func (c *Config) AuditMode() bool {
+	return c != nil && c.EnforcementMode == EnforcementModeAudit
 }
 
 // NetworkConfig defines network restrictions.
 type NetworkConfig struct {
-	AllowedDomains      []string `json:"allowedDomains"`
-	DeniedDomains       []string `json:"deniedDomains"`
-	AllowUnixSockets    []string `json:"allowUnixSockets,omitempty"`
-	AllowAllUnixSockets bool     `json:"allowAllUnixSockets,omitempty"`
-	AllowLocalBinding   bool     `json:"allowLocalBinding,omitempty"`
-	AllowLocalOutbound  *bool    `json:"allowLocalOutbound,omitempty"` // If nil, defaults to AllowLocalBinding value
-	HTTPProxyPort       int      `json:"httpProxyPort,omitempty"`
-	SOCKSProxyPort      int      `json:"socksProxyPort,omitempty"`
+	AllowedDomains      []string `json:"allowedDomains" fence:"description=Domains the sandbox may connect to. Supports *.example.com, \"*\" for all, IP addresses, CIDR blocks and tag:/proto: prefixes;example=github.com;example=*.npmjs.org;example=10.0.0.0/8"`
+	DeniedDomains       []string `json:"deniedDomains" fence:"description=Domains that are always blocked, checked before allowedDomains;example=*.evil.example;example=169.254.169.254"`
+	AllowUnixSockets    []string `json:"allowUnixSockets,omitempty" fence:"description=Unix socket paths the sandbox may connect to;example=/var/run/docker.sock"`
+	AllowAllUnixSockets bool     `json:"allowAllUnixSockets,omitempty" fence:"description=Allow connecting to any Unix socket"`
+	AllowLocalBinding   bool     `json:"allowLocalBinding,omitempty" fence:"description=Allow listening on localhost ports"`
+	AllowLocalOutbound  *bool    `json:"allowLocalOutbound,omitempty" fence:"description=Allow connections to localhost; defaults to allowLocalBinding when unset"`
+	HTTPProxyPort       int      `json:"httpProxyPort,omitempty" fence:"description=Fixed port for the HTTP proxy; 0 picks a free port;example=3128"`
+	SOCKSProxyPort      int      `json:"socksProxyPort,omitempty" fence:"description=Fixed port for the SOCKS5 proxy; 0 picks a free port;example=1080"`
+	HonorHostsFile      *bool    `json:"honorHostsFile,omitempty" fence:"description=Resolve allowed domains through /etc/hosts; defaults to true, false uses DNS only"`
+	DNSCacheTTL         int      `json:"dnsCacheTTL,omitempty" fence:"description=Longest time in seconds the proxies cache a resolved address; shorter DNS TTLs are respected; 0 uses 300;example=60"`
+	AlertOnNewDomain    bool     `json:"alertOnNewDomain,omitempty" fence:"description=Report the first allowed connection to each domain"`
+	AllowACMEChallenge  bool     `json:"allowACMEChallenge,omitempty" fence:"description=Also allow the ACME certificate authority endpoints"`
+	InspectTLS          bool     `json:"inspectTLS,omitempty" fence:"description=Terminate and inspect HTTPS traffic in the HTTP proxy"`
+	InspectTLSCACert    string   `json:"inspectTLSCACert,omitempty" fence:"description=PEM CA certificate used to sign intercepted connections"`
+	InspectTLSCAKey     string   `json:"inspectTLSCAKey,omitempty" fence:"description=PEM private key for inspectTLSCACert"`
+	ValidateSPF         bool     `json:"validateSPF,omitempty" fence:"description=Warn when allowed mail servers lack an SPF record"`
+
+	// AllowedPorts, when non-empty, limits proxied connections to these
+	// destination ports. DeniedPorts are refused even for allowed domains.
+	AllowedPorts []int `json:"allowedPorts,omitempty" fence:"description=When set, only these destination ports may be used;example=443;example=22"`
+	DeniedPorts  []int `json:"deniedPorts,omitempty" fence:"description=Destination ports that are refused even for allowed domains;example=25"`
 }
 
 // FilesystemConfig defines filesystem restrictions.
 type FilesystemConfig struct {
-	DefaultDenyRead bool     `json:"defaultDenyRead,omitempty"` // If true, deny reads by default except system paths and AllowRead
-	WSLInterop      *bool    `json:"wslInterop,omitempty"`      // If nil, auto-detect WSL and allow /init; true/false to override
-	AllowRead       []string `json:"allowRead"`                 // Paths to allow reading
-	AllowExecute    []string `json:"allowExecute"`              // Paths to allow executing (read+execute only, no directory listing)
-	DenyRead        []string `json:"denyRead"`
-	AllowWrite      []string `json:"allowWrite"`
-	DenyWrite       []string `json:"denyWrite"`
-	AllowGitConfig  bool     `json:"allowGitConfig,omitempty"`
+	DefaultDenyRead bool     `json:"defaultDenyRead,omitempty" fence:"description=Deny reads everywhere except system paths and allowRead"`
+	WSLInterop      *bool    `json:"wslInterop,omitempty" fence:"description=Allow running Windows executables under WSL; auto-detected when unset"`
+	AllowRead       []string `json:"allowRead" fence:"description=Paths that may be read when defaultDenyRead is set;example=~/.gitconfig;example=./src/**"`
+	AllowExecute    []string `json:"allowExecute" fence:"description=Paths that may be executed but not listed when defaultDenyRead is set;example=/opt/tools/bin/linter"`
+	DenyExecute     []string `json:"denyExecute,omitempty" fence:"description=Executables (names or paths) blocked at exec time, even inside writable paths;example=nc;example=/usr/bin/curl"`
+	DenyRead        []string `json:"denyRead" fence:"description=Paths that may not be read;example=~/.ssh/**;example=./.env"`
+	AllowWrite      []string `json:"allowWrite" fence:"description=Paths that may be written. Supports os:<goos>:<path> entries and an atomic:true suffix;example=.;example=/tmp;example=os:darwin:~/Library/Caches"`
+	DenyWrite       []string `json:"denyWrite" fence:"description=Paths that may not be written, even inside allowWrite;example=./.git/hooks/**;example=./.env"`
+	AllowGitConfig  bool     `json:"allowGitConfig,omitempty" fence:"description=Allow writing .git/config files in the project and its subdirectories; they stay read-only by default"`
+
+	// TrustedDangerousFiles and TrustedDangerousDirectories name entries of
+	// the mandatory write protection (e.g. ".bashrc", ".vscode") that the
+	// project opts out of, such as checked-in test fixtures. Files in the
+	// home directory stay protected.
+	TrustedDangerousFiles       []string `json:"trustedDangerousFiles,omitempty" fence:"description=Names of normally write-protected files to leave writable in the project;example=.bashrc"`
+	TrustedDangerousDirectories []string `json:"trustedDangerousDirectories,omitempty" fence:"description=Names of normally write-protected directories to leave writable in the project;example=.vscode"`
+
+	// UseFenceIgnore reads .fenceignore in the working directory to skip
+	// checked-in fixtures in the Linux dangerous file scan. The file comes
+	// with the project, so this is off unless the user opts in.
+	UseFenceIgnore bool `json:"useFenceIgnore,omitempty" fence:"description=Skip paths listed in the project's .fenceignore when protecting dangerous files in subdirectories (Linux); only enable it for projects you trust"`
+
+	// KernelWatchDenyRead watches DenyRead paths with inotify (Linux) and
+	// kills the sandboxed command if any of them is opened.
+	KernelWatchDenyRead bool `json:"kernelWatchDenyRead,omitempty" fence:"description=Watch denyRead paths with inotify and kill the command if one is opened (Linux)"`
+
+	// ProtectDeniedExecutables adds the resolved paths of executables blocked
+	// by command.deny to DenyWrite, so a blocked binary cannot be replaced.
+	// If nil, defaults to true.
+	ProtectDeniedExecutables *bool `json:"protectDeniedExecutables,omitempty" fence:"description=Make executables blocked by command.deny read-only; defaults to true"`
+
+	// ScratchDir is an empty tmpfs mounted in the Linux sandbox and used as
+	// TMPDIR, so temporary files neither come from nor outlive the host.
+	ScratchDir       string `json:"scratchDir,omitempty" fence:"description=Absolute path of an empty tmpfs mounted in the sandbox and used as TMPDIR (Linux);example=/tmp/scratch"`
+	ScratchDirSizeMB int    `json:"scratchDirSizeMB,omitempty" fence:"description=Size limit of the scratch tmpfs in megabytes; unlimited when unset"`
 }
 
 // CommandConfig defines command restrictions.
 type CommandConfig struct {
-	Deny        []string `json:"deny"`
-	Allow       []string `json:"allow"`
-	UseDefaults *bool    `json:"useDefaults,omitempty"`
+	Deny        []string `json:"deny" fence:"description=Command prefixes that are blocked;example=git push;example=rm -rf /"`
+	Allow       []string `json:"allow" fence:"description=Command prefixes allowed even if they match deny or a default rule;example=git push origin feature/*"`
+	UseDefaults *bool    `json:"useDefaults,omitempty" fence:"description=Apply the built-in list of denied commands; defaults to true"`
+	ShadowMode  bool     `json:"shadowMode,omitempty" fence:"description=Report denied commands without blocking them"`
+
+	// VerifyBinaryIntegrity records the SHA-256 of each executable on first
+	// use and blocks it if the file later changes.
+	VerifyBinaryIntegrity bool `json:"verifyBinaryIntegrity,omitempty" fence:"description=Block executables whose SHA-256 changed since first use"`
+
+	// DenyOutputPatterns are regexes matched against command stdout. Matching
+	// lines are replaced with [REDACTED] before the output is shown.
+	DenyOutputPatterns []string `json:"denyOutputPatterns,omitempty" fence:"description=Regular expressions; matching stdout lines are replaced with [REDACTED];example=AKIA[0-9A-Z]{16}"`
 }
 
 // SSHConfig defines SSH command restrictions.
 // SSH commands are filtered using an allowlist by default for security.
 type SSHConfig struct {
-	AllowedHosts     []string `json:"allowedHosts"`               // Host patterns to allow SSH to (supports wildcards like *.example.com)
-	DeniedHosts      []string `json:"deniedHosts"`                // Host patterns to deny SSH to (checked before allowed)
-	AllowedCommands  []string `json:"allowedCommands"`            // Commands allowed over SSH (allowlist mode)
-	DeniedCommands   []string `json:"deniedCommands"`             // Commands denied over SSH (checked before allowed)
-	AllowAllCommands bool     `json:"allowAllCommands,omitempty"` // If true, use denylist mode instead of allowlist
-	InheritDeny      bool     `json:"inheritDeny,omitempty"`      // If true, also apply global command.deny rules
+	AllowedHosts     []string `json:"allowedHosts" fence:"description=Hosts that may be reached over SSH; supports *.example.com;example=*.example.com"`
+	DeniedHosts      []string `json:"deniedHosts" fence:"description=Hosts that may not be reached over SSH, checked before allowedHosts;example=prod-*.example.com"`
+	AllowedCommands  []string `json:"allowedCommands" fence:"description=Commands allowed over SSH in allowlist mode;example=ls;example=cat;example=journalctl"`
+	DeniedCommands   []string `json:"deniedCommands" fence:"description=Commands denied over SSH, checked before allowedCommands;example=rm;example=shutdown"`
+	AllowAllCommands bool     `json:"allowAllCommands,omitempty" fence:"description=Use denylist mode: allow every SSH command not in deniedCommands"`
+	InheritDeny      bool     `json:"inheritDeny,omitempty" fence:"description=Also apply command.deny to SSH commands"`
+}
+
+// ShellConfig selects the shell that runs the command. Mode "custom" runs
+// the shell at Path, which must be one of the supported shells.
+type ShellConfig struct {
+	Mode  string `json:"mode,omitempty" fence:"description=default runs bash from PATH, user runs $SHELL, custom runs path;example=custom"`
+	Path  string `json:"path,omitempty" fence:"description=Absolute path of the shell for custom mode, such as a bash outside PATH;example=/usr/local/bin/bash"`
+	Login bool   `json:"login,omitempty" fence:"description=Run the shell as a login shell (-lc)"`
+}
+
+// ResourceLimits caps the resources available to the sandboxed command.
+// A zero value means unlimited.
+type ResourceLimits struct {
+	MaxMemoryMB    int `json:"maxMemoryMB,omitempty" fence:"description=Data segment size per process in MB (Linux), and the cgroup memory limit where available; 0 is unlimited;example=2048"`
+	MaxCPUPercent  int `json:"maxCPUPercent,omitempty" fence:"description=CPU share in percent of one core; requires cgroup v2 on Linux; 0 is unlimited;example=200"`
+	MaxProcesses   int `json:"maxProcesses,omitempty" fence:"description=Maximum number of processes: cgroup v2 pids.max on Linux, RLIMIT_NPROC on macOS, where every process of the user counts; 0 is unlimited;example=256"`
+	MaxOpenFiles   int `json:"maxOpenFiles,omitempty" fence:"description=Maximum open file descriptors per process (RLIMIT_NOFILE); 0 is unlimited;example=1024"`
+	MaxWallSeconds int `json:"maxWallSeconds,omitempty" fence:"description=Wall-clock run time in seconds, after which the command is killed; 0 is unlimited;example=600"`
 }
 
 // DefaultDeniedCommands returns commands that are blocked by default.
@@ -106,6 +205,17 @@ var DefaultDeniedCommands = []string{
 	"chroot",
 	"unshare",
 	"nsenter",
+
+	// Data encoding - base64 is the usual way to smuggle file contents through
+	// an allowed channel (base64 ~/.ssh/id_rsa | curl -d @- ...) and to unpack
+	// hidden payloads (base64 -d <<< "..." | bash). The prefix also covers
+	// the decode form.
+	"base64",
+}
+
+// GetDefaultDeniedCommands returns a copy of DefaultDeniedCommands.
+func GetDefaultDeniedCommands() []string {
+	return slices.Clone(DefaultDeniedCommands)
 }
 
 // Default returns the default configuration with all network blocked.
@@ -137,6 +247,7 @@ func Default() *Config {
 // DefaultConfigPath returns the default config file path.
 // Uses the OS-preferred config directory (XDG on Linux, ~/Library/Application Support on macOS).
 // Falls back to ~/.fence.json if the new location doesn't exist but the legacy one does.
+// In the config directory, fence.yaml and fence.yml are tried after fence.json.
 func DefaultConfigPath() string {
 	// Try OS-preferred config directory first
 	configDir, err := os.UserConfigDir()
@@ -145,6 +256,12 @@ func DefaultConfigPath() string {
 		if _, err := os.Stat(newPath); err == nil {
 			return newPath
 		}
+		for _, name := range []string{"fence.yaml", "fence.yml"} {
+			yamlPath := filepath.Join(configDir, "fence", name)
+			if _, err := os.Stat(yamlPath); err == nil {
+				return yamlPath
+			}
+		}
 		// Check if parent directory exists (user has set up the new location)
 		// If so, prefer this even if config doesn't exist yet
 		if _, err := os.Stat(filepath.Dir(newPath)); err == nil {
@@ -169,7 +286,8 @@ func DefaultConfigPath() string {
 	return filepath.Join(home, ".config", "fence", "fence.json")
 }
 
-// Load loads configuration from a file path.
+// Load loads configuration from a file path. It returns nil, nil if the
+// file does not exist or is empty.
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path) //nolint:gosec // user-provided config path - intentional
 	if err != nil {
@@ -179,13 +297,29 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// Handle empty file
-	if len(strings.TrimSpace(string(data))) == 0 {
+	if IsYAMLPath(path) {
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil, nil
+		}
+		converted, err := YAMLToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML in config file: %w", err)
+		}
+		data = converted
+	}
+
+	return LoadConfigBytes(data)
+}
+
+// LoadConfigBytes parses and validates a JSON config, which may contain
+// comments (JSONC). Like Load, it returns nil, nil for empty input.
+func LoadConfigBytes(data []byte) (*Config, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
 		return nil, nil
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(jsonc.ToJSON(data), &cfg); err != nil {
+	cfg, err := ParseConfig("", data)
+	if err != nil {
 		return nil, fmt.Errorf("invalid JSON in config file: %w", err)
 	}
 
@@ -193,37 +327,96 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	return &cfg, nil
+	return cfg, nil
+}
+
+// LoadConfigReader reads a JSON config from r and loads it with
+// LoadConfigBytes.
+func LoadConfigReader(r io.Reader) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	return LoadConfigBytes(data)
 }
 
 // Validate validates the configuration.
 func (c *Config) Validate() error {
 	for _, domain := range c.Network.AllowedDomains {
-		if err := validateDomainPattern(domain); err != nil {
+		if err := validateDomainEntry(domain); err != nil {
 			return fmt.Errorf("invalid allowed domain %q: %w", domain, err)
 		}
 	}
 	for _, domain := range c.Network.DeniedDomains {
-		if err := validateDomainPattern(domain); err != nil {
+		if err := validateDomainEntry(domain); err != nil {
 			return fmt.Errorf("invalid denied domain %q: %w", domain, err)
 		}
 	}
 
+	for _, port := range c.Network.AllowedPorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid network.allowedPorts entry %d: must be 1-65535", port)
+		}
+	}
+	for _, port := range c.Network.DeniedPorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid network.deniedPorts entry %d: must be 1-65535", port)
+		}
+	}
+
+	if c.Network.DNSCacheTTL < 0 {
+		return errors.New("network.dnsCacheTTL must not be negative (0 uses the default)")
+	}
+
+	if c.Network.InspectTLS && (c.Network.InspectTLSCACert == "" || c.Network.InspectTLSCAKey == "") {
+		return errors.New("network.inspectTLS requires network.inspectTLSCACert and network.inspectTLSCAKey")
+	}
+
 	if slices.Contains(c.Filesystem.AllowRead, "") {
 		return errors.New("filesystem.allowRead contains empty path")
 	}
 	if slices.Contains(c.Filesystem.AllowExecute, "") {
 		return errors.New("filesystem.allowExecute contains empty path")
 	}
+	for _, entry := range c.Filesystem.DenyExecute {
+		if entry == "" {
+			return errors.New("filesystem.denyExecute contains empty entry")
+		}
+		if strings.ContainsAny(entry, "*?[") {
+			return fmt.Errorf("filesystem.denyExecute entry %q: globs are not supported, list each executable", entry)
+		}
+	}
 	if slices.Contains(c.Filesystem.DenyRead, "") {
 		return errors.New("filesystem.denyRead contains empty path")
 	}
 	if slices.Contains(c.Filesystem.AllowWrite, "") {
 		return errors.New("filesystem.allowWrite contains empty path")
 	}
+	for _, entry := range c.Filesystem.AllowWrite {
+		if _, path, ok := splitOSPrefix(entry); ok && path == "" {
+			return fmt.Errorf("filesystem.allowWrite entry %q has no path", entry)
+		}
+		if path, atomic := splitAtomicFlag(entry); atomic && strings.ContainsAny(path, "*?[") {
+			return fmt.Errorf("filesystem.allowWrite entry %q: atomic:true requires a single file, not a glob", entry)
+		}
+	}
 	if slices.Contains(c.Filesystem.DenyWrite, "") {
 		return errors.New("filesystem.denyWrite contains empty path")
 	}
+	if dir := c.Filesystem.ScratchDir; dir != "" {
+		if !filepath.IsAbs(dir) || filepath.Clean(dir) == "/" {
+			return fmt.Errorf("filesystem.scratchDir %q must be an absolute path other than /", dir)
+		}
+		if strings.ContainsAny(dir, "*?[") {
+			return fmt.Errorf("filesystem.scratchDir %q: globs are not supported", dir)
+		}
+	}
+	if c.Filesystem.ScratchDirSizeMB < 0 {
+		return errors.New("filesystem.scratchDirSizeMB must not be negative (0 means unlimited)")
+	}
+	if c.Filesystem.ScratchDirSizeMB > 0 && c.Filesystem.ScratchDir == "" {
+		return errors.New("filesystem.scratchDirSizeMB requires filesystem.scratchDir")
+	}
 
 	if slices.Contains(c.Command.Deny, "") {
 		return errors.New("command.deny contains empty command")
@@ -231,6 +424,35 @@ func (c *Config) Validate() error {
 	if slices.Contains(c.Command.Allow, "") {
 		return errors.New("command.allow contains empty command")
 	}
+	for _, rule := range c.Command.Allow {
+		parsed, err := ParseCommandRule(rule)
+		if err != nil {
+			return fmt.Errorf("invalid command.allow %q: %w", rule, err)
+		}
+		if parsed.Reason != "" {
+			return fmt.Errorf("invalid command.allow %q: reason is only supported in command.deny", rule)
+		}
+	}
+	for _, rule := range c.Command.Deny {
+		parsed, err := ParseCommandRule(rule)
+		if err != nil {
+			return fmt.Errorf("invalid command.deny %q: %w", rule, err)
+		}
+		if parsed.HasMaxArgs() {
+			return fmt.Errorf("invalid command.deny %q: maxArgs is only supported in command.allow", rule)
+		}
+		if parsed.Workdir != "" {
+			return fmt.Errorf("invalid command.deny %q: workdir is only supported in command.allow", rule)
+		}
+		if parsed.When != "" {
+			return fmt.Errorf("invalid command.deny %q: when is only supported in command.allow", rule)
+		}
+	}
+	for _, pattern := range c.Command.DenyOutputPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid command.denyOutputPatterns %q: %w", pattern, err)
+		}
+	}
 
 	// SSH config
 	for _, host := range c.SSH.AllowedHosts {
@@ -250,14 +472,263 @@ func (c *Config) Validate() error {
 		return errors.New("ssh.deniedCommands contains empty command")
 	}
 
+	switch c.Shell.Mode {
+	case "", "default", "user":
+	case "custom":
+		if c.Shell.Path == "" {
+			return errors.New(`shell.path is required when shell.mode is "custom"`)
+		}
+	default:
+		return fmt.Errorf(`invalid shell.mode %q: must be "default", "user" or "custom"`, c.Shell.Mode)
+	}
+	if c.Shell.Path != "" && !filepath.IsAbs(c.Shell.Path) {
+		return fmt.Errorf("shell.path %q must be an absolute path", c.Shell.Path)
+	}
+
+	switch c.EnforcementMode {
+	case "", EnforcementModeEnforce, EnforcementModeAudit:
+	default:
+		return fmt.Errorf("invalid enforcementMode %q: must be %q or %q", c.EnforcementMode, EnforcementModeEnforce, EnforcementModeAudit)
+	}
+	if err := validateWebhookURL(c.WebhookURL); err != nil {
+		return fmt.Errorf("invalid webhookUrl %q: %w", c.WebhookURL, err)
+	}
+	if _, _, err := ParseSandboxProfileExtra(c.SandboxProfileExtra); err != nil {
+		return fmt.Errorf("invalid sandboxProfileExtra: %w", err)
+	}
+
+	limits := []struct {
+		name  string
+		value int
+	}{
+		{"maxMemoryMB", c.ResourceLimits.MaxMemoryMB},
+		{"maxCPUPercent", c.ResourceLimits.MaxCPUPercent},
+		{"maxProcesses", c.ResourceLimits.MaxProcesses},
+		{"maxOpenFiles", c.ResourceLimits.MaxOpenFiles},
+		{"maxWallSeconds", c.ResourceLimits.MaxWallSeconds},
+	}
+	for _, l := range limits {
+		if l.value < 0 {
+			return fmt.Errorf("resourceLimits.%s must not be negative (0 means unlimited)", l.name)
+		}
+	}
+
 	return nil
 }
 
+// Warnings returns non-fatal configuration problems, such as entries that
+// will never apply on any supported platform. With network.validateSPF set
+// this performs DNS lookups.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if c.AuditMode() {
+		warnings = append(warnings, "enforcementMode is audit: the command runs unsandboxed and blocked actions are only logged")
+	}
+	if c.SandboxProfileExtra != "" && currentOS != "darwin" {
+		warnings = append(warnings, "sandboxProfileExtra only applies to the macOS sandbox and is ignored on "+currentOS)
+	}
+	for _, entry := range c.Filesystem.AllowWrite {
+		if goos, _, ok := splitOSPrefix(entry); ok && !slices.Contains(knownOSPrefixes, goos) {
+			warnings = append(warnings, fmt.Sprintf("filesystem.allowWrite entry %q uses unknown OS %q (expected one of %v)", entry, goos, knownOSPrefixes))
+		}
+	}
+	writable := c.Filesystem.WritablePaths()
+	for _, entry := range c.Filesystem.DenyWrite {
+		sameAsEntry := func(p string) bool { return filepath.Clean(p) == filepath.Clean(entry) }
+		if slices.ContainsFunc(writable, sameAsEntry) {
+			warnings = append(warnings, fmt.Sprintf("filesystem.denyWrite entry %q is also listed in allowWrite; denyWrite takes precedence and the path stays read-only", entry))
+		}
+	}
+	for _, name := range c.Filesystem.TrustedDangerousFiles {
+		switch {
+		case slices.Contains(sensitiveDangerousFiles, name):
+			warnings = append(warnings, fmt.Sprintf("filesystem.trustedDangerousFiles entry %q is read by git as configuration; sandboxed commands could change what git runs", name))
+		case strings.ContainsAny(name, `/\`):
+			warnings = append(warnings, fmt.Sprintf("filesystem.trustedDangerousFiles entry %q is a path; entries are file names such as \".bashrc\" and apply in every project directory", name))
+		}
+	}
+	for _, entry := range c.Network.AllowedDomains {
+		proto := DomainProto(entry)
+		if proto == "" || proto == "tcp" {
+			continue
+		}
+		// On macOS a "proto:udp *" wildcard becomes a Seatbelt rule
+		if proto == "udp" && BareDomain(entry) == "*" && currentOS == "darwin" {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("network.allowedDomains entry %q has no effect: only TCP traffic is proxied, and direct %s traffic stays blocked", entry, proto))
+	}
+	if (len(c.Network.AllowedPorts) > 0 || len(c.Network.DeniedPorts) > 0) && c.Network.AllowsDirectNetwork() {
+		warnings = append(warnings, `network.allowedPorts and deniedPorts only apply to proxied traffic; with "*" in allowedDomains, direct connections can use any port`)
+	}
+	// Loopback addresses are in NO_PROXY, so clients connect to them
+	// directly, which the macOS profile only allows with allowLocalOutbound.
+	if currentOS == "darwin" && !c.Network.AllowsLocalOutbound() {
+		for _, entry := range c.Network.LoopbackAllowedDomains() {
+			warnings = append(warnings, fmt.Sprintf("network.allowedDomains entry %q only allows connections made through the proxy; set network.allowLocalOutbound to let clients that bypass the proxy for localhost connect directly", entry))
+		}
+	}
+	if c.Network.ValidateSPF {
+		warnings = append(warnings, c.spfWarnings()...)
+	}
+	return warnings
+}
+
+// sensitiveDangerousFiles are protected files that are rarely fixtures and
+// change how git behaves when written, so trusting them is warned about.
+var sensitiveDangerousFiles = []string{".gitconfig", ".gitmodules"}
+
+// osPrefix marks a platform-specific path entry, e.g. "os:darwin:/private/tmp/build".
+const osPrefix = "os:"
+
+// knownOSPrefixes lists the runtime.GOOS values fence supports in "os:" entries.
+var knownOSPrefixes = []string{"darwin", "linux"}
+
+// currentOS is the platform used to filter "os:" entries. Tests override it.
+var currentOS = runtime.GOOS
+
+// splitOSPrefix splits an "os:<goos>:<path>" entry. ok is false for entries
+// without an OS prefix.
+func splitOSPrefix(entry string) (goos, path string, ok bool) {
+	if !strings.HasPrefix(entry, osPrefix) {
+		return "", entry, false
+	}
+	rest := entry[len(osPrefix):]
+	idx := strings.Index(rest, ":")
+	if idx == -1 {
+		return "", entry, false
+	}
+	return rest[:idx], rest[idx+1:], true
+}
+
+// WritablePaths returns the allowWrite entries that apply on the current
+// platform, with any "os:<goos>:" prefix removed. Entries without a prefix
+// apply everywhere.
+func (f *FilesystemConfig) WritablePaths() []string {
+	if f.AllowWrite == nil {
+		return nil
+	}
+	paths := make([]string, 0, len(f.AllowWrite))
+	for _, entry := range f.AllowWrite {
+		goos, path, ok := splitOSPrefix(entry)
+		if ok && goos != currentOS {
+			continue
+		}
+		path, _ = splitAtomicFlag(path)
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// atomicSuffix marks an allowWrite file that may only be replaced by renaming
+// a finished file onto it, e.g. "/workspace/config.json atomic:true".
+const atomicSuffix = " atomic:true"
+
+// splitAtomicFlag strips a trailing atomic:true flag from an allowWrite entry.
+func splitAtomicFlag(entry string) (path string, atomic bool) {
+	if path, ok := strings.CutSuffix(entry, atomicSuffix); ok {
+		return strings.TrimRight(path, " "), true
+	}
+	return entry, false
+}
+
+// AtomicWritePaths returns the allowWrite files marked atomic:true that apply
+// on the current platform. Direct writes to them are refused; they may only be
+// replaced by writing a temporary file and renaming it over the path.
+func (f *FilesystemConfig) AtomicWritePaths() []string {
+	var paths []string
+	for _, entry := range f.AllowWrite {
+		goos, path, ok := splitOSPrefix(entry)
+		if ok && goos != currentOS {
+			continue
+		}
+		if path, atomic := splitAtomicFlag(path); atomic {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// HonorsHostsFile returns whether proxied connections may use /etc/hosts
+// overrides when resolving allowed domains.
+func (n *NetworkConfig) HonorsHostsFile() bool {
+	return n.HonorHostsFile == nil || *n.HonorHostsFile
+}
+
+// AllowsDirectNetwork reports whether an allowedDomains entry is a bare "*"
+// (possibly tagged, but without a protocol), which lets the sandbox reach
+// the network directly instead of only through the proxies.
+func (n *NetworkConfig) AllowsDirectNetwork() bool {
+	for _, entry := range n.EffectiveAllowedDomains() {
+		if BareDomain(entry) == "*" && DomainProto(entry) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsLocalOutbound returns allowLocalOutbound, which defaults to
+// allowLocalBinding when unset.
+func (n *NetworkConfig) AllowsLocalOutbound() bool {
+	if n.AllowLocalOutbound != nil {
+		return *n.AllowLocalOutbound
+	}
+	return n.AllowLocalBinding
+}
+
+// LoopbackAllowedDomains returns the allowedDomains entries that are
+// loopback IP addresses such as "127.0.0.1" or "::1" and apply to TCP.
+func (n *NetworkConfig) LoopbackAllowedDomains() []string {
+	var entries []string
+	for _, entry := range n.EffectiveAllowedDomains() {
+		if !AppliesToTCP(entry) {
+			continue
+		}
+		if parsed, err := ParseDomainEntry(entry); err == nil && parsed.Kind == DomainIP && parsed.IP.IsLoopback() {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// ACMEDomains are the ACME v2 certificate authority endpoints allowed by
+// network.allowACMEChallenge.
+var ACMEDomains = []string{
+	"acme-v02.api.letsencrypt.org",
+	"acme-staging-v02.api.letsencrypt.org",
+	"acme.zerossl.com",
+	"dv.acme-v02.api.pki.goog",
+	"dv.acme-v02.test-api.pki.goog",
+	"api.buypass.com",
+	"api.test4.buypass.no",
+}
+
+// EffectiveAllowedDomains returns AllowedDomains plus any domains enabled by
+// shorthand options such as AllowACMEChallenge.
+func (n *NetworkConfig) EffectiveAllowedDomains() []string {
+	if !n.AllowACMEChallenge {
+		return n.AllowedDomains
+	}
+	return mergeStrings(n.AllowedDomains, slices.Clone(ACMEDomains))
+}
+
+// ProtectsDeniedExecutables returns whether denied executables are write-protected.
+func (f *FilesystemConfig) ProtectsDeniedExecutables() bool {
+	return f.ProtectDeniedExecutables == nil || *f.ProtectDeniedExecutables
+}
+
 // UseDefaultDeniedCommands returns whether to use the default deny list.
 func (c *CommandConfig) UseDefaultDeniedCommands() bool {
 	return c.UseDefaults == nil || *c.UseDefaults
 }
 
+// validateDomainEntry validates an allowedDomains/deniedDomains entry,
+// including optional "tag:<name>" and "proto:<protocol>" prefixes.
+func validateDomainEntry(entry string) error {
+	_, err := ParseDomainEntry(entry)
+	return err
+}
+
 func validateDomainPattern(pattern string) error {
 	if pattern == "localhost" {
 		return nil
@@ -330,6 +801,25 @@ func validateHostPattern(pattern string) error {
 	return nil
 }
 
+// validateWebhookURL checks that a webhook URL, if set, is an absolute http
+// or https URL.
+func validateWebhookURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("must be an http or https URL")
+	}
+	if u.Host == "" {
+		return errors.New("missing host")
+	}
+	return nil
+}
+
 // isIPv6Pattern checks if a pattern looks like an IPv6 address.
 func isIPv6Pattern(pattern string) bool {
 	// IPv6 addresses contain multiple colons
@@ -338,8 +828,19 @@ func isIPv6Pattern(pattern string) bool {
 }
 
 // MatchesDomain checks if a hostname matches a domain pattern.
+// "tag:<name>" and "proto:<protocol>" prefixes on the pattern are ignored.
+// A CIDR pattern matches IP address literals in its range, and an IP pattern
+// matches the same address in any notation (brackets, a port, or the
+// IPv4-mapped IPv6 form).
+//
+// Matching is on whole labels: "api.openai.com" matches only that host, and
+// "*.api.openai.com" matches hosts ending in ".api.openai.com", so neither
+// matches "api.openai.com.evil.com" or "evilapi.openai.com". A trailing dot
+// on the hostname (fully qualified form) is ignored so "evil.com." cannot
+// slip past a deny rule for "evil.com".
 func MatchesDomain(hostname, pattern string) bool {
-	hostname = strings.ToLower(hostname)
+	pattern = BareDomain(pattern)
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
 	pattern = strings.ToLower(pattern)
 
 	// "*" matches all domains
@@ -347,6 +848,19 @@ func MatchesDomain(hostname, pattern string) bool {
 		return true
 	}
 
+	if strings.Contains(pattern, "/") {
+		_, network, err := net.ParseCIDR(pattern)
+		ip := ParseIPLiteral(hostname)
+		return err == nil && ip != nil && network.Contains(ip)
+	}
+
+	// IP literals compare as addresses, so "[::1]" matches "::1" and
+	// "::ffff:127.0.0.1" matches "127.0.0.1"
+	if patternIP := parseBracketedIP(pattern); patternIP != nil {
+		ip := ParseIPLiteral(hostname)
+		return ip != nil && ip.Equal(patternIP)
+	}
+
 	// Wildcard pattern like *.example.com
 	if strings.HasPrefix(pattern, "*.") {
 		baseDomain := pattern[2:]
@@ -357,6 +871,107 @@ func MatchesDomain(hostname, pattern string) bool {
 	return hostname == pattern
 }
 
+// domainTagPrefix marks a tagged domain entry, e.g. "tag:openai api.openai.com".
+const domainTagPrefix = "tag:"
+
+// SplitDomainTag splits a domain entry of the form "tag:<name> <domain>" into
+// its tag and domain. Untagged entries are returned unchanged with an empty tag.
+func SplitDomainTag(entry string) (tag, domain string) {
+	entry = strings.TrimSpace(entry)
+	if !strings.HasPrefix(entry, domainTagPrefix) {
+		return "", entry
+	}
+
+	rest := entry[len(domainTagPrefix):]
+	idx := strings.IndexAny(rest, " \t")
+	if idx == -1 {
+		return rest, ""
+	}
+	return rest[:idx], strings.TrimSpace(rest[idx+1:])
+}
+
+// domainProtoPrefix restricts a domain entry to one IP protocol, e.g.
+// "proto:tcp api.openai.com". It follows any "tag:" prefix.
+const domainProtoPrefix = "proto:"
+
+// DomainProtocols lists the protocols accepted in "proto:" prefixes.
+var DomainProtocols = []string{"tcp", "udp", "icmp"}
+
+// SplitDomainProto splits a domain entry of the form "proto:<protocol> <domain>"
+// into its protocol and domain. Entries without the prefix are returned
+// unchanged with an empty protocol, meaning they apply to every protocol.
+func SplitDomainProto(entry string) (proto, domain string) {
+	entry = strings.TrimSpace(entry)
+	if !strings.HasPrefix(entry, domainProtoPrefix) {
+		return "", entry
+	}
+
+	rest := entry[len(domainProtoPrefix):]
+	idx := strings.IndexAny(rest, " \t")
+	if idx == -1 {
+		return strings.ToLower(rest), ""
+	}
+	return strings.ToLower(rest[:idx]), strings.TrimSpace(rest[idx+1:])
+}
+
+// DomainProto returns the protocol a domain entry is restricted to, or ""
+// when it applies to every protocol.
+func DomainProto(entry string) string {
+	_, rest := SplitDomainTag(entry)
+	proto, _ := SplitDomainProto(rest)
+	return proto
+}
+
+// AppliesToTCP reports whether a domain entry covers TCP connections, which
+// is all the HTTP and SOCKS proxies carry.
+func AppliesToTCP(entry string) bool {
+	proto := DomainProto(entry)
+	return proto == "" || proto == "tcp"
+}
+
+// BareDomain returns the domain pattern of an entry with any "tag:" and
+// "proto:" prefixes removed.
+func BareDomain(entry string) string {
+	_, rest := SplitDomainTag(entry)
+	_, domain := SplitDomainProto(rest)
+	return domain
+}
+
+// GroupDomainsByTag groups domain entries by tag, with tags stripped from the
+// returned domains. Untagged entries are grouped under the empty string.
+func GroupDomainsByTag(entries []string) map[string][]string {
+	groups := make(map[string][]string)
+	for _, entry := range entries {
+		tag, domain := SplitDomainTag(entry)
+		groups[tag] = append(groups[tag], domain)
+	}
+	return groups
+}
+
+// RemoveDomainTags removes every allowedDomains entry carrying one of the
+// given tags. deniedDomains entries are kept, so disabling a tag can only
+// narrow what is reachable.
+func (n *NetworkConfig) RemoveDomainTags(tags ...string) {
+	if len(tags) == 0 {
+		return
+	}
+	n.AllowedDomains = removeTaggedDomains(n.AllowedDomains, tags)
+}
+
+func removeTaggedDomains(entries, tags []string) []string {
+	if entries == nil {
+		return nil
+	}
+	result := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if tag, _ := SplitDomainTag(entry); tag != "" && slices.Contains(tags, tag) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
 // MatchesHost checks if a hostname matches an SSH host pattern.
 // SSH host patterns support wildcards anywhere in the pattern.
 func MatchesHost(hostname, pattern string) bool {
@@ -422,10 +1037,33 @@ func matchGlob(s, pattern string) bool {
 	return true
 }
 
+// Deny strategies for MergeOptions.DenyStrategy.
+const (
+	// DenyStrategyUnion keeps the deny entries of both configs (the default).
+	DenyStrategyUnion = "union"
+	// DenyStrategyOverride lets a deny list set in override replace the
+	// base list, so a project can relax a shared policy deliberately.
+	DenyStrategyOverride = "override"
+)
+
+// MergeOptions controls how MergeWithOptions combines configs.
+type MergeOptions struct {
+	// DenyStrategy is DenyStrategyUnion or DenyStrategyOverride. Empty or
+	// unknown values are treated as DenyStrategyUnion, the safe choice.
+	DenyStrategy string
+}
+
 // Merge combines a base config with an override config.
 // Values in override take precedence. Slice fields are appended (base + override).
 // The Extends field is cleared in the result since inheritance has been resolved.
 func Merge(base, override *Config) *Config {
+	return MergeWithOptions(base, override, MergeOptions{})
+}
+
+// MergeWithOptions is like Merge, with opts controlling how the deny lists
+// (network.deniedDomains and deniedPorts, filesystem.denyRead, denyWrite and denyExecute,
+// command.deny, ssh.deniedHosts and deniedCommands) are combined.
+func MergeWithOptions(base, override *Config, opts MergeOptions) *Config {
 	if base == nil {
 		if override == nil {
 			return Default()
@@ -443,23 +1081,49 @@ func Merge(base, override *Config) *Config {
 	result := &Config{
 		// AllowPty: true if either config enables it
 		AllowPty: base.AllowPty || override.AllowPty,
+		// AllowGPU: true if either config enables it
+		AllowGPU: base.AllowGPU || override.AllowGPU,
+		// AllowKeychain: true if either config enables it
+		AllowKeychain: base.AllowKeychain || override.AllowKeychain,
+
+		// String fields: override wins if set
+		EnforcementMode: mergeString(base.EnforcementMode, override.EnforcementMode),
+		AuditLogPath:    mergeString(base.AuditLogPath, override.AuditLogPath),
+		WebhookURL:      mergeString(base.WebhookURL, override.WebhookURL),
+
+		// Profile rules accumulate, like the list fields
+		SandboxProfileExtra: joinNonEmpty("\n", base.SandboxProfileExtra, override.SandboxProfileExtra),
 
 		Network: NetworkConfig{
 			// Append slices (base first, then override additions)
 			AllowedDomains:   mergeStrings(base.Network.AllowedDomains, override.Network.AllowedDomains),
 			DeniedDomains:    mergeStrings(base.Network.DeniedDomains, override.Network.DeniedDomains),
 			AllowUnixSockets: mergeStrings(base.Network.AllowUnixSockets, override.Network.AllowUnixSockets),
+			AllowedPorts:     mergeInts(base.Network.AllowedPorts, override.Network.AllowedPorts),
+			DeniedPorts:      mergeInts(base.Network.DeniedPorts, override.Network.DeniedPorts),
 
 			// Boolean fields: override wins if set, otherwise base
 			AllowAllUnixSockets: base.Network.AllowAllUnixSockets || override.Network.AllowAllUnixSockets,
 			AllowLocalBinding:   base.Network.AllowLocalBinding || override.Network.AllowLocalBinding,
+			AlertOnNewDomain:    base.Network.AlertOnNewDomain || override.Network.AlertOnNewDomain,
+			AllowACMEChallenge:  base.Network.AllowACMEChallenge || override.Network.AllowACMEChallenge,
+			InspectTLS:          base.Network.InspectTLS || override.Network.InspectTLS,
+			ValidateSPF:         base.Network.ValidateSPF || override.Network.ValidateSPF,
+
+			// String fields: override wins if set
+			InspectTLSCACert: mergeString(base.Network.InspectTLSCACert, override.Network.InspectTLSCACert),
+			InspectTLSCAKey:  mergeString(base.Network.InspectTLSCAKey, override.Network.InspectTLSCAKey),
 
 			// Pointer fields: override wins if set, otherwise base
 			AllowLocalOutbound: mergeOptionalBool(base.Network.AllowLocalOutbound, override.Network.AllowLocalOutbound),
+			HonorHostsFile:     mergeOptionalBool(base.Network.HonorHostsFile, override.Network.HonorHostsFile),
 
 			// Port fields: override wins if non-zero
 			HTTPProxyPort:  mergeInt(base.Network.HTTPProxyPort, override.Network.HTTPProxyPort),
 			SOCKSProxyPort: mergeInt(base.Network.SOCKSProxyPort, override.Network.SOCKSProxyPort),
+
+			// Override wins if non-zero
+			DNSCacheTTL: mergeInt(base.Network.DNSCacheTTL, override.Network.DNSCacheTTL),
 		},
 
 		Filesystem: FilesystemConfig{
@@ -472,12 +1136,24 @@ func Merge(base, override *Config) *Config {
 			// Append slices
 			AllowRead:    mergeStrings(base.Filesystem.AllowRead, override.Filesystem.AllowRead),
 			AllowExecute: mergeStrings(base.Filesystem.AllowExecute, override.Filesystem.AllowExecute),
+			DenyExecute:  mergeStrings(base.Filesystem.DenyExecute, override.Filesystem.DenyExecute),
 			DenyRead:     mergeStrings(base.Filesystem.DenyRead, override.Filesystem.DenyRead),
 			AllowWrite:   mergeStrings(base.Filesystem.AllowWrite, override.Filesystem.AllowWrite),
 			DenyWrite:    mergeStrings(base.Filesystem.DenyWrite, override.Filesystem.DenyWrite),
 
+			TrustedDangerousFiles:       mergeStrings(base.Filesystem.TrustedDangerousFiles, override.Filesystem.TrustedDangerousFiles),
+			TrustedDangerousDirectories: mergeStrings(base.Filesystem.TrustedDangerousDirectories, override.Filesystem.TrustedDangerousDirectories),
+
 			// Boolean fields: override wins if set
-			AllowGitConfig: base.Filesystem.AllowGitConfig || override.Filesystem.AllowGitConfig,
+			AllowGitConfig:      base.Filesystem.AllowGitConfig || override.Filesystem.AllowGitConfig,
+			KernelWatchDenyRead: base.Filesystem.KernelWatchDenyRead || override.Filesystem.KernelWatchDenyRead,
+			UseFenceIgnore:      base.Filesystem.UseFenceIgnore || override.Filesystem.UseFenceIgnore,
+
+			ProtectDeniedExecutables: mergeOptionalBool(base.Filesystem.ProtectDeniedExecutables, override.Filesystem.ProtectDeniedExecutables),
+
+			// Scalar fields: override wins if set
+			ScratchDir:       mergeString(base.Filesystem.ScratchDir, override.Filesystem.ScratchDir),
+			ScratchDirSizeMB: mergeInt(base.Filesystem.ScratchDirSizeMB, override.Filesystem.ScratchDirSizeMB),
 		},
 
 		Command: CommandConfig{
@@ -485,8 +1161,14 @@ func Merge(base, override *Config) *Config {
 			Deny:  mergeStrings(base.Command.Deny, override.Command.Deny),
 			Allow: mergeStrings(base.Command.Allow, override.Command.Allow),
 
+			DenyOutputPatterns: mergeStrings(base.Command.DenyOutputPatterns, override.Command.DenyOutputPatterns),
+
 			// Pointer field: override wins if set
 			UseDefaults: mergeOptionalBool(base.Command.UseDefaults, override.Command.UseDefaults),
+
+			// Boolean fields: true if either enables it
+			ShadowMode:            base.Command.ShadowMode || override.Command.ShadowMode,
+			VerifyBinaryIntegrity: base.Command.VerifyBinaryIntegrity || override.Command.VerifyBinaryIntegrity,
 		},
 
 		SSH: SSHConfig{
@@ -500,19 +1182,51 @@ func Merge(base, override *Config) *Config {
 			AllowAllCommands: base.SSH.AllowAllCommands || override.SSH.AllowAllCommands,
 			InheritDeny:      base.SSH.InheritDeny || override.SSH.InheritDeny,
 		},
+
+		Shell: ShellConfig{
+			Mode:  mergeString(base.Shell.Mode, override.Shell.Mode),
+			Path:  mergeString(base.Shell.Path, override.Shell.Path),
+			Login: base.Shell.Login || override.Shell.Login,
+		},
+
+		ResourceLimits: ResourceLimits{
+			// Int fields: override wins if non-zero
+			MaxMemoryMB:    mergeInt(base.ResourceLimits.MaxMemoryMB, override.ResourceLimits.MaxMemoryMB),
+			MaxCPUPercent:  mergeInt(base.ResourceLimits.MaxCPUPercent, override.ResourceLimits.MaxCPUPercent),
+			MaxProcesses:   mergeInt(base.ResourceLimits.MaxProcesses, override.ResourceLimits.MaxProcesses),
+			MaxOpenFiles:   mergeInt(base.ResourceLimits.MaxOpenFiles, override.ResourceLimits.MaxOpenFiles),
+			MaxWallSeconds: mergeInt(base.ResourceLimits.MaxWallSeconds, override.ResourceLimits.MaxWallSeconds),
+		},
+	}
+
+	if opts.DenyStrategy == DenyStrategyOverride {
+		// A nil list in override means "not set", so the base list is kept
+		result.Network.DeniedDomains = overrideStrings(base.Network.DeniedDomains, override.Network.DeniedDomains)
+		result.Network.DeniedPorts = overrideInts(base.Network.DeniedPorts, override.Network.DeniedPorts)
+		result.Filesystem.DenyRead = overrideStrings(base.Filesystem.DenyRead, override.Filesystem.DenyRead)
+		result.Filesystem.DenyWrite = overrideStrings(base.Filesystem.DenyWrite, override.Filesystem.DenyWrite)
+		result.Filesystem.DenyExecute = overrideStrings(base.Filesystem.DenyExecute, override.Filesystem.DenyExecute)
+		result.Command.Deny = overrideStrings(base.Command.Deny, override.Command.Deny)
+		result.SSH.DeniedHosts = overrideStrings(base.SSH.DeniedHosts, override.SSH.DeniedHosts)
+		result.SSH.DeniedCommands = overrideStrings(base.SSH.DeniedCommands, override.SSH.DeniedCommands)
 	}
 
 	return result
 }
 
+// overrideStrings returns override if it is set, otherwise base.
+func overrideStrings(base, override []string) []string {
+	if override != nil {
+		return override
+	}
+	return base
+}
+
 // mergeStrings appends two string slices, removing duplicates.
 func mergeStrings(base, override []string) []string {
-	if len(base) == 0 {
+	if len(base) == 0 && len(override) == 0 {
 		return override
 	}
-	if len(override) == 0 {
-		return base
-	}
 
 	seen := make(map[string]bool, len(base))
 	result := make([]string, 0, len(base)+len(override))
@@ -532,6 +1246,37 @@ func mergeStrings(base, override []string) []string {
 	return result
 }
 
+// overrideInts returns override if it is set, otherwise base.
+func overrideInts(base, override []int) []int {
+	if override != nil {
+		return override
+	}
+	return base
+}
+
+// mergeInts appends two int slices, removing duplicates.
+func mergeInts(base, override []int) []int {
+	if len(base) == 0 && len(override) == 0 {
+		return override
+	}
+
+	seen := make(map[int]bool, len(base))
+	result := make([]int, 0, len(base)+len(override))
+	for _, n := range base {
+		if !seen[n] {
+			seen[n] = true
+			result = append(result, n)
+		}
+	}
+	for _, n := range override {
+		if !seen[n] {
+			seen[n] = true
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
 // mergeOptionalBool returns override if non-nil, otherwise base.
 func mergeOptionalBool(base, override *bool) *bool {
 	if override != nil {
@@ -540,6 +1285,25 @@ func mergeOptionalBool(base, override *bool) *bool {
 	return base
 }
 
+// joinNonEmpty joins the non-empty parts with sep.
+func joinNonEmpty(sep string, parts ...string) string {
+	var kept []string
+	for _, p := range parts {
+		if p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+// mergeString returns override if non-empty, otherwise base.
+func mergeString(base, override string) string {
+	if override != "" {
+		return override
+	}
+	return base
+}
+
 // mergeInt returns override if non-zero, otherwise base.
 func mergeInt(base, override int) int {
 	if override != 0 {
@@ -24,6 +24,16 @@ type cleanNetworkConfig struct {
 	AllowLocalOutbound  *bool    `json:"allowLocalOutbound,omitempty"`
 	HTTPProxyPort       int      `json:"httpProxyPort,omitempty"`
 	SOCKSProxyPort      int      `json:"socksProxyPort,omitempty"`
+	HonorHostsFile      *bool    `json:"honorHostsFile,omitempty"`
+	DNSCacheTTL         int      `json:"dnsCacheTTL,omitempty"`
+	AlertOnNewDomain    bool     `json:"alertOnNewDomain,omitempty"`
+	AllowACMEChallenge  bool     `json:"allowACMEChallenge,omitempty"`
+	InspectTLS          bool     `json:"inspectTLS,omitempty"`
+	InspectTLSCACert    string   `json:"inspectTLSCACert,omitempty"`
+	InspectTLSCAKey     string   `json:"inspectTLSCAKey,omitempty"`
+	ValidateSPF         bool     `json:"validateSPF,omitempty"`
+	AllowedPorts        []int    `json:"allowedPorts,omitempty"`
+	DeniedPorts         []int    `json:"deniedPorts,omitempty"`
 }
 
 // cleanFilesystemConfig is used for JSON output with omitempty to skip empty fields.
@@ -32,10 +42,21 @@ type cleanFilesystemConfig struct {
 	WSLInterop      *bool    `json:"wslInterop,omitempty"`
 	AllowRead       []string `json:"allowRead,omitempty"`
 	AllowExecute    []string `json:"allowExecute,omitempty"`
+	DenyExecute     []string `json:"denyExecute,omitempty"`
 	DenyRead        []string `json:"denyRead,omitempty"`
 	AllowWrite      []string `json:"allowWrite,omitempty"`
 	DenyWrite       []string `json:"denyWrite,omitempty"`
 	AllowGitConfig  bool     `json:"allowGitConfig,omitempty"`
+
+	TrustedDangerousFiles       []string `json:"trustedDangerousFiles,omitempty"`
+	TrustedDangerousDirectories []string `json:"trustedDangerousDirectories,omitempty"`
+	UseFenceIgnore              bool     `json:"useFenceIgnore,omitempty"`
+
+	KernelWatchDenyRead      bool  `json:"kernelWatchDenyRead,omitempty"`
+	ProtectDeniedExecutables *bool `json:"protectDeniedExecutables,omitempty"`
+
+	ScratchDir       string `json:"scratchDir,omitempty"`
+	ScratchDirSizeMB int    `json:"scratchDirSizeMB,omitempty"`
 }
 
 // cleanCommandConfig is used for JSON output with omitempty to skip empty fields.
@@ -43,6 +64,10 @@ type cleanCommandConfig struct {
 	Deny        []string `json:"deny,omitempty"`
 	Allow       []string `json:"allow,omitempty"`
 	UseDefaults *bool    `json:"useDefaults,omitempty"`
+	ShadowMode  bool     `json:"shadowMode,omitempty"`
+
+	DenyOutputPatterns    []string `json:"denyOutputPatterns,omitempty"`
+	VerifyBinaryIntegrity bool     `json:"verifyBinaryIntegrity,omitempty"`
 }
 
 // cleanSSHConfig is used for JSON output with omitempty to skip empty fields.
@@ -57,20 +82,38 @@ type cleanSSHConfig struct {
 
 // cleanConfig is used for JSON output with fields in desired order and omitempty.
 type cleanConfig struct {
-	Extends    string                 `json:"extends,omitempty"`
-	AllowPty   bool                   `json:"allowPty,omitempty"`
-	Network    *cleanNetworkConfig    `json:"network,omitempty"`
-	Filesystem *cleanFilesystemConfig `json:"filesystem,omitempty"`
-	Command    *cleanCommandConfig    `json:"command,omitempty"`
-	SSH        *cleanSSHConfig        `json:"ssh,omitempty"`
+	Extends       string                 `json:"extends,omitempty"`
+	AllowPty      bool                   `json:"allowPty,omitempty"`
+	AllowGPU      bool                   `json:"allowGpu,omitempty"`
+	AllowKeychain bool                   `json:"allowKeychain,omitempty"`
+	Network       *cleanNetworkConfig    `json:"network,omitempty"`
+	Filesystem    *cleanFilesystemConfig `json:"filesystem,omitempty"`
+	Command       *cleanCommandConfig    `json:"command,omitempty"`
+	SSH           *cleanSSHConfig        `json:"ssh,omitempty"`
+	Shell         *ShellConfig           `json:"shell,omitempty"`
+
+	ResourceLimits *ResourceLimits `json:"resourceLimits,omitempty"`
+
+	EnforcementMode string `json:"enforcementMode,omitempty"`
+	AuditLogPath    string `json:"auditLogPath,omitempty"`
+	WebhookURL      string `json:"webhookUrl,omitempty"`
+
+	SandboxProfileExtra string `json:"sandboxProfileExtra,omitempty"`
 }
 
 // MarshalConfigJSON marshals a fence config to clean JSON, omitting empty arrays
 // and with fields in a logical order (extends first).
 func MarshalConfigJSON(cfg *Config) ([]byte, error) {
 	clean := cleanConfig{
-		Extends:  cfg.Extends,
-		AllowPty: cfg.AllowPty,
+		Extends:         cfg.Extends,
+		AllowPty:        cfg.AllowPty,
+		AllowGPU:        cfg.AllowGPU,
+		AllowKeychain:   cfg.AllowKeychain,
+		EnforcementMode: cfg.EnforcementMode,
+		AuditLogPath:    cfg.AuditLogPath,
+		WebhookURL:      cfg.WebhookURL,
+
+		SandboxProfileExtra: cfg.SandboxProfileExtra,
 	}
 
 	// Network config - only include if non-empty
@@ -83,6 +126,16 @@ func MarshalConfigJSON(cfg *Config) ([]byte, error) {
 		AllowLocalOutbound:  cfg.Network.AllowLocalOutbound,
 		HTTPProxyPort:       cfg.Network.HTTPProxyPort,
 		SOCKSProxyPort:      cfg.Network.SOCKSProxyPort,
+		HonorHostsFile:      cfg.Network.HonorHostsFile,
+		DNSCacheTTL:         cfg.Network.DNSCacheTTL,
+		AlertOnNewDomain:    cfg.Network.AlertOnNewDomain,
+		AllowACMEChallenge:  cfg.Network.AllowACMEChallenge,
+		InspectTLS:          cfg.Network.InspectTLS,
+		InspectTLSCACert:    cfg.Network.InspectTLSCACert,
+		InspectTLSCAKey:     cfg.Network.InspectTLSCAKey,
+		ValidateSPF:         cfg.Network.ValidateSPF,
+		AllowedPorts:        cfg.Network.AllowedPorts,
+		DeniedPorts:         cfg.Network.DeniedPorts,
 	}
 	if !isNetworkEmpty(network) {
 		clean.Network = &network
@@ -94,10 +147,21 @@ func MarshalConfigJSON(cfg *Config) ([]byte, error) {
 		WSLInterop:      cfg.Filesystem.WSLInterop,
 		AllowRead:       cfg.Filesystem.AllowRead,
 		AllowExecute:    cfg.Filesystem.AllowExecute,
+		DenyExecute:     cfg.Filesystem.DenyExecute,
 		DenyRead:        cfg.Filesystem.DenyRead,
 		AllowWrite:      cfg.Filesystem.AllowWrite,
 		DenyWrite:       cfg.Filesystem.DenyWrite,
 		AllowGitConfig:  cfg.Filesystem.AllowGitConfig,
+
+		TrustedDangerousFiles:       cfg.Filesystem.TrustedDangerousFiles,
+		TrustedDangerousDirectories: cfg.Filesystem.TrustedDangerousDirectories,
+		UseFenceIgnore:              cfg.Filesystem.UseFenceIgnore,
+
+		KernelWatchDenyRead:      cfg.Filesystem.KernelWatchDenyRead,
+		ProtectDeniedExecutables: cfg.Filesystem.ProtectDeniedExecutables,
+
+		ScratchDir:       cfg.Filesystem.ScratchDir,
+		ScratchDirSizeMB: cfg.Filesystem.ScratchDirSizeMB,
 	}
 	if !isFilesystemEmpty(filesystem) {
 		clean.Filesystem = &filesystem
@@ -108,6 +172,10 @@ func MarshalConfigJSON(cfg *Config) ([]byte, error) {
 		Deny:        cfg.Command.Deny,
 		Allow:       cfg.Command.Allow,
 		UseDefaults: cfg.Command.UseDefaults,
+		ShadowMode:  cfg.Command.ShadowMode,
+
+		DenyOutputPatterns:    cfg.Command.DenyOutputPatterns,
+		VerifyBinaryIntegrity: cfg.Command.VerifyBinaryIntegrity,
 	}
 	if !isCommandEmpty(command) {
 		clean.Command = &command
@@ -126,6 +194,16 @@ func MarshalConfigJSON(cfg *Config) ([]byte, error) {
 		clean.SSH = &ssh
 	}
 
+	// Shell - only include if set
+	if shell := cfg.Shell; shell != (ShellConfig{}) {
+		clean.Shell = &shell
+	}
+
+	// Resource limits - only include if any limit is set
+	if limits := cfg.ResourceLimits; !isResourceLimitsEmpty(limits) {
+		clean.ResourceLimits = &limits
+	}
+
 	return json.MarshalIndent(clean, "", "  ")
 }
 
@@ -137,7 +215,17 @@ func isNetworkEmpty(n cleanNetworkConfig) bool {
 		!n.AllowLocalBinding &&
 		n.AllowLocalOutbound == nil &&
 		n.HTTPProxyPort == 0 &&
-		n.SOCKSProxyPort == 0
+		n.SOCKSProxyPort == 0 &&
+		n.HonorHostsFile == nil &&
+		n.DNSCacheTTL == 0 &&
+		!n.AlertOnNewDomain &&
+		!n.AllowACMEChallenge &&
+		!n.InspectTLS &&
+		n.InspectTLSCACert == "" &&
+		n.InspectTLSCAKey == "" &&
+		!n.ValidateSPF &&
+		len(n.AllowedPorts) == 0 &&
+		len(n.DeniedPorts) == 0
 }
 
 func isFilesystemEmpty(f cleanFilesystemConfig) bool {
@@ -145,16 +233,27 @@ func isFilesystemEmpty(f cleanFilesystemConfig) bool {
 		f.WSLInterop == nil &&
 		len(f.AllowRead) == 0 &&
 		len(f.AllowExecute) == 0 &&
+		len(f.DenyExecute) == 0 &&
 		len(f.DenyRead) == 0 &&
 		len(f.AllowWrite) == 0 &&
 		len(f.DenyWrite) == 0 &&
-		!f.AllowGitConfig
+		!f.AllowGitConfig &&
+		len(f.TrustedDangerousFiles) == 0 &&
+		len(f.TrustedDangerousDirectories) == 0 &&
+		!f.UseFenceIgnore &&
+		!f.KernelWatchDenyRead &&
+		f.ProtectDeniedExecutables == nil &&
+		f.ScratchDir == "" &&
+		f.ScratchDirSizeMB == 0
 }
 
 func isCommandEmpty(c cleanCommandConfig) bool {
 	return len(c.Deny) == 0 &&
 		len(c.Allow) == 0 &&
-		c.UseDefaults == nil
+		c.UseDefaults == nil &&
+		!c.ShadowMode &&
+		len(c.DenyOutputPatterns) == 0 &&
+		!c.VerifyBinaryIntegrity
 }
 
 func isSSHEmpty(s cleanSSHConfig) bool {
@@ -166,6 +265,10 @@ func isSSHEmpty(s cleanSSHConfig) bool {
 		!s.InheritDeny
 }
 
+func isResourceLimitsEmpty(r ResourceLimits) bool {
+	return r == ResourceLimits{}
+}
+
 // FormatConfigForFile returns config JSON with optional header lines.
 func FormatConfigForFile(cfg *Config, opts FileWriteOptions) (string, error) {
 	data, err := MarshalConfigJSON(cfg)
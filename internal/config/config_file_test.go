@@ -82,3 +82,69 @@ func TestMarshalConfigJSON_IncludesExtendedFilesystemAndSSH(t *testing.T) {
 	assert.Contains(t, output, `"ls"`)
 	assert.Contains(t, output, `"inheritDeny": true`)
 }
+
+func TestMarshalConfigJSON_TrustedDangerous(t *testing.T) {
+	cfg := &Config{}
+	cfg.Filesystem.TrustedDangerousFiles = []string{".bashrc"}
+	cfg.Filesystem.TrustedDangerousDirectories = []string{".vscode"}
+
+	data, err := MarshalConfigJSON(cfg)
+	require.NoError(t, err)
+
+	output := string(data)
+	assert.Contains(t, output, `"trustedDangerousFiles": [`)
+	assert.Contains(t, output, `".bashrc"`)
+	assert.Contains(t, output, `"trustedDangerousDirectories": [`)
+	assert.Contains(t, output, `".vscode"`)
+}
+
+func TestMarshalConfigJSON_ResourceLimits(t *testing.T) {
+	data, err := MarshalConfigJSON(&Config{})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), `"resourceLimits"`)
+
+	cfg := &Config{}
+	cfg.ResourceLimits.MaxProcesses = 64
+	data, err = MarshalConfigJSON(cfg)
+	require.NoError(t, err)
+
+	output := string(data)
+	assert.Contains(t, output, `"resourceLimits"`)
+	assert.Contains(t, output, `"maxProcesses": 64`)
+	assert.NotContains(t, output, `"maxMemoryMB"`)
+}
+
+func TestMarshalConfigJSON_EnforcementMode(t *testing.T) {
+	cfg := &Config{EnforcementMode: EnforcementModeAudit, AuditLogPath: "~/.fence/audit.jsonl"}
+	data, err := MarshalConfigJSON(cfg)
+	require.NoError(t, err)
+
+	output := string(data)
+	assert.Contains(t, output, `"enforcementMode": "audit"`)
+	assert.Contains(t, output, `"auditLogPath": "~/.fence/audit.jsonl"`)
+}
+
+func TestMarshalConfigJSON_Shell(t *testing.T) {
+	data, err := MarshalConfigJSON(&Config{Shell: ShellConfig{Mode: "custom", Path: "/usr/local/bin/bash"}})
+	require.NoError(t, err)
+
+	output := string(data)
+	assert.Contains(t, output, `"shell": {`)
+	assert.Contains(t, output, `"mode": "custom"`)
+	assert.Contains(t, output, `"path": "/usr/local/bin/bash"`)
+	assert.NotContains(t, output, `"login"`)
+
+	data, err = MarshalConfigJSON(&Config{})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), `"shell"`)
+}
+
+func TestMarshalConfigJSON_WebhookURL(t *testing.T) {
+	data, err := MarshalConfigJSON(&Config{WebhookURL: "https://hooks.example.com/fence"})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"webhookUrl": "https://hooks.example.com/fence"`)
+
+	data, err = MarshalConfigJSON(&Config{})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), `"webhookUrl"`)
+}
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestValidateDomainPattern(t *testing.T) {
@@ -60,6 +64,27 @@ func TestMatchesDomain(t *testing.T) {
 		{"wildcard no match base domain", "example.com", "*.example.com", false},
 		{"wildcard no match different domain", "api.other.com", "*.example.com", false},
 		{"wildcard case insensitive", "API.Example.COM", "*.example.com", true},
+
+		// Label boundaries
+		{"allowed domain as prefix of attacker domain", "api.openai.com.evil.com", "api.openai.com", false},
+		{"wildcard base as prefix of attacker domain", "cdn.api.openai.com.evil.com", "*.api.openai.com", false},
+		{"wildcard subdomain match", "cdn.api.openai.com", "*.api.openai.com", true},
+		{"suffix without label separator", "evilapi.openai.com", "api.openai.com", false},
+		{"wildcard suffix without label separator", "evilapi.openai.com", "*.api.openai.com", false},
+		{"hyphenated lookalike", "api-openai-com.evil.com", "api.openai.com", false},
+		{"trailing dot exact match", "api.openai.com.", "api.openai.com", true},
+		{"trailing dot wildcard match", "cdn.api.openai.com.", "*.api.openai.com", true},
+
+		// Tagged patterns
+		{"tagged exact match", "api.openai.com", "tag:openai api.openai.com", true},
+		{"tagged wildcard match", "cdn.openai.com", "tag:openai *.openai.com", true},
+		{"tagged no match", "example.com", "tag:openai api.openai.com", false},
+		{"tag name is not a domain", "openai", "tag:openai api.openai.com", false},
+
+		// Protocol-restricted patterns
+		{"proto exact match", "api.openai.com", "proto:tcp api.openai.com", true},
+		{"tagged proto match", "api.openai.com", "tag:openai proto:tcp api.openai.com", true},
+		{"proto no match", "example.com", "proto:udp api.openai.com", false},
 	}
 
 	for _, tt := range tests {
@@ -72,6 +97,307 @@ func TestMatchesDomain(t *testing.T) {
 	}
 }
 
+func TestSplitDomainTag(t *testing.T) {
+	tests := []struct {
+		entry      string
+		wantTag    string
+		wantDomain string
+	}{
+		{"api.openai.com", "", "api.openai.com"},
+		{"tag:openai api.openai.com", "openai", "api.openai.com"},
+		{"tag:openai   *.openai.com", "openai", "*.openai.com"},
+		{" tag:ci\tgithub.com ", "ci", "github.com"},
+		{"tag:openai", "openai", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			tag, domain := SplitDomainTag(tt.entry)
+			if tag != tt.wantTag || domain != tt.wantDomain {
+				t.Errorf("SplitDomainTag(%q) = (%q, %q), want (%q, %q)", tt.entry, tag, domain, tt.wantTag, tt.wantDomain)
+			}
+		})
+	}
+}
+
+func TestSplitDomainProto(t *testing.T) {
+	tests := []struct {
+		entry      string
+		wantProto  string
+		wantDomain string
+	}{
+		{"api.openai.com", "", "api.openai.com"},
+		{"proto:tcp api.openai.com", "tcp", "api.openai.com"},
+		{"proto:UDP  *", "udp", "*"},
+		{"proto:icmp", "icmp", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			proto, domain := SplitDomainProto(tt.entry)
+			if proto != tt.wantProto || domain != tt.wantDomain {
+				t.Errorf("SplitDomainProto(%q) = (%q, %q), want (%q, %q)", tt.entry, proto, domain, tt.wantProto, tt.wantDomain)
+			}
+		})
+	}
+}
+
+func TestDomainProto(t *testing.T) {
+	tests := []struct {
+		entry   string
+		want    string
+		wantTCP bool
+	}{
+		{"example.com", "", true},
+		{"proto:tcp example.com", "tcp", true},
+		{"tag:dns proto:udp example.com", "udp", false},
+		{"proto:icmp example.com", "icmp", false},
+	}
+
+	for _, tt := range tests {
+		if got := DomainProto(tt.entry); got != tt.want {
+			t.Errorf("DomainProto(%q) = %q, want %q", tt.entry, got, tt.want)
+		}
+		if got := AppliesToTCP(tt.entry); got != tt.wantTCP {
+			t.Errorf("AppliesToTCP(%q) = %v, want %v", tt.entry, got, tt.wantTCP)
+		}
+		if got := BareDomain(tt.entry); got != "example.com" {
+			t.Errorf("BareDomain(%q) = %q, want example.com", tt.entry, got)
+		}
+	}
+}
+
+func TestRemoveDomainTags(t *testing.T) {
+	n := NetworkConfig{
+		AllowedDomains: []string{"tag:openai api.openai.com", "github.com", "tag:anthropic api.anthropic.com", "tag:openai *.openai.com"},
+		DeniedDomains:  []string{"tag:openai evil.openai.com", "sentry.io"},
+	}
+
+	n.RemoveDomainTags("openai")
+
+	wantAllowed := []string{"github.com", "tag:anthropic api.anthropic.com"}
+	// Tagged denies survive, so disabling a tag never allows more
+	wantDenied := []string{"tag:openai evil.openai.com", "sentry.io"}
+	if !slices.Equal(n.AllowedDomains, wantAllowed) {
+		t.Errorf("AllowedDomains = %v, want %v", n.AllowedDomains, wantAllowed)
+	}
+	if !slices.Equal(n.DeniedDomains, wantDenied) {
+		t.Errorf("DeniedDomains = %v, want %v", n.DeniedDomains, wantDenied)
+	}
+}
+
+func TestGroupDomainsByTag(t *testing.T) {
+	groups := GroupDomainsByTag([]string{"tag:openai api.openai.com", "github.com", "tag:openai *.openai.com"})
+
+	if want := []string{"api.openai.com", "*.openai.com"}; !slices.Equal(groups["openai"], want) {
+		t.Errorf("groups[openai] = %v, want %v", groups["openai"], want)
+	}
+	if want := []string{"github.com"}; !slices.Equal(groups[""], want) {
+		t.Errorf("groups[\"\"] = %v, want %v", groups[""], want)
+	}
+}
+
+func TestWritablePaths(t *testing.T) {
+	fs := FilesystemConfig{
+		AllowWrite: []string{
+			".",
+			"os:darwin:/private/tmp/build",
+			"os:linux:/run/user/1000/build",
+		},
+	}
+
+	tests := []struct {
+		goos string
+		want []string
+	}{
+		{"darwin", []string{".", "/private/tmp/build"}},
+		{"linux", []string{".", "/run/user/1000/build"}},
+		{"windows", []string{"."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goos, func(t *testing.T) {
+			orig := currentOS
+			currentOS = tt.goos
+			t.Cleanup(func() { currentOS = orig })
+
+			got := fs.WritablePaths()
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("WritablePaths() on %s = %v, want %v", tt.goos, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAtomicWritePaths(t *testing.T) {
+	orig := currentOS
+	currentOS = "linux"
+	t.Cleanup(func() { currentOS = orig })
+
+	fs := FilesystemConfig{
+		AllowWrite: []string{
+			"/workspace",
+			"/workspace/config.json atomic:true",
+			"os:linux:/workspace/state.json atomic:true",
+			"os:darwin:/workspace/mac.json atomic:true",
+		},
+	}
+
+	if got, want := fs.WritablePaths(), []string{"/workspace", "/workspace/config.json", "/workspace/state.json"}; !slices.Equal(got, want) {
+		t.Errorf("WritablePaths() = %v, want %v", got, want)
+	}
+	if got, want := fs.AtomicWritePaths(), []string{"/workspace/config.json", "/workspace/state.json"}; !slices.Equal(got, want) {
+		t.Errorf("AtomicWritePaths() = %v, want %v", got, want)
+	}
+}
+
+func TestConfigWarnings(t *testing.T) {
+	cfg := Config{
+		Filesystem: FilesystemConfig{
+			AllowWrite: []string{".", "os:linux:/run/user/1000", "os:plan9:/tmp/x"},
+		},
+	}
+
+	warnings := cfg.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("Warnings() = %v, want exactly one warning", warnings)
+	}
+	if !strings.Contains(warnings[0], "plan9") {
+		t.Errorf("warning %q should mention the unknown OS", warnings[0])
+	}
+}
+
+func TestConfigWarningsAuditMode(t *testing.T) {
+	cfg := Config{EnforcementMode: EnforcementModeAudit}
+	if !cfg.AuditMode() {
+		t.Fatal("AuditMode() = false, want true")
+	}
+	warnings := cfg.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "unsandboxed") {
+		t.Errorf("Warnings() = %v, want an audit mode warning", warnings)
+	}
+
+	var nilCfg *Config
+	if nilCfg.AuditMode() {
+		t.Error("AuditMode() on nil config = true, want false")
+	}
+}
+
+func TestConfigWarningsSandboxProfileExtra(t *testing.T) {
+	orig := currentOS
+	t.Cleanup(func() { currentOS = orig })
+	cfg := Config{SandboxProfileExtra: "(allow network-outbound)"}
+
+	currentOS = "linux"
+	if warnings := cfg.Warnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "sandboxProfileExtra") {
+		t.Errorf("Warnings() on linux = %v, want a sandboxProfileExtra warning", warnings)
+	}
+	currentOS = "darwin"
+	if warnings := cfg.Warnings(); len(warnings) != 0 {
+		t.Errorf("Warnings() on darwin = %v, want none", warnings)
+	}
+}
+
+func TestConfigWarningsPortsWithWildcard(t *testing.T) {
+	for _, tt := range []struct {
+		domains []string
+		want    bool
+	}{
+		{[]string{"*"}, true},
+		{[]string{"tag:open *"}, true},
+		{[]string{"proto:udp *"}, false},
+		{[]string{"*.example.com"}, false},
+	} {
+		cfg := Config{Network: NetworkConfig{AllowedDomains: tt.domains, AllowedPorts: []int{443}}}
+		got := slices.ContainsFunc(cfg.Warnings(), func(w string) bool {
+			return strings.Contains(w, "direct connections can use any port")
+		})
+		if got != tt.want {
+			t.Errorf("allowedDomains %v: port warning = %v, want %v", tt.domains, got, tt.want)
+		}
+	}
+}
+
+func TestConfigWarningsLoopbackWithoutLocalOutbound(t *testing.T) {
+	orig := currentOS
+	t.Cleanup(func() { currentOS = orig })
+	currentOS = "darwin"
+
+	cfg := Config{Network: NetworkConfig{AllowedDomains: []string{"::1", "github.com"}}}
+	if warnings := cfg.Warnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "allowLocalOutbound") {
+		t.Errorf("Warnings() = %v, want an allowLocalOutbound warning", warnings)
+	}
+
+	enabled := true
+	cfg.Network.AllowLocalOutbound = &enabled
+	if warnings := cfg.Warnings(); len(warnings) != 0 {
+		t.Errorf("Warnings() with allowLocalOutbound = %v, want none", warnings)
+	}
+
+	currentOS = "linux"
+	cfg.Network.AllowLocalOutbound = nil
+	if warnings := cfg.Warnings(); len(warnings) != 0 {
+		t.Errorf("Warnings() on linux = %v, want none", warnings)
+	}
+}
+
+func TestConfigWarningsTrustedDangerousFiles(t *testing.T) {
+	cfg := Config{
+		Filesystem: FilesystemConfig{
+			TrustedDangerousFiles:       []string{".bashrc", ".gitconfig", "testdata/.zshrc"},
+			TrustedDangerousDirectories: []string{".vscode", ".claude/commands"},
+		},
+	}
+
+	warnings := cfg.Warnings()
+	if len(warnings) != 2 {
+		t.Fatalf("Warnings() = %v, want two warnings", warnings)
+	}
+	if !strings.Contains(warnings[0], `".gitconfig"`) {
+		t.Errorf("warning %q should name .gitconfig", warnings[0])
+	}
+	if !strings.Contains(warnings[1], `"testdata/.zshrc" is a path`) {
+		t.Errorf("warning %q should flag the path entry", warnings[1])
+	}
+}
+
+func TestConfigWarningsDenyWriteOverlapsAllowWrite(t *testing.T) {
+	cfg := Config{
+		Filesystem: FilesystemConfig{
+			AllowWrite: []string{".", "/srv/data/", "/srv/logs"},
+			DenyWrite:  []string{"/srv/data", "/srv/logs/app.log"},
+		},
+	}
+
+	warnings := cfg.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("Warnings() = %v, want exactly one warning", warnings)
+	}
+	if !strings.Contains(warnings[0], `"/srv/data"`) || !strings.Contains(warnings[0], "takes precedence") {
+		t.Errorf("warning %q should name the overlapping entry", warnings[0])
+	}
+}
+
+func TestConfigWarningsProtoDomains(t *testing.T) {
+	orig := currentOS
+	currentOS = "linux"
+	t.Cleanup(func() { currentOS = orig })
+
+	cfg := Config{
+		Network: NetworkConfig{
+			AllowedDomains: []string{"proto:tcp api.openai.com", "proto:udp dns.example.com", "example.com"},
+		},
+	}
+
+	warnings := cfg.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("Warnings() = %v, want exactly one warning", warnings)
+	}
+	if !strings.Contains(warnings[0], "proto:udp dns.example.com") {
+		t.Errorf("warning %q should name the udp entry", warnings[0])
+	}
+}
+
 func TestConfigValidate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -102,6 +428,251 @@ func TestConfigValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid proto domains",
+			config: Config{
+				Network: NetworkConfig{
+					AllowedDomains: []string{"proto:tcp api.openai.com", "tag:dns proto:udp *"},
+					DeniedDomains:  []string{"proto:icmp example.com"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "wildcard allowed domain",
+			config: Config{
+				Network: NetworkConfig{
+					AllowedDomains: []string{"*"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown proto",
+			config: Config{
+				Network: NetworkConfig{
+					AllowedDomains: []string{"proto:sctp example.com"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "proto without domain",
+			config: Config{
+				Network: NetworkConfig{
+					AllowedDomains: []string{"proto:tcp"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid tagged domains",
+			config: Config{
+				Network: NetworkConfig{
+					AllowedDomains: []string{"tag:openai api.openai.com", "tag:openai *.openai.com"},
+					DeniedDomains:  []string{"tag:telemetry sentry.io"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "inspectTLS without CA",
+			config: Config{
+				Network: NetworkConfig{
+					InspectTLS:       true,
+					InspectTLSCACert: "/etc/fence/ca.pem",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "inspectTLS with CA",
+			config: Config{
+				Network: NetworkConfig{
+					InspectTLS:       true,
+					InspectTLSCACert: "/etc/fence/ca.pem",
+					InspectTLSCAKey:  "/etc/fence/ca-key.pem",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "tagged entry without domain",
+			config: Config{
+				Network: NetworkConfig{
+					AllowedDomains: []string{"tag:openai"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "tagged entry with empty tag",
+			config: Config{
+				Network: NetworkConfig{
+					AllowedDomains: []string{"tag: api.openai.com"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "tagged entry with invalid domain",
+			config: Config{
+				Network: NetworkConfig{
+					AllowedDomains: []string{"tag:openai https://api.openai.com"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "os-prefixed allowWrite without path",
+			config: Config{
+				Filesystem: FilesystemConfig{
+					AllowWrite: []string{"os:linux:"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "atomic allowWrite file",
+			config: Config{
+				Filesystem: FilesystemConfig{
+					AllowWrite: []string{"/workspace/config.json atomic:true"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "atomic allowWrite glob",
+			config: Config{
+				Filesystem: FilesystemConfig{
+					AllowWrite: []string{"/workspace/*.json atomic:true"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "scratch dir",
+			config: Config{
+				Filesystem: FilesystemConfig{ScratchDir: "/tmp/scratch", ScratchDirSizeMB: 256},
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative scratch dir",
+			config: Config{
+				Filesystem: FilesystemConfig{ScratchDir: "scratch"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "scratch dir at root",
+			config: Config{
+				Filesystem: FilesystemConfig{ScratchDir: "/"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "scratch size without dir",
+			config: Config{
+				Filesystem: FilesystemConfig{ScratchDirSizeMB: 64},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative scratch size",
+			config: Config{
+				Filesystem: FilesystemConfig{ScratchDir: "/tmp/scratch", ScratchDirSizeMB: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative DNS cache TTL",
+			config: Config{
+				Network: NetworkConfig{DNSCacheTTL: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid maxArgs constraint",
+			config: Config{
+				Command: CommandConfig{
+					Allow: []string{"python3 maxArgs:x"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "maxArgs on deny rule",
+			config: Config{
+				Command: CommandConfig{
+					Deny: []string{"python3 maxArgs:1"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "workdir on deny rule",
+			config: Config{
+				Command: CommandConfig{
+					Deny: []string{"workdir:/tmp npm publish"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "reason on deny rule",
+			config: Config{
+				Command: CommandConfig{
+					Deny: []string{"curl reason:Use fetch() instead"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "when on deny rule",
+			config: Config{
+				Command: CommandConfig{
+					Deny: []string{"npm publish when:git-tag-matches:v*"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "reason on allow rule",
+			config: Config{
+				Command: CommandConfig{
+					Allow: []string{"curl reason:Trusted"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "priority on deny rule",
+			config: Config{
+				Command: CommandConfig{
+					Deny: []string{"npm run deploy priority:10"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid denyOutputPatterns regex",
+			config: Config{
+				Command: CommandConfig{
+					DenyOutputPatterns: []string{"AKIA[0-9A-Z"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid denyOutputPatterns",
+			config: Config{
+				Command: CommandConfig{
+					DenyOutputPatterns: []string{"AKIA[0-9A-Z]{16}"},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "invalid denied domain",
 			config: Config{
@@ -183,6 +754,112 @@ func TestConfigValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid ports",
+			config: Config{
+				Network: NetworkConfig{
+					AllowedPorts: []int{80, 443},
+					DeniedPorts:  []int{9418},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "port out of range",
+			config: Config{
+				Network: NetworkConfig{
+					DeniedPorts: []int{70000},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid denyExecute",
+			config: Config{
+				Filesystem: FilesystemConfig{
+					DenyExecute: []string{"curl", "/usr/bin/wget"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "glob in denyExecute",
+			config: Config{
+				Filesystem: FilesystemConfig{
+					DenyExecute: []string{"/usr/bin/*"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid resource limits",
+			config: Config{
+				ResourceLimits: ResourceLimits{MaxMemoryMB: 2048, MaxCPUPercent: 150, MaxProcesses: 256, MaxOpenFiles: 1024, MaxWallSeconds: 600},
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative resource limit",
+			config: Config{
+				ResourceLimits: ResourceLimits{MaxOpenFiles: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "audit enforcement mode",
+			config:  Config{EnforcementMode: EnforcementModeAudit, AuditLogPath: "/tmp/fence-audit.jsonl"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid enforcement mode",
+			config:  Config{EnforcementMode: "permissive"},
+			wantErr: true,
+		},
+		{
+			name:    "custom shell",
+			config:  Config{Shell: ShellConfig{Mode: "custom", Path: "/usr/local/bin/bash"}},
+			wantErr: false,
+		},
+		{
+			name:    "custom shell without path",
+			config:  Config{Shell: ShellConfig{Mode: "custom"}},
+			wantErr: true,
+		},
+		{
+			name:    "relative shell path",
+			config:  Config{Shell: ShellConfig{Mode: "custom", Path: "bin/bash"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid shell mode",
+			config:  Config{Shell: ShellConfig{Mode: "login"}},
+			wantErr: true,
+		},
+		{
+			name:    "https webhook URL",
+			config:  Config{WebhookURL: "https://hooks.example.com/fence"},
+			wantErr: false,
+		},
+		{
+			name:    "webhook URL with unsupported scheme",
+			config:  Config{WebhookURL: "ftp://hooks.example.com/fence"},
+			wantErr: true,
+		},
+		{
+			name:    "webhook URL without host",
+			config:  Config{WebhookURL: "https:///fence"},
+			wantErr: true,
+		},
+		{
+			name:    "sandbox profile extra rules",
+			config:  Config{SandboxProfileExtra: `(allow mach-lookup (global-name "com.apple.SecurityServer"))`},
+			wantErr: false,
+		},
+		{
+			name:    "sandbox profile extra with unbalanced parens",
+			config:  Config{SandboxProfileExtra: `(allow file-read* (subpath "/opt")`},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -397,6 +1074,60 @@ func TestLoad(t *testing.T) {
 	}
 }
 
+func TestLoadConfigBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantNil bool
+		wantErr string
+	}{
+		{
+			name: "JSONC comments are stripped",
+			data: `{
+  // Allow the registry
+  "network": {"allowedDomains": ["registry.npmjs.org"]}, /* trailing */
+}`,
+		},
+		{name: "empty input", data: "", wantNil: true},
+		{name: "whitespace only", data: " \n\t", wantNil: true},
+		{name: "invalid JSON", data: "{invalid json}", wantErr: "invalid JSON in config file"},
+		{name: "invalid config", data: `{"network":{"allowedDomains":["*.com"]}}`, wantErr: "invalid configuration"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, load := range []func() (*Config, error){
+				func() (*Config, error) { return LoadConfigBytes([]byte(tt.data)) },
+				func() (*Config, error) { return LoadConfigReader(strings.NewReader(tt.data)) },
+			} {
+				cfg, err := load()
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if (cfg == nil) != tt.wantNil {
+					t.Fatalf("cfg = %v, wantNil %v", cfg, tt.wantNil)
+				}
+				if cfg != nil && !slices.Equal(cfg.Network.AllowedDomains, []string{"registry.npmjs.org"}) {
+					t.Errorf("allowedDomains = %v", cfg.Network.AllowedDomains)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigReaderError(t *testing.T) {
+	_, err := LoadConfigReader(iotest.ErrReader(errors.New("boom")))
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("LoadConfigReader() error = %v, want read error", err)
+	}
+}
+
 func TestDefaultConfigPath(t *testing.T) {
 	path := DefaultConfigPath()
 	if path == "" {
@@ -499,6 +1230,7 @@ func TestMerge(t *testing.T) {
 	t.Run("merge boolean flags", func(t *testing.T) {
 		base := &Config{
 			AllowPty: false,
+			AllowGPU: true,
 			Network: NetworkConfig{
 				AllowLocalBinding: true,
 			},
@@ -514,6 +1246,12 @@ func TestMerge(t *testing.T) {
 		if !result.AllowPty {
 			t.Error("expected AllowPty to be true (from override)")
 		}
+		if !result.AllowGPU {
+			t.Error("expected AllowGPU to be true (from base)")
+		}
+		if result.AllowKeychain {
+			t.Error("expected AllowKeychain to stay false")
+		}
 		if !result.Network.AllowLocalBinding {
 			t.Error("expected AllowLocalBinding to be true (from base)")
 		}
@@ -725,6 +1463,23 @@ func TestMerge(t *testing.T) {
 		}
 	})
 
+	t.Run("merge honorHostsFile", func(t *testing.T) {
+		base := &Config{Network: NetworkConfig{HonorHostsFile: boolPtr(false)}}
+		result := Merge(base, &Config{})
+		if result.Network.HonorsHostsFile() {
+			t.Error("expected honorHostsFile false from base")
+		}
+
+		result = Merge(base, &Config{Network: NetworkConfig{HonorHostsFile: boolPtr(true)}})
+		if !result.Network.HonorsHostsFile() {
+			t.Error("expected honorHostsFile true from override")
+		}
+
+		if !Merge(nil, &Config{}).Network.HonorsHostsFile() {
+			t.Error("expected honorHostsFile to default to true")
+		}
+	})
+
 	t.Run("override ports", func(t *testing.T) {
 		base := &Config{
 			Network: NetworkConfig{
@@ -747,6 +1502,78 @@ func TestMerge(t *testing.T) {
 			t.Errorf("expected SOCKSProxyPort 1080, got %d", result.Network.SOCKSProxyPort)
 		}
 	})
+
+	t.Run("merge ports", func(t *testing.T) {
+		base := &Config{Network: NetworkConfig{AllowedPorts: []int{443}, DeniedPorts: []int{22}}}
+		override := &Config{Network: NetworkConfig{AllowedPorts: []int{80, 443}, DeniedPorts: []int{9418}}}
+		result := Merge(base, override)
+
+		if !slices.Equal(result.Network.AllowedPorts, []int{443, 80}) {
+			t.Errorf("expected AllowedPorts [443 80], got %v", result.Network.AllowedPorts)
+		}
+		if !slices.Equal(result.Network.DeniedPorts, []int{22, 9418}) {
+			t.Errorf("expected DeniedPorts [22 9418], got %v", result.Network.DeniedPorts)
+		}
+
+		result = MergeWithOptions(base, override, MergeOptions{DenyStrategy: DenyStrategyOverride})
+		if !slices.Equal(result.Network.DeniedPorts, []int{9418}) {
+			t.Errorf("expected override DeniedPorts [9418], got %v", result.Network.DeniedPorts)
+		}
+	})
+
+	t.Run("override resource limits", func(t *testing.T) {
+		base := &Config{ResourceLimits: ResourceLimits{MaxMemoryMB: 1024, MaxProcesses: 128}}
+		override := &Config{ResourceLimits: ResourceLimits{MaxMemoryMB: 4096, MaxWallSeconds: 60}}
+		result := Merge(base, override)
+
+		want := ResourceLimits{MaxMemoryMB: 4096, MaxProcesses: 128, MaxWallSeconds: 60}
+		if result.ResourceLimits != want {
+			t.Errorf("expected ResourceLimits %+v, got %+v", want, result.ResourceLimits)
+		}
+	})
+
+	t.Run("override enforcement mode", func(t *testing.T) {
+		base := &Config{EnforcementMode: EnforcementModeAudit, AuditLogPath: "/tmp/base.jsonl"}
+		result := Merge(base, &Config{AuditLogPath: "/tmp/override.jsonl"})
+		if result.EnforcementMode != EnforcementModeAudit || result.AuditLogPath != "/tmp/override.jsonl" {
+			t.Errorf("expected audit mode logging to override path, got %q %q", result.EnforcementMode, result.AuditLogPath)
+		}
+
+		result = Merge(base, &Config{EnforcementMode: EnforcementModeEnforce})
+		if result.AuditMode() {
+			t.Error("expected override to switch back to enforce mode")
+		}
+	})
+
+	t.Run("merge shell", func(t *testing.T) {
+		base := &Config{Shell: ShellConfig{Mode: "custom", Path: "/opt/secure/sh", Login: true}}
+		result := Merge(base, &Config{Shell: ShellConfig{Path: "/usr/local/bin/bash"}})
+		want := ShellConfig{Mode: "custom", Path: "/usr/local/bin/bash", Login: true}
+		if result.Shell != want {
+			t.Errorf("expected Shell %+v, got %+v", want, result.Shell)
+		}
+	})
+
+	t.Run("inherit webhook URL", func(t *testing.T) {
+		base := &Config{WebhookURL: "https://hooks.example.com/base"}
+		if result := Merge(base, &Config{}); result.WebhookURL != base.WebhookURL {
+			t.Errorf("expected webhook URL to be inherited, got %q", result.WebhookURL)
+		}
+		override := &Config{WebhookURL: "https://hooks.example.com/override"}
+		if result := Merge(base, override); result.WebhookURL != override.WebhookURL {
+			t.Errorf("expected override webhook URL, got %q", result.WebhookURL)
+		}
+	})
+
+	t.Run("append sandbox profile extra", func(t *testing.T) {
+		base := &Config{SandboxProfileExtra: "(allow a)"}
+		if result := Merge(base, &Config{}); result.SandboxProfileExtra != "(allow a)" {
+			t.Errorf("expected sandboxProfileExtra to be inherited, got %q", result.SandboxProfileExtra)
+		}
+		if result := Merge(base, &Config{SandboxProfileExtra: "(deny b)"}); result.SandboxProfileExtra != "(allow a)\n(deny b)" {
+			t.Errorf("expected sandboxProfileExtra rules to accumulate, got %q", result.SandboxProfileExtra)
+		}
+	})
 }
 
 func boolPtr(b bool) *bool {
@@ -894,6 +1721,110 @@ func TestSSHConfigValidation(t *testing.T) {
 	}
 }
 
+func TestMergeFilesystemSlices(t *testing.T) {
+	base := &Config{Filesystem: FilesystemConfig{
+		AllowRead:    []string{"/r1"},
+		AllowExecute: []string{"/x1"},
+		DenyRead:     []string{"/dr1"},
+		AllowWrite:   []string{"/w1"},
+		DenyWrite:    []string{"/dw1"},
+
+		TrustedDangerousFiles: []string{".bashrc"},
+	}}
+	override := &Config{Filesystem: FilesystemConfig{
+		AllowRead:    []string{"/r1", "/r2"},
+		AllowExecute: []string{"/x2"},
+		DenyRead:     []string{"/dr2", "/dr2"},
+		AllowWrite:   []string{"/w2"},
+		DenyWrite:    []string{"/dw1", "/dw2"},
+
+		TrustedDangerousFiles:       []string{".zshrc"},
+		TrustedDangerousDirectories: []string{".vscode"},
+	}}
+
+	fs := Merge(base, override).Filesystem
+	tests := []struct {
+		field string
+		got   []string
+		want  []string
+	}{
+		{"allowRead", fs.AllowRead, []string{"/r1", "/r2"}},
+		{"allowExecute", fs.AllowExecute, []string{"/x1", "/x2"}},
+		{"denyRead", fs.DenyRead, []string{"/dr1", "/dr2"}},
+		{"allowWrite", fs.AllowWrite, []string{"/w1", "/w2"}},
+		{"denyWrite", fs.DenyWrite, []string{"/dw1", "/dw2"}},
+		{"trustedDangerousFiles", fs.TrustedDangerousFiles, []string{".bashrc", ".zshrc"}},
+		{"trustedDangerousDirectories", fs.TrustedDangerousDirectories, []string{".vscode"}},
+	}
+	for _, tt := range tests {
+		if !slices.Equal(tt.got, tt.want) {
+			t.Errorf("merged %s = %v, want %v", tt.field, tt.got, tt.want)
+		}
+	}
+}
+
+func TestMergeDeduplicatesAndValidates(t *testing.T) {
+	base := &Config{Extends: "code", Network: NetworkConfig{AllowedDomains: []string{"github.com"}}}
+	override := &Config{Extends: "./local.json", Network: NetworkConfig{AllowedDomains: []string{"example.com", "github.com", "example.com"}}}
+
+	result := Merge(base, override)
+	if want := []string{"github.com", "example.com"}; !slices.Equal(result.Network.AllowedDomains, want) {
+		t.Errorf("AllowedDomains = %v, want %v", result.Network.AllowedDomains, want)
+	}
+	// Inheritance is resolved by the merge, so neither extends survives
+	if result.Extends != "" {
+		t.Errorf("Extends = %q, want it cleared", result.Extends)
+	}
+	if errs := Validate(result); len(errs) != 0 {
+		t.Errorf("Validate(merged) = %v, want no errors", errs)
+	}
+
+	// Duplicates within override are removed even when base is empty
+	result = Merge(&Config{}, override)
+	if want := []string{"example.com", "github.com"}; !slices.Equal(result.Network.AllowedDomains, want) {
+		t.Errorf("AllowedDomains = %v, want %v", result.Network.AllowedDomains, want)
+	}
+}
+
+func TestMergeWithOptionsDenyStrategy(t *testing.T) {
+	base := &Config{
+		Network:    NetworkConfig{DeniedDomains: []string{"evil.com"}},
+		Filesystem: FilesystemConfig{DenyWrite: []string{"/etc"}},
+		Command:    CommandConfig{Deny: []string{"git push", "npm publish"}},
+		SSH:        SSHConfig{DeniedHosts: []string{"prod.example.com"}},
+	}
+	override := &Config{
+		Command: CommandConfig{Deny: []string{"npm publish"}, Allow: []string{"ls"}},
+	}
+
+	union := MergeWithOptions(base, override, MergeOptions{DenyStrategy: DenyStrategyUnion})
+	if want := []string{"git push", "npm publish"}; !slices.Equal(union.Command.Deny, want) {
+		t.Errorf("union Command.Deny = %v, want %v", union.Command.Deny, want)
+	}
+	if got := Merge(base, override).Command.Deny; !slices.Equal(got, union.Command.Deny) {
+		t.Errorf("Merge() Command.Deny = %v, want the union strategy result %v", got, union.Command.Deny)
+	}
+
+	replaced := MergeWithOptions(base, override, MergeOptions{DenyStrategy: DenyStrategyOverride})
+	if want := []string{"npm publish"}; !slices.Equal(replaced.Command.Deny, want) {
+		t.Errorf("override Command.Deny = %v, want %v", replaced.Command.Deny, want)
+	}
+	// Deny lists the override does not set are kept from base
+	if want := []string{"evil.com"}; !slices.Equal(replaced.Network.DeniedDomains, want) {
+		t.Errorf("override DeniedDomains = %v, want %v", replaced.Network.DeniedDomains, want)
+	}
+	if want := []string{"/etc"}; !slices.Equal(replaced.Filesystem.DenyWrite, want) {
+		t.Errorf("override DenyWrite = %v, want %v", replaced.Filesystem.DenyWrite, want)
+	}
+	if want := []string{"prod.example.com"}; !slices.Equal(replaced.SSH.DeniedHosts, want) {
+		t.Errorf("override SSH.DeniedHosts = %v, want %v", replaced.SSH.DeniedHosts, want)
+	}
+	// Allow lists are still unioned
+	if want := []string{"ls"}; !slices.Equal(replaced.Command.Allow, want) {
+		t.Errorf("override Command.Allow = %v, want %v", replaced.Command.Allow, want)
+	}
+}
+
 func TestMergeSSHConfig(t *testing.T) {
 	t.Run("merge SSH allowed hosts", func(t *testing.T) {
 		base := &Config{
@@ -959,3 +1890,50 @@ func TestMergeSSHConfig(t *testing.T) {
 		}
 	})
 }
+
+func TestEffectiveAllowedDomains(t *testing.T) {
+	n := NetworkConfig{AllowedDomains: []string{"github.com", "acme.zerossl.com"}}
+
+	got := n.EffectiveAllowedDomains()
+	for _, d := range ACMEDomains {
+		if d != "acme.zerossl.com" && slices.Contains(got, d) {
+			t.Errorf("expected %s to be absent when allowACMEChallenge is false", d)
+		}
+	}
+
+	n.AllowACMEChallenge = true
+	got = n.EffectiveAllowedDomains()
+	for _, d := range ACMEDomains {
+		if !slices.Contains(got, d) {
+			t.Errorf("expected %s in effective allowed domains, got %v", d, got)
+		}
+	}
+	if got[0] != "github.com" {
+		t.Errorf("expected configured domains first, got %v", got)
+	}
+	if n := len(got); n != len(ACMEDomains)+1 {
+		t.Errorf("expected duplicates to be merged, got %d entries: %v", n, got)
+	}
+	if len(n.AllowedDomains) != 2 {
+		t.Errorf("AllowedDomains was modified: %v", n.AllowedDomains)
+	}
+
+	merged := Merge(&Config{Network: NetworkConfig{AllowACMEChallenge: true}}, &Config{})
+	if !merged.Network.AllowACMEChallenge {
+		t.Error("expected allowACMEChallenge to survive merge")
+	}
+}
+
+func TestGetDefaultDeniedCommands(t *testing.T) {
+	got := GetDefaultDeniedCommands()
+	for _, want := range []string{"base64", "shutdown"} {
+		if !slices.Contains(got, want) {
+			t.Errorf("GetDefaultDeniedCommands() missing %q", want)
+		}
+	}
+
+	got[0] = "modified"
+	if DefaultDeniedCommands[0] == "modified" {
+		t.Error("GetDefaultDeniedCommands() should return a copy")
+	}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ChangeType describes how a field differs between two configs.
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeRemoved  ChangeType = "removed"
+	ChangeModified ChangeType = "modified"
+)
+
+// FieldChange is a single difference found by Diff. For slice fields each
+// added or removed element is its own change, with Field naming the slice.
+type FieldChange struct {
+	Field      string // Dot path using JSON names, e.g. "network.allowedDomains"
+	Old        any    // Previous value; nil for ChangeAdded
+	New        any    // New value; nil for ChangeRemoved
+	ChangeType ChangeType
+}
+
+// securityRelevantFields widen or narrow what a sandboxed command can reach.
+// An entry ending in ".*" covers every field below it.
+var securityRelevantFields = []string{
+	"allowGpu",
+	"allowKeychain",
+	"sandboxProfileExtra",
+	"network.allowedDomains",
+	"network.deniedDomains",
+	"network.allowedPorts",
+	"network.deniedPorts",
+	"network.allowUnixSockets",
+	"network.allowAllUnixSockets",
+	"network.allowLocalBinding",
+	"network.allowLocalOutbound",
+	"filesystem.defaultDenyRead",
+	"filesystem.allowRead",
+	"filesystem.allowExecute",
+	"filesystem.allowWrite",
+	"filesystem.denyWrite",
+	"filesystem.denyRead",
+	"filesystem.denyExecute",
+	"filesystem.allowGitConfig",
+	"filesystem.useFenceIgnore",
+	"command.allow",
+	"command.deny",
+	"command.useDefaults",
+	"ssh.*",
+}
+
+// SecurityRelevant reports whether the change affects network, filesystem,
+// command or SSH access rules that reviewers should look at closely.
+func (c FieldChange) SecurityRelevant() bool {
+	for _, field := range securityRelevantFields {
+		if prefix, ok := strings.CutSuffix(field, "*"); ok && strings.HasPrefix(c.Field, prefix) {
+			return true
+		}
+		if c.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the change as one line of "fence diff" output.
+func (c FieldChange) String() string {
+	var line string
+	switch c.ChangeType {
+	case ChangeAdded:
+		line = fmt.Sprintf("+ %s: %s", c.Field, formatDiffValue(c.New))
+	case ChangeRemoved:
+		line = fmt.Sprintf("- %s: %s", c.Field, formatDiffValue(c.Old))
+	default:
+		line = fmt.Sprintf("~ %s: %s -> %s", c.Field, formatDiffValue(c.Old), formatDiffValue(c.New))
+	}
+	if c.SecurityRelevant() {
+		line += "  [security]"
+	}
+	return line
+}
+
+func formatDiffValue(v any) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Diff compares two configs field by field, in struct order. A nil config
+// is treated as an empty one.
+func Diff(a, b *Config) []FieldChange {
+	if a == nil {
+		a = &Config{}
+	}
+	if b == nil {
+		b = &Config{}
+	}
+	return diffStruct("", reflect.ValueOf(*a), reflect.ValueOf(*b))
+}
+
+func diffStruct(prefix string, a, b reflect.Value) []FieldChange {
+	var changes []FieldChange
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		changes = append(changes, diffValue(name, a.Field(i), b.Field(i))...)
+	}
+	return changes
+}
+
+func diffValue(name string, a, b reflect.Value) []FieldChange {
+	switch a.Kind() {
+	case reflect.Struct:
+		return diffStruct(name, a, b)
+	case reflect.Pointer:
+		switch {
+		case a.IsNil() && b.IsNil():
+			return nil
+		case a.IsNil():
+			return []FieldChange{{Field: name, New: b.Elem().Interface(), ChangeType: ChangeAdded}}
+		case b.IsNil():
+			return []FieldChange{{Field: name, Old: a.Elem().Interface(), ChangeType: ChangeRemoved}}
+		default:
+			return diffValue(name, a.Elem(), b.Elem())
+		}
+	case reflect.Slice:
+		return diffSlice(name, a, b)
+	default:
+		if a.Interface() == b.Interface() {
+			return nil
+		}
+		return []FieldChange{{Field: name, Old: a.Interface(), New: b.Interface(), ChangeType: ChangeModified}}
+	}
+}
+
+// diffSlice reports elements removed from a, then elements added in b.
+// Reordering alone is not a change.
+func diffSlice(name string, a, b reflect.Value) []FieldChange {
+	contains := func(s reflect.Value, v any) bool {
+		for i := 0; i < s.Len(); i++ {
+			if reflect.DeepEqual(s.Index(i).Interface(), v) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var changes []FieldChange
+	for i := 0; i < a.Len(); i++ {
+		if v := a.Index(i).Interface(); !contains(b, v) {
+			changes = append(changes, FieldChange{Field: name, Old: v, ChangeType: ChangeRemoved})
+		}
+	}
+	for i := 0; i < b.Len(); i++ {
+		if v := b.Index(i).Interface(); !contains(a, v) {
+			changes = append(changes, FieldChange{Field: name, New: v, ChangeType: ChangeAdded})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	enabled := true
+
+	a := &Config{
+		Extends: "code",
+		Network: NetworkConfig{
+			AllowedDomains: []string{"github.com", "npmjs.org"},
+			HTTPProxyPort:  3128,
+		},
+		Filesystem: FilesystemConfig{DenyWrite: []string{"/etc"}},
+	}
+	b := &Config{
+		Extends: "code",
+		Network: NetworkConfig{
+			AllowedDomains: []string{"npmjs.org", "github.com", "example.com"},
+			HTTPProxyPort:  8080,
+			HonorHostsFile: &enabled,
+		},
+		Filesystem: FilesystemConfig{},
+		AllowPty:   true,
+	}
+
+	want := []FieldChange{
+		{Field: "network.allowedDomains", New: "example.com", ChangeType: ChangeAdded},
+		{Field: "network.httpProxyPort", Old: 3128, New: 8080, ChangeType: ChangeModified},
+		{Field: "network.honorHostsFile", New: true, ChangeType: ChangeAdded},
+		{Field: "filesystem.denyWrite", Old: "/etc", ChangeType: ChangeRemoved},
+		{Field: "allowPty", Old: false, New: true, ChangeType: ChangeModified},
+	}
+	if got := Diff(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() =\n%v\nwant\n%v", got, want)
+	}
+
+	if got := Diff(b, b); len(got) != 0 {
+		t.Errorf("Diff(b, b) = %v, want no changes", got)
+	}
+}
+
+func TestDiffPointerTransitions(t *testing.T) {
+	off := false
+	on := true
+
+	tests := []struct {
+		name string
+		a, b *bool
+		want []FieldChange
+	}{
+		{"nil to set", nil, &off, []FieldChange{{Field: "command.useDefaults", New: false, ChangeType: ChangeAdded}}},
+		{"set to nil", &on, nil, []FieldChange{{Field: "command.useDefaults", Old: true, ChangeType: ChangeRemoved}}},
+		{"value change", &on, &off, []FieldChange{{Field: "command.useDefaults", Old: true, New: false, ChangeType: ChangeModified}}},
+		{"both nil", nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(&Config{Command: CommandConfig{UseDefaults: tt.a}}, &Config{Command: CommandConfig{UseDefaults: tt.b}})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldChangeString(t *testing.T) {
+	tests := []struct {
+		change FieldChange
+		want   string
+	}{
+		{FieldChange{Field: "network.allowedDomains", New: "example.com", ChangeType: ChangeAdded}, `+ network.allowedDomains: "example.com"  [security]`},
+		{FieldChange{Field: "filesystem.denyWrite", Old: "/etc", ChangeType: ChangeRemoved}, `- filesystem.denyWrite: "/etc"  [security]`},
+		{FieldChange{Field: "network.httpProxyPort", Old: 0, New: 3128, ChangeType: ChangeModified}, `~ network.httpProxyPort: 0 -> 3128`},
+	}
+	for _, tt := range tests {
+		if got := tt.change.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+		if strings.Contains(tt.want, "[security]") != tt.change.SecurityRelevant() {
+			t.Errorf("SecurityRelevant() for %s = %v", tt.change.Field, tt.change.SecurityRelevant())
+		}
+	}
+}
+
+func TestFieldChangeSecurityRelevant(t *testing.T) {
+	for _, field := range []string{
+		"command.useDefaults",
+		"network.allowAllUnixSockets",
+		"network.allowUnixSockets",
+		"network.allowLocalBinding",
+		"network.allowLocalOutbound",
+		"filesystem.allowRead",
+		"filesystem.allowExecute",
+		"filesystem.defaultDenyRead",
+		"filesystem.allowGitConfig",
+		"ssh.allowedHosts",
+		"ssh.allowAllCommands",
+		"allowKeychain",
+		"allowGpu",
+		"sandboxProfileExtra",
+	} {
+		if !(FieldChange{Field: field}).SecurityRelevant() {
+			t.Errorf("%s should be security relevant", field)
+		}
+	}
+	for _, field := range []string{"network.httpProxyPort", "shell.mode", "sshx.allowedHosts"} {
+		if (FieldChange{Field: field}).SecurityRelevant() {
+			t.Errorf("%s should not be security relevant", field)
+		}
+	}
+}
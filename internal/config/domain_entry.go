@@ -0,0 +1,118 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// DomainEntryKind is the kind of pattern in an allowedDomains/deniedDomains entry.
+type DomainEntryKind string
+
+const (
+	// DomainExact matches one hostname, e.g. "api.github.com".
+	DomainExact DomainEntryKind = "exact"
+	// DomainGlob matches subdomains ("*.example.com") or, for "*", everything.
+	DomainGlob DomainEntryKind = "glob"
+	// DomainCIDR matches destination addresses in an IP range, e.g. "10.10.0.0/16".
+	DomainCIDR DomainEntryKind = "cidr"
+	// DomainIP matches one IPv4 or IPv6 address, e.g. "::1" or "[::1]".
+	DomainIP DomainEntryKind = "ip"
+)
+
+// DomainEntry is a parsed allowedDomains/deniedDomains entry.
+type DomainEntry struct {
+	Tag     string          // From a "tag:<name>" prefix, or ""
+	Proto   string          // From a "proto:<protocol>" prefix, or "" for every protocol
+	Kind    DomainEntryKind // Which of Pattern or Network applies
+	Pattern string          // Hostname pattern with prefixes removed
+	Network *net.IPNet      // Address range for DomainCIDR entries
+	IP      net.IP          // Address for DomainIP entries
+}
+
+// ParseDomainEntry parses and validates an allowedDomains/deniedDomains
+// entry, including optional "tag:<name>" and "proto:<protocol>" prefixes.
+// There is no regular expression syntax for domain entries, so an entry is
+// either an exact name, a glob, an IP address or a CIDR block. IPv6
+// addresses may be written with or without brackets.
+func ParseDomainEntry(s string) (DomainEntry, error) {
+	tag, domain := SplitDomainTag(s)
+	if strings.HasPrefix(strings.TrimSpace(s), domainTagPrefix) {
+		if tag == "" {
+			return DomainEntry{}, errors.New("empty tag name")
+		}
+		if domain == "" {
+			return DomainEntry{}, errors.New("tagged entry is missing a domain")
+		}
+	}
+
+	entry := DomainEntry{Tag: tag}
+	if strings.HasPrefix(domain, domainProtoPrefix) {
+		proto, rest := SplitDomainProto(domain)
+		if !slices.Contains(DomainProtocols, proto) {
+			return DomainEntry{}, fmt.Errorf("unknown protocol %q (expected one of %v)", proto, DomainProtocols)
+		}
+		if rest == "" {
+			return DomainEntry{}, errors.New("proto entry is missing a domain")
+		}
+		entry.Proto = proto
+		domain = rest
+	}
+	entry.Pattern = domain
+
+	switch {
+	case domain == "*":
+		// "*" enables relaxed network mode
+		entry.Kind = DomainGlob
+	case strings.Contains(domain, "/"):
+		_, network, err := net.ParseCIDR(domain)
+		if err != nil {
+			return DomainEntry{}, errors.New("domain pattern cannot contain protocol, path, or port (use a.b.c.d/n for a CIDR block)")
+		}
+		entry.Kind = DomainCIDR
+		entry.Network = network
+	case parseBracketedIP(domain) != nil:
+		entry.Kind = DomainIP
+		entry.IP = parseBracketedIP(domain)
+	default:
+		if err := validateDomainPattern(domain); err != nil {
+			return DomainEntry{}, err
+		}
+		entry.Kind = DomainExact
+		if strings.HasPrefix(domain, "*.") {
+			entry.Kind = DomainGlob
+		}
+	}
+	return entry, nil
+}
+
+// Matches reports whether host matches the entry. A CIDR entry only matches
+// an IP address literal; use ContainsIP for resolved addresses.
+func (e DomainEntry) Matches(host string) bool {
+	return MatchesDomain(host, e.Pattern)
+}
+
+// ParseIPLiteral parses host as an IP address, accepting IPv6 addresses in
+// brackets and a trailing port ("[::1]:443", "127.0.0.1:80"). It returns nil
+// for hostnames.
+func ParseIPLiteral(host string) net.IP {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return parseBracketedIP(host)
+}
+
+// parseBracketedIP parses an IP address that may be wrapped in brackets.
+func parseBracketedIP(s string) net.IP {
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
+	}
+	return net.ParseIP(s)
+}
+
+// ContainsIP reports whether a CIDR entry covers ip.
+func (e DomainEntry) ContainsIP(ip net.IP) bool {
+	return e.Kind == DomainCIDR && ip != nil && e.Network.Contains(ip)
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseDomainEntry(t *testing.T) {
+	tests := []struct {
+		entry       string
+		wantKind    DomainEntryKind
+		wantPattern string
+		wantTag     string
+		wantProto   string
+		wantErr     bool
+	}{
+		{entry: "api.github.com", wantKind: DomainExact, wantPattern: "api.github.com"},
+		{entry: "*.npmjs.org", wantKind: DomainGlob, wantPattern: "*.npmjs.org"},
+		{entry: "*", wantKind: DomainGlob, wantPattern: "*"},
+		{entry: "10.10.0.0/16", wantKind: DomainCIDR, wantPattern: "10.10.0.0/16"},
+		{entry: "fd00::/8", wantKind: DomainCIDR, wantPattern: "fd00::/8"},
+		{entry: "tag:onprem proto:tcp 10.10.0.0/16", wantKind: DomainCIDR, wantPattern: "10.10.0.0/16", wantTag: "onprem", wantProto: "tcp"},
+		{entry: "::1", wantKind: DomainIP, wantPattern: "::1"},
+		{entry: "[::1]", wantKind: DomainIP, wantPattern: "[::1]"},
+		{entry: "127.0.0.1", wantKind: DomainIP, wantPattern: "127.0.0.1"},
+		{entry: "proto:tcp 2001:db8::1", wantKind: DomainIP, wantPattern: "2001:db8::1", wantProto: "tcp"},
+		{entry: "[::1]:443", wantErr: true},
+		{entry: "10.10.0.0/33", wantErr: true},
+		{entry: "example.com/path", wantErr: true},
+		{entry: "https://example.com", wantErr: true},
+		{entry: "*.com", wantErr: true},
+		{entry: "proto:sctp example.com", wantErr: true},
+		{entry: "tag: example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			got, err := ParseDomainEntry(tt.entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDomainEntry(%q) error = %v, wantErr %v", tt.entry, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Kind != tt.wantKind || got.Pattern != tt.wantPattern || got.Tag != tt.wantTag || got.Proto != tt.wantProto {
+				t.Errorf("ParseDomainEntry(%q) = %+v, want kind %q pattern %q tag %q proto %q",
+					tt.entry, got, tt.wantKind, tt.wantPattern, tt.wantTag, tt.wantProto)
+			}
+			if (got.Network != nil) != (tt.wantKind == DomainCIDR) {
+				t.Errorf("ParseDomainEntry(%q) Network = %v", tt.entry, got.Network)
+			}
+			if (got.IP != nil) != (tt.wantKind == DomainIP) {
+				t.Errorf("ParseDomainEntry(%q) IP = %v", tt.entry, got.IP)
+			}
+		})
+	}
+}
+
+func TestDomainEntryCIDRMatching(t *testing.T) {
+	entry, err := ParseDomainEntry("10.10.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"10.10.3.4", true},
+		{"10.11.0.1", false},
+		{"internal.example.com", false}, // names are matched by resolved address, not here
+	}
+	for _, tt := range tests {
+		if got := entry.Matches(tt.host); got != tt.want {
+			t.Errorf("Matches(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+
+	if !entry.ContainsIP(net.ParseIP("10.10.255.255")) {
+		t.Error("ContainsIP should cover the last address of the range")
+	}
+	if exact, _ := ParseDomainEntry("10.10.3.4"); exact.ContainsIP(net.ParseIP("10.10.3.4")) {
+		t.Error("ContainsIP should be false for non-CIDR entries")
+	}
+}
+
+func TestDomainEntryIPMatching(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{"::1", "::1", true},
+		{"::1", "[::1]", true},
+		{"::1", "[::1]:443", true},
+		{"[::1]", "::1", true},
+		{"::1", "0:0:0:0:0:0:0:1", true},
+		{"::1", "::2", false},
+		{"::1", "127.0.0.1", false},
+		{"127.0.0.1", "::ffff:127.0.0.1", true},
+		{"::ffff:127.0.0.1", "127.0.0.1", true},
+		{"127.0.0.1", "127.0.0.1:8080", true},
+		{"127.0.0.1", "localhost", false},
+		{"2001:DB8::1", "2001:db8::1", true},
+	}
+	for _, tt := range tests {
+		entry, err := ParseDomainEntry(tt.pattern)
+		if err != nil {
+			t.Fatalf("ParseDomainEntry(%q): %v", tt.pattern, err)
+		}
+		if got := entry.Matches(tt.host); got != tt.want {
+			t.Errorf("%q.Matches(%q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestParseIPLiteral(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"::1", "::1"},
+		{"[::1]", "::1"},
+		{"[::1]:443", "::1"},
+		{"10.0.0.1:80", "10.0.0.1"},
+		{"::ffff:127.0.0.1", "127.0.0.1"},
+		{"example.com", ""},
+		{"example.com:443", ""},
+	}
+	for _, tt := range tests {
+		got := ParseIPLiteral(tt.host)
+		if tt.want == "" {
+			if got != nil {
+				t.Errorf("ParseIPLiteral(%q) = %v, want nil", tt.host, got)
+			}
+			continue
+		}
+		if !got.Equal(net.ParseIP(tt.want)) {
+			t.Errorf("ParseIPLiteral(%q) = %v, want %s", tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseSandboxProfileExtra splits sandboxProfileExtra, a fragment of macOS
+// sandbox profile, into its top-level (allow ...) and (deny ...) rules.
+// Comments starting with ";" are skipped. Any other top-level form, and
+// unbalanced parentheses or strings, are errors.
+func ParseSandboxProfileExtra(extra string) (allow, deny []string, err error) {
+	forms, err := splitProfileForms(extra)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, form := range forms {
+		switch profileFormHead(form) {
+		case "allow":
+			allow = append(allow, form)
+		case "deny":
+			deny = append(deny, form)
+		default:
+			return nil, nil, fmt.Errorf("rule %q: only (allow ...) and (deny ...) rules are supported", form)
+		}
+	}
+	return allow, deny, nil
+}
+
+// profileFormHead returns the first symbol of a form such as "(allow x)".
+func profileFormHead(form string) string {
+	fields := strings.FieldsFunc(strings.TrimPrefix(form, "("), func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '(' || r == ')'
+	})
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// splitProfileForms returns the top-level parenthesized forms of a profile
+// fragment. Parentheses inside strings ("..." and regex literals #"...")
+// and comments do not count.
+func splitProfileForms(src string) ([]string, error) {
+	var forms []string
+	depth, start := 0, 0
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == ';':
+			// Comment to end of line
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			end, err := skipProfileString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			if depth == 0 {
+				return nil, fmt.Errorf("unexpected string at top level: %q", src[i:end+1])
+			}
+			i = end
+		case c == '(':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case c == ')':
+			if depth == 0 {
+				return nil, errors.New("unbalanced ')'")
+			}
+			depth--
+			if depth == 0 {
+				forms = append(forms, src[start:i+1])
+			}
+		case depth == 0 && !isProfileSpace(c):
+			return nil, fmt.Errorf("unexpected %q outside a rule", c)
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unbalanced '(': rule is not closed")
+	}
+	return forms, nil
+}
+
+// skipProfileString returns the index of the quote closing the string that
+// starts at src[open].
+func skipProfileString(src string, open int) (int, error) {
+	for i := open + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '"':
+			return i, nil
+		}
+	}
+	return 0, errors.New("unterminated string")
+}
+
+func isProfileSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
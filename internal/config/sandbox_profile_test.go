@@ -0,0 +1,54 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSandboxProfileExtra(t *testing.T) {
+	tests := []struct {
+		name      string
+		extra     string
+		wantAllow []string
+		wantDeny  []string
+		wantErr   bool
+	}{
+		{name: "empty"},
+		{
+			name:      "allow and deny",
+			extra:     "(allow mach-lookup (global-name \"com.apple.x\"))\n(deny file-read* (subpath \"/opt/secret\"))",
+			wantAllow: []string{`(allow mach-lookup (global-name "com.apple.x"))`},
+			wantDeny:  []string{`(deny file-read* (subpath "/opt/secret"))`},
+		},
+		{
+			name:      "comments and parens in strings",
+			extra:     "; extra rules (for tests)\n(allow file-read* (literal \"/opt/a)b\")) ; trailing",
+			wantAllow: []string{`(allow file-read* (literal "/opt/a)b"))`},
+		},
+		{
+			name:      "regex literal",
+			extra:     `(allow file-ioctl (regex #"^/dev/(tty|cu)\."))`,
+			wantAllow: []string{`(allow file-ioctl (regex #"^/dev/(tty|cu)\."))`},
+		},
+		{name: "other form", extra: `(version 1)`, wantErr: true},
+		{name: "unclosed rule", extra: `(allow file-read*`, wantErr: true},
+		{name: "extra close", extra: `(allow file-read*))`, wantErr: true},
+		{name: "unterminated string", extra: `(allow file-read* (literal "/opt))`, wantErr: true},
+		{name: "bare symbol", extra: `allow`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allow, deny, err := ParseSandboxProfileExtra(tt.extra)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSandboxProfileExtra() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(allow, tt.wantAllow) {
+				t.Errorf("allow = %q, want %q", allow, tt.wantAllow)
+			}
+			if !slices.Equal(deny, tt.wantDeny) {
+				t.Errorf("deny = %q, want %q", deny, tt.wantDeny)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// mailHostLabels are leading hostname labels that mark an allowed domain as a
+// mail server (SMTP on ports 25, 465 or 587). Domains carry no port in
+// allowedDomains, so the hostname is the only signal available.
+var mailHostLabels = []string{"smtp", "mail", "mx", "relay", "smtp-relay", "email-smtp", "outbound"}
+
+// lookupTXT resolves TXT records for SPF checks. Tests override it.
+var lookupTXT = net.DefaultResolver.LookupTXT
+
+// spfLookupTimeout bounds the time spent on SPF checks at startup.
+const spfLookupTimeout = 5 * time.Second
+
+// isMailHost reports whether domain looks like an SMTP server.
+func isMailHost(domain string) bool {
+	first, _, ok := strings.Cut(domain, ".")
+	if !ok {
+		return false
+	}
+	first = strings.TrimRight(first, "0123456789")
+	return slices.Contains(mailHostLabels, first)
+}
+
+// spfWarnings checks that each mail-server entry in allowedDomains, or its
+// parent domain, publishes an SPF record.
+func (c *Config) spfWarnings() []string {
+	ctx, cancel := context.WithTimeout(context.Background(), spfLookupTimeout)
+	defer cancel()
+
+	var warnings []string
+	for _, entry := range c.Network.AllowedDomains {
+		domain := strings.ToLower(BareDomain(entry))
+		if strings.HasPrefix(domain, "*") || !isMailHost(domain) {
+			continue
+		}
+
+		_, parent, _ := strings.Cut(domain, ".")
+		if hasSPFRecord(ctx, domain) || (strings.Contains(parent, ".") && hasSPFRecord(ctx, parent)) {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("network.allowedDomains entry %q looks like a mail server but neither it nor %q publishes an SPF record", domain, parent))
+	}
+	return warnings
+}
+
+// hasSPFRecord reports whether domain has a "v=spf1" TXT record.
+func hasSPFRecord(ctx context.Context, domain string) bool {
+	records, err := lookupTXT(ctx, domain)
+	if err != nil {
+		return false
+	}
+	for _, r := range records {
+		r = strings.ToLower(r)
+		if r == "v=spf1" || strings.HasPrefix(r, "v=spf1 ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIsMailHost(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"smtp.example.com", true},
+		{"smtp2.example.com", true},
+		{"mail.example.com", true},
+		{"email-smtp.us-east-1.amazonaws.com", true},
+		{"api.example.com", false},
+		{"smtpserver.example.com", false},
+		{"localhost", false},
+	}
+	for _, tt := range tests {
+		if got := isMailHost(tt.domain); got != tt.want {
+			t.Errorf("isMailHost(%q) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestConfigWarningsValidateSPF(t *testing.T) {
+	origLookupTXT := lookupTXT
+	t.Cleanup(func() { lookupTXT = origLookupTXT })
+
+	records := map[string][]string{
+		"smtp.good.com":       {"google-site-verification=abc"},
+		"good.com":            {"v=spf1 include:_spf.google.com ~all"},
+		"mail.direct.com":     {"V=SPF1 -all"},
+		"smtp.nospf.com":      {"some-other-record"},
+		"nospf.com":           {"v=DMARC1; p=none"},
+		"api.unrelated.com":   {},
+		"smtp.lookupfail.com": nil,
+	}
+	lookupTXT = func(_ context.Context, name string) ([]string, error) {
+		r, ok := records[name]
+		if !ok || r == nil {
+			return nil, errors.New("no such host")
+		}
+		return r, nil
+	}
+
+	cfg := Config{
+		Network: NetworkConfig{
+			ValidateSPF: true,
+			AllowedDomains: []string{
+				"smtp.good.com",
+				"tag:mail mail.direct.com",
+				"smtp.nospf.com",
+				"api.unrelated.com",
+				"smtp.lookupfail.com",
+				"*.mail.example.com",
+			},
+		},
+	}
+
+	warnings := cfg.Warnings()
+	if len(warnings) != 2 {
+		t.Fatalf("Warnings() = %v, want 2 SPF warnings", warnings)
+	}
+	for i, domain := range []string{"smtp.nospf.com", "smtp.lookupfail.com"} {
+		if !strings.Contains(warnings[i], domain) {
+			t.Errorf("warning %q should mention %s", warnings[i], domain)
+		}
+	}
+
+	cfg.Network.ValidateSPF = false
+	if warnings := cfg.Warnings(); len(warnings) != 0 {
+		t.Errorf("Warnings() with validateSPF off = %v, want none", warnings)
+	}
+}
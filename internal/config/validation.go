@@ -0,0 +1,229 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ValidationCode identifies the kind of problem a ValidationError reports.
+type ValidationCode string
+
+const (
+	// CodeUnknownTemplate means extends names a built-in template that does not exist.
+	CodeUnknownTemplate ValidationCode = "unknown_template"
+	// CodeInvalidPort means a port is outside 1-65535.
+	CodeInvalidPort ValidationCode = "invalid_port"
+	// CodeDuplicateEntry means a list contains the same entry twice.
+	CodeDuplicateEntry ValidationCode = "duplicate_entry"
+	// CodeInvalidGlob means a path pattern cannot be matched as intended.
+	CodeInvalidGlob ValidationCode = "invalid_glob"
+	// CodeWriteConflict means a path is in both allowWrite and denyWrite.
+	CodeWriteConflict ValidationCode = "write_conflict"
+	// CodeInvalidValue means a field is not one of its accepted values.
+	CodeInvalidValue ValidationCode = "invalid_value"
+)
+
+// ValidationError is a field-level configuration problem.
+type ValidationError struct {
+	Field   string         // Dot path such as "network.allowedDomains[2]"
+	Code    ValidationCode // Machine-readable kind of problem
+	Message string         // Human-readable description
+}
+
+func (e ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// IsWarning reports whether the problem leaves the config usable as written,
+// such as a duplicate entry or an allowWrite path that denyWrite overrides.
+// Warnings are reported but do not make the config invalid.
+func (e ValidationError) IsWarning() bool {
+	return e.Code == CodeDuplicateEntry || e.Code == CodeWriteConflict
+}
+
+// TemplateExists reports whether a built-in template exists. It is set by
+// the templates package, which imports this one; when nil, extends is not
+// checked.
+var TemplateExists func(name string) bool
+
+// TemplateNames lists the built-in templates. Like TemplateExists, it is set
+// by the templates package.
+var TemplateNames func() []string
+
+// BuiltinTemplateNames returns the names of the built-in templates, sorted,
+// or nil if the templates package is not linked in.
+func BuiltinTemplateNames() []string {
+	if TemplateNames == nil {
+		return nil
+	}
+	return TemplateNames()
+}
+
+// Validate checks cfg field by field and returns every problem found. It
+// complements (*Config).Validate, which Load runs and which stops at the first
+// malformed entry.
+func Validate(cfg *Config) []ValidationError {
+	if cfg == nil {
+		return nil
+	}
+
+	var errs []ValidationError
+	errs = append(errs, validateExtends(cfg.Extends)...)
+	switch cfg.EnforcementMode {
+	case "", EnforcementModeEnforce, EnforcementModeAudit:
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "enforcementMode",
+			Code:    CodeInvalidValue,
+			Message: fmt.Sprintf("%q is not %q or %q", cfg.EnforcementMode, EnforcementModeEnforce, EnforcementModeAudit),
+		})
+	}
+	if err := validateWebhookURL(cfg.WebhookURL); err != nil {
+		errs = append(errs, ValidationError{
+			Field:   "webhookUrl",
+			Code:    CodeInvalidValue,
+			Message: fmt.Sprintf("%q: %v", cfg.WebhookURL, err),
+		})
+	}
+	errs = append(errs, validatePort("network.httpProxyPort", cfg.Network.HTTPProxyPort)...)
+	errs = append(errs, validatePort("network.socksProxyPort", cfg.Network.SOCKSProxyPort)...)
+	for _, f := range []struct {
+		name  string
+		ports []int
+	}{
+		{"network.allowedPorts", cfg.Network.AllowedPorts},
+		{"network.deniedPorts", cfg.Network.DeniedPorts},
+	} {
+		for i, port := range f.ports {
+			if port < 1 || port > 65535 {
+				errs = append(errs, ValidationError{
+					Field:   fmt.Sprintf("%s[%d]", f.name, i),
+					Code:    CodeInvalidPort,
+					Message: fmt.Sprintf("port %d is outside 1-65535", port),
+				})
+			}
+		}
+	}
+	errs = append(errs, findDuplicates("", reflect.ValueOf(*cfg))...)
+
+	pathFields := []struct {
+		name  string
+		paths []string
+	}{
+		{"filesystem.allowRead", cfg.Filesystem.AllowRead},
+		{"filesystem.allowExecute", cfg.Filesystem.AllowExecute},
+		{"filesystem.denyRead", cfg.Filesystem.DenyRead},
+		{"filesystem.allowWrite", cfg.Filesystem.AllowWrite},
+		{"filesystem.denyWrite", cfg.Filesystem.DenyWrite},
+	}
+	for _, f := range pathFields {
+		for i, p := range f.paths {
+			if err := validateGlob(p); err != nil {
+				errs = append(errs, ValidationError{
+					Field:   fmt.Sprintf("%s[%d]", f.name, i),
+					Code:    CodeInvalidGlob,
+					Message: err.Error(),
+				})
+			}
+		}
+	}
+
+	allowWrite := make(map[string]bool)
+	for _, entry := range cfg.Filesystem.AllowWrite {
+		_, path, _ := splitOSPrefix(entry)
+		path, _ = splitAtomicFlag(path)
+		allowWrite[path] = true
+	}
+	for i, path := range cfg.Filesystem.DenyWrite {
+		if allowWrite[path] {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("filesystem.denyWrite[%d]", i),
+				Code:    CodeWriteConflict,
+				Message: fmt.Sprintf("%q is also in filesystem.allowWrite; denyWrite takes precedence, so the allowWrite entry has no effect", path),
+			})
+		}
+	}
+
+	return errs
+}
+
+// validateExtends checks that a template name refers to a built-in template.
+// File paths are resolved later, relative to the config file.
+func validateExtends(extends string) []ValidationError {
+	if extends == "" || TemplateExists == nil {
+		return nil
+	}
+	if strings.ContainsAny(extends, "/\\") || strings.HasPrefix(extends, ".") {
+		return nil
+	}
+	if TemplateExists(extends) {
+		return nil
+	}
+	return []ValidationError{{
+		Field:   "extends",
+		Code:    CodeUnknownTemplate,
+		Message: fmt.Sprintf("unknown template %q (run fence --list-templates)", extends),
+	}}
+}
+
+// validatePort checks a port field. Zero means the port is chosen automatically.
+func validatePort(field string, port int) []ValidationError {
+	if port == 0 || (port >= 1 && port <= 65535) {
+		return nil
+	}
+	return []ValidationError{{
+		Field:   field,
+		Code:    CodeInvalidPort,
+		Message: fmt.Sprintf("port %d is outside 1-65535", port),
+	}}
+}
+
+// findDuplicates reports repeated entries in every []string and []int field
+// of the struct v, naming fields by their JSON keys.
+func findDuplicates(prefix string, v reflect.Value) []ValidationError {
+	var errs []ValidationError
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		value := v.Field(i)
+		switch {
+		case value.Kind() == reflect.Struct:
+			errs = append(errs, findDuplicates(name, value)...)
+		case value.Kind() == reflect.Slice && (value.Type().Elem().Kind() == reflect.String || value.Type().Elem().Kind() == reflect.Int):
+			first := make(map[any]int)
+			for j := 0; j < value.Len(); j++ {
+				entry := value.Index(j).Interface()
+				if k, ok := first[entry]; ok {
+					errs = append(errs, ValidationError{
+						Field:   fmt.Sprintf("%s[%d]", name, j),
+						Code:    CodeDuplicateEntry,
+						Message: fmt.Sprintf("%#v duplicates %s[%d]", entry, name, k),
+					})
+					continue
+				}
+				first[entry] = j
+			}
+		}
+	}
+	return errs
+}
+
+// validateGlob rejects path segments that mix "**" with "?". "**" stands for
+// any number of whole directories, not part of a name.
+func validateGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if strings.Contains(segment, "**") && strings.Contains(segment, "?") {
+			return fmt.Errorf("segment %q mixes ** with ?; use ** as a whole path segment", segment)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package config
+
+import "testing"
+
+func TestValidateFieldErrors(t *testing.T) {
+	orig := TemplateExists
+	TemplateExists = func(name string) bool { return name == "code" }
+	t.Cleanup(func() { TemplateExists = orig })
+
+	tests := []struct {
+		name      string
+		cfg       Config
+		wantField string
+		wantCode  ValidationCode
+	}{
+		{
+			name:      "unknown template",
+			cfg:       Config{Extends: "cod"},
+			wantField: "extends",
+			wantCode:  CodeUnknownTemplate,
+		},
+		{
+			name:      "unknown enforcement mode",
+			cfg:       Config{EnforcementMode: "log"},
+			wantField: "enforcementMode",
+			wantCode:  CodeInvalidValue,
+		},
+		{
+			name:      "webhook URL without scheme",
+			cfg:       Config{WebhookURL: "hooks.example.com/fence"},
+			wantField: "webhookUrl",
+			wantCode:  CodeInvalidValue,
+		},
+		{
+			name:      "port out of range",
+			cfg:       Config{Network: NetworkConfig{HTTPProxyPort: 70000}},
+			wantField: "network.httpProxyPort",
+			wantCode:  CodeInvalidPort,
+		},
+		{
+			name:      "negative port",
+			cfg:       Config{Network: NetworkConfig{SOCKSProxyPort: -1}},
+			wantField: "network.socksProxyPort",
+			wantCode:  CodeInvalidPort,
+		},
+		{
+			name:      "duplicate domain",
+			cfg:       Config{Network: NetworkConfig{AllowedDomains: []string{"a.com", "b.com", "a.com"}}},
+			wantField: "network.allowedDomains[2]",
+			wantCode:  CodeDuplicateEntry,
+		},
+		{
+			name:      "port list entry out of range",
+			cfg:       Config{Network: NetworkConfig{AllowedPorts: []int{443, 0}}},
+			wantField: "network.allowedPorts[1]",
+			wantCode:  CodeInvalidPort,
+		},
+		{
+			name:      "duplicate port",
+			cfg:       Config{Network: NetworkConfig{DeniedPorts: []int{22, 22}}},
+			wantField: "network.deniedPorts[1]",
+			wantCode:  CodeDuplicateEntry,
+		},
+		{
+			name:      "duplicate command",
+			cfg:       Config{Command: CommandConfig{Deny: []string{"git push", "git push"}}},
+			wantField: "command.deny[1]",
+			wantCode:  CodeDuplicateEntry,
+		},
+		{
+			name:      "glob mixing ** and ?",
+			cfg:       Config{Filesystem: FilesystemConfig{DenyRead: []string{"/home/**?/secret"}}},
+			wantField: "filesystem.denyRead[0]",
+			wantCode:  CodeInvalidGlob,
+		},
+		{
+			name: "allowWrite and denyWrite conflict",
+			cfg: Config{Filesystem: FilesystemConfig{
+				AllowWrite: []string{"os:linux:/workspace/out atomic:true"},
+				DenyWrite:  []string{"/workspace/out"},
+			}},
+			wantField: "filesystem.denyWrite[0]",
+			wantCode:  CodeWriteConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Validate(&tt.cfg)
+			if len(errs) != 1 {
+				t.Fatalf("Validate() = %v, want exactly one error", errs)
+			}
+			if errs[0].Field != tt.wantField || errs[0].Code != tt.wantCode {
+				t.Errorf("Validate() = {%s %s}, want {%s %s}", errs[0].Field, errs[0].Code, tt.wantField, tt.wantCode)
+			}
+			if errs[0].Message == "" {
+				t.Error("Message should not be empty")
+			}
+		})
+	}
+}
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	orig := TemplateExists
+	TemplateExists = func(name string) bool { return name == "code" }
+	t.Cleanup(func() { TemplateExists = orig })
+
+	for _, cfg := range []*Config{
+		nil,
+		Default(),
+		{Extends: "code"},
+		{Extends: "./base.json"},
+		{
+			Network:    NetworkConfig{AllowedDomains: []string{"github.com"}, HTTPProxyPort: 3128},
+			Filesystem: FilesystemConfig{AllowWrite: []string{"."}, DenyWrite: []string{"./.env"}, DenyRead: []string{"~/.ssh/**"}},
+		},
+	} {
+		if errs := Validate(cfg); len(errs) != 0 {
+			t.Errorf("Validate(%+v) = %v, want no errors", cfg, errs)
+		}
+	}
+}
+
+func TestValidationErrorIsWarning(t *testing.T) {
+	cfg := &Config{
+		Network: NetworkConfig{
+			AllowedDomains: []string{"github.com", "github.com"},
+			HTTPProxyPort:  70000,
+		},
+		Filesystem: FilesystemConfig{AllowWrite: []string{"./out"}, DenyWrite: []string{"./out"}},
+	}
+
+	warnings := map[ValidationCode]bool{}
+	for _, e := range Validate(cfg) {
+		warnings[e.Code] = e.IsWarning()
+	}
+	want := map[ValidationCode]bool{
+		CodeDuplicateEntry: true,
+		CodeWriteConflict:  true,
+		CodeInvalidPort:    false,
+	}
+	for code, warning := range want {
+		got, ok := warnings[code]
+		if !ok {
+			t.Errorf("Validate() reported no %s problem", code)
+			continue
+		}
+		if got != warning {
+			t.Errorf("%s: IsWarning() = %v, want %v", code, got, warning)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/tidwall/jsonc"
+	"gopkg.in/yaml.v3"
+)
+
+// IsYAMLPath reports whether path names a YAML config file (.yaml or .yml).
+func IsYAMLPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// ParseConfig parses config file contents without validating them. YAML
+// files (by extension) use the same camelCase keys as JSON; anything else is
+// parsed as JSON with comments (JSONC).
+func ParseConfig(path string, data []byte) (*Config, error) {
+	if IsYAMLPath(path) {
+		// JSONC comment stripping would corrupt YAML ("#" comments, "//" in URLs)
+		converted, err := YAMLToJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		data = converted
+	} else {
+		data = jsonc.ToJSON(data)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// YAMLToJSON converts a YAML document to JSON, so it can be decoded with the
+// config structs' JSON tags.
+func YAMLToJSON(data []byte) ([]byte, error) {
+	var v any
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// JSONToYAML converts a JSON document to block-style YAML, keeping key order.
+func JSONToYAML(data []byte) ([]byte, error) {
+	// JSON is valid YAML, so decoding into a node keeps the key order
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	clearYAMLStyle(&node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// clearYAMLStyle switches nodes decoded from JSON from flow style to block
+// style, keeping quotes only where a string would otherwise change type.
+func clearYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" {
+		var decoded any
+		if err := yaml.Unmarshal([]byte(node.Value), &decoded); err != nil {
+			node.Style = yaml.DoubleQuotedStyle
+		} else if s, ok := decoded.(string); !ok || s != node.Value {
+			node.Style = yaml.DoubleQuotedStyle
+		}
+	}
+	for _, child := range node.Content {
+		clearYAMLStyle(child)
+	}
+}
+
+// MarshalConfigYAML marshals a fence config to YAML with the same fields and
+// order as MarshalConfigJSON.
+func MarshalConfigYAML(cfg *Config) ([]byte, error) {
+	data, err := MarshalConfigJSON(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return JSONToYAML(data)
+}
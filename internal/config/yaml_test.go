@@ -0,0 +1,185 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fillConfig sets every field of v to a distinct non-zero value, so a
+// round-trip test notices any field the marshaller drops.
+func fillConfig(t *testing.T, v reflect.Value, name string) {
+	t.Helper()
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fillConfig(t, v.Field(i), name+"."+v.Type().Field(i).Name)
+			}
+		}
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fillConfig(t, v.Elem(), name)
+	case reflect.String:
+		v.SetString(name)
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int:
+		v.SetInt(int64(len(name)))
+	case reflect.Slice:
+		switch v.Type().Elem().Kind() {
+		case reflect.String:
+			v.Set(reflect.ValueOf([]string{name + "[0]", name + "[1]"}))
+		case reflect.Int:
+			v.Set(reflect.ValueOf([]int{len(name), len(name) + 1}))
+		default:
+			t.Fatalf("fillConfig: unsupported slice type %s for %s", v.Type(), name)
+		}
+	default:
+		t.Fatalf("fillConfig: unsupported kind %s for %s", v.Kind(), name)
+	}
+}
+
+func TestMarshalConfigYAML_RoundTrip(t *testing.T) {
+	cfg := &Config{}
+	fillConfig(t, reflect.ValueOf(cfg).Elem(), "cfg")
+
+	data, err := MarshalConfigYAML(cfg)
+	require.NoError(t, err)
+
+	parsed, err := ParseConfig("fence.yaml", data)
+	require.NoError(t, err)
+	assert.Equal(t, cfg, parsed, "YAML:\n%s", data)
+}
+
+func TestMarshalConfigYAML_QuotesAmbiguousStrings(t *testing.T) {
+	cfg := &Config{}
+	cfg.Network.AllowedDomains = []string{"*", "yes", "null", "123", "0x1F", "tag:ci *.example.com", "# not a comment"}
+	cfg.Command.Deny = []string{"git push --force", "rm -rf /: x"}
+
+	data, err := MarshalConfigYAML(cfg)
+	require.NoError(t, err)
+
+	parsed, err := ParseConfig("fence.yml", data)
+	require.NoError(t, err)
+	assert.Equal(t, cfg.Network.AllowedDomains, parsed.Network.AllowedDomains, "YAML:\n%s", data)
+	assert.Equal(t, cfg.Command.Deny, parsed.Command.Deny, "YAML:\n%s", data)
+}
+
+func TestLoadYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    func(t *testing.T, cfg *Config)
+		wantErr bool
+	}{
+		{
+			name: "camelCase keys",
+			file: "fence.yaml",
+			content: `# project sandbox
+extends: code
+network:
+  allowedDomains:
+    - github.com
+    - "*.npmjs.org"
+  httpProxyPort: 8080
+filesystem:
+  allowWrite: [.]
+  wslInterop: false
+allowPty: true
+`,
+			want: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, "code", cfg.Extends)
+				assert.Equal(t, []string{"github.com", "*.npmjs.org"}, cfg.Network.AllowedDomains)
+				assert.Equal(t, 8080, cfg.Network.HTTPProxyPort)
+				assert.Equal(t, []string{"."}, cfg.Filesystem.AllowWrite)
+				require.NotNil(t, cfg.Filesystem.WSLInterop)
+				assert.False(t, *cfg.Filesystem.WSLInterop)
+				assert.True(t, cfg.AllowPty)
+			},
+		},
+		{
+			// JSONC comment stripping would cut these values at "//"
+			name: "no JSONC stripping",
+			file: "fence.yml",
+			content: `filesystem:
+  denyRead:
+    - //server/share
+command:
+  deny:
+    - "curl https://example.com/*"
+`,
+			want: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []string{"//server/share"}, cfg.Filesystem.DenyRead)
+				assert.Equal(t, []string{"curl https://example.com/*"}, cfg.Command.Deny)
+			},
+		},
+		{
+			name:    "invalid YAML",
+			file:    "fence.yaml",
+			content: "network: [unclosed",
+			wantErr: true,
+		},
+		{
+			name:    "validation still applies",
+			file:    "fence.yaml",
+			content: "network:\n  allowedDomains: [\"https://example.com\"]\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			cfg, err := Load(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Load() expected error, got config %+v", cfg)
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, cfg)
+			tt.want(t, cfg)
+		})
+	}
+}
+
+func TestIsYAMLPath(t *testing.T) {
+	tests := map[string]bool{
+		"fence.yaml":         true,
+		"fence.yml":          true,
+		"/etc/fence/cfg.YML": true,
+		"fence.json":         false,
+		"fence":              false,
+		"yaml":               false,
+	}
+	for path, want := range tests {
+		if got := IsYAMLPath(path); got != want {
+			t.Errorf("IsYAMLPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestMarshalConfigYAML_BlockStyle(t *testing.T) {
+	cfg := &Config{Extends: "code"}
+	cfg.Network.AllowedDomains = []string{"github.com", "*.npmjs.org"}
+
+	data, err := MarshalConfigYAML(cfg)
+	require.NoError(t, err)
+
+	want := `extends: code
+network:
+  allowedDomains:
+    - github.com
+    - "*.npmjs.org"
+`
+	assert.Equal(t, want, string(data))
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/Use-Tusk/fence/internal/config"
+	// Registers the built-in template names used for the extends enum
+	_ "github.com/Use-Tusk/fence/internal/templates"
 )
 
 const (
@@ -14,23 +16,47 @@ const (
 	DefaultSchemaPath = "https://raw.githubusercontent.com/Use-Tusk/fence/main/docs/schema/fence.schema.json"
 )
 
-// Generate creates a JSON Schema document from the config structs.
+// Generate creates a JSON Schema document from the config structs, with
+// "default" values taken from config.Default().
 func Generate() ([]byte, error) {
 	rootType := reflect.TypeOf(config.Config{})
 	rootSchema, err := schemaForType(rootType)
 	if err != nil {
 		return nil, err
 	}
+	if err := applyDefaults(rootSchema, reflect.ValueOf(config.Default()).Elem()); err != nil {
+		return nil, err
+	}
 
 	properties, ok := rootSchema["properties"].(map[string]any)
 	if !ok {
 		return nil, fmt.Errorf("root schema missing properties")
 	}
 
+	extends, ok := properties["extends"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("root schema missing extends")
+	}
+	delete(extends, "type")
+	extends["anyOf"] = extendsSchemas(config.BuiltinTemplateNames())
+
+	enforcementMode, ok := properties["enforcementMode"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("root schema missing enforcementMode")
+	}
+	enforcementMode["enum"] = []string{"", config.EnforcementModeEnforce, config.EnforcementModeAudit}
+
+	webhookURL, ok := properties["webhookUrl"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("root schema missing webhookUrl")
+	}
+	webhookURL["pattern"] = "^(https?://.+)?$"
+
 	// Optional editor hint key; fence ignores unknown keys when parsing config.
 	properties["$schema"] = map[string]any{
-		"type":   "string",
-		"format": "uri",
+		"type":        "string",
+		"format":      "uri",
+		"description": "URL of this schema, for editor validation and completion",
 	}
 
 	document := map[string]any{
@@ -46,6 +72,21 @@ func Generate() ([]byte, error) {
 	return json.MarshalIndent(document, "", "  ")
 }
 
+// extendsSchemas returns the alternatives accepted by extends: nothing, a
+// built-in template name, or the path of a config file. Listing the template
+// names lets editors complete them.
+func extendsSchemas(templateNames []string) []any {
+	return []any{
+		map[string]any{"type": "string", "const": ""},
+		map[string]any{"type": "string", "enum": templateNames},
+		map[string]any{
+			"type":        "string",
+			"pattern":     `^\.{0,2}/`,
+			"description": "Path of a config file, absolute or relative to this one",
+		},
+	}
+}
+
 func schemaForType(t reflect.Type) (map[string]any, error) {
 	switch t.Kind() {
 	case reflect.Pointer:
@@ -71,6 +112,7 @@ func schemaForType(t reflect.Type) (map[string]any, error) {
 			if err != nil {
 				return nil, err
 			}
+			annotate(fieldSchema, field)
 			properties[jsonName] = fieldSchema
 		}
 
@@ -103,6 +145,124 @@ func schemaForType(t reflect.Type) (map[string]any, error) {
 	}
 }
 
+// fenceTagKeys are the options accepted in a `fence:"..."` struct tag.
+var fenceTagKeys = []string{"description", "example"}
+
+// parseFenceTag parses a `fence:"description=...;example=...;example=..."`
+// struct tag into its options, in order. Options are separated by
+// semicolons; a semicolon that does not start a known option is part of the
+// preceding value, so descriptions may contain semicolons.
+func parseFenceTag(tag string) map[string][]string {
+	options := make(map[string][]string)
+	lastKey := ""
+	for _, part := range strings.Split(tag, ";") {
+		key, value, found := strings.Cut(part, "=")
+		if found && containsString(fenceTagKeys, key) {
+			options[key] = append(options[key], value)
+			lastKey = key
+			continue
+		}
+		if lastKey != "" {
+			values := options[lastKey]
+			values[len(values)-1] += ";" + part
+		}
+	}
+	return options
+}
+
+// annotate adds "description" and "examples" from the field's fence tag to
+// its schema. Examples of non-string fields are parsed as JSON, so numbers
+// stay numbers. The examples of an array field form a single example array.
+func annotate(schema map[string]any, field reflect.StructField) {
+	options := parseFenceTag(field.Tag.Get("fence"))
+	if descriptions := options["description"]; len(descriptions) > 0 {
+		schema["description"] = descriptions[0]
+	}
+
+	if len(options["example"]) == 0 {
+		return
+	}
+	valueType := field.Type
+	isArray := valueType.Kind() == reflect.Slice || valueType.Kind() == reflect.Array
+	if isArray {
+		valueType = valueType.Elem()
+	}
+	var examples []any
+	for _, raw := range options["example"] {
+		var value any = raw
+		if valueType.Kind() != reflect.String {
+			if err := json.Unmarshal([]byte(raw), &value); err != nil {
+				value = raw
+			}
+		}
+		examples = append(examples, value)
+	}
+	if isArray {
+		examples = []any{examples}
+	}
+	schema["examples"] = examples
+}
+
+// applyDefaults sets "default" on the schema of every non-object field of the
+// struct value v, descending into nested structs. Nil pointers default to
+// null and nil slices to an empty array.
+func applyDefaults(schema map[string]any, v reflect.Value) error {
+	properties, ok := schema["properties"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("schema for %s missing properties", v.Type())
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		jsonName, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+		fieldSchema, ok := properties[jsonName].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		value := v.Field(i)
+		if value.Kind() == reflect.Pointer && !value.IsNil() && value.Elem().Kind() == reflect.Struct {
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Struct {
+			if err := applyDefaults(fieldSchema, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		defaultValue, err := defaultFor(value)
+		if err != nil {
+			return fmt.Errorf("default for %s.%s: %w", t.Name(), field.Name, err)
+		}
+		fieldSchema["default"] = defaultValue
+	}
+	return nil
+}
+
+// defaultFor returns the JSON form of a field's default value.
+func defaultFor(v reflect.Value) (any, error) {
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return []any{}, nil
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func nullable(base map[string]any) map[string]any {
 	copied := make(map[string]any, len(base)+1)
 	for k, v := range base {
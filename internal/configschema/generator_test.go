@@ -1,6 +1,7 @@
 package configschema
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -25,6 +26,192 @@ func TestGeneratedSchemaIsInSync(t *testing.T) {
 	}
 }
 
+func TestGeneratedSchemaDefaults(t *testing.T) {
+	generated, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(generated, &schema); err != nil {
+		t.Fatalf("generated schema is not valid JSON: %v", err)
+	}
+
+	tests := []struct {
+		path []string
+		want string
+	}{
+		{[]string{"allowPty"}, "false"},
+		{[]string{"command", "shadowMode"}, "false"},
+		{[]string{"command", "useDefaults"}, "null"},
+		{[]string{"command", "deny"}, "[]"},
+		{[]string{"command", "denyOutputPatterns"}, "[]"},
+		{[]string{"extends"}, `""`},
+	}
+	for _, tt := range tests {
+		node := schemaNode(t, schema, tt.path)
+		got, ok := node["default"]
+		if !ok {
+			t.Errorf("%v has no default", tt.path)
+			continue
+		}
+		data, _ := json.Marshal(got)
+		if string(data) != tt.want {
+			t.Errorf("%v default = %s, want %s", tt.path, data, tt.want)
+		}
+	}
+
+	// Every boolean field must document its default
+	var walk func(node map[string]any, path string)
+	walk = func(node map[string]any, path string) {
+		properties, _ := node["properties"].(map[string]any)
+		for name, child := range properties {
+			childNode, _ := child.(map[string]any)
+			if _, hasProps := childNode["properties"]; hasProps {
+				walk(childNode, path+"."+name)
+				continue
+			}
+			if childNode["type"] == "boolean" {
+				if _, ok := childNode["default"]; !ok {
+					t.Errorf("boolean field %s.%s has no default", path, name)
+				}
+			}
+		}
+	}
+	walk(schema, "")
+}
+
+func TestGeneratedSchemaAnnotations(t *testing.T) {
+	generated, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(generated, &schema); err != nil {
+		t.Fatalf("generated schema is not valid JSON: %v", err)
+	}
+
+	for _, path := range [][]string{
+		{"network", "allowedDomains"},
+		{"filesystem", "denyWrite"},
+	} {
+		node := schemaNode(t, schema, path)
+		if description, _ := node["description"].(string); description == "" {
+			t.Errorf("%v has no description", path)
+		}
+	}
+
+	examples, _ := schemaNode(t, schema, []string{"network", "allowedPorts"})["examples"].([]any)
+	if data, _ := json.Marshal(examples); string(data) != "[[443,22]]" {
+		t.Errorf("network.allowedPorts examples = %s, want [[443,22]]", data)
+	}
+	examples, _ = schemaNode(t, schema, []string{"extends"})["examples"].([]any)
+	if data, _ := json.Marshal(examples); string(data) != `["code","./base.json"]` {
+		t.Errorf("extends examples = %s", data)
+	}
+
+	// Every config field must be described
+	var walk func(node map[string]any, path string)
+	walk = func(node map[string]any, path string) {
+		properties, _ := node["properties"].(map[string]any)
+		for name, child := range properties {
+			childNode, _ := child.(map[string]any)
+			if description, _ := childNode["description"].(string); description == "" {
+				t.Errorf("field %s.%s has no description", path, name)
+			}
+			if _, hasProps := childNode["properties"]; hasProps {
+				walk(childNode, path+"."+name)
+			}
+		}
+	}
+	walk(schema, "")
+}
+
+func TestGeneratedSchemaExtends(t *testing.T) {
+	generated, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(generated, &schema); err != nil {
+		t.Fatalf("generated schema is not valid JSON: %v", err)
+	}
+
+	extends := schemaNode(t, schema, []string{"extends"})
+	if _, ok := extends["type"]; ok {
+		t.Error("extends should be constrained by anyOf, not a plain type")
+	}
+	alternatives, _ := extends["anyOf"].([]any)
+	if len(alternatives) != 3 {
+		t.Fatalf("extends anyOf = %v, want 3 alternatives", extends["anyOf"])
+	}
+
+	enum, _ := alternatives[1].(map[string]any)["enum"].([]any)
+	found := false
+	for _, name := range enum {
+		if name == "code" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("extends enum %v does not list the code template", enum)
+	}
+	if pattern := alternatives[2].(map[string]any)["pattern"]; pattern != `^\.{0,2}/` {
+		t.Errorf("extends path pattern = %v", pattern)
+	}
+}
+
+func TestGeneratedSchemaEnforcementMode(t *testing.T) {
+	generated, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(generated, &schema); err != nil {
+		t.Fatalf("generated schema is not valid JSON: %v", err)
+	}
+
+	enum, _ := schemaNode(t, schema, []string{"enforcementMode"})["enum"].([]any)
+	if len(enum) != 3 || enum[0] != "" || enum[1] != "enforce" || enum[2] != "audit" {
+		t.Errorf("enforcementMode enum = %v, want [\"\" enforce audit]", enum)
+	}
+	if _, ok := schemaNode(t, schema, []string{"auditLogPath"})["type"]; !ok {
+		t.Error("auditLogPath missing from schema")
+	}
+	if pattern := schemaNode(t, schema, []string{"webhookUrl"})["pattern"]; pattern != "^(https?://.+)?$" {
+		t.Errorf("webhookUrl pattern = %v", pattern)
+	}
+}
+
+func TestParseFenceTag(t *testing.T) {
+	got := parseFenceTag("description=Allow a; or b;example=x;example=y")
+	if d := got["description"]; len(d) != 1 || d[0] != "Allow a; or b" {
+		t.Errorf("description = %q", d)
+	}
+	if e := got["example"]; len(e) != 2 || e[0] != "x" || e[1] != "y" {
+		t.Errorf("examples = %q", e)
+	}
+	if got := parseFenceTag(""); len(got) != 0 {
+		t.Errorf("parseFenceTag(\"\") = %v, want empty", got)
+	}
+}
+
+func schemaNode(t *testing.T, schema map[string]any, path []string) map[string]any {
+	t.Helper()
+
+	node := schema
+	for _, name := range path {
+		properties, ok := node["properties"].(map[string]any)
+		if !ok {
+			t.Fatalf("no properties above %q in %v", name, path)
+		}
+		node, ok = properties[name].(map[string]any)
+		if !ok {
+			t.Fatalf("schema has no field %v", path)
+		}
+	}
+	return node
+}
+
 func schemaFilePath(t *testing.T) string {
 	t.Helper()
 
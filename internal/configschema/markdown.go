@@ -0,0 +1,226 @@
+package configschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
+
+// GenerateMarkdown creates a Markdown reference of every config field, with
+// its type, default and description, from the same structs and fence tags
+// as Generate.
+func GenerateMarkdown() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("# Configuration Reference\n\n")
+	buf.WriteString("<!-- Generated by `go run ./tools/generate-config-docs`; do not edit by hand. -->\n\n")
+	buf.WriteString("Every field accepted in a fence config file, with its type and default value. ")
+	buf.WriteString("See [Configuration](configuration.md) for a guide and examples.\n")
+
+	root := reflect.ValueOf(config.Default()).Elem()
+	t := root.Type()
+
+	// Scalar top-level fields are grouped under one heading, ahead of the
+	// nested sections.
+	var general []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if isSection(field) {
+			continue
+		}
+		line, err := markdownField(field, root.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		if line != "" {
+			general = append(general, line)
+		}
+	}
+	if len(general) > 0 {
+		writeMarkdownSection(&buf, 2, "General", "", general)
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !isSection(field) {
+			continue
+		}
+		if err := writeMarkdownStruct(&buf, 2, field, root.Field(i)); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// isSection reports whether an exported field holds a nested struct, which
+// gets its own heading.
+func isSection(field reflect.StructField) bool {
+	if !field.IsExported() {
+		return false
+	}
+	if _, skip := jsonFieldName(field); skip {
+		return false
+	}
+	t := field.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// writeMarkdownStruct writes the section for a struct field: its scalar
+// fields as a list, followed by any nested structs as subsections.
+func writeMarkdownStruct(buf *bytes.Buffer, level int, field reflect.StructField, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v = reflect.New(v.Type().Elem())
+		}
+		v = v.Elem()
+	}
+	t := v.Type()
+
+	var lines []string
+	var nested []int
+	for i := 0; i < t.NumField(); i++ {
+		if isSection(t.Field(i)) {
+			nested = append(nested, i)
+			continue
+		}
+		line, err := markdownField(t.Field(i), v.Field(i))
+		if err != nil {
+			return err
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	jsonName, _ := jsonFieldName(field)
+	description := parseFenceTag(field.Tag.Get("fence"))["description"]
+	intro := fmt.Sprintf("Fields under `%s`.", jsonName)
+	if len(description) > 0 {
+		intro = escapeMarkdown(description[0]) + ". " + intro
+	}
+	writeMarkdownSection(buf, level, headingTitle(field.Name), intro, lines)
+
+	for _, i := range nested {
+		if err := writeMarkdownStruct(buf, level+1, t.Field(i), v.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMarkdownSection(buf *bytes.Buffer, level int, title, intro string, lines []string) {
+	fmt.Fprintf(buf, "\n%s %s\n", strings.Repeat("#", level), title)
+	if intro != "" {
+		fmt.Fprintf(buf, "\n%s\n", intro)
+	}
+	if len(lines) > 0 {
+		buf.WriteString("\n")
+		for _, line := range lines {
+			buf.WriteString(line + "\n")
+		}
+	}
+}
+
+// markdownField returns the list item describing a scalar or list field, or
+// "" if the field is not part of the config file.
+func markdownField(field reflect.StructField, v reflect.Value) (string, error) {
+	if !field.IsExported() {
+		return "", nil
+	}
+	jsonName, skip := jsonFieldName(field)
+	if skip {
+		return "", nil
+	}
+
+	defaultText := "unset"
+	if v.Kind() != reflect.Pointer || !v.IsNil() {
+		defaultValue, err := defaultFor(v)
+		if err != nil {
+			return "", fmt.Errorf("default for %s: %w", field.Name, err)
+		}
+		data, err := json.Marshal(defaultValue)
+		if err != nil {
+			return "", err
+		}
+		defaultText = "`" + string(data) + "`"
+	}
+
+	line := fmt.Sprintf("- **%s** (`%s`, default %s)", jsonName, markdownType(field.Type), defaultText)
+
+	options := parseFenceTag(field.Tag.Get("fence"))
+	if description := options["description"]; len(description) > 0 {
+		line += ": " + escapeMarkdown(description[0]) + "."
+	}
+	if jsonName == "extends" {
+		if names := config.BuiltinTemplateNames(); len(names) > 0 {
+			line += " Built-in templates: `" + strings.Join(names, "`, `") + "`."
+		}
+	}
+	if examples := options["example"]; len(examples) > 0 {
+		label := "Example"
+		if len(examples) > 1 {
+			label = "Examples"
+		}
+		line += " " + label + ": `" + strings.Join(examples, "`, `") + "`."
+	}
+	return line, nil
+}
+
+// markdownType returns a short, JSON-oriented name for a field type.
+func markdownType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return markdownType(t.Elem())
+	case reflect.Slice, reflect.Array:
+		return markdownType(t.Elem()) + "[]"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return "object"
+	}
+}
+
+// headingTitle splits a Go field name into words: "ResourceLimits" becomes
+// "Resource Limits", while acronyms such as "SSH" are kept whole.
+func headingTitle(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && unicode.IsLower(runes[i-1]) {
+			b.WriteRune(' ')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// markdownEscaper escapes characters in descriptions that Markdown would
+// otherwise treat as emphasis, links or HTML.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`*`, `\*`,
+	`_`, `\_`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`<`, `\<`,
+	`>`, `\>`,
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
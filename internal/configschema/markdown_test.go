@@ -0,0 +1,66 @@
+package configschema
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedMarkdownIsInSync(t *testing.T) {
+	generated, err := GenerateMarkdown()
+	if err != nil {
+		t.Fatalf("GenerateMarkdown() failed: %v", err)
+	}
+
+	expectedPath := filepath.Join(filepath.Dir(filepath.Dir(schemaFilePath(t))), "config-reference.md")
+	expected, err := os.ReadFile(expectedPath) //nolint:gosec // reading repo fixture in tests
+	if err != nil {
+		t.Fatalf("failed to read config reference: %v", err)
+	}
+
+	if string(expected) != string(generated) {
+		t.Fatalf("config reference is stale: run `go run ./tools/generate-config-docs`")
+	}
+}
+
+func TestGenerateMarkdown(t *testing.T) {
+	generated, err := GenerateMarkdown()
+	if err != nil {
+		t.Fatalf("GenerateMarkdown() failed: %v", err)
+	}
+	doc := string(generated)
+
+	for _, want := range []string{
+		"\n## Network\n",
+		"\n## Filesystem\n",
+		"\n## Command\n",
+		"\n## SSH\n",
+		"\n## Resource Limits\n",
+		"- **allowedDomains** (`string[]`, default `[]`): Domains the sandbox may connect to.",
+		"- **allowedPorts** (`integer[]`, default `[]`)",
+		"- **useDefaults** (`boolean`, default unset)",
+		"Built-in templates: `code`",
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("generated Markdown missing %q", want)
+		}
+	}
+
+	if strings.Contains(doc, "\n\n\n") || !strings.HasSuffix(doc, ".\n") || strings.HasSuffix(doc, "\n\n") {
+		t.Error("generated Markdown should have single blank lines and one trailing newline")
+	}
+}
+
+func TestHeadingTitle(t *testing.T) {
+	tests := map[string]string{
+		"Network":        "Network",
+		"SSH":            "SSH",
+		"ResourceLimits": "Resource Limits",
+	}
+	for in, want := range tests {
+		if got := headingTitle(in); got != want {
+			t.Errorf("headingTitle(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package configschema
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
+
+// GenerateTypeScript creates TypeScript interface definitions (.d.ts) for
+// the config structs. Each struct becomes an exported interface named after
+// its Go type. As in the JSON schema, every field is optional; pointer
+// fields also accept null.
+func GenerateTypeScript() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("// Generated by `go run ./tools/generate-config-types`; do not edit by hand.\n")
+
+	queue := []reflect.Type{reflect.TypeOf(config.Config{})}
+	seen := map[reflect.Type]bool{queue[0]: true}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+
+		nested, err := writeInterface(&buf, t)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nested {
+			if !seen[n] {
+				seen[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeInterface writes the interface for struct type t and returns the
+// struct types its fields refer to.
+func writeInterface(buf *bytes.Buffer, t reflect.Type) ([]reflect.Type, error) {
+	fmt.Fprintf(buf, "\nexport interface %s {\n", t.Name())
+	if t == reflect.TypeOf(config.Config{}) {
+		// Optional editor hint key, as in the JSON schema
+		buf.WriteString("  /** URL of the JSON schema, for editor validation and completion */\n")
+		buf.WriteString("  $schema?: string;\n")
+	}
+
+	var nested []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		jsonName, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+
+		tsType, refs, err := typeScriptType(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+		}
+		nested = append(nested, refs...)
+
+		if description := parseFenceTag(field.Tag.Get("fence"))["description"]; len(description) > 0 {
+			fmt.Fprintf(buf, "  /** %s */\n", strings.ReplaceAll(description[0], "*/", "*\\/"))
+		}
+		fmt.Fprintf(buf, "  %s?: %s;\n", jsonName, tsType)
+	}
+	buf.WriteString("}\n")
+	return nested, nil
+}
+
+// typeScriptType returns the TypeScript type for a Go type, along with any
+// struct types it refers to.
+func typeScriptType(t reflect.Type) (string, []reflect.Type, error) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		inner, refs, err := typeScriptType(t.Elem())
+		if err != nil {
+			return "", nil, err
+		}
+		return inner + " | null", refs, nil
+	case reflect.Struct:
+		return t.Name(), []reflect.Type{t}, nil
+	case reflect.Slice, reflect.Array:
+		inner, refs, err := typeScriptType(t.Elem())
+		if err != nil {
+			return "", nil, err
+		}
+		if strings.Contains(inner, " ") {
+			inner = "(" + inner + ")"
+		}
+		return inner + "[]", refs, nil
+	case reflect.String:
+		return "string", nil, nil
+	case reflect.Bool:
+		return "boolean", nil, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number", nil, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported config field kind: %s", t.Kind())
+	}
+}
@@ -0,0 +1,76 @@
+package configschema
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedTypeScriptIsInSync(t *testing.T) {
+	generated, err := GenerateTypeScript()
+	if err != nil {
+		t.Fatalf("GenerateTypeScript() failed: %v", err)
+	}
+
+	expectedPath := filepath.Join(filepath.Dir(schemaFilePath(t)), "fence.d.ts")
+	expected, err := os.ReadFile(expectedPath) //nolint:gosec // reading repo fixture in tests
+	if err != nil {
+		t.Fatalf("failed to read type definitions: %v", err)
+	}
+
+	if string(expected) != string(generated) {
+		t.Fatalf("type definitions are stale: run `go run ./tools/generate-config-types`")
+	}
+}
+
+func TestGenerateTypeScript(t *testing.T) {
+	generated, err := GenerateTypeScript()
+	if err != nil {
+		t.Fatalf("GenerateTypeScript() failed: %v", err)
+	}
+	types := string(generated)
+
+	for _, want := range []string{
+		"export interface Config {",
+		"export interface NetworkConfig {",
+		"export interface FilesystemConfig {",
+		"export interface CommandConfig {",
+		"export interface SSHConfig {",
+		"export interface ResourceLimits {",
+		"  network?: NetworkConfig;\n",
+		"  allowedDomains?: string[];\n",
+		"  allowedPorts?: number[];\n",
+		"  allowPty?: boolean;\n",
+		"  allowLocalOutbound?: boolean | null;\n",
+		"  /** Paths that may not be written, even inside allowWrite */\n  denyWrite?: string[];\n",
+	} {
+		if !strings.Contains(types, want) {
+			t.Errorf("generated types missing %q", want)
+		}
+	}
+	if n := strings.Count(types, "export interface NetworkConfig"); n != 1 {
+		t.Errorf("NetworkConfig declared %d times, want once", n)
+	}
+}
+
+func TestTypeScriptType(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{"", "string"},
+		{0, "number"},
+		{false, "boolean"},
+		{[]string{}, "string[]"},
+		{new(bool), "boolean | null"},
+		{[]*int{}, "(number | null)[]"},
+	}
+	for _, tt := range tests {
+		got, _, err := typeScriptType(reflect.TypeOf(tt.value))
+		if err != nil || got != tt.want {
+			t.Errorf("typeScriptType(%T) = %q, %v; want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Use-Tusk/fence/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// aiderConfigName is the file name of Aider's YAML config.
+const aiderConfigName = ".aider.conf.yml"
+
+// AiderSettings represents the parts of .aider.conf.yml that map to fence rules.
+// Aider names its options "read" and "test-cmd"; the "read-only-files" and
+// "auto-test-cmd" spellings are accepted as well.
+type AiderSettings struct {
+	ReadOnlyFiles aiderStringList `yaml:"read-only-files"`
+	Read          aiderStringList `yaml:"read"`
+	AutoTestCmd   string          `yaml:"auto-test-cmd"`
+	TestCmd       string          `yaml:"test-cmd"`
+}
+
+// aiderStringList accepts either a single string or a list of strings, since
+// Aider options that can be repeated may be written either way.
+type aiderStringList []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (l *aiderStringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var s string
+		if err := node.Decode(&s); err != nil {
+			return err
+		}
+		*l = aiderStringList{s}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// readOnlyFiles returns the read-only file entries from both option names.
+func (s *AiderSettings) readOnlyFiles() []string {
+	return append(append([]string{}, s.ReadOnlyFiles...), s.Read...)
+}
+
+// testCmd returns the configured test command, preferring auto-test-cmd.
+func (s *AiderSettings) testCmd() string {
+	if cmd := strings.TrimSpace(s.AutoTestCmd); cmd != "" {
+		return cmd
+	}
+	return strings.TrimSpace(s.TestCmd)
+}
+
+// DefaultAiderConfigPath returns the Aider config path: .aider.conf.yml in the
+// current directory if it exists, otherwise the one in the home directory.
+func DefaultAiderConfigPath() string {
+	if cwd, err := os.Getwd(); err == nil {
+		path := filepath.Join(cwd, aiderConfigName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, aiderConfigName)
+}
+
+// LoadAiderConfig loads Aider settings from a .aider.conf.yml file.
+func LoadAiderConfig(path string) (*AiderSettings, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // user-provided path - intentional
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Aider config: %w", err)
+	}
+
+	// Handle empty file
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return &AiderSettings{}, nil
+	}
+
+	var settings AiderSettings
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("invalid YAML in Aider config: %w", err)
+	}
+
+	return &settings, nil
+}
+
+// ConvertAiderToFence converts Aider settings to a fence config.
+// Read-only files become filesystem.denyWrite entries and the test command
+// is allowed.
+func ConvertAiderToFence(settings *AiderSettings) *config.Config {
+	cfg := config.Default()
+
+	for _, path := range settings.readOnlyFiles() {
+		if path = normalizeClaudePath(path); path != "" {
+			cfg.Filesystem.DenyWrite = appendUnique(cfg.Filesystem.DenyWrite, path)
+		}
+	}
+
+	if cmd := settings.testCmd(); cmd != "" {
+		cfg.Command.Allow = appendUnique(cfg.Command.Allow, cmd)
+	}
+
+	return cfg
+}
+
+// ImportFromAider imports settings from Aider and returns a fence config.
+// If path is empty, it tries the default Aider config path.
+func ImportFromAider(path string, opts ImportOptions) (*ImportResult, error) {
+	if path == "" {
+		path = DefaultAiderConfigPath()
+	}
+
+	if path == "" {
+		return nil, fmt.Errorf("could not determine Aider config path")
+	}
+
+	settings, err := LoadAiderConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := ConvertAiderToFence(settings)
+
+	// Set extends if specified
+	if opts.Extends != "" {
+		cfg.Extends = opts.Extends
+	}
+
+	result := &ImportResult{
+		Config:        cfg,
+		SourcePath:    path,
+		RulesImported: len(settings.readOnlyFiles()),
+		Stats: ConversionStats{
+			FilesystemDenyWriteConverted: len(settings.readOnlyFiles()),
+		},
+	}
+
+	if cmd := settings.testCmd(); cmd != "" {
+		result.RulesImported++
+		result.Stats.CommandAllowConverted++
+		result.Warnings = append(result.Warnings,
+			fmt.Sprintf("Test command %q allowed; fence cannot restrict what the command itself runs", cmd))
+	}
+
+	if err := applyDryRun(result, opts); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,172 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertAiderToFence(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      *AiderSettings
+		wantDenyWrite []string
+		wantCmdAllow  []string
+	}{
+		{
+			name:     "empty settings",
+			settings: &AiderSettings{},
+		},
+		{
+			name: "read-only files",
+			settings: &AiderSettings{
+				ReadOnlyFiles: aiderStringList{"CONVENTIONS.md", "docs/**"},
+			},
+			wantDenyWrite: []string{"CONVENTIONS.md", "docs/**"},
+		},
+		{
+			name: "read option is merged with read-only-files",
+			settings: &AiderSettings{
+				ReadOnlyFiles: aiderStringList{"CONVENTIONS.md"},
+				Read:          aiderStringList{"CONVENTIONS.md", "README.md"},
+			},
+			wantDenyWrite: []string{"CONVENTIONS.md", "README.md"},
+		},
+		{
+			name: "auto-test-cmd is allowed",
+			settings: &AiderSettings{
+				AutoTestCmd: " pytest -x ",
+			},
+			wantCmdAllow: []string{"pytest -x"},
+		},
+		{
+			name: "auto-test-cmd is preferred over test-cmd",
+			settings: &AiderSettings{
+				AutoTestCmd: "go test ./...",
+				TestCmd:     "make test",
+			},
+			wantCmdAllow: []string{"go test ./..."},
+		},
+		{
+			name: "test-cmd",
+			settings: &AiderSettings{
+				TestCmd: "npm test",
+			},
+			wantCmdAllow: []string{"npm test"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ConvertAiderToFence(tt.settings)
+
+			assert.ElementsMatch(t, tt.wantDenyWrite, cfg.Filesystem.DenyWrite, "filesystem.denyWrite mismatch")
+			assert.ElementsMatch(t, tt.wantCmdAllow, cfg.Command.Allow, "command.allow mismatch")
+			assert.Empty(t, cfg.Command.Deny)
+		})
+	}
+}
+
+func TestLoadAiderConfig(t *testing.T) {
+	t.Run("list and scalar values", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".aider.conf.yml")
+
+		content := `# Aider settings
+model: sonnet
+read-only-files:
+  - CONVENTIONS.md
+  - docs/**
+read: README.md
+auto-test-cmd: pytest
+`
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600)) //nolint:gosec // test file
+
+		settings, err := LoadAiderConfig(path)
+		require.NoError(t, err)
+
+		assert.Equal(t, aiderStringList{"CONVENTIONS.md", "docs/**"}, settings.ReadOnlyFiles)
+		assert.Equal(t, aiderStringList{"README.md"}, settings.Read)
+		assert.Equal(t, "pytest", settings.AutoTestCmd)
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".aider.conf.yml")
+		require.NoError(t, os.WriteFile(path, []byte(""), 0o600)) //nolint:gosec // test file
+
+		settings, err := LoadAiderConfig(path)
+		require.NoError(t, err)
+		assert.NotNil(t, settings)
+	})
+
+	t.Run("file not found", func(t *testing.T) {
+		_, err := LoadAiderConfig("/nonexistent/path/.aider.conf.yml")
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid yaml", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".aider.conf.yml")
+		require.NoError(t, os.WriteFile(path, []byte("read-only-files: [a, b\n"), 0o600)) //nolint:gosec // test file
+
+		_, err := LoadAiderConfig(path)
+		assert.Error(t, err)
+	})
+}
+
+func TestDefaultAiderConfigPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	cwd := t.TempDir()
+	t.Chdir(cwd)
+
+	assert.Equal(t, filepath.Join(home, ".aider.conf.yml"), DefaultAiderConfigPath())
+
+	require.NoError(t, os.WriteFile(filepath.Join(cwd, ".aider.conf.yml"), []byte("read: a\n"), 0o600)) //nolint:gosec // test file
+	got, err := filepath.EvalSymlinks(DefaultAiderConfigPath())
+	require.NoError(t, err)
+	want, err := filepath.EvalSymlinks(filepath.Join(cwd, ".aider.conf.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestImportFromAider(t *testing.T) {
+	t.Run("read-only files and test command", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".aider.conf.yml")
+
+		content := `read-only-files: [CONVENTIONS.md, docs/**]
+auto-test-cmd: go test ./...
+`
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600)) //nolint:gosec // test file
+
+		result, err := ImportFromAider(path, DefaultImportOptions())
+		require.NoError(t, err)
+
+		assert.Equal(t, path, result.SourcePath)
+		assert.Equal(t, 3, result.RulesImported)
+		assert.Equal(t, "code", result.Config.Extends)
+		assert.Equal(t, []string{"CONVENTIONS.md", "docs/**"}, result.Config.Filesystem.DenyWrite)
+		assert.Equal(t, []string{"go test ./..."}, result.Config.Command.Allow)
+		require.Len(t, result.Warnings, 1)
+		assert.Contains(t, result.Warnings[0], "go test ./...")
+		assert.Equal(t, ConversionStats{
+			CommandAllowConverted:        1,
+			FilesystemDenyWriteConverted: 2,
+		}, result.Stats)
+	})
+
+	t.Run("no test command means no warning", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".aider.conf.yml")
+		require.NoError(t, os.WriteFile(path, []byte("read: CONVENTIONS.md\n"), 0o600)) //nolint:gosec // test file
+
+		result, err := ImportFromAider(path, ImportOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, result.RulesImported)
+		assert.Equal(t, "", result.Config.Extends)
+		assert.Empty(t, result.Warnings)
+		assert.Empty(t, result.Config.Command.Allow)
+	})
+}
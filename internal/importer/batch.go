@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
+
+// batchSource is a tool that BatchImport looks for.
+type batchSource struct {
+	name        string
+	relPath     string // Settings path relative to the directory being probed
+	defaultPath func() string
+	importFn    func(path string, opts ImportOptions) (*ImportResult, error)
+}
+
+// batchSources lists the tools BatchImport probes, in merge order.
+var batchSources = []batchSource{
+	{"Claude Code", filepath.Join(".claude", "settings.json"), DefaultClaudeSettingsPath, ImportFromClaude},
+	{"Cursor", filepath.Join(".cursor", "mcp.json"), DefaultCursorSettingsPath, ImportFromCursor},
+	{"Gemini CLI", filepath.Join(".gemini", "settings.json"), DefaultGeminiSettingsPath, ImportFromGemini},
+	{"Aider", aiderConfigName, DefaultAiderConfigPath, ImportFromAider},
+}
+
+// BatchImport imports the settings of every supported tool found in dir and
+// merges them into one config. If dir is empty, each tool's default settings
+// path is used. Missing files are skipped; files that fail to parse are
+// reported as warnings. It is an error if nothing could be imported.
+func BatchImport(dir string, opts ImportOptions) (*ImportResult, error) {
+	var results []*ImportResult
+	var warnings []string
+	for _, source := range batchSources {
+		path := source.defaultPath()
+		if dir != "" {
+			path = filepath.Join(dir, source.relPath)
+		}
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		// Extends and the preview apply to the merged config
+		result, err := source.importFn(path, ImportOptions{})
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s settings skipped: %v", source.name, err))
+			continue
+		}
+		results = append(results, result)
+	}
+
+	if len(results) == 0 {
+		if len(warnings) > 0 {
+			return nil, errors.New(strings.Join(warnings, "; "))
+		}
+		return nil, errors.New("no supported tool settings found")
+	}
+
+	merged, err := MergeImportResults(results, opts)
+	if err != nil {
+		return nil, err
+	}
+	merged.Warnings = append(warnings, merged.Warnings...)
+	return merged, nil
+}
+
+// MergeImportResults merges the configs of several imports, in order, into
+// one result. Rule counts and stats are summed and warnings are prefixed with
+// the file they came from. mergeOpts applies to the merged config.
+func MergeImportResults(results []*ImportResult, mergeOpts ImportOptions) (*ImportResult, error) {
+	if len(results) == 0 {
+		return nil, errors.New("no import results to merge")
+	}
+
+	merged := &ImportResult{}
+	var cfg *config.Config
+	var sources []string
+	for _, result := range results {
+		cfg = config.Merge(cfg, result.Config)
+		sources = append(sources, result.SourcePath)
+		merged.RulesImported += result.RulesImported
+		merged.Stats = merged.Stats.add(result.Stats)
+		for _, warning := range result.Warnings {
+			merged.Warnings = append(merged.Warnings, result.SourcePath+": "+warning)
+		}
+	}
+
+	cfg.Extends = mergeOpts.Extends
+	merged.Config = cfg
+	merged.SourcePath = strings.Join(sources, ", ")
+
+	if err := applyDryRun(merged, mergeOpts); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
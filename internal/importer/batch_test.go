@@ -0,0 +1,119 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600)) //nolint:gosec // test file
+}
+
+func TestBatchImport(t *testing.T) {
+	t.Run("merges every tool found", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, ".claude", "settings.json"),
+			`{"permissions": {"allow": ["Bash(npm install)"], "deny": ["Read(./.env)"], "ask": ["Bash(git push)"]}}`)
+		writeTestFile(t, filepath.Join(dir, ".cursor", "mcp.json"),
+			`{"allowedTools": ["Shell(make)"], "blockedTools": ["Shell(curl:*)", "Shell(git push)"]}`)
+		writeTestFile(t, filepath.Join(dir, ".gemini", "settings.json"),
+			`{"permissions": {"deny": ["FileWrite(./.git/**)"]}}`)
+		writeTestFile(t, filepath.Join(dir, ".aider.conf.yml"),
+			"read: CONVENTIONS.md\ntest-cmd: go test ./...\n")
+
+		result, err := BatchImport(dir, DefaultImportOptions())
+		require.NoError(t, err)
+
+		cfg := result.Config
+		assert.Equal(t, "code", cfg.Extends)
+		assert.ElementsMatch(t, []string{"npm install", "make", "go test ./..."}, cfg.Command.Allow)
+		assert.ElementsMatch(t, []string{"git push", "curl"}, cfg.Command.Deny)
+		assert.Equal(t, []string{"./.env"}, cfg.Filesystem.DenyRead)
+		assert.ElementsMatch(t, []string{"./.git/**", "CONVENTIONS.md"}, cfg.Filesystem.DenyWrite)
+
+		assert.Equal(t, 3+3+1+2, result.RulesImported)
+		assert.Equal(t, 6, result.Stats.CommandAllowConverted+result.Stats.CommandDenyConverted)
+		assert.Equal(t, 1, result.Stats.AskRulesConverted)
+		for _, name := range []string{".claude", ".cursor", ".gemini", ".aider.conf.yml"} {
+			assert.Contains(t, result.SourcePath, name)
+		}
+
+		// Per-file warnings name their source
+		require.NotEmpty(t, result.Warnings)
+		assert.True(t, strings.HasPrefix(result.Warnings[0], filepath.Join(dir, ".claude", "settings.json")+": "), result.Warnings[0])
+	})
+
+	t.Run("missing files are skipped and parse errors are warnings", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, ".cursor", "mcp.json"), `{"allowedTools": ["Shell(make)"]}`)
+		writeTestFile(t, filepath.Join(dir, ".gemini", "settings.json"), "not json")
+
+		result, err := BatchImport(dir, ImportOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"make"}, result.Config.Command.Allow)
+		assert.Equal(t, "", result.Config.Extends)
+		require.Len(t, result.Warnings, 1)
+		assert.Contains(t, result.Warnings[0], "Gemini CLI settings skipped")
+	})
+
+	t.Run("dry run previews the merged config", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, ".cursor", "mcp.json"), `{"allowedTools": ["Shell(make)"]}`)
+
+		result, err := BatchImport(dir, ImportOptions{DryRun: true})
+		require.NoError(t, err)
+		assert.Contains(t, result.Preview, `"make"`)
+	})
+
+	t.Run("nothing found", func(t *testing.T) {
+		_, err := BatchImport(t.TempDir(), DefaultImportOptions())
+		assert.Error(t, err)
+	})
+
+	t.Run("only unparseable files", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, ".claude", "settings.json"), "{")
+
+		_, err := BatchImport(dir, DefaultImportOptions())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Claude Code settings skipped")
+	})
+}
+
+func TestMergeImportResults(t *testing.T) {
+	first := &ImportResult{
+		Config:        ConvertClaudeToFence(&ClaudeSettings{Permissions: ClaudePermissions{Allow: []string{"Bash(ls)"}}}),
+		SourcePath:    "a.json",
+		RulesImported: 1,
+		Stats:         ConversionStats{CommandAllowConverted: 1},
+		Warnings:      []string{"first"},
+	}
+	second := &ImportResult{
+		Config:        ConvertClaudeToFence(&ClaudeSettings{Permissions: ClaudePermissions{Allow: []string{"Bash(ls)", "Bash(pwd)"}}}),
+		SourcePath:    "b.json",
+		RulesImported: 2,
+		Stats:         ConversionStats{CommandAllowConverted: 2},
+	}
+
+	merged, err := MergeImportResults([]*ImportResult{first, second}, ImportOptions{Extends: "code"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"ls", "pwd"}, merged.Config.Command.Allow)
+	assert.Equal(t, "code", merged.Config.Extends)
+	assert.Equal(t, "a.json, b.json", merged.SourcePath)
+	assert.Equal(t, 3, merged.RulesImported)
+	assert.Equal(t, 3, merged.Stats.CommandAllowConverted)
+	assert.Equal(t, []string{"a.json: first"}, merged.Warnings)
+	assert.Empty(t, merged.Preview)
+
+	_, err = MergeImportResults(nil, ImportOptions{})
+	assert.Error(t, err)
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/Use-Tusk/fence/internal/config"
 	"github.com/tidwall/jsonc"
@@ -79,25 +80,36 @@ func LoadClaudeSettings(path string) (*ClaudeSettings, error) {
 
 // ConvertClaudeToFence converts Claude Code settings to a fence config.
 func ConvertClaudeToFence(settings *ClaudeSettings) *config.Config {
+	cfg, _ := convertClaude(settings)
+	return cfg
+}
+
+// convertClaude converts Claude Code settings and counts the converted rules.
+func convertClaude(settings *ClaudeSettings) (*config.Config, ConversionStats) {
 	cfg := config.Default()
+	var stats ConversionStats
 
 	// Process allow rules
 	for _, rule := range settings.Permissions.Allow {
-		processClaudeRule(rule, cfg, true)
+		stats.record(rule, processClaudeRule(rule, cfg, true))
 	}
 
 	// Process deny rules
 	for _, rule := range settings.Permissions.Deny {
-		processClaudeRule(rule, cfg, false)
+		stats.record(rule, processClaudeRule(rule, cfg, false))
 	}
 
 	// Process ask rules (treat as deny for fence, since fence doesn't have interactive prompts)
 	// Users can review and move to allow if needed
 	for _, rule := range settings.Permissions.Ask {
-		processClaudeRule(rule, cfg, false)
+		target := processClaudeRule(rule, cfg, false)
+		stats.record(rule, target)
+		if target != targetNone {
+			stats.AskRulesConverted++
+		}
 	}
 
-	return cfg
+	return cfg, stats
 }
 
 // bashPattern matches Bash permission rules like "Bash(npm run test:*)" or "Bash(curl:*)"
@@ -112,68 +124,63 @@ var writePattern = regexp.MustCompile(`^Write\((.+)\)$`)
 // editPattern matches Edit permission rules (similar to Write)
 var editPattern = regexp.MustCompile(`^Edit\((.+)\)$`)
 
-// processClaudeRule processes a single Claude permission rule and updates the fence config.
-func processClaudeRule(rule string, cfg *config.Config, isAllow bool) {
+// processClaudeRule processes a single Claude permission rule and updates the
+// fence config. It returns the fence rule list the rule was added to, or
+// targetNone if it has no fence equivalent.
+func processClaudeRule(rule string, cfg *config.Config, isAllow bool) ruleTarget {
 	rule = strings.TrimSpace(rule)
 	if rule == "" {
-		return
+		return targetNone
 	}
 
 	// Handle Bash(command) rules
 	if matches := bashPattern.FindStringSubmatch(rule); len(matches) == 2 {
 		cmd := normalizeClaudeCommand(matches[1])
-		if cmd != "" {
-			if isAllow {
-				cfg.Command.Allow = appendUnique(cfg.Command.Allow, cmd)
-			} else {
-				cfg.Command.Deny = appendUnique(cfg.Command.Deny, cmd)
-			}
+		if cmd == "" {
+			return targetNone
 		}
-		return
+		if isAllow {
+			cfg.Command.Allow = appendUnique(cfg.Command.Allow, cmd)
+			return targetCommandAllow
+		}
+		cfg.Command.Deny = appendUnique(cfg.Command.Deny, cmd)
+		return targetCommandDeny
 	}
 
 	// Handle Read(path) rules
 	if matches := readPattern.FindStringSubmatch(rule); len(matches) == 2 {
 		path := normalizeClaudePath(matches[1])
-		if path != "" {
-			if !isAllow {
-				// Read deny -> filesystem.denyRead
-				cfg.Filesystem.DenyRead = appendUnique(cfg.Filesystem.DenyRead, path)
-			}
-			// Note: fence doesn't have an "allowRead" concept - everything is readable by default
+		// Note: fence doesn't have an "allowRead" concept - everything is readable by default
+		if path == "" || isAllow {
+			return targetNone
 		}
-		return
+		// Read deny -> filesystem.denyRead
+		cfg.Filesystem.DenyRead = appendUnique(cfg.Filesystem.DenyRead, path)
+		return targetDenyRead
 	}
 
-	// Handle Write(path) rules
-	if matches := writePattern.FindStringSubmatch(rule); len(matches) == 2 {
-		path := normalizeClaudePath(matches[1])
-		if path != "" {
-			if isAllow {
-				cfg.Filesystem.AllowWrite = appendUnique(cfg.Filesystem.AllowWrite, path)
-			} else {
-				cfg.Filesystem.DenyWrite = appendUnique(cfg.Filesystem.DenyWrite, path)
-			}
-		}
-		return
+	// Handle Write(path) and Edit(path) rules (Edit is the same as Write)
+	matches := writePattern.FindStringSubmatch(rule)
+	if len(matches) != 2 {
+		matches = editPattern.FindStringSubmatch(rule)
 	}
-
-	// Handle Edit(path) rules (same as Write)
-	if matches := editPattern.FindStringSubmatch(rule); len(matches) == 2 {
+	if len(matches) == 2 {
 		path := normalizeClaudePath(matches[1])
-		if path != "" {
-			if isAllow {
-				cfg.Filesystem.AllowWrite = appendUnique(cfg.Filesystem.AllowWrite, path)
-			} else {
-				cfg.Filesystem.DenyWrite = appendUnique(cfg.Filesystem.DenyWrite, path)
-			}
+		if path == "" {
+			return targetNone
+		}
+		if isAllow {
+			cfg.Filesystem.AllowWrite = appendUnique(cfg.Filesystem.AllowWrite, path)
+			return targetAllowWrite
 		}
-		return
+		cfg.Filesystem.DenyWrite = appendUnique(cfg.Filesystem.DenyWrite, path)
+		return targetDenyWrite
 	}
 
 	// Handle bare tool names (e.g., "Read", "Write", "Bash")
 	// These are global permissions that don't map directly to fence's path-based model
 	// We skip them as they don't provide actionable path/command restrictions
+	return targetNone
 }
 
 // normalizeClaudeCommand converts Claude's command format to fence format.
@@ -217,13 +224,121 @@ type ImportResult struct {
 	Config        *config.Config
 	SourcePath    string
 	RulesImported int
+	Stats         ConversionStats
 	Warnings      []string
+	// Preview is the config file content that WriteConfig would write. It is
+	// only set for dry runs.
+	Preview string
+}
+
+// ConversionStats breaks down how the rules of an import were converted.
+// A demoted ask rule counts both in AskRulesConverted and in the deny
+// category it was converted to.
+type ConversionStats struct {
+	CommandAllowConverted         int
+	CommandDenyConverted          int
+	FilesystemDenyReadConverted   int
+	FilesystemAllowWriteConverted int
+	FilesystemDenyWriteConverted  int
+	GlobalRulesSkipped            int // Bare tool names such as "Read"
+	UnsupportedRulesSkipped       int // Rules with a target but no fence equivalent
+	AskRulesConverted             int // Ask rules demoted to deny
+}
+
+// Converted returns the number of rules that were converted to fence rules.
+func (s ConversionStats) Converted() int {
+	return s.CommandAllowConverted + s.CommandDenyConverted +
+		s.FilesystemDenyReadConverted + s.FilesystemAllowWriteConverted +
+		s.FilesystemDenyWriteConverted
+}
+
+// Skipped returns the number of rules that were not converted.
+func (s ConversionStats) Skipped() int {
+	return s.GlobalRulesSkipped + s.UnsupportedRulesSkipped
+}
+
+// add returns the sum of s and other.
+func (s ConversionStats) add(other ConversionStats) ConversionStats {
+	return ConversionStats{
+		CommandAllowConverted:         s.CommandAllowConverted + other.CommandAllowConverted,
+		CommandDenyConverted:          s.CommandDenyConverted + other.CommandDenyConverted,
+		FilesystemDenyReadConverted:   s.FilesystemDenyReadConverted + other.FilesystemDenyReadConverted,
+		FilesystemAllowWriteConverted: s.FilesystemAllowWriteConverted + other.FilesystemAllowWriteConverted,
+		FilesystemDenyWriteConverted:  s.FilesystemDenyWriteConverted + other.FilesystemDenyWriteConverted,
+		GlobalRulesSkipped:            s.GlobalRulesSkipped + other.GlobalRulesSkipped,
+		UnsupportedRulesSkipped:       s.UnsupportedRulesSkipped + other.UnsupportedRulesSkipped,
+		AskRulesConverted:             s.AskRulesConverted + other.AskRulesConverted,
+	}
+}
+
+// Summary formats the stats as a table with one row per category.
+func (s ConversionStats) Summary() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	rows := []struct {
+		name  string
+		count int
+	}{
+		{"command.allow", s.CommandAllowConverted},
+		{"command.deny", s.CommandDenyConverted},
+		{"filesystem.denyRead", s.FilesystemDenyReadConverted},
+		{"filesystem.allowWrite", s.FilesystemAllowWriteConverted},
+		{"filesystem.denyWrite", s.FilesystemDenyWriteConverted},
+		{"ask rules demoted to deny", s.AskRulesConverted},
+		{"global rules skipped", s.GlobalRulesSkipped},
+		{"unsupported rules skipped", s.UnsupportedRulesSkipped},
+	}
+	fmt.Fprintln(w, "Rule type\tCount")
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\t%d\n", row.name, row.count)
+	}
+	_ = w.Flush()
+	return b.String()
+}
+
+// ruleTarget identifies the fence rule list a converted rule was added to.
+type ruleTarget int
+
+const (
+	targetNone ruleTarget = iota
+	targetCommandAllow
+	targetCommandDeny
+	targetDenyRead
+	targetAllowWrite
+	targetDenyWrite
+)
+
+// record counts one source rule that was converted to target.
+func (s *ConversionStats) record(rule string, target ruleTarget) {
+	switch target {
+	case targetCommandAllow:
+		s.CommandAllowConverted++
+	case targetCommandDeny:
+		s.CommandDenyConverted++
+	case targetDenyRead:
+		s.FilesystemDenyReadConverted++
+	case targetAllowWrite:
+		s.FilesystemAllowWriteConverted++
+	case targetDenyWrite:
+		s.FilesystemDenyWriteConverted++
+	default:
+		switch {
+		case strings.TrimSpace(rule) == "":
+		case isGlobalToolRule(rule):
+			s.GlobalRulesSkipped++
+		default:
+			s.UnsupportedRulesSkipped++
+		}
+	}
 }
 
 // ImportOptions configures the import behavior.
 type ImportOptions struct {
 	// Extends specifies a template or file to extend. Empty string means no extends.
 	Extends string
+	// DryRun fills in ImportResult.Preview so the config can be shown
+	// instead of written.
+	DryRun bool
 }
 
 // DefaultImportOptions returns the default import options.
@@ -250,7 +365,7 @@ func ImportFromClaude(path string, opts ImportOptions) (*ImportResult, error) {
 		return nil, err
 	}
 
-	cfg := ConvertClaudeToFence(settings)
+	cfg, stats := convertClaude(settings)
 
 	// Set extends if specified
 	if opts.Extends != "" {
@@ -263,6 +378,7 @@ func ImportFromClaude(path string, opts ImportOptions) (*ImportResult, error) {
 		RulesImported: len(settings.Permissions.Allow) +
 			len(settings.Permissions.Deny) +
 			len(settings.Permissions.Ask),
+		Stats: stats,
 	}
 
 	// Add warnings for rules that couldn't be fully converted
@@ -288,9 +404,26 @@ func ImportFromClaude(path string, opts ImportOptions) (*ImportResult, error) {
 		}
 	}
 
+	if err := applyDryRun(result, opts); err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
 
+// applyDryRun sets the preview of a dry-run import.
+func applyDryRun(result *ImportResult, opts ImportOptions) error {
+	if !opts.DryRun {
+		return nil
+	}
+	preview, err := FormatConfigWithComment(result.Config)
+	if err != nil {
+		return fmt.Errorf("failed to format config preview: %w", err)
+	}
+	result.Preview = preview
+	return nil
+}
+
 // isGlobalToolRule checks if a rule is a global tool permission (no path/command specified).
 func isGlobalToolRule(rule string) bool {
 	rule = strings.TrimSpace(rule)
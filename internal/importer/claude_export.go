@@ -0,0 +1,167 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Use-Tusk/fence/internal/config"
+	"github.com/tidwall/jsonc"
+)
+
+// ExportToClaude converts a fence config to Claude Code settings, reversing
+// ConvertClaudeToFence. Only the rules in cfg itself are exported; resolve
+// extends first to include inherited rules. The returned warnings describe
+// settings that have no Claude equivalent and were skipped.
+//
+// Fence command rules match by prefix. Allowed commands are exported as exact
+// Bash(cmd) rules and denied commands as Bash(cmd:*) prefix rules, so Claude
+// never permits more than fence does. Constraints such as priority:N are
+// dropped from the exported prefix, and allow rules whose constraints Claude
+// cannot express (workdir:, maxArgs:, when:) are skipped.
+func ExportToClaude(cfg *config.Config) (*ClaudeSettings, []string) {
+	settings := &ClaudeSettings{
+		Permissions: ClaudePermissions{
+			Allow: []string{},
+			Deny:  []string{},
+			Ask:   []string{},
+		},
+	}
+	perms := &settings.Permissions
+
+	for _, rule := range cfg.Command.Allow {
+		if prefix, ok := claudeAllowPrefix(rule); ok {
+			perms.Allow = appendUnique(perms.Allow, "Bash("+prefix+")")
+		}
+	}
+	for _, rule := range cfg.Command.Deny {
+		prefix := rule
+		if parsed, err := config.ParseCommandRule(rule); err == nil {
+			prefix = parsed.Prefix
+		}
+		perms.Deny = appendUnique(perms.Deny, "Bash("+prefix+":*)")
+	}
+
+	for _, path := range cfg.Filesystem.DenyRead {
+		perms.Deny = appendUnique(perms.Deny, "Read("+path+")")
+	}
+	// Claude checks Write and Edit separately, so both are needed to cover
+	// what a fence write rule covers
+	for _, path := range cfg.Filesystem.WritablePaths() {
+		perms.Allow = appendUnique(perms.Allow, "Write("+path+")")
+		perms.Allow = appendUnique(perms.Allow, "Edit("+path+")")
+	}
+	for _, path := range cfg.Filesystem.DenyWrite {
+		perms.Deny = appendUnique(perms.Deny, "Write("+path+")")
+		perms.Deny = appendUnique(perms.Deny, "Edit("+path+")")
+	}
+
+	return settings, claudeExportWarnings(cfg)
+}
+
+// claudeAllowPrefix returns the command prefix of a command.allow rule, or
+// false if the rule cannot be exported without allowing more than fence does.
+// A denied rule with the same constraints can be exported as a plain prefix,
+// since denying more is safe.
+func claudeAllowPrefix(rule string) (string, bool) {
+	parsed, err := config.ParseCommandRule(rule)
+	if err != nil || parsed.Workdir != "" || parsed.HasMaxArgs() || parsed.When != "" {
+		return "", false
+	}
+	return parsed.Prefix, true
+}
+
+// claudeExportWarnings lists the parts of cfg that ExportToClaude cannot express.
+func claudeExportWarnings(cfg *config.Config) []string {
+	var warnings []string
+	if cfg.Extends != "" {
+		warnings = append(warnings,
+			fmt.Sprintf("Rules inherited from %q are not exported (only this config's own rules are)", cfg.Extends))
+	}
+
+	var cidrs []string
+	domains := 0
+	for _, entry := range append(append([]string{}, cfg.Network.AllowedDomains...), cfg.Network.DeniedDomains...) {
+		if parsed, err := config.ParseDomainEntry(entry); err == nil && parsed.Kind == config.DomainCIDR {
+			cidrs = append(cidrs, entry)
+		} else {
+			domains++
+		}
+	}
+	if domains > 0 {
+		warnings = append(warnings,
+			fmt.Sprintf("%d network domain rule(s) skipped (Claude permissions have no network rules)", domains))
+	}
+	if len(cidrs) > 0 {
+		warnings = append(warnings,
+			fmt.Sprintf("CIDR block(s) %s skipped (Claude permissions have no network rules)", strings.Join(cidrs, ", ")))
+	}
+	for _, rule := range cfg.Command.Allow {
+		if _, ok := claudeAllowPrefix(rule); !ok {
+			warnings = append(warnings,
+				fmt.Sprintf("command.allow rule %q skipped (Claude rules have no workdir:, maxArgs: or when: constraints)", rule))
+		}
+	}
+	if len(cfg.Network.AllowedPorts) > 0 || len(cfg.Network.DeniedPorts) > 0 {
+		warnings = append(warnings, "network.allowedPorts/deniedPorts skipped (Claude has no port filters)")
+	}
+	if cfg.ResourceLimits != (config.ResourceLimits{}) {
+		warnings = append(warnings, "resourceLimits skipped (Claude has no resource limits)")
+	}
+	if len(cfg.Filesystem.AllowRead) > 0 || cfg.Filesystem.DefaultDenyRead {
+		warnings = append(warnings, "filesystem.allowRead/defaultDenyRead skipped (Claude reads are allowed by default)")
+	}
+	if len(cfg.Filesystem.DenyExecute) > 0 {
+		warnings = append(warnings, "filesystem.denyExecute skipped (Claude rules match commands, not executable paths)")
+	}
+	return warnings
+}
+
+// WriteClaudeSettings writes settings to a Claude Code settings.json file.
+// Only the "allow" and "deny" lists inside "permissions" are replaced; other
+// keys, including other permissions such as "ask" or "defaultMode", are
+// kept, although comments are not preserved.
+func WriteClaudeSettings(settings *ClaudeSettings, path string) error {
+	doc := map[string]json.RawMessage{}
+	if data, err := os.ReadFile(path); err == nil { //nolint:gosec // user-provided path - intentional
+		if len(strings.TrimSpace(string(data))) > 0 {
+			if err := json.Unmarshal(jsonc.ToJSON(data), &doc); err != nil {
+				return fmt.Errorf("invalid JSON in Claude settings: %w", err)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read Claude settings: %w", err)
+	}
+
+	perms := map[string]json.RawMessage{}
+	if raw, ok := doc["permissions"]; ok {
+		if err := json.Unmarshal(raw, &perms); err != nil {
+			return fmt.Errorf("invalid permissions in Claude settings: %w", err)
+		}
+	}
+	for key, rules := range map[string][]string{
+		"allow": settings.Permissions.Allow,
+		"deny":  settings.Permissions.Deny,
+	} {
+		raw, err := json.Marshal(rules)
+		if err != nil {
+			return fmt.Errorf("failed to marshal Claude permissions: %w", err)
+		}
+		perms[key] = raw
+	}
+	raw, err := json.Marshal(perms)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Claude permissions: %w", err)
+	}
+	doc["permissions"] = raw
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal Claude settings: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write Claude settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package importer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Use-Tusk/fence/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportToClaude(t *testing.T) {
+	cfg := config.Default()
+	cfg.Command.Allow = []string{"npm install", "git status"}
+	cfg.Command.Deny = []string{"curl", "git push"}
+	cfg.Filesystem.DenyRead = []string{"./.env"}
+	cfg.Filesystem.AllowWrite = []string{"./dist/**", "os:plan9:/tmp/x"}
+	cfg.Filesystem.DenyWrite = []string{"./.git/**"}
+
+	settings, warnings := ExportToClaude(cfg)
+
+	assert.Equal(t, []string{
+		"Bash(npm install)",
+		"Bash(git status)",
+		"Write(./dist/**)",
+		"Edit(./dist/**)",
+	}, settings.Permissions.Allow)
+	assert.Equal(t, []string{
+		"Bash(curl:*)",
+		"Bash(git push:*)",
+		"Read(./.env)",
+		"Write(./.git/**)",
+		"Edit(./.git/**)",
+	}, settings.Permissions.Deny)
+	assert.Empty(t, settings.Permissions.Ask)
+	assert.Empty(t, warnings)
+}
+
+func TestExportToClaudeCommandConstraints(t *testing.T) {
+	cfg := config.Default()
+	cfg.Command.Allow = []string{
+		"npm run build priority:10",
+		"python3 maxArgs:1",
+		"workdir:/workspace npm publish",
+		"npm publish when:git-tag-matches:v*",
+	}
+	cfg.Command.Deny = []string{"git push priority:5", "rm -rf reason:destructive"}
+
+	settings, warnings := ExportToClaude(cfg)
+
+	assert.Equal(t, []string{"Bash(npm run build)"}, settings.Permissions.Allow)
+	assert.Equal(t, []string{"Bash(git push:*)", "Bash(rm -rf:*)"}, settings.Permissions.Deny)
+	require.Len(t, warnings, 3)
+	all := strings.Join(warnings, "\n")
+	assert.Contains(t, all, `"python3 maxArgs:1"`)
+	assert.Contains(t, all, `"workdir:/workspace npm publish"`)
+	assert.Contains(t, all, `"npm publish when:git-tag-matches:v*"`)
+}
+
+func TestExportToClaudeWarnings(t *testing.T) {
+	cfg := config.Default()
+	cfg.Extends = "code"
+	cfg.Network.AllowedDomains = []string{"github.com", "10.0.0.0/8"}
+	cfg.Network.DeniedPorts = []int{25}
+	cfg.ResourceLimits.MaxMemoryMB = 512
+	cfg.Filesystem.DenyExecute = []string{"/usr/bin/nc"}
+
+	_, warnings := ExportToClaude(cfg)
+
+	require.Len(t, warnings, 6)
+	all := strings.Join(warnings, "\n")
+	assert.Contains(t, all, `"code"`)
+	assert.Contains(t, all, "1 network domain rule(s)")
+	assert.Contains(t, all, "10.0.0.0/8")
+	assert.Contains(t, all, "deniedPorts")
+	assert.Contains(t, all, "resourceLimits")
+	assert.Contains(t, all, "denyExecute")
+}
+
+func TestExportToClaudeRoundTrip(t *testing.T) {
+	original := &ClaudeSettings{
+		Permissions: ClaudePermissions{
+			Allow: []string{
+				"Bash(npm install)",
+				"Bash(npm run:*)",
+				"Write(./dist/**)",
+				"Edit(./build)",
+			},
+			Deny: []string{
+				"Bash(curl:*)",
+				"Bash(rm -rf /)",
+				"Read(./.env)",
+				"Write(./.git/**)",
+			},
+		},
+	}
+
+	imported := ConvertClaudeToFence(original)
+	exported, warnings := ExportToClaude(imported)
+	assert.Empty(t, warnings)
+
+	// Exporting and importing again yields the same fence rules
+	reimported := ConvertClaudeToFence(exported)
+	assert.ElementsMatch(t, imported.Command.Allow, reimported.Command.Allow)
+	assert.ElementsMatch(t, imported.Command.Deny, reimported.Command.Deny)
+	assert.ElementsMatch(t, imported.Filesystem.DenyRead, reimported.Filesystem.DenyRead)
+	assert.ElementsMatch(t, imported.Filesystem.AllowWrite, reimported.Filesystem.AllowWrite)
+	assert.ElementsMatch(t, imported.Filesystem.DenyWrite, reimported.Filesystem.DenyWrite)
+}
+
+func TestWriteClaudeSettings(t *testing.T) {
+	settings := &ClaudeSettings{
+		Permissions: ClaudePermissions{
+			Allow: []string{"Bash(npm install)"},
+			Deny:  []string{"Read(./.env)"},
+			Ask:   []string{},
+		},
+	}
+
+	t.Run("new file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "settings.json")
+		require.NoError(t, WriteClaudeSettings(settings, path))
+
+		loaded, err := LoadClaudeSettings(path)
+		require.NoError(t, err)
+		assert.Equal(t, settings.Permissions.Allow, loaded.Permissions.Allow)
+		assert.Equal(t, settings.Permissions.Deny, loaded.Permissions.Deny)
+	})
+
+	t.Run("existing keys are kept", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "settings.json")
+		content := `{
+  // user settings
+  "model": "opus",
+  "permissions": {"allow": ["Bash(old)"], "ask": ["Bash(git push:*)"], "defaultMode": "acceptEdits"}
+}`
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600)) //nolint:gosec // test file
+		require.NoError(t, WriteClaudeSettings(settings, path))
+
+		data, err := os.ReadFile(path) //nolint:gosec // test file
+		require.NoError(t, err)
+		var doc map[string]any
+		require.NoError(t, json.Unmarshal(data, &doc))
+		assert.Equal(t, "opus", doc["model"])
+
+		assert.Equal(t, "acceptEdits", doc["permissions"].(map[string]any)["defaultMode"])
+
+		loaded, err := LoadClaudeSettings(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Bash(npm install)"}, loaded.Permissions.Allow)
+		assert.Equal(t, []string{"Read(./.env)"}, loaded.Permissions.Deny)
+		assert.Equal(t, []string{"Bash(git push:*)"}, loaded.Permissions.Ask)
+	})
+
+	t.Run("invalid existing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "settings.json")
+		require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600)) //nolint:gosec // test file
+		assert.Error(t, WriteClaudeSettings(settings, path))
+	})
+}
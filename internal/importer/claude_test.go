@@ -353,6 +353,15 @@ func TestImportFromClaude(t *testing.T) {
 		assert.Contains(t, result.Config.Command.Deny, "git push") // ask -> deny
 		assert.Contains(t, result.Config.Filesystem.AllowWrite, "./dist/**")
 		assert.Contains(t, result.Config.Filesystem.DenyRead, "./.env")
+
+		assert.Equal(t, ConversionStats{
+			CommandAllowConverted:         1,
+			CommandDenyConverted:          2,
+			FilesystemDenyReadConverted:   1,
+			FilesystemAllowWriteConverted: 1,
+			AskRulesConverted:             1,
+		}, result.Stats)
+		assert.Equal(t, result.RulesImported, result.Stats.Converted()+result.Stats.Skipped())
 	})
 
 	t.Run("import with no extend", func(t *testing.T) {
@@ -375,6 +384,7 @@ func TestImportFromClaude(t *testing.T) {
 
 		assert.Equal(t, "", result.Config.Extends) // no extends
 		assert.Contains(t, result.Config.Command.Allow, "npm install")
+		assert.Equal(t, ConversionStats{CommandAllowConverted: 1}, result.Stats)
 	})
 
 	t.Run("import with custom extend", func(t *testing.T) {
@@ -396,6 +406,7 @@ func TestImportFromClaude(t *testing.T) {
 		require.NoError(t, err)
 
 		assert.Equal(t, "local-dev-server", result.Config.Extends)
+		assert.Equal(t, ConversionStats{CommandAllowConverted: 1}, result.Stats)
 	})
 
 	t.Run("warnings for global rules", func(t *testing.T) {
@@ -425,9 +436,76 @@ func TestImportFromClaude(t *testing.T) {
 		assert.Contains(t, warningsStr, "Edit")
 		assert.Contains(t, warningsStr, "Write")
 		assert.Contains(t, warningsStr, "skipped")
+
+		// Global ask rules are skipped, not demoted
+		assert.Equal(t, ConversionStats{
+			CommandAllowConverted: 1,
+			GlobalRulesSkipped:    4,
+		}, result.Stats)
 	})
 }
 
+func TestImportFromClaudeDryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	settingsPath := filepath.Join(tmpDir, "settings.json")
+	content := `{"permissions": {"allow": ["Bash(npm install)"], "deny": ["Read(./.env)"]}}`
+	require.NoError(t, os.WriteFile(settingsPath, []byte(content), 0o600)) //nolint:gosec // test file
+
+	t.Run("preview matches the written config", func(t *testing.T) {
+		opts := DefaultImportOptions()
+		opts.DryRun = true
+		result, err := ImportFromClaude(settingsPath, opts)
+		require.NoError(t, err)
+		require.NotEmpty(t, result.Preview)
+
+		entries, err := os.ReadDir(tmpDir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "dry run should not create files")
+
+		imported, err := ImportFromClaude(settingsPath, DefaultImportOptions())
+		require.NoError(t, err)
+		outPath := filepath.Join(t.TempDir(), "fence.json")
+		require.NoError(t, WriteConfig(imported.Config, outPath))
+		written, err := os.ReadFile(outPath) //nolint:gosec // test file
+		require.NoError(t, err)
+		assert.Equal(t, string(written), result.Preview)
+	})
+
+	t.Run("no preview without dry run", func(t *testing.T) {
+		result, err := ImportFromClaude(settingsPath, DefaultImportOptions())
+		require.NoError(t, err)
+		assert.Empty(t, result.Preview)
+	})
+}
+
+func TestConversionStats(t *testing.T) {
+	_, stats := convertClaude(&ClaudeSettings{
+		Permissions: ClaudePermissions{
+			Allow: []string{"Bash(npm install)", "Read(./src/**)", "Edit(./dist)", "Grep"},
+			Deny:  []string{"Write(./.git/**)", "Read(./.env)"},
+			Ask:   []string{"Bash(git push)", "Write(./config.json)"},
+		},
+	})
+
+	assert.Equal(t, ConversionStats{
+		CommandAllowConverted:         1,
+		CommandDenyConverted:          1,
+		FilesystemDenyReadConverted:   1,
+		FilesystemAllowWriteConverted: 1,
+		FilesystemDenyWriteConverted:  2,
+		GlobalRulesSkipped:            1,
+		UnsupportedRulesSkipped:       1, // Read allow has no fence equivalent
+		AskRulesConverted:             2,
+	}, stats)
+	assert.Equal(t, 6, stats.Converted())
+	assert.Equal(t, 2, stats.Skipped())
+
+	summary := stats.Summary()
+	assert.Contains(t, summary, "Rule type")
+	assert.Regexp(t, `filesystem\.denyWrite\s+2\n`, summary)
+	assert.Regexp(t, `ask rules demoted to deny\s+2\n`, summary)
+}
+
 func TestWriteConfig(t *testing.T) {
 	tmpDir := t.TempDir()
 	outputPath := filepath.Join(tmpDir, "fence.json")
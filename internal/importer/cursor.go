@@ -0,0 +1,183 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Use-Tusk/fence/internal/config"
+	"github.com/tidwall/jsonc"
+)
+
+// CursorSettings represents the Cursor .cursor/mcp.json structure.
+// Only the tool permission lists are converted; MCP server definitions are
+// parsed so their count can be reported but have no fence equivalent.
+type CursorSettings struct {
+	MCPServers   map[string]json.RawMessage `json:"mcpServers"`
+	AllowedTools []string                   `json:"allowedTools"`
+	BlockedTools []string                   `json:"blockedTools"`
+}
+
+// CursorSettingsPaths returns the standard paths where Cursor stores MCP settings.
+func CursorSettingsPaths() []string {
+	var paths []string
+	if dir := os.Getenv("CURSOR_CONFIG_DIR"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "mcp.json"))
+	}
+
+	home, err := os.UserHomeDir()
+	if err == nil {
+		paths = append(paths, filepath.Join(home, ".cursor", "mcp.json"))
+	}
+
+	// Also check project-level settings in current directory
+	cwd, err := os.Getwd()
+	if err == nil {
+		paths = append(paths, filepath.Join(cwd, ".cursor", "mcp.json"))
+	}
+
+	return paths
+}
+
+// DefaultCursorSettingsPath returns the default user-level Cursor MCP settings
+// path: $CURSOR_CONFIG_DIR/mcp.json if CURSOR_CONFIG_DIR is set, otherwise
+// ~/.cursor/mcp.json.
+func DefaultCursorSettingsPath() string {
+	if dir := os.Getenv("CURSOR_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "mcp.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cursor", "mcp.json")
+}
+
+// LoadCursorSettings loads Cursor MCP settings from a file.
+func LoadCursorSettings(path string) (*CursorSettings, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // user-provided path - intentional
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Cursor settings: %w", err)
+	}
+
+	// Handle empty file
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return &CursorSettings{}, nil
+	}
+
+	var settings CursorSettings
+	if err := json.Unmarshal(jsonc.ToJSON(data), &settings); err != nil {
+		return nil, fmt.Errorf("invalid JSON in Cursor settings: %w", err)
+	}
+
+	return &settings, nil
+}
+
+// ConvertCursorToFence converts Cursor tool permissions to a fence config.
+func ConvertCursorToFence(settings *CursorSettings) *config.Config {
+	cfg, _ := convertCursor(settings)
+	return cfg
+}
+
+// convertCursor converts Cursor tool permissions and counts the converted rules.
+func convertCursor(settings *CursorSettings) (*config.Config, ConversionStats) {
+	cfg := config.Default()
+	var stats ConversionStats
+
+	for _, rule := range settings.AllowedTools {
+		stats.record(rule, processCursorRule(rule, cfg, true))
+	}
+
+	for _, rule := range settings.BlockedTools {
+		stats.record(rule, processCursorRule(rule, cfg, false))
+	}
+
+	return cfg, stats
+}
+
+// shellPattern matches Cursor shell rules like "Shell(npm run test)" or "Shell(curl:*)"
+var shellPattern = regexp.MustCompile(`^Shell\((.+)\)$`)
+
+// processCursorRule processes a single Cursor tool rule and updates the fence
+// config. Cursor uses Shell(command) where Claude uses Bash(command); Read and
+// Write rules have the same form in both.
+func processCursorRule(rule string, cfg *config.Config, isAllow bool) ruleTarget {
+	rule = strings.TrimSpace(rule)
+	if !isConvertibleCursorRule(rule) {
+		return targetNone
+	}
+	if matches := shellPattern.FindStringSubmatch(rule); len(matches) == 2 {
+		rule = "Bash(" + matches[1] + ")"
+	}
+	return processClaudeRule(rule, cfg, isAllow)
+}
+
+// isConvertibleCursorRule reports whether a Cursor tool rule maps to a fence rule.
+func isConvertibleCursorRule(rule string) bool {
+	rule = strings.TrimSpace(rule)
+	for _, pattern := range []*regexp.Regexp{shellPattern, readPattern, writePattern} {
+		if pattern.MatchString(rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportFromCursor imports tool permissions from Cursor and returns a fence config.
+// If path is empty, it tries the default Cursor settings path.
+func ImportFromCursor(path string, opts ImportOptions) (*ImportResult, error) {
+	if path == "" {
+		path = DefaultCursorSettingsPath()
+	}
+
+	if path == "" {
+		return nil, fmt.Errorf("could not determine Cursor settings path")
+	}
+
+	settings, err := LoadCursorSettings(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, stats := convertCursor(settings)
+
+	// Set extends if specified
+	if opts.Extends != "" {
+		cfg.Extends = opts.Extends
+	}
+
+	result := &ImportResult{
+		Config:        cfg,
+		SourcePath:    path,
+		RulesImported: len(settings.AllowedTools) + len(settings.BlockedTools),
+		Stats:         stats,
+	}
+
+	// Add warnings for rules that couldn't be converted
+	for _, rule := range slices.Concat(settings.AllowedTools, settings.BlockedTools) {
+		if strings.TrimSpace(rule) == "" || isConvertibleCursorRule(rule) {
+			continue
+		}
+		if isGlobalToolRule(rule) {
+			result.Warnings = append(result.Warnings,
+				fmt.Sprintf("Global tool permission %q skipped (fence uses path/command-based rules)", rule))
+		} else {
+			result.Warnings = append(result.Warnings,
+				fmt.Sprintf("Tool permission %q skipped (no fence equivalent)", rule))
+		}
+	}
+	if len(settings.MCPServers) > 0 {
+		result.Warnings = append(result.Warnings,
+			fmt.Sprintf("%d MCP server definition(s) skipped (fence sandboxes commands, not MCP servers)", len(settings.MCPServers)))
+	}
+
+	if err := applyDryRun(result, opts); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
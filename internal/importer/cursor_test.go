@@ -0,0 +1,311 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertCursorToFence(t *testing.T) {
+	tests := []struct {
+		name           string
+		settings       *CursorSettings
+		wantCmdAllow   []string
+		wantCmdDeny    []string
+		wantDenyRead   []string
+		wantAllowWrite []string
+		wantDenyWrite  []string
+	}{
+		{
+			name:     "empty settings",
+			settings: &CursorSettings{},
+		},
+		{
+			name: "shell allow rules",
+			settings: &CursorSettings{
+				AllowedTools: []string{
+					"Shell(npm run lint)",
+					"Shell(npm run test:*)",
+					"Shell(git status)",
+				},
+			},
+			wantCmdAllow: []string{"npm run lint", "npm run test", "git status"},
+		},
+		{
+			name: "shell blocked rules",
+			settings: &CursorSettings{
+				BlockedTools: []string{
+					"Shell(curl:*)",
+					"Shell(sudo:*)",
+					"Shell(rm -rf /)",
+				},
+			},
+			wantCmdDeny: []string{"curl", "sudo", "rm -rf /"},
+		},
+		{
+			name: "read blocked rules",
+			settings: &CursorSettings{
+				BlockedTools: []string{
+					"Read(./.env)",
+					"Read(./secrets/**)",
+					"Read(~/.ssh/*)",
+				},
+			},
+			wantDenyRead: []string{"./.env", "./secrets/**", "~/.ssh/*"},
+		},
+		{
+			name: "read allow rules add nothing",
+			settings: &CursorSettings{
+				AllowedTools: []string{"Read(./src/**)"},
+			},
+		},
+		{
+			name: "write allow rules",
+			settings: &CursorSettings{
+				AllowedTools: []string{
+					"Write(./output/**)",
+					"Write(./build)",
+				},
+			},
+			wantAllowWrite: []string{"./output/**", "./build"},
+		},
+		{
+			name: "write blocked rules",
+			settings: &CursorSettings{
+				BlockedTools: []string{"Write(./.git/**)"},
+			},
+			wantDenyWrite: []string{"./.git/**"},
+		},
+		{
+			name: "global and unknown tool rules are skipped",
+			settings: &CursorSettings{
+				AllowedTools: []string{
+					"Shell",
+					"Mcp(github:create_issue)",
+					"Bash(npm install)", // Claude syntax, not Cursor
+					"Shell(npm run build)",
+				},
+				BlockedTools: []string{
+					"Edit(./package.json)", // Claude syntax, not Cursor
+					"Shell(sudo:*)",
+				},
+			},
+			wantCmdAllow: []string{"npm run build"},
+			wantCmdDeny:  []string{"sudo"},
+		},
+		{
+			name: "duplicate rules are merged",
+			settings: &CursorSettings{
+				AllowedTools: []string{"Shell(git status)", " Shell(git status) ", ""},
+			},
+			wantCmdAllow: []string{"git status"},
+		},
+		{
+			name: "mixed rules",
+			settings: &CursorSettings{
+				AllowedTools: []string{
+					"Shell(npm install)",
+					"Shell(npm run:*)",
+					"Write(./dist/**)",
+				},
+				BlockedTools: []string{
+					"Shell(curl:*)",
+					"Read(./.env)",
+					"Write(./.git/**)",
+				},
+			},
+			wantCmdAllow:   []string{"npm install", "npm run"},
+			wantCmdDeny:    []string{"curl"},
+			wantDenyRead:   []string{"./.env"},
+			wantAllowWrite: []string{"./dist/**"},
+			wantDenyWrite:  []string{"./.git/**"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ConvertCursorToFence(tt.settings)
+
+			assert.ElementsMatch(t, tt.wantCmdAllow, cfg.Command.Allow, "command.allow mismatch")
+			assert.ElementsMatch(t, tt.wantCmdDeny, cfg.Command.Deny, "command.deny mismatch")
+			assert.ElementsMatch(t, tt.wantDenyRead, cfg.Filesystem.DenyRead, "filesystem.denyRead mismatch")
+			assert.ElementsMatch(t, tt.wantAllowWrite, cfg.Filesystem.AllowWrite, "filesystem.allowWrite mismatch")
+			assert.ElementsMatch(t, tt.wantDenyWrite, cfg.Filesystem.DenyWrite, "filesystem.denyWrite mismatch")
+		})
+	}
+}
+
+func TestLoadCursorSettings(t *testing.T) {
+	t.Run("valid settings", func(t *testing.T) {
+		settingsPath := filepath.Join(t.TempDir(), "mcp.json")
+
+		content := `{
+  // Cursor allows comments in mcp.json
+  "mcpServers": {
+    "github": {"command": "npx", "args": ["-y", "@modelcontextprotocol/server-github"]}
+  },
+  "allowedTools": ["Shell(npm install)", "Read(./src/**)"],
+  "blockedTools": ["Shell(sudo:*)"]
+}`
+		err := os.WriteFile(settingsPath, []byte(content), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		settings, err := LoadCursorSettings(settingsPath)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"Shell(npm install)", "Read(./src/**)"}, settings.AllowedTools)
+		assert.Equal(t, []string{"Shell(sudo:*)"}, settings.BlockedTools)
+		assert.Len(t, settings.MCPServers, 1)
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		settingsPath := filepath.Join(t.TempDir(), "mcp.json")
+
+		err := os.WriteFile(settingsPath, []byte(""), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		settings, err := LoadCursorSettings(settingsPath)
+		require.NoError(t, err)
+		assert.NotNil(t, settings)
+	})
+
+	t.Run("file not found", func(t *testing.T) {
+		_, err := LoadCursorSettings("/nonexistent/path/mcp.json")
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		settingsPath := filepath.Join(t.TempDir(), "mcp.json")
+
+		err := os.WriteFile(settingsPath, []byte("not json"), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		_, err = LoadCursorSettings(settingsPath)
+		assert.Error(t, err)
+	})
+}
+
+func TestCursorSettingsPaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	t.Run("default path", func(t *testing.T) {
+		t.Setenv("CURSOR_CONFIG_DIR", "")
+		assert.Equal(t, filepath.Join(home, ".cursor", "mcp.json"), DefaultCursorSettingsPath())
+
+		paths := CursorSettingsPaths()
+		require.NotEmpty(t, paths)
+		assert.Equal(t, filepath.Join(home, ".cursor", "mcp.json"), paths[0])
+	})
+
+	t.Run("CURSOR_CONFIG_DIR takes precedence", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("CURSOR_CONFIG_DIR", dir)
+		assert.Equal(t, filepath.Join(dir, "mcp.json"), DefaultCursorSettingsPath())
+
+		paths := CursorSettingsPaths()
+		require.GreaterOrEqual(t, len(paths), 2)
+		assert.Equal(t, filepath.Join(dir, "mcp.json"), paths[0])
+		assert.Equal(t, filepath.Join(home, ".cursor", "mcp.json"), paths[1])
+	})
+}
+
+func TestImportFromCursor(t *testing.T) {
+	t.Run("successful import with default extends", func(t *testing.T) {
+		settingsPath := filepath.Join(t.TempDir(), "mcp.json")
+
+		content := `{
+  "allowedTools": ["Shell(npm install)", "Write(./dist/**)"],
+  "blockedTools": ["Shell(curl:*)", "Read(./.env)"]
+}`
+		err := os.WriteFile(settingsPath, []byte(content), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		result, err := ImportFromCursor(settingsPath, DefaultImportOptions())
+		require.NoError(t, err)
+
+		assert.Equal(t, settingsPath, result.SourcePath)
+		assert.Equal(t, 4, result.RulesImported)
+		assert.Equal(t, "code", result.Config.Extends)
+		assert.Empty(t, result.Warnings)
+
+		assert.Contains(t, result.Config.Command.Allow, "npm install")
+		assert.Contains(t, result.Config.Command.Deny, "curl")
+		assert.Contains(t, result.Config.Filesystem.AllowWrite, "./dist/**")
+		assert.Contains(t, result.Config.Filesystem.DenyRead, "./.env")
+	})
+
+	t.Run("import with no extend", func(t *testing.T) {
+		settingsPath := filepath.Join(t.TempDir(), "mcp.json")
+
+		err := os.WriteFile(settingsPath, []byte(`{"allowedTools": ["Shell(npm install)"]}`), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		result, err := ImportFromCursor(settingsPath, ImportOptions{Extends: ""})
+		require.NoError(t, err)
+
+		assert.Equal(t, "", result.Config.Extends)
+		assert.Contains(t, result.Config.Command.Allow, "npm install")
+		assert.Empty(t, result.Preview)
+	})
+
+	t.Run("dry run sets preview", func(t *testing.T) {
+		settingsPath := filepath.Join(t.TempDir(), "mcp.json")
+
+		err := os.WriteFile(settingsPath, []byte(`{"blockedTools": ["Shell(sudo:*)"]}`), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		result, err := ImportFromCursor(settingsPath, ImportOptions{DryRun: true})
+		require.NoError(t, err)
+
+		assert.Contains(t, result.Preview, `"sudo"`)
+	})
+
+	t.Run("default path from CURSOR_CONFIG_DIR", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("CURSOR_CONFIG_DIR", dir)
+
+		err := os.WriteFile(filepath.Join(dir, "mcp.json"), []byte(`{"blockedTools": ["Shell(sudo:*)"]}`), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		result, err := ImportFromCursor("", DefaultImportOptions())
+		require.NoError(t, err)
+
+		assert.Equal(t, filepath.Join(dir, "mcp.json"), result.SourcePath)
+		assert.Contains(t, result.Config.Command.Deny, "sudo")
+	})
+
+	t.Run("warnings for skipped rules and MCP servers", func(t *testing.T) {
+		settingsPath := filepath.Join(t.TempDir(), "mcp.json")
+
+		content := `{
+  "mcpServers": {"github": {"command": "npx"}, "fs": {"command": "node"}},
+  "allowedTools": ["Shell", "Mcp(github:create_issue)", "Shell(npm install)"],
+  "blockedTools": ["Write"]
+}`
+		err := os.WriteFile(settingsPath, []byte(content), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		result, err := ImportFromCursor(settingsPath, DefaultImportOptions())
+		require.NoError(t, err)
+
+		// Shell, Mcp(...), Write and the MCP server definitions
+		assert.Len(t, result.Warnings, 4)
+
+		warningsStr := strings.Join(result.Warnings, " ")
+		assert.Contains(t, warningsStr, `"Shell"`)
+		assert.Contains(t, warningsStr, "Mcp(github:create_issue)")
+		assert.Contains(t, warningsStr, `"Write"`)
+		assert.Contains(t, warningsStr, "2 MCP server")
+
+		assert.Equal(t, ConversionStats{
+			CommandAllowConverted:   1,
+			GlobalRulesSkipped:      2,
+			UnsupportedRulesSkipped: 1,
+		}, result.Stats)
+	})
+}
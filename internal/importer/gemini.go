@@ -0,0 +1,149 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Use-Tusk/fence/internal/config"
+	"github.com/tidwall/jsonc"
+)
+
+// GeminiSettings represents the Gemini CLI settings.json structure.
+type GeminiSettings struct {
+	Permissions GeminiPermissions `json:"permissions"`
+}
+
+// GeminiPermissions represents the permissions block in Gemini CLI settings.
+type GeminiPermissions struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// DefaultGeminiSettingsPath returns the default user-level Gemini CLI settings path.
+func DefaultGeminiSettingsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gemini", "settings.json")
+}
+
+// LoadGeminiSettings loads Gemini CLI settings from a file.
+func LoadGeminiSettings(path string) (*GeminiSettings, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // user-provided path - intentional
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Gemini settings: %w", err)
+	}
+
+	// Handle empty file
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return &GeminiSettings{}, nil
+	}
+
+	var settings GeminiSettings
+	if err := json.Unmarshal(jsonc.ToJSON(data), &settings); err != nil {
+		return nil, fmt.Errorf("invalid JSON in Gemini settings: %w", err)
+	}
+
+	return &settings, nil
+}
+
+// ConvertGeminiToFence converts Gemini CLI settings to a fence config.
+func ConvertGeminiToFence(settings *GeminiSettings) *config.Config {
+	cfg, _ := convertGemini(settings)
+	return cfg
+}
+
+// convertGemini converts Gemini CLI settings and counts the converted rules.
+func convertGemini(settings *GeminiSettings) (*config.Config, ConversionStats) {
+	cfg := config.Default()
+	var stats ConversionStats
+
+	for _, rule := range settings.Permissions.Allow {
+		stats.record(rule, processGeminiRule(rule, cfg, true))
+	}
+
+	for _, rule := range settings.Permissions.Deny {
+		stats.record(rule, processGeminiRule(rule, cfg, false))
+	}
+
+	return cfg, stats
+}
+
+// geminiPattern matches Gemini permission rules like "ShellExec(npm test)" or "FileRead(./.env)"
+var geminiPattern = regexp.MustCompile(`^(ShellExec|FileRead|FileWrite)\((.+)\)$`)
+
+// geminiToClaudeTool maps Gemini tool names to the Claude tool with the same meaning.
+var geminiToClaudeTool = map[string]string{
+	"ShellExec": "Bash",
+	"FileRead":  "Read",
+	"FileWrite": "Write",
+}
+
+// processGeminiRule processes a single Gemini permission rule and updates the
+// fence config. Gemini rules have the same form as Claude rules under
+// different tool names, so they are converted by processClaudeRule.
+func processGeminiRule(rule string, cfg *config.Config, isAllow bool) ruleTarget {
+	matches := geminiPattern.FindStringSubmatch(strings.TrimSpace(rule))
+	if len(matches) != 3 {
+		return targetNone
+	}
+	return processClaudeRule(geminiToClaudeTool[matches[1]]+"("+matches[2]+")", cfg, isAllow)
+}
+
+// ImportFromGemini imports settings from the Gemini CLI and returns a fence config.
+// If path is empty, it tries the default Gemini settings path.
+func ImportFromGemini(path string, opts ImportOptions) (*ImportResult, error) {
+	if path == "" {
+		path = DefaultGeminiSettingsPath()
+	}
+
+	if path == "" {
+		return nil, fmt.Errorf("could not determine Gemini settings path")
+	}
+
+	settings, err := LoadGeminiSettings(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, stats := convertGemini(settings)
+
+	// Set extends if specified
+	if opts.Extends != "" {
+		cfg.Extends = opts.Extends
+	}
+
+	result := &ImportResult{
+		Config:        cfg,
+		SourcePath:    path,
+		RulesImported: len(settings.Permissions.Allow) + len(settings.Permissions.Deny),
+		Stats:         stats,
+	}
+
+	// Add warnings for rules that couldn't be converted
+	for _, rules := range [][]string{settings.Permissions.Allow, settings.Permissions.Deny} {
+		for _, rule := range rules {
+			if strings.TrimSpace(rule) == "" || geminiPattern.MatchString(strings.TrimSpace(rule)) {
+				continue
+			}
+			if isGlobalToolRule(rule) {
+				result.Warnings = append(result.Warnings,
+					fmt.Sprintf("Global tool permission %q skipped (fence uses path/command-based rules)", rule))
+			} else {
+				result.Warnings = append(result.Warnings,
+					fmt.Sprintf("Tool permission %q skipped (no fence equivalent)", rule))
+			}
+		}
+	}
+
+	if err := applyDryRun(result, opts); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,265 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertGeminiToFence(t *testing.T) {
+	tests := []struct {
+		name           string
+		settings       *GeminiSettings
+		wantCmdAllow   []string
+		wantCmdDeny    []string
+		wantDenyRead   []string
+		wantAllowWrite []string
+		wantDenyWrite  []string
+	}{
+		{
+			name:     "empty settings",
+			settings: &GeminiSettings{},
+		},
+		{
+			name: "shell allow rules",
+			settings: &GeminiSettings{
+				Permissions: GeminiPermissions{
+					Allow: []string{
+						"ShellExec(npm run lint)",
+						"ShellExec(npm run test:*)",
+						"ShellExec(git status)",
+					},
+				},
+			},
+			wantCmdAllow: []string{"npm run lint", "npm run test", "git status"},
+		},
+		{
+			name: "shell deny rules",
+			settings: &GeminiSettings{
+				Permissions: GeminiPermissions{
+					Deny: []string{
+						"ShellExec(curl:*)",
+						"ShellExec(sudo:*)",
+						"ShellExec(rm -rf /)",
+					},
+				},
+			},
+			wantCmdDeny: []string{"curl", "sudo", "rm -rf /"},
+		},
+		{
+			name: "read deny rules",
+			settings: &GeminiSettings{
+				Permissions: GeminiPermissions{
+					Deny: []string{
+						"FileRead(./.env)",
+						"FileRead(./secrets/**)",
+						"FileRead(~/.ssh/*)",
+					},
+				},
+			},
+			wantDenyRead: []string{"./.env", "./secrets/**", "~/.ssh/*"},
+		},
+		{
+			name: "write allow rules",
+			settings: &GeminiSettings{
+				Permissions: GeminiPermissions{
+					Allow: []string{
+						"FileWrite(./output/**)",
+						"FileWrite(./build)",
+					},
+				},
+			},
+			wantAllowWrite: []string{"./output/**", "./build"},
+		},
+		{
+			name: "write deny rules",
+			settings: &GeminiSettings{
+				Permissions: GeminiPermissions{
+					Deny: []string{
+						"FileWrite(./.git/**)",
+						"FileWrite(./package-lock.json)",
+					},
+				},
+			},
+			wantDenyWrite: []string{"./.git/**", "./package-lock.json"},
+		},
+		{
+			name: "global and unknown tool rules are skipped",
+			settings: &GeminiSettings{
+				Permissions: GeminiPermissions{
+					Allow: []string{
+						"FileRead",
+						"WebFetch",
+						"Bash(npm install)", // Claude syntax, not Gemini
+						"ShellExec(npm run build)",
+					},
+					Deny: []string{
+						"ShellExec",
+						"ShellExec(sudo:*)",
+					},
+				},
+			},
+			wantCmdAllow: []string{"npm run build"},
+			wantCmdDeny:  []string{"sudo"},
+		},
+		{
+			name: "mixed rules",
+			settings: &GeminiSettings{
+				Permissions: GeminiPermissions{
+					Allow: []string{
+						"ShellExec(npm install)",
+						"ShellExec(npm run:*)",
+						"FileWrite(./dist/**)",
+					},
+					Deny: []string{
+						"ShellExec(curl:*)",
+						"FileRead(./.env)",
+						"FileWrite(./.git/**)",
+					},
+				},
+			},
+			wantCmdAllow:   []string{"npm install", "npm run"},
+			wantCmdDeny:    []string{"curl"},
+			wantDenyRead:   []string{"./.env"},
+			wantAllowWrite: []string{"./dist/**"},
+			wantDenyWrite:  []string{"./.git/**"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ConvertGeminiToFence(tt.settings)
+
+			assert.ElementsMatch(t, tt.wantCmdAllow, cfg.Command.Allow, "command.allow mismatch")
+			assert.ElementsMatch(t, tt.wantCmdDeny, cfg.Command.Deny, "command.deny mismatch")
+			assert.ElementsMatch(t, tt.wantDenyRead, cfg.Filesystem.DenyRead, "filesystem.denyRead mismatch")
+			assert.ElementsMatch(t, tt.wantAllowWrite, cfg.Filesystem.AllowWrite, "filesystem.allowWrite mismatch")
+			assert.ElementsMatch(t, tt.wantDenyWrite, cfg.Filesystem.DenyWrite, "filesystem.denyWrite mismatch")
+		})
+	}
+}
+
+func TestLoadGeminiSettings(t *testing.T) {
+	t.Run("settings with comments (JSONC)", func(t *testing.T) {
+		settingsPath := filepath.Join(t.TempDir(), "settings.json")
+
+		content := `{
+  // This is a comment
+  "theme": "GitHub",
+  "permissions": {
+    "allow": ["ShellExec(npm install)"],
+    "deny": ["FileRead(./.env)"], // Another comment
+  }
+}`
+		err := os.WriteFile(settingsPath, []byte(content), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		settings, err := LoadGeminiSettings(settingsPath)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"ShellExec(npm install)"}, settings.Permissions.Allow)
+		assert.Equal(t, []string{"FileRead(./.env)"}, settings.Permissions.Deny)
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		settingsPath := filepath.Join(t.TempDir(), "settings.json")
+
+		err := os.WriteFile(settingsPath, []byte(""), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		settings, err := LoadGeminiSettings(settingsPath)
+		require.NoError(t, err)
+		assert.NotNil(t, settings)
+	})
+
+	t.Run("file not found", func(t *testing.T) {
+		_, err := LoadGeminiSettings("/nonexistent/path/settings.json")
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		settingsPath := filepath.Join(t.TempDir(), "settings.json")
+
+		err := os.WriteFile(settingsPath, []byte("not json"), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		_, err = LoadGeminiSettings(settingsPath)
+		assert.Error(t, err)
+	})
+}
+
+func TestImportFromGemini(t *testing.T) {
+	t.Run("successful import with default extends", func(t *testing.T) {
+		settingsPath := filepath.Join(t.TempDir(), "settings.json")
+
+		content := `{
+  "permissions": {
+    "allow": ["ShellExec(npm install)", "FileWrite(./dist/**)"],
+    "deny": ["ShellExec(curl:*)", "FileRead(./.env)"]
+  }
+}`
+		err := os.WriteFile(settingsPath, []byte(content), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		result, err := ImportFromGemini(settingsPath, DefaultImportOptions())
+		require.NoError(t, err)
+
+		assert.Equal(t, settingsPath, result.SourcePath)
+		assert.Equal(t, 4, result.RulesImported)
+		assert.Equal(t, "code", result.Config.Extends)
+		assert.Empty(t, result.Warnings)
+
+		assert.Contains(t, result.Config.Command.Allow, "npm install")
+		assert.Contains(t, result.Config.Command.Deny, "curl")
+		assert.Contains(t, result.Config.Filesystem.AllowWrite, "./dist/**")
+		assert.Contains(t, result.Config.Filesystem.DenyRead, "./.env")
+	})
+
+	t.Run("default path", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		require.NoError(t, os.MkdirAll(filepath.Join(home, ".gemini"), 0o750))
+		err := os.WriteFile(filepath.Join(home, ".gemini", "settings.json"), []byte(`{"permissions": {"deny": ["ShellExec(sudo:*)"]}}`), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		result, err := ImportFromGemini("", ImportOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, filepath.Join(home, ".gemini", "settings.json"), result.SourcePath)
+		assert.Equal(t, "", result.Config.Extends)
+		assert.Contains(t, result.Config.Command.Deny, "sudo")
+	})
+
+	t.Run("warnings for skipped rules", func(t *testing.T) {
+		settingsPath := filepath.Join(t.TempDir(), "settings.json")
+
+		content := `{
+  "permissions": {
+    "allow": ["FileRead", "WebFetch(https://example.com)", "ShellExec(npm install)"],
+    "deny": ["ShellExec"]
+  }
+}`
+		err := os.WriteFile(settingsPath, []byte(content), 0o600) //nolint:gosec // test file
+		require.NoError(t, err)
+
+		result, err := ImportFromGemini(settingsPath, DefaultImportOptions())
+		require.NoError(t, err)
+
+		assert.Len(t, result.Warnings, 3)
+
+		warningsStr := strings.Join(result.Warnings, " ")
+		assert.Contains(t, warningsStr, `"FileRead"`)
+		assert.Contains(t, warningsStr, "WebFetch(https://example.com)")
+		assert.Contains(t, warningsStr, `"ShellExec"`)
+
+		assert.Equal(t, ConversionStats{
+			CommandAllowConverted:   1,
+			GlobalRulesSkipped:      2,
+			UnsupportedRulesSkipped: 1,
+		}, result.Stats)
+	})
+}
@@ -0,0 +1,303 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// LookupFunc resolves a hostname to IP address strings.
+type LookupFunc func(ctx context.Context, host string) ([]string, error)
+
+// DefaultResolvConfPath is where nameservers are read from for DNS-only lookups.
+const DefaultResolvConfPath = "/etc/resolv.conf"
+
+const (
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+	dnsClassIN  = 1
+)
+
+// TTLLookupFunc resolves a hostname and reports how long the answer may be cached.
+type TTLLookupFunc func(ctx context.Context, host string) ([]net.IP, time.Duration, error)
+
+// NewDNSOnlyLookup returns a LookupFunc that queries the given nameservers
+// directly, bypassing /etc/hosts. Servers are "host" or "host:port" strings.
+// If servers is empty, nameservers are read from /etc/resolv.conf.
+func NewDNSOnlyLookup(servers []string) LookupFunc {
+	return NewCachingLookup(nil, NewDNSOnlyTTLLookup(servers))
+}
+
+// NewDNSOnlyTTLLookup is like NewDNSOnlyLookup but also returns the smallest
+// TTL among the answer records.
+func NewDNSOnlyTTLLookup(servers []string) TTLLookupFunc {
+	return func(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+		if ip := net.ParseIP(host); ip != nil {
+			return []net.IP{ip}, 0, nil
+		}
+
+		nameservers := servers
+		if len(nameservers) == 0 {
+			var err error
+			nameservers, err = readNameservers(DefaultResolvConfPath)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+
+		var lastErr error
+		for _, server := range nameservers {
+			if _, _, err := net.SplitHostPort(server); err != nil {
+				server = net.JoinHostPort(server, "53")
+			}
+
+			var ips []net.IP
+			var minTTL time.Duration
+			for _, qtype := range []uint16{dnsTypeA, dnsTypeAAAA} {
+				answers, ttl, err := queryDNS(ctx, server, host, qtype)
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				if len(answers) > 0 && (len(ips) == 0 || ttl < minTTL) {
+					minTTL = ttl
+				}
+				ips = append(ips, answers...)
+			}
+			if len(ips) > 0 {
+				return ips, minTTL, nil
+			}
+		}
+
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no DNS records for %s", host)
+		}
+		return nil, 0, lastErr
+	}
+}
+
+// SystemLookupTTL is how long answers from the system resolver are cached.
+// It does not report record TTLs, so a short fixed lifetime is used instead.
+const SystemLookupTTL = 30 * time.Second
+
+// NewSystemTTLLookup returns a TTLLookupFunc that uses the system resolver,
+// which honors /etc/hosts. Every answer is reported with SystemLookupTTL.
+func NewSystemTTLLookup() TTLLookupFunc {
+	return func(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+		if ip := net.ParseIP(host); ip != nil {
+			return []net.IP{ip}, 0, nil
+		}
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, 0, err
+		}
+		ips := make([]net.IP, 0, len(addrs))
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+		return ips, SystemLookupTTL, nil
+	}
+}
+
+// readNameservers returns the nameserver entries from a resolv.conf file.
+func readNameservers(path string) ([]string, error) {
+	f, err := os.Open(path) //nolint:gosec // fixed system path
+	if err != nil {
+		return nil, fmt.Errorf("failed to read nameservers: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var servers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no nameservers in %s", path)
+	}
+	return servers, scanner.Err()
+}
+
+// dnsFlagTC is the truncation bit in the third header byte.
+const dnsFlagTC = 0x02
+
+// queryDNS sends a single question and returns the A/AAAA answers and their
+// smallest TTL. The question goes over UDP first and is retried over TCP
+// when the answer is truncated.
+func queryDNS(ctx context.Context, server, host string, qtype uint16) ([]net.IP, time.Duration, error) {
+	query, id, err := buildDNSQuery(host, qtype)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := exchangeDNS(ctx, "udp", server, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp) >= 3 && resp[2]&dnsFlagTC != 0 {
+		if resp, err = exchangeDNS(ctx, "tcp", server, query); err != nil {
+			return nil, 0, err
+		}
+	}
+	return parseDNSResponse(resp, id, qtype)
+}
+
+// exchangeDNS sends query to server and returns the raw response. Over TCP
+// messages carry a two-byte length prefix (RFC 1035 section 4.2.2).
+func exchangeDNS(ctx context.Context, network, server string, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+
+	if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(query))), query...)); err != nil { //nolint:gosec // queries are a few hundred bytes
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// buildDNSQuery encodes a recursive query for host.
+func buildDNSQuery(host string, qtype uint16) ([]byte, uint16, error) {
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return nil, 0, err
+	}
+	id := binary.BigEndian.Uint16(idBytes[:])
+
+	msg := make([]byte, 12, 12+len(host)+6)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], 0x0100) // recursion desired
+	binary.BigEndian.PutUint16(msg[4:], 1)      // one question
+
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return nil, 0, fmt.Errorf("invalid hostname %q", host)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassIN)
+
+	return msg, id, nil
+}
+
+// parseDNSResponse extracts addresses of the requested type from a response,
+// along with the smallest TTL among them.
+func parseDNSResponse(msg []byte, id, qtype uint16) ([]net.IP, time.Duration, error) {
+	if len(msg) < 12 {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return nil, 0, errors.New("DNS response ID mismatch")
+	}
+	if rcode := msg[3] & 0x0f; rcode != 0 {
+		return nil, 0, fmt.Errorf("DNS query failed with rcode %d", rcode)
+	}
+
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+
+	off := 12
+	for range qdcount {
+		next, err := skipDNSName(msg, off)
+		if err != nil {
+			return nil, 0, err
+		}
+		off = next + 4 // type + class
+	}
+
+	var ips []net.IP
+	var minTTL time.Duration
+	for range ancount {
+		next, err := skipDNSName(msg, off)
+		if err != nil {
+			return nil, 0, err
+		}
+		off = next
+		if off+10 > len(msg) {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		rtype := binary.BigEndian.Uint16(msg[off:])
+		ttl := time.Duration(binary.BigEndian.Uint32(msg[off+4:])) * time.Second
+		rdlen := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+rdlen > len(msg) {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		rdata := msg[off : off+rdlen]
+		off += rdlen
+
+		if rtype != qtype {
+			continue // e.g. CNAME records preceding the address
+		}
+		if (rtype == dnsTypeA && rdlen == net.IPv4len) || (rtype == dnsTypeAAAA && rdlen == net.IPv6len) {
+			if len(ips) == 0 || ttl < minTTL {
+				minTTL = ttl
+			}
+			ips = append(ips, net.IP(slices.Clone(rdata)))
+		}
+	}
+
+	return ips, minTTL, nil
+}
+
+// skipDNSName returns the offset just past the (possibly compressed) name at off.
+func skipDNSName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		length := int(msg[off])
+		switch {
+		case length == 0:
+			return off + 1, nil
+		case length&0xc0 == 0xc0:
+			// Compression pointer: two bytes, ends the name
+			return off + 2, nil
+		default:
+			off += 1 + length
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxCacheTTL caps how long the proxies cache DNS answers.
+const DefaultMaxCacheTTL = 5 * time.Minute
+
+// DNSCache caches DNS answers for their TTL.
+type DNSCache struct {
+	// MaxCacheTTL caps the TTL of stored answers. Zero means no cap.
+	MaxCacheTTL time.Duration
+
+	entries sync.Map // lowercased host -> *dnsCacheEntry
+	now     func() time.Time
+}
+
+type dnsCacheEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// NewDNSCache creates a cache whose entries live at most maxTTL.
+func NewDNSCache(maxTTL time.Duration) *DNSCache {
+	return &DNSCache{MaxCacheTTL: maxTTL, now: time.Now}
+}
+
+// Lookup returns the cached addresses for host and when they expire.
+// Expired entries are removed and reported as missing.
+func (c *DNSCache) Lookup(host string) ([]net.IP, time.Time, bool) {
+	key := dnsCacheKey(host)
+	v, ok := c.entries.Load(key)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	entry := v.(*dnsCacheEntry)
+	if !c.clock().Before(entry.expires) {
+		c.entries.CompareAndDelete(key, v)
+		return nil, time.Time{}, false
+	}
+	return slices.Clone(entry.ips), entry.expires, true
+}
+
+// Store caches ips for host for ttl, capped at MaxCacheTTL. Answers with a
+// non-positive TTL are not cached.
+func (c *DNSCache) Store(host string, ips []net.IP, ttl time.Duration) {
+	if c.MaxCacheTTL > 0 && ttl > c.MaxCacheTTL {
+		ttl = c.MaxCacheTTL
+	}
+	if ttl <= 0 || len(ips) == 0 {
+		return
+	}
+	c.entries.Store(dnsCacheKey(host), &dnsCacheEntry{
+		ips:     slices.Clone(ips),
+		expires: c.clock().Add(ttl),
+	})
+}
+
+func (c *DNSCache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func dnsCacheKey(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// NewCachingLookup returns a LookupFunc that answers from cache when
+// possible and otherwise calls resolve, caching the result for its TTL.
+// A nil cache disables caching.
+func NewCachingLookup(cache *DNSCache, resolve TTLLookupFunc) LookupFunc {
+	return func(ctx context.Context, host string) ([]string, error) {
+		if cache != nil {
+			if ips, _, ok := cache.Lookup(host); ok {
+				return ipStrings(ips), nil
+			}
+		}
+
+		ips, ttl, err := resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if cache != nil {
+			cache.Store(host, ips, ttl)
+		}
+		return ipStrings(ips), nil
+	}
+}
+
+func ipStrings(ips []net.IP) []string {
+	out := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		out = append(out, ip.String())
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDNSCache_LookupAndExpiry(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	cache := NewDNSCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	ips := []net.IP{net.ParseIP("192.0.2.1")}
+	cache.Store("Example.COM.", ips, 30*time.Second)
+
+	got, expires, ok := cache.Lookup("example.com")
+	if !ok || !slices.EqualFunc(got, ips, net.IP.Equal) {
+		t.Fatalf("Lookup() = %v, %v; want %v", got, ok, ips)
+	}
+	if want := now.Add(30 * time.Second); !expires.Equal(want) {
+		t.Errorf("expires = %v, want %v", expires, want)
+	}
+
+	now = now.Add(30 * time.Second)
+	if _, _, ok := cache.Lookup("example.com"); ok {
+		t.Error("expected entry to expire after its TTL")
+	}
+}
+
+func TestDNSCache_MaxCacheTTL(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	cache := NewDNSCache(10 * time.Second)
+	cache.now = func() time.Time { return now }
+
+	cache.Store("example.com", []net.IP{net.ParseIP("192.0.2.1")}, time.Hour)
+	_, expires, ok := cache.Lookup("example.com")
+	if !ok {
+		t.Fatal("expected cached entry")
+	}
+	if want := now.Add(10 * time.Second); !expires.Equal(want) {
+		t.Errorf("expires = %v, want TTL capped to %v", expires, want)
+	}
+
+	cache.Store("zero.example.com", []net.IP{net.ParseIP("192.0.2.2")}, 0)
+	if _, _, ok := cache.Lookup("zero.example.com"); ok {
+		t.Error("expected zero-TTL answer not to be cached")
+	}
+}
+
+func TestNewCachingLookup(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	cache := NewDNSCache(DefaultMaxCacheTTL)
+	cache.now = func() time.Time { return now }
+
+	calls := 0
+	resolve := func(_ context.Context, host string) ([]net.IP, time.Duration, error) {
+		calls++
+		return []net.IP{net.ParseIP("192.0.2.10")}, 30 * time.Second, nil
+	}
+	lookup := NewCachingLookup(cache, resolve)
+
+	for i := range 2 {
+		ips, err := lookup(context.Background(), "api.example.com")
+		if err != nil {
+			t.Fatalf("lookup %d error = %v", i, err)
+		}
+		if !slices.Equal(ips, []string{"192.0.2.10"}) {
+			t.Errorf("lookup %d = %v", i, ips)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("resolver called %d times within TTL, want 1", calls)
+	}
+
+	now = now.Add(31 * time.Second)
+	if _, err := lookup(context.Background(), "api.example.com"); err != nil {
+		t.Fatalf("lookup after expiry error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("resolver called %d times after TTL expiry, want 2", calls)
+	}
+
+	// Without a cache every lookup hits the resolver.
+	calls = 0
+	uncached := NewCachingLookup(nil, resolve)
+	_, _ = uncached(context.Background(), "api.example.com")
+	_, _ = uncached(context.Background(), "api.example.com")
+	if calls != 2 {
+		t.Errorf("uncached resolver called %d times, want 2", calls)
+	}
+}
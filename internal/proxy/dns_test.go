@@ -0,0 +1,176 @@
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// serveFakeDNS answers A queries for every name with addr until the conn closes.
+func serveFakeDNS(t *testing.T, pc net.PacketConn, addr net.IP) {
+	t.Helper()
+	buf := make([]byte, 512)
+	for {
+		n, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query := buf[:n]
+		qtype := binary.BigEndian.Uint16(query[n-4:])
+
+		resp := append([]byte(nil), query...)
+		resp[2] |= 0x80 // response
+		if qtype == dnsTypeA {
+			binary.BigEndian.PutUint16(resp[6:], 1)
+			resp = append(resp, 0xc0, 12) // pointer to question name
+			resp = binary.BigEndian.AppendUint16(resp, dnsTypeA)
+			resp = binary.BigEndian.AppendUint16(resp, dnsClassIN)
+			resp = binary.BigEndian.AppendUint32(resp, 60)
+			resp = binary.BigEndian.AppendUint16(resp, net.IPv4len)
+			resp = append(resp, addr.To4()...)
+		}
+		_, _ = pc.WriteTo(resp, from)
+	}
+}
+
+func TestDNSOnlyLookup(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = pc.Close() }()
+	go serveFakeDNS(t, pc, net.ParseIP("192.0.2.10"))
+
+	lookup := NewDNSOnlyLookup([]string{pc.LocalAddr().String()})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ips, err := lookup(ctx, "localhost")
+	if err != nil {
+		t.Fatalf("lookup() error = %v", err)
+	}
+	// localhost would be 127.0.0.1 via /etc/hosts; the DNS-only lookup must not use it.
+	if want := []string{"192.0.2.10"}; !slices.Equal(ips, want) {
+		t.Errorf("lookup(localhost) = %v, want %v", ips, want)
+	}
+
+	ttlIPs, ttl, err := NewDNSOnlyTTLLookup([]string{pc.LocalAddr().String()})(ctx, "example.com")
+	if err != nil {
+		t.Fatalf("TTL lookup error = %v", err)
+	}
+	if len(ttlIPs) != 1 || ttl != 60*time.Second {
+		t.Errorf("TTL lookup = %v, %v; want one address with TTL 60s", ttlIPs, ttl)
+	}
+
+	ips, err = lookup(ctx, "203.0.113.7")
+	if err != nil || !slices.Equal(ips, []string{"203.0.113.7"}) {
+		t.Errorf("lookup(IP) = %v, %v; want the IP unchanged", ips, err)
+	}
+}
+
+func TestDNSOnlyLookupTCPFallback(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	pc, err := net.ListenPacket("udp", ln.Addr().String())
+	if err != nil {
+		t.Skipf("cannot listen on UDP %s: %v", ln.Addr(), err)
+	}
+	defer func() { _ = pc.Close() }()
+
+	// UDP answers are empty and truncated, so only a TCP retry finds the address
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp := append([]byte(nil), buf[:n]...)
+			resp[2] |= 0x80 | dnsFlagTC
+			_, _ = pc.WriteTo(resp, from)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				tcpPC := &streamPacketConn{Conn: conn}
+				serveFakeDNS(t, tcpPC, net.ParseIP("192.0.2.20"))
+			}()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	ips, err := NewDNSOnlyLookup([]string{ln.Addr().String()})(ctx, "example.com")
+	if err != nil {
+		t.Fatalf("lookup() error = %v", err)
+	}
+	if want := []string{"192.0.2.20"}; !slices.Equal(ips, want) {
+		t.Errorf("lookup() = %v, want %v", ips, want)
+	}
+}
+
+// streamPacketConn adapts a TCP DNS connection, whose messages carry a
+// two-byte length prefix, to the net.PacketConn used by serveFakeDNS.
+type streamPacketConn struct {
+	net.Conn
+}
+
+func (c *streamPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(c.Conn, length[:]); err != nil {
+		return 0, nil, err
+	}
+	n := int(binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(c.Conn, p[:n]); err != nil {
+		return 0, nil, err
+	}
+	return n, c.RemoteAddr(), nil
+}
+
+func (c *streamPacketConn) WriteTo(p []byte, _ net.Addr) (int, error) {
+	return c.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(p))), p...)) //nolint:gosec // test messages are small
+}
+
+func TestParseDNSResponseErrors(t *testing.T) {
+	query, id, err := buildDNSQuery("example.com", dnsTypeA)
+	if err != nil {
+		t.Fatalf("buildDNSQuery() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		msg  []byte
+	}{
+		{"truncated", query[:5]},
+		{"wrong id", func() []byte {
+			m := append([]byte(nil), query...)
+			binary.BigEndian.PutUint16(m, id+1)
+			return m
+		}()},
+		{"nxdomain", func() []byte {
+			m := append([]byte(nil), query...)
+			m[3] |= 3
+			return m
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseDNSResponse(tt.msg, id, dnsTypeA); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
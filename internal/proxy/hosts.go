@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
+
+// DefaultHostsFilePath is the system hosts file consulted for overrides.
+const DefaultHostsFilePath = "/etc/hosts"
+
+// DialFunc dials a network address. It matches net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// HostsOverride describes an allowed domain that /etc/hosts points somewhere
+// other than where DNS does.
+type HostsOverride struct {
+	Domain   string
+	HostsIPs []string
+	DNSIPs   []string
+}
+
+func (o HostsOverride) String() string {
+	return fmt.Sprintf("%s resolves to %s via /etc/hosts but %s via DNS",
+		o.Domain, strings.Join(o.HostsIPs, ", "), strings.Join(o.DNSIPs, ", "))
+}
+
+// ParseHostsFile parses hosts(5) content into a map of lowercased hostname to IPs.
+func ParseHostsFile(r io.Reader) (map[string][]string, error) {
+	hosts := make(map[string][]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx != -1 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			continue
+		}
+		for _, name := range fields[1:] {
+			name = strings.ToLower(strings.TrimSuffix(name, "."))
+			if !slices.Contains(hosts[name], ip.String()) {
+				hosts[name] = append(hosts[name], ip.String())
+			}
+		}
+	}
+
+	return hosts, scanner.Err()
+}
+
+// LoadHostsFile parses the hosts file at path.
+func LoadHostsFile(path string) (map[string][]string, error) {
+	f, err := os.Open(path) //nolint:gosec // fixed system path or test fixture
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return ParseHostsFile(f)
+}
+
+// FindHostsOverrides returns the allowed domains whose hosts file entries
+// point at addresses that DNS does not return for them. Wildcard patterns
+// are checked against every matching hosts entry. localhost and its
+// subdomains are skipped, since they never resolve through DNS; loopback
+// entries for any other allowed domain are reported, as they redirect its
+// traffic to the local machine.
+func FindHostsOverrides(ctx context.Context, allowedDomains []string, hosts map[string][]string, lookup LookupFunc) []HostsOverride {
+	var overrides []HostsOverride
+
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		matched := false
+		for _, pattern := range allowedDomains {
+			if config.BareDomain(pattern) != "*" && config.AppliesToTCP(pattern) && config.MatchesDomain(name, pattern) {
+				matched = true
+				break
+			}
+		}
+		if !matched || name == "localhost" || strings.HasSuffix(name, ".localhost") {
+			continue
+		}
+		hostsIPs := hosts[name]
+
+		dnsIPs, err := lookup(ctx, name)
+		if err != nil {
+			// Names that only exist in /etc/hosts (e.g. local mocks) have no
+			// DNS answer to compare against.
+			dnsIPs = nil
+		}
+
+		for _, ip := range hostsIPs {
+			if !slices.Contains(dnsIPs, ip) {
+				overrides = append(overrides, HostsOverride{
+					Domain:   name,
+					HostsIPs: hostsIPs,
+					DNSIPs:   dnsIPs,
+				})
+				break
+			}
+		}
+	}
+
+	return overrides
+}
+
+// NewDialer returns the dial function the proxies use for outbound
+// connections. Hostnames are resolved with lookup, which decides whether
+// /etc/hosts overrides apply and caches the answers, and each address is
+// tried in turn.
+func NewDialer(lookup LookupFunc) DialFunc {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		ips, err := lookup(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("DNS lookup for %s failed: %w", host, err)
+		}
+
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no addresses for %s", host)
+		}
+		return nil, lastErr
+	}
+}
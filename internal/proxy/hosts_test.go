@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseHostsFile(t *testing.T) {
+	content := `# comment
+127.0.0.1	localhost
+::1	localhost ip6-localhost
+10.0.0.5	API.Example.com internal.example.com # trailing comment
+not-an-ip	ignored.example.com
+10.0.0.6
+`
+	hosts, err := ParseHostsFile(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("ParseHostsFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"localhost", []string{"127.0.0.1", "::1"}},
+		{"ip6-localhost", []string{"::1"}},
+		{"api.example.com", []string{"10.0.0.5"}},
+		{"internal.example.com", []string{"10.0.0.5"}},
+		{"ignored.example.com", nil},
+	}
+	for _, tt := range tests {
+		if got := hosts[tt.name]; !slices.Equal(got, tt.want) {
+			t.Errorf("hosts[%q] = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFindHostsOverrides(t *testing.T) {
+	hosts := map[string][]string{
+		"api.example.com":   {"10.0.0.5"},
+		"cdn.example.com":   {"93.184.216.34"},
+		"mock.example.com":  {"127.0.0.1"},
+		"app.localhost":     {"127.0.0.1"},
+		"unrelated.org":     {"10.0.0.9"},
+		"localhost":         {"127.0.0.1"},
+		"www.github.com":    {"10.1.1.1"},
+		"other.example.net": {"10.0.0.7"},
+	}
+	dns := map[string][]string{
+		"api.example.com": {"93.184.216.34"},
+		"cdn.example.com": {"93.184.216.34"},
+		"www.github.com":  {"140.82.112.3"},
+	}
+	lookup := func(_ context.Context, host string) ([]string, error) {
+		if ips, ok := dns[host]; ok {
+			return ips, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	allowed := []string{"*.example.com", "tag:github www.github.com", "localhost", "*.localhost", "*"}
+	overrides := FindHostsOverrides(context.Background(), allowed, hosts, lookup)
+
+	var got []string
+	for _, o := range overrides {
+		got = append(got, o.Domain)
+	}
+	// localhost names are not overrides, but loopback entries for other
+	// allowed domains are
+	want := []string{"api.example.com", "mock.example.com", "www.github.com"}
+	if !slices.Equal(got, want) {
+		t.Errorf("FindHostsOverrides() domains = %v, want %v", got, want)
+	}
+
+	if len(overrides) > 0 {
+		msg := overrides[0].String()
+		if !strings.Contains(msg, "10.0.0.5") || !strings.Contains(msg, "93.184.216.34") {
+			t.Errorf("HostsOverride.String() = %q, want both addresses", msg)
+		}
+	}
+}
+
+func TestNewDialer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	var lookedUp []string
+	lookup := func(_ context.Context, host string) ([]string, error) {
+		lookedUp = append(lookedUp, host)
+		if host == "upstream.test" {
+			return []string{"127.0.0.1"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	dial := NewDialer(lookup)
+
+	conn, err := dial(context.Background(), "tcp", net.JoinHostPort("upstream.test", port))
+	if err != nil {
+		t.Fatalf("dial via lookup failed: %v", err)
+	}
+	_ = conn.Close()
+
+	if _, err := dial(context.Background(), "tcp", net.JoinHostPort("missing.test", port)); err == nil {
+		t.Error("expected dial to fail when lookup fails")
+	}
+
+	conn, err = dial(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial by IP failed: %v", err)
+	}
+	_ = conn.Close()
+
+	if want := []string{"upstream.test", "missing.test"}; !slices.Equal(lookedUp, want) {
+		t.Errorf("lookups = %v, want %v", lookedUp, want)
+	}
+}
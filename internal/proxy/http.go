@@ -3,12 +3,14 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -22,24 +24,72 @@ type FilterFunc func(host string, port int) bool
 
 // HTTPProxy is an HTTP/HTTPS proxy server with domain filtering.
 type HTTPProxy struct {
-	server   *http.Server
-	listener net.Listener
-	filter   FilterFunc
-	debug    bool
-	monitor  bool
-	mu       sync.RWMutex
-	running  bool
+	server           *http.Server
+	listener         net.Listener
+	filter           FilterFunc
+	dial             DialFunc
+	transport        *http.Transport
+	inspector        *TLSInspector
+	inspectTransport *http.Transport // Re-originates TLS for intercepted CONNECT tunnels
+	debug            bool
+	monitor          bool
+	mu               sync.RWMutex
+	running          bool
 }
 
 // NewHTTPProxy creates a new HTTP proxy with the given filter.
 // If monitor is true, only blocked requests are logged.
 // If debug is true, all requests and filter rules are logged.
 func NewHTTPProxy(filter FilterFunc, debug, monitor bool) *HTTPProxy {
-	return &HTTPProxy{
+	p := &HTTPProxy{
 		filter:  filter,
 		debug:   debug,
 		monitor: monitor,
 	}
+	p.SetDialer((&net.Dialer{Timeout: 10 * time.Second}).DialContext)
+	return p
+}
+
+// SetDialer sets the function used for outbound connections. It must be
+// called before Start.
+func (p *HTTPProxy) SetDialer(dial DialFunc) {
+	if dial == nil {
+		return
+	}
+	p.dial = dial
+	p.transport = http.DefaultTransport.(*http.Transport).Clone()
+	p.transport.DialContext = dial
+	p.updateInspectTransport()
+}
+
+// SetTLSInspector enables TLS inspection of CONNECT tunnels. It must be
+// called before Start.
+func (p *HTTPProxy) SetTLSInspector(inspector *TLSInspector) {
+	p.inspector = inspector
+	p.updateInspectTransport()
+}
+
+// updateInspectTransport rebuilds the transport used for intercepted
+// connections so it shares the configured dialer.
+func (p *HTTPProxy) updateInspectTransport() {
+	if p.inspector == nil || p.transport == nil {
+		p.inspectTransport = nil
+		return
+	}
+	p.inspectTransport = p.transport.Clone()
+	// Responses are relayed as HTTP/1.1, so keep the upstream on HTTP/1.1 too.
+	p.inspectTransport.ForceAttemptHTTP2 = false
+	p.inspectTransport.TLSClientConfig = &tls.Config{
+		RootCAs:    p.inspector.roots,
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// dialTarget dials host:port with the configured dialer and a timeout.
+func (p *HTTPProxy) dialTarget(ctx context.Context, host string, port int) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return p.dial(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
 }
 
 // Start starts the HTTP proxy on a random available port.
@@ -93,13 +143,32 @@ func (p *HTTPProxy) Port() int {
 }
 
 func (p *HTTPProxy) handleRequest(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodConnect {
+	switch {
+	case r.Method == http.MethodConnect:
 		p.handleConnect(w, r)
-	} else {
+	case isUpgradeRequest(r):
+		p.handleUpgrade(w, r)
+	default:
 		p.handleHTTP(w, r)
 	}
 }
 
+// isUpgradeRequest reports whether r asks to switch protocols
+// (e.g. "Upgrade: h2c" for cleartext HTTP/2 or "Upgrade: websocket").
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // handleConnect handles HTTPS CONNECT requests (tunnel).
 func (p *HTTPProxy) handleConnect(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
@@ -125,8 +194,13 @@ func (p *HTTPProxy) handleConnect(w http.ResponseWriter, r *http.Request) {
 
 	p.logRequest("CONNECT", fmt.Sprintf("https://%s:%d", host, port), host, 200, "ALLOWED", time.Since(start))
 
+	if p.inspector != nil {
+		p.handleInspectedConnect(w, host, port)
+		return
+	}
+
 	// Connect to target
-	targetConn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, port), 10*time.Second)
+	targetConn, err := p.dialTarget(r.Context(), host, port)
 	if err != nil {
 		p.logDebug("CONNECT dial failed: %s:%d: %v", host, port, err)
 		http.Error(w, "Bad Gateway", http.StatusBadGateway)
@@ -169,6 +243,29 @@ func (p *HTTPProxy) handleConnect(w http.ResponseWriter, r *http.Request) {
 	wg.Wait()
 }
 
+// handleInspectedConnect accepts a CONNECT tunnel and terminates its TLS
+// locally instead of piping bytes to the target.
+func (p *HTTPProxy) handleInspectedConnect(w http.ResponseWriter, host string, port int) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+
+	clientConn, _, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(w, "Failed to hijack connection", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = clientConn.Close() }()
+
+	if _, err := clientConn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		return
+	}
+
+	p.serveInspected(clientConn, host, port)
+}
+
 // handleHTTP handles regular HTTP proxy requests.
 func (p *HTTPProxy) handleHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
@@ -212,7 +309,8 @@ func (p *HTTPProxy) handleHTTP(w http.ResponseWriter, r *http.Request) {
 	proxyReq.Header.Del("Proxy-Authorization")
 
 	client := &http.Client{
-		Timeout: 30 * time.Second,
+		Timeout:   30 * time.Second,
+		Transport: p.transport,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
@@ -239,6 +337,83 @@ func (p *HTTPProxy) handleHTTP(w http.ResponseWriter, r *http.Request) {
 	p.logRequest(r.Method, r.RequestURI, host, resp.StatusCode, "ALLOWED", time.Since(start))
 }
 
+// handleUpgrade handles plain HTTP requests that switch protocols, such as
+// h2c (cleartext HTTP/2, used by some gRPC clients) and WebSocket upgrades.
+// After the upgrade the proxy can no longer see individual requests, so the
+// target host is checked against the domain policy before switching.
+func (p *HTTPProxy) handleUpgrade(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	protocol := r.Header.Get("Upgrade")
+	targetURL, err := url.Parse(r.RequestURI)
+	if err != nil || targetURL.Hostname() == "" {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	host := targetURL.Hostname()
+	port := 80
+	if targetURL.Port() != "" {
+		if p, err := strconv.Atoi(targetURL.Port()); err == nil {
+			port = p
+		}
+	}
+
+	if !p.filter(host, port) {
+		p.logRequest(r.Method, r.RequestURI, host, 403, "BLOCKED", time.Since(start))
+		http.Error(w, "Connection blocked by network allowlist", http.StatusForbidden)
+		return
+	}
+
+	targetConn, err := p.dialTarget(r.Context(), host, port)
+	if err != nil {
+		p.logDebug("%s upgrade dial failed: %s:%d: %v", protocol, host, port, err)
+		p.logRequest(r.Method, r.RequestURI, host, 502, "ERROR", time.Since(start))
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = targetConn.Close() }()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+
+	clientConn, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(w, "Failed to hijack connection", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = clientConn.Close() }()
+
+	// Forward the upgrade request in origin form
+	r.Header.Del("Proxy-Connection")
+	r.Header.Del("Proxy-Authorization")
+	r.Host = targetURL.Host
+	if err := r.Write(targetConn); err != nil {
+		p.logDebug("%s upgrade write failed: %s:%d: %v", protocol, host, port, err)
+		return
+	}
+
+	p.logRequest(r.Method, r.RequestURI, host, 101, "ALLOWED", time.Since(start))
+
+	// Pipe data bidirectionally, including anything the client already buffered
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(targetConn, clientBuf)
+	}()
+
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(clientConn, targetConn)
+	}()
+
+	wg.Wait()
+}
+
 func (p *HTTPProxy) logDebug(format string, args ...interface{}) {
 	if p.debug {
 		fmt.Fprintf(os.Stderr, "[fence:http] "+format+"\n", args...)
@@ -278,21 +453,111 @@ func truncateURL(url string, maxLen int) string {
 	return url[:maxLen-3] + "..."
 }
 
+// NetworkAuditEventType identifies the kind of network audit event.
+type NetworkAuditEventType string
+
+// NewDomainFirstSeen is emitted the first time an allowed connection is made
+// to a domain during a session when network.alertOnNewDomain is set.
+const NewDomainFirstSeen NetworkAuditEventType = "NewDomainFirstSeen"
+
+// NetworkAuditEvent describes a network connection of interest to auditors.
+type NetworkAuditEvent struct {
+	Type NetworkAuditEventType
+	Host string // Lowercased destination hostname
+	Port int    // Destination port of the first connection
+}
+
+// NetworkAuditFunc receives network audit events.
+type NetworkAuditFunc func(NetworkAuditEvent)
+
 // CreateDomainFilter creates a filter function from a config.
 // When debug is true, logs filter rule matches to stderr.
 func CreateDomainFilter(cfg *config.Config, debug bool) FilterFunc {
+	return CreateDomainFilterWithAudit(cfg, debug, nil)
+}
+
+// CreateDomainFilterWithAudit is like CreateDomainFilter but reports network
+// events to audit. When network.alertOnNewDomain is set, a NewDomainFirstSeen
+// event is emitted the first time each domain is allowed. Seen domains are
+// tracked per filter, so a filter shared by the proxies covers one session.
+func CreateDomainFilterWithAudit(cfg *config.Config, debug bool, audit NetworkAuditFunc) FilterFunc {
+	return CreateDomainFilterWithResolver(cfg, debug, audit, nil)
+}
+
+// CreateDomainFilterWithResolver is like CreateDomainFilterWithAudit but
+// resolves hostnames for CIDR checks with resolver, which records the
+// addresses each allowed host was checked with for resolver.DialLookup. A
+// nil resolver uses the system resolver.
+func CreateDomainFilterWithResolver(cfg *config.Config, debug bool, audit NetworkAuditFunc, resolver *Resolver) FilterFunc {
+	filter := createDomainFilter(cfg, debug, resolver)
+	if audit == nil || cfg == nil || !cfg.Network.AlertOnNewDomain {
+		return filter
+	}
+
+	var seen sync.Map
 	return func(host string, port int) bool {
-		if cfg == nil {
-			// No config = deny all
+		if !filter(host, port) {
+			return false
+		}
+		domain := strings.ToLower(strings.TrimSuffix(host, "."))
+		if _, loaded := seen.LoadOrStore(domain, struct{}{}); !loaded {
+			audit(NetworkAuditEvent{Type: NewDomainFirstSeen, Host: domain, Port: port})
+		}
+		return true
+	}
+}
+
+func createDomainFilter(cfg *config.Config, debug bool, resolver *Resolver) FilterFunc {
+	if cfg == nil {
+		// No config = deny all
+		return func(host string, port int) bool {
 			if debug {
 				fmt.Fprintf(os.Stderr, "[fence:filter] No config, denying: %s:%d\n", host, port)
 			}
 			return false
 		}
+	}
+
+	// CIDR entries also cover hostnames that resolve into the range. They
+	// are parsed once here rather than per connection.
+	deniedCIDRs := tcpCIDREntries(cfg.Network.DeniedDomains)
+	allowedCIDRs := tcpCIDREntries(cfg.Network.EffectiveAllowedDomains())
+	lookup := lookupFilterHost
+	if resolver != nil {
+		lookup = func(host string) ([]net.IP, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), filterLookupTimeout)
+			defer cancel()
+			return resolver.lookupIPs(ctx, host)
+		}
+	}
+	// allow records the addresses host was checked with, so the dialer
+	// connects to those rather than to a fresh DNS answer.
+	allow := func(host string, ips []net.IP) bool {
+		if resolver != nil {
+			resolver.setChecked(host, ips)
+		}
+		return true
+	}
+
+	return func(host string, port int) bool {
+		if slices.Contains(cfg.Network.DeniedPorts, port) {
+			if debug {
+				fmt.Fprintf(os.Stderr, "[fence:filter] Denied port: %s:%d\n", host, port)
+			}
+			return false
+		}
+		if len(cfg.Network.AllowedPorts) > 0 && !slices.Contains(cfg.Network.AllowedPorts, port) {
+			if debug {
+				fmt.Fprintf(os.Stderr, "[fence:filter] Port not in allowedPorts: %s:%d\n", host, port)
+			}
+			return false
+		}
 
 		// Check denied domains first
+		// Proxied connections are TCP, so entries restricted to another
+		// protocol do not apply.
 		for _, denied := range cfg.Network.DeniedDomains {
-			if config.MatchesDomain(host, denied) {
+			if config.AppliesToTCP(denied) && config.MatchesDomain(host, denied) {
 				if debug {
 					fmt.Fprintf(os.Stderr, "[fence:filter] Denied by rule: %s:%d (matched %s)\n", host, port, denied)
 				}
@@ -300,16 +565,49 @@ func CreateDomainFilter(cfg *config.Config, debug bool) FilterFunc {
 			}
 		}
 
+		// IP literals were already matched against CIDR entries above.
+		var ips []net.IP
+		if (len(deniedCIDRs) > 0 || len(allowedCIDRs) > 0) && config.ParseIPLiteral(host) == nil {
+			var err error
+			ips, err = lookup(host)
+			if err != nil && len(deniedCIDRs) > 0 {
+				// Without addresses a denied range cannot be ruled out
+				if debug {
+					fmt.Fprintf(os.Stderr, "[fence:filter] Cannot resolve %s to check deniedDomains CIDR blocks, denying: %v\n", host, err)
+				}
+				return false
+			}
+		}
+		for _, entry := range deniedCIDRs {
+			for _, ip := range ips {
+				if entry.ContainsIP(ip) {
+					if debug {
+						fmt.Fprintf(os.Stderr, "[fence:filter] Denied by rule: %s:%d (%s matched %s)\n", host, port, ip, entry.Pattern)
+					}
+					return false
+				}
+			}
+		}
+
 		// Check allowed domains
-		for _, allowed := range cfg.Network.AllowedDomains {
-			if config.MatchesDomain(host, allowed) {
+		for _, allowed := range cfg.Network.EffectiveAllowedDomains() {
+			if config.AppliesToTCP(allowed) && config.MatchesDomain(host, allowed) {
 				if debug {
 					fmt.Fprintf(os.Stderr, "[fence:filter] Allowed by rule: %s:%d (matched %s)\n", host, port, allowed)
 				}
-				return true
+				return allow(host, ips)
 			}
 		}
 
+		// A hostname is allowed by CIDR entries only if every address it
+		// resolves to is covered, since the dialer may use any of them.
+		if len(ips) > 0 && len(allowedCIDRs) > 0 && allIPsCovered(ips, allowedCIDRs) {
+			if debug {
+				fmt.Fprintf(os.Stderr, "[fence:filter] Allowed by CIDR rules: %s:%d (resolved to %v)\n", host, port, ips)
+			}
+			return allow(host, ips)
+		}
+
 		if debug {
 			fmt.Fprintf(os.Stderr, "[fence:filter] No matching rule, denying: %s:%d\n", host, port)
 		}
@@ -317,15 +615,61 @@ func CreateDomainFilter(cfg *config.Config, debug bool) FilterFunc {
 	}
 }
 
+// filterLookupTimeout bounds the DNS lookup made to check CIDR entries.
+const filterLookupTimeout = 5 * time.Second
+
+// lookupFilterHost resolves a hostname for CIDR checks when the filter has no
+// Resolver. Tests override it.
+var lookupFilterHost = func(host string) ([]net.IP, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), filterLookupTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return ips, nil
+}
+
+// tcpCIDREntries returns the CIDR blocks among domain entries that apply to TCP.
+func tcpCIDREntries(entries []string) []config.DomainEntry {
+	var cidrs []config.DomainEntry
+	for _, entry := range entries {
+		parsed, err := config.ParseDomainEntry(entry)
+		if err != nil || parsed.Kind != config.DomainCIDR || !config.AppliesToTCP(entry) {
+			continue
+		}
+		cidrs = append(cidrs, parsed)
+	}
+	return cidrs
+}
+
+// allIPsCovered reports whether every ip is in one of the CIDR entries.
+func allIPsCovered(ips []net.IP, cidrs []config.DomainEntry) bool {
+	for _, ip := range ips {
+		if !slices.ContainsFunc(cidrs, func(e config.DomainEntry) bool { return e.ContainsIP(ip) }) {
+			return false
+		}
+	}
+	return true
+}
+
 // GetHostFromRequest extracts the hostname from a request.
 func GetHostFromRequest(r *http.Request) string {
 	host := r.Host
 	if h := r.URL.Hostname(); h != "" {
 		host = h
 	}
-	// Strip port
-	if idx := strings.LastIndex(host, ":"); idx != -1 {
-		host = host[:idx]
+	// Strip port. IPv6 literals keep their brackets, and a literal without a
+	// port ("::1", "[::1]") has no port to strip.
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		if strings.Contains(h, ":") {
+			return "[" + h + "]"
+		}
+		return h
 	}
 	return host
 }
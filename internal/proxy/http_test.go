@@ -1,9 +1,13 @@
 package proxy
 
 import (
+	"bufio"
+	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/Use-Tusk/fence/internal/config"
 )
@@ -69,6 +73,24 @@ func TestGetHostFromRequest(t *testing.T) {
 			urlStr:   "/path",
 			wantHost: "[::1]",
 		},
+		{
+			name:     "ipv6 host without port",
+			host:     "[::1]",
+			urlStr:   "/path",
+			wantHost: "[::1]",
+		},
+		{
+			name:     "bare ipv6 host",
+			host:     "::1",
+			urlStr:   "/path",
+			wantHost: "::1",
+		},
+		{
+			name:     "ipv6 url",
+			host:     "other.com",
+			urlStr:   "http://[::1]:443/path",
+			wantHost: "::1",
+		},
 	}
 
 	for _, tt := range tests {
@@ -125,6 +147,40 @@ func TestCreateDomainFilter(t *testing.T) {
 			port:    443,
 			allowed: false,
 		},
+		{
+			name: "allowed domain does not match attacker suffix",
+			cfg: &config.Config{
+				Network: config.NetworkConfig{
+					AllowedDomains: []string{"api.openai.com"},
+				},
+			},
+			host:    "api.openai.com.evil.com",
+			port:    443,
+			allowed: false,
+		},
+		{
+			name: "fully qualified host still denied",
+			cfg: &config.Config{
+				Network: config.NetworkConfig{
+					AllowedDomains: []string{"*"},
+					DeniedDomains:  []string{"evil.com"},
+				},
+			},
+			host:    "evil.com.",
+			port:    443,
+			allowed: false,
+		},
+		{
+			name: "tagged allowed domain",
+			cfg: &config.Config{
+				Network: config.NetworkConfig{
+					AllowedDomains: []string{"tag:example example.com"},
+				},
+			},
+			host:    "example.com",
+			port:    443,
+			allowed: true,
+		},
 		{
 			name: "wildcard allowed",
 			cfg: &config.Config{
@@ -205,6 +261,63 @@ func TestCreateDomainFilter(t *testing.T) {
 			port:    443,
 			allowed: true,
 		},
+		{
+			name: "proto:tcp entry allows proxied connection",
+			cfg: &config.Config{
+				Network: config.NetworkConfig{
+					AllowedDomains: []string{"proto:tcp api.openai.com"},
+				},
+			},
+			host:    "api.openai.com",
+			port:    443,
+			allowed: true,
+		},
+		{
+			name: "tagged proto:tcp entry allows proxied connection",
+			cfg: &config.Config{
+				Network: config.NetworkConfig{
+					AllowedDomains: []string{"tag:openai proto:tcp api.openai.com"},
+				},
+			},
+			host:    "api.openai.com",
+			port:    443,
+			allowed: true,
+		},
+		{
+			name: "proto:udp entry does not allow proxied connection",
+			cfg: &config.Config{
+				Network: config.NetworkConfig{
+					AllowedDomains: []string{"proto:udp dns.example.com"},
+				},
+			},
+			host:    "dns.example.com",
+			port:    443,
+			allowed: false,
+		},
+		{
+			name: "proto:icmp deny does not block proxied connection",
+			cfg: &config.Config{
+				Network: config.NetworkConfig{
+					AllowedDomains: []string{"example.com"},
+					DeniedDomains:  []string{"proto:icmp example.com"},
+				},
+			},
+			host:    "example.com",
+			port:    443,
+			allowed: true,
+		},
+		{
+			name: "proto:tcp deny blocks proxied connection",
+			cfg: &config.Config{
+				Network: config.NetworkConfig{
+					AllowedDomains: []string{"*"},
+					DeniedDomains:  []string{"proto:tcp example.com"},
+				},
+			},
+			host:    "example.com",
+			port:    443,
+			allowed: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -306,3 +419,318 @@ func TestHTTPProxyPortBeforeStart(t *testing.T) {
 		t.Errorf("Port() before Start() = %d, want 0", proxy.Port())
 	}
 }
+
+func TestIsUpgradeRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		upgrade    string
+		connection string
+		want       bool
+	}{
+		{"h2c upgrade", "h2c", "Upgrade, HTTP2-Settings", true},
+		{"websocket upgrade", "websocket", "upgrade", true},
+		{"upgrade header without connection token", "h2c", "keep-alive", false},
+		{"plain request", "", "keep-alive", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &http.Request{Header: http.Header{}}
+			if tt.upgrade != "" {
+				r.Header.Set("Upgrade", tt.upgrade)
+			}
+			r.Header.Set("Connection", tt.connection)
+			if got := isUpgradeRequest(r); got != tt.want {
+				t.Errorf("isUpgradeRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// sendH2CUpgrade sends a raw h2c upgrade request through the proxy and returns the response.
+func sendH2CUpgrade(t *testing.T, proxyPort int, target, hostHeader string) *http.Response {
+	t.Helper()
+
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", proxyPort), 5*time.Second)
+	if err != nil {
+		t.Fatalf("failed to dial proxy: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAMAAABkAAQAAP__\r\n\r\n", target, hostHeader)
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatalf("failed to write request: %v", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	return resp
+}
+
+func TestHTTPProxyH2CUpgrade(t *testing.T) {
+	// Minimal upstream that accepts the h2c upgrade
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() { _ = upstream.Close() }()
+	go func() {
+		for {
+			conn, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer func() { _ = c.Close() }()
+				if _, err := http.ReadRequest(bufio.NewReader(c)); err != nil {
+					return
+				}
+				_, _ = c.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: h2c\r\n\r\n"))
+			}(conn)
+		}
+	}()
+	upstreamAddr := upstream.Addr().String()
+
+	cfg := &config.Config{
+		Network: config.NetworkConfig{
+			AllowedDomains: []string{"127.0.0.1"},
+			DeniedDomains:  []string{"blocked.example.com"},
+		},
+	}
+	proxy := NewHTTPProxy(CreateDomainFilter(cfg, false), false, false)
+	port, err := proxy.Start()
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer func() { _ = proxy.Stop() }()
+
+	tests := []struct {
+		name       string
+		target     string
+		hostHeader string
+		wantStatus int
+	}{
+		{"blocked domain", "http://blocked.example.com/", "blocked.example.com", http.StatusForbidden},
+		{"unlisted domain", "http://other.example.com/", "other.example.com", http.StatusForbidden},
+		{"allowed target", "http://" + upstreamAddr + "/", upstreamAddr, http.StatusSwitchingProtocols},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := sendH2CUpgrade(t, port, tt.target, tt.hostHeader)
+			defer func() { _ = resp.Body.Close() }()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCreateDomainFilterWithAuditNewDomain(t *testing.T) {
+	cfg := &config.Config{
+		Network: config.NetworkConfig{
+			AllowedDomains:   []string{"*.example.com", "api.github.com"},
+			DeniedDomains:    []string{"blocked.example.com"},
+			AlertOnNewDomain: true,
+		},
+	}
+
+	var events []NetworkAuditEvent
+	filter := CreateDomainFilterWithAudit(cfg, false, func(e NetworkAuditEvent) {
+		events = append(events, e)
+	})
+
+	connections := []struct {
+		host    string
+		port    int
+		allowed bool
+	}{
+		{"api.example.com", 443, true},
+		{"api.example.com", 443, true},
+		{"API.example.com", 80, true},
+		{"blocked.example.com", 443, false},
+		{"evil.org", 443, false},
+		{"api.github.com", 443, true},
+		{"api.github.com", 443, true},
+	}
+	for _, c := range connections {
+		if got := filter(c.host, c.port); got != c.allowed {
+			t.Errorf("filter(%q, %d) = %v, want %v", c.host, c.port, got, c.allowed)
+		}
+	}
+
+	want := []NetworkAuditEvent{
+		{Type: NewDomainFirstSeen, Host: "api.example.com", Port: 443},
+		{Type: NewDomainFirstSeen, Host: "api.github.com", Port: 443},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events %v, want %v", len(events), events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event[%d] = %+v, want %+v", i, events[i], want[i])
+		}
+	}
+
+	// A separate filter is a separate session.
+	events = nil
+	filter = CreateDomainFilterWithAudit(cfg, false, func(e NetworkAuditEvent) {
+		events = append(events, e)
+	})
+	filter("api.example.com", 443)
+	if len(events) != 1 {
+		t.Errorf("expected new session to report api.example.com again, got %v", events)
+	}
+
+	// Disabled: no events.
+	cfg.Network.AlertOnNewDomain = false
+	events = nil
+	filter = CreateDomainFilterWithAudit(cfg, false, func(e NetworkAuditEvent) {
+		events = append(events, e)
+	})
+	filter("api.example.com", 443)
+	if len(events) != 0 {
+		t.Errorf("expected no events with alertOnNewDomain disabled, got %v", events)
+	}
+}
+
+func TestCreateDomainFilterAllowACMEChallenge(t *testing.T) {
+	cfg := &config.Config{Network: config.NetworkConfig{AllowedDomains: []string{"github.com"}}}
+
+	if CreateDomainFilter(cfg, false)("acme-v02.api.letsencrypt.org", 443) {
+		t.Error("expected ACME endpoint to be blocked without allowACMEChallenge")
+	}
+
+	cfg.Network.AllowACMEChallenge = true
+	filter := CreateDomainFilter(cfg, false)
+	for _, d := range config.ACMEDomains {
+		if !filter(d, 443) {
+			t.Errorf("expected %s to be allowed with allowACMEChallenge", d)
+		}
+	}
+	if !filter("github.com", 443) {
+		t.Error("expected configured domain to remain allowed")
+	}
+}
+
+func TestCreateDomainFilterPorts(t *testing.T) {
+	tests := []struct {
+		name         string
+		allowedPorts []int
+		deniedPorts  []int
+		host         string
+		port         int
+		want         bool
+	}{
+		{"no port rules", nil, nil, "api.github.com", 9418, true},
+		{"denied port on allowed domain", nil, []int{9418}, "api.github.com", 9418, false},
+		{"other port not denied", nil, []int{9418}, "api.github.com", 443, true},
+		{"port in allowedPorts", []int{80, 443}, nil, "api.github.com", 443, true},
+		{"port outside allowedPorts", []int{80, 443}, nil, "api.github.com", 9418, false},
+		{"denied wins over allowed", []int{443}, []int{443}, "api.github.com", 443, false},
+		{"allowed port on unlisted domain", []int{443}, nil, "example.org", 443, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Network: config.NetworkConfig{
+					AllowedDomains: []string{"api.github.com"},
+					AllowedPorts:   tt.allowedPorts,
+					DeniedPorts:    tt.deniedPorts,
+				},
+			}
+			if got := CreateDomainFilter(cfg, false)(tt.host, tt.port); got != tt.want {
+				t.Errorf("filter(%q, %d) = %v, want %v", tt.host, tt.port, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPProxyConnectDeniedPort(t *testing.T) {
+	cfg := &config.Config{
+		Network: config.NetworkConfig{
+			AllowedDomains: []string{"api.github.com"},
+			AllowedPorts:   []int{443, 9418},
+			DeniedPorts:    []int{9418},
+		},
+	}
+	proxy := NewHTTPProxy(CreateDomainFilter(cfg, false), false, false)
+	port, err := proxy.Start()
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer func() { _ = proxy.Stop() }()
+
+	for _, target := range []string{"api.github.com:9418", "api.github.com:22"} {
+		conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			t.Fatalf("failed to connect to proxy: %v", err)
+		}
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err := fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target); err != nil {
+			t.Fatalf("failed to write request: %v", err)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		_ = resp.Body.Close()
+		_ = conn.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("CONNECT %s: status = %d, want %d", target, resp.StatusCode, http.StatusForbidden)
+		}
+	}
+}
+
+func TestCreateDomainFilterCIDR(t *testing.T) {
+	resolved := map[string][]net.IP{
+		"api.corp.example":    {net.ParseIP("10.10.1.5")},
+		"secret.corp.example": {net.ParseIP("10.10.9.9")},
+		"mixed.corp.example":  {net.ParseIP("10.10.1.6"), net.ParseIP("203.0.113.7")},
+		"github.com":          {net.ParseIP("140.82.112.3")},
+		"rogue.example.org":   {net.ParseIP("10.66.0.1")},
+	}
+	oldLookup := lookupFilterHost
+	lookupFilterHost = func(host string) ([]net.IP, error) {
+		if ips, ok := resolved[host]; ok {
+			return ips, nil
+		}
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+	t.Cleanup(func() { lookupFilterHost = oldLookup })
+
+	cfg := &config.Config{
+		Network: config.NetworkConfig{
+			AllowedDomains: []string{"10.10.0.0/16", "github.com", "rogue.example.org"},
+			DeniedDomains:  []string{"secret.corp.example", "10.66.0.0/16"},
+		},
+	}
+	filter := CreateDomainFilter(cfg, false)
+
+	tests := []struct {
+		name string
+		host string
+		want bool
+	}{
+		{"IP literal in allowed block", "10.10.7.7", true},
+		{"IP literal outside block", "10.20.0.1", false},
+		{"hostname resolving into allowed block", "api.corp.example", true},
+		{"denied name inside allowed block", "secret.corp.example", false},
+		{"allowed name resolving into denied block", "rogue.example.org", false},
+		{"IP literal in denied block", "10.66.1.1", false},
+		{"only some addresses in allowed block", "mixed.corp.example", false},
+		{"name rule still applies", "github.com", true},
+		{"unresolvable host with denied blocks", "unknown.example.net", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter(tt.host, 443); got != tt.want {
+				t.Errorf("filter(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+)
+
+// Resolver resolves hostnames for the domain filter and the dialers, and
+// remembers the addresses the filter last allowed for each host. Dialing
+// through DialLookup connects to exactly those addresses, so a DNS answer
+// that changes between the CIDR check and the dial cannot reach a range the
+// rules deny.
+type Resolver struct {
+	lookup  LookupFunc
+	checked sync.Map // lowercased host -> []string
+}
+
+// NewResolver creates a Resolver that resolves hostnames with lookup.
+func NewResolver(lookup LookupFunc) *Resolver {
+	return &Resolver{lookup: lookup}
+}
+
+// Lookup resolves host with the underlying lookup.
+func (r *Resolver) Lookup(ctx context.Context, host string) ([]string, error) {
+	return r.lookup(ctx, host)
+}
+
+// DialLookup returns the addresses the filter checked for host, or resolves
+// host when the filter did not need to, e.g. because there are no CIDR rules.
+func (r *Resolver) DialLookup(ctx context.Context, host string) ([]string, error) {
+	if v, ok := r.checked.Load(dnsCacheKey(host)); ok {
+		return slices.Clone(v.([]string)), nil
+	}
+	return r.lookup(ctx, host)
+}
+
+// lookupIPs resolves host for CIDR checks.
+func (r *Resolver) lookupIPs(ctx context.Context, host string) ([]net.IP, error) {
+	addrs, err := r.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+// setChecked records the addresses the filter allowed host with. A nil ips
+// forgets earlier ones, so the dialer resolves host itself.
+func (r *Resolver) setChecked(host string, ips []net.IP) {
+	if len(ips) == 0 {
+		r.checked.Delete(dnsCacheKey(host))
+		return
+	}
+	r.checked.Store(dnsCacheKey(host), ipStrings(ips))
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
+
+func TestResolver_DialsCheckedAddresses(t *testing.T) {
+	answers := map[string][]string{
+		"api.corp.example": {"10.10.1.5"},
+		"github.com":       {"140.82.112.3"},
+	}
+	lookups := 0
+	resolver := NewResolver(func(_ context.Context, host string) ([]string, error) {
+		lookups++
+		if ips, ok := answers[host]; ok {
+			return ips, nil
+		}
+		return nil, fmt.Errorf("no such host %s", host)
+	})
+
+	cfg := &config.Config{
+		Network: config.NetworkConfig{
+			AllowedDomains: []string{"10.10.0.0/16", "github.com"},
+			DeniedDomains:  []string{"10.66.0.0/16"},
+		},
+	}
+	filter := CreateDomainFilterWithResolver(cfg, false, nil, resolver)
+
+	if !filter("api.corp.example", 443) {
+		t.Fatal("expected api.corp.example to be allowed by CIDR rules")
+	}
+	// The name now resolves into the denied range, as in a rebinding attack.
+	answers["api.corp.example"] = []string{"10.66.0.1"}
+	got, err := resolver.DialLookup(context.Background(), "API.corp.example")
+	if err != nil {
+		t.Fatalf("DialLookup: %v", err)
+	}
+	if want := []string{"10.10.1.5"}; !slices.Equal(got, want) {
+		t.Errorf("DialLookup = %v, want the checked addresses %v", got, want)
+	}
+
+	// A new check sees the new answer and denies it, leaving the checked
+	// addresses in place.
+	if filter("api.corp.example", 443) {
+		t.Error("expected api.corp.example to be denied once it resolves into 10.66.0.0/16")
+	}
+	got, _ = resolver.DialLookup(context.Background(), "api.corp.example")
+	if want := []string{"10.10.1.5"}; !slices.Equal(got, want) {
+		t.Errorf("DialLookup after denial = %v, want %v", got, want)
+	}
+
+	// Hosts the filter never resolved are looked up when dialed.
+	before := lookups
+	if got, err := resolver.DialLookup(context.Background(), "example.net"); err == nil {
+		t.Errorf("DialLookup(example.net) = %v, want lookup error", got)
+	}
+	if lookups != before+1 {
+		t.Errorf("DialLookup(example.net) made %d lookups, want 1", lookups-before)
+	}
+}
+
+func TestCreateDomainFilterWithResolver_NoCIDRRules(t *testing.T) {
+	resolver := NewResolver(func(_ context.Context, host string) ([]string, error) {
+		t.Errorf("unexpected lookup of %s without CIDR rules", host)
+		return nil, fmt.Errorf("no such host %s", host)
+	})
+	cfg := &config.Config{Network: config.NetworkConfig{AllowedDomains: []string{"github.com"}}}
+	filter := CreateDomainFilterWithResolver(cfg, false, nil, resolver)
+
+	if !filter("github.com", 443) {
+		t.Error("expected github.com to be allowed")
+	}
+	if filter("example.com", 443) {
+		t.Error("expected example.com to be denied")
+	}
+}
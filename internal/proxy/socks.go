@@ -15,6 +15,8 @@ type SOCKSProxy struct {
 	server   *socks5.Server
 	listener net.Listener
 	filter   FilterFunc
+	lookup   LookupFunc
+	dial     DialFunc
 	debug    bool
 	monitor  bool
 	port     int
@@ -31,6 +33,38 @@ func NewSOCKSProxy(filter FilterFunc, debug, monitor bool) *SOCKSProxy {
 	}
 }
 
+// SetLookup makes the proxy resolve hostnames with lookup, e.g. a cached
+// resolver shared with the HTTP proxy, instead of the system resolver. It
+// must be called before Start.
+func (p *SOCKSProxy) SetLookup(lookup LookupFunc) {
+	p.lookup = lookup
+}
+
+// SetDialer sets the dial function used for outbound connections. Hostnames
+// are passed to it unresolved, so it decides which addresses to connect to.
+// It must be called before Start.
+func (p *SOCKSProxy) SetDialer(dial DialFunc) {
+	p.dial = dial
+}
+
+// lookupResolver adapts a LookupFunc to socks5.NameResolver.
+type lookupResolver struct {
+	lookup LookupFunc
+}
+
+func (r lookupResolver) Resolve(ctx context.Context, name string) (context.Context, net.IP, error) {
+	ips, err := r.lookup(ctx, name)
+	if err != nil {
+		return ctx, nil, err
+	}
+	for _, s := range ips {
+		if ip := net.ParseIP(s); ip != nil {
+			return ctx, ip, nil
+		}
+	}
+	return ctx, nil, fmt.Errorf("no addresses for %s", name)
+}
+
 // fenceRuleSet implements socks5.RuleSet for domain filtering.
 type fenceRuleSet struct {
 	filter  FilterFunc
@@ -56,9 +90,27 @@ func (r *fenceRuleSet) Allow(ctx context.Context, req *socks5.Request) (context.
 			fmt.Fprintf(os.Stderr, "[fence:socks] %s ✗ CONNECT %s:%d BLOCKED\n", timestamp, host, port)
 		}
 	}
+	if allowed && req.DestAddr.FQDN != "" {
+		ctx = context.WithValue(ctx, destHostKey{}, req.DestAddr.FQDN)
+	}
 	return ctx, allowed
 }
 
+// destHostKey is the context key under which fenceRuleSet passes the
+// hostname of an allowed CONNECT request to the dialer.
+type destHostKey struct{}
+
+// dialDest dials the destination of a CONNECT request by the hostname the
+// client sent, rather than the address the SOCKS server resolved it to.
+func (p *SOCKSProxy) dialDest(ctx context.Context, network, addr string) (net.Conn, error) {
+	if host, ok := ctx.Value(destHostKey{}).(string); ok && network == "tcp" {
+		if _, port, err := net.SplitHostPort(addr); err == nil {
+			addr = net.JoinHostPort(host, port)
+		}
+	}
+	return p.dial(ctx, network, addr)
+}
+
 // Start starts the SOCKS5 proxy on a random available port.
 func (p *SOCKSProxy) Start() (int, error) {
 	// Create listener first to get a random port
@@ -69,13 +121,20 @@ func (p *SOCKSProxy) Start() (int, error) {
 	p.listener = listener
 	p.port = listener.Addr().(*net.TCPAddr).Port
 
-	server := socks5.NewServer(
+	opts := []socks5.Option{
 		socks5.WithRule(&fenceRuleSet{
 			filter:  p.filter,
 			debug:   p.debug,
 			monitor: p.monitor,
 		}),
-	)
+	}
+	if p.lookup != nil {
+		opts = append(opts, socks5.WithResolver(lookupResolver{lookup: p.lookup}))
+	}
+	if p.dial != nil {
+		opts = append(opts, socks5.WithDial(p.dialDest))
+	}
+	server := socks5.NewServer(opts...)
 	p.server = server
 
 	go func() {
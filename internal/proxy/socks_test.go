@@ -2,7 +2,9 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"net"
+	"slices"
 	"testing"
 
 	"github.com/things-go/go-socks5"
@@ -128,3 +130,28 @@ func TestSOCKSProxyPortBeforeStart(t *testing.T) {
 		t.Errorf("Port() before Start() = %d, want 0", proxy.Port())
 	}
 }
+
+func TestSOCKSProxyDialsByHostname(t *testing.T) {
+	var dialed []string
+	p := NewSOCKSProxy(func(host string, port int) bool { return host == "allowed.com" }, false, false)
+	p.SetDialer(func(_ context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, network+" "+addr)
+		return nil, errors.New("not connecting in tests")
+	})
+
+	rs := &fenceRuleSet{filter: p.filter}
+	req := &socks5.Request{
+		DestAddr: &statute.AddrSpec{FQDN: "allowed.com", IP: net.ParseIP("10.0.0.1"), Port: 443},
+	}
+	ctx, allowed := rs.Allow(context.Background(), req)
+	if !allowed {
+		t.Fatal("expected allowed.com to be allowed")
+	}
+	_, _ = p.dialDest(ctx, "tcp", req.DestAddr.String())
+	_, _ = p.dialDest(context.Background(), "tcp", "10.0.0.2:80")
+
+	want := []string{"tcp allowed.com:443", "tcp 10.0.0.2:80"}
+	if !slices.Equal(dialed, want) {
+		t.Errorf("dialed %v, want %v", dialed, want)
+	}
+}
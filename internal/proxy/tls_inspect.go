@@ -0,0 +1,318 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxInspectedBodySize caps how much of a request body is buffered for
+// inspection. Larger bodies are rejected rather than forwarded unchecked.
+const maxInspectedBodySize = 10 << 20
+
+// leafCertValidity is how long generated per-host certificates are valid.
+const leafCertValidity = 24 * time.Hour
+
+// TLSInspector terminates HTTPS connections tunneled through the HTTP proxy
+// with certificates signed by a local CA, so request plaintext can be checked
+// against deny patterns before being re-encrypted to the upstream server.
+type TLSInspector struct {
+	ca       *x509.Certificate
+	caKey    crypto.Signer
+	patterns []*regexp.Regexp
+	roots    *x509.CertPool // nil uses the system roots
+
+	mu    sync.Mutex
+	certs map[string]*tls.Certificate
+}
+
+// NewTLSInspector loads the PEM CA certificate and key used to sign
+// intercepted connections. Request headers and bodies matching any of
+// patterns are blocked.
+func NewTLSInspector(caCertPath, caKeyPath string, patterns []string) (*TLSInspector, error) {
+	pair, err := tls.LoadX509KeyPair(caCertPath, caKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS inspection CA: %w", err)
+	}
+	ca, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse TLS inspection CA: %w", err)
+	}
+	if !ca.IsCA {
+		return nil, fmt.Errorf("TLS inspection certificate %s is not a CA", caCertPath)
+	}
+	signer, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("TLS inspection CA key cannot sign certificates")
+	}
+
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+
+	return &TLSInspector{
+		ca:       ca,
+		caKey:    signer,
+		patterns: compiled,
+		certs:    make(map[string]*tls.Certificate),
+	}, nil
+}
+
+// SetUpstreamRoots sets the CAs trusted when re-originating TLS to upstream
+// servers. By default the system roots are used.
+func (i *TLSInspector) SetUpstreamRoots(roots *x509.CertPool) {
+	i.roots = roots
+}
+
+// Match returns the first pattern matching data, if any.
+func (i *TLSInspector) Match(data []byte) (string, bool) {
+	for _, re := range i.patterns {
+		if re.Match(data) {
+			return re.String(), true
+		}
+	}
+	return "", false
+}
+
+// certificateFor returns a leaf certificate for host signed by the CA,
+// generating and caching it on first use.
+func (i *TLSInspector) certificateFor(host string) (*tls.Certificate, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if cert, ok := i.certs[host]; ok && time.Now().Before(cert.Leaf.NotAfter.Add(-time.Hour)) {
+		return cert, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(leafCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, i.ca, &key.PublicKey, i.caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign certificate for %s: %w", host, err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &tls.Certificate{
+		Certificate: [][]byte{der, i.ca.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	i.certs[host] = cert
+	return cert, nil
+}
+
+// serveInspected terminates TLS on clientConn and proxies each HTTP request
+// to host:port over a fresh TLS connection, blocking requests whose headers
+// or body match a deny pattern.
+func (p *HTTPProxy) serveInspected(clientConn net.Conn, host string, port int) {
+	cert, err := p.inspector.certificateFor(host)
+	if err != nil {
+		p.logDebug("TLS inspection failed for %s: %v", host, err)
+		return
+	}
+
+	tlsConn := tls.Server(clientConn, &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		NextProtos:   []string{"http/1.1"},
+		MinVersion:   tls.VersionTLS12,
+	})
+	defer func() { _ = tlsConn.Close() }()
+	if err := tlsConn.Handshake(); err != nil {
+		p.logDebug("TLS inspection handshake failed for %s: %v", host, err)
+		return
+	}
+
+	target := host
+	if port != 443 {
+		target = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+
+	reader := bufio.NewReader(tlsConn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		if !p.forwardInspected(tlsConn, req, host, target) {
+			return
+		}
+	}
+}
+
+// forwardInspected handles one intercepted request and reports whether the
+// connection can be reused for another.
+func (p *HTTPProxy) forwardInspected(w io.Writer, req *http.Request, host, target string) bool {
+	start := time.Now()
+	rawURL := "https://" + target + req.URL.RequestURI()
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxInspectedBodySize+1))
+	_ = req.Body.Close()
+	if err != nil {
+		return false
+	}
+	if len(body) > maxInspectedBodySize {
+		p.logRequest(req.Method, rawURL, host, 413, "BLOCKED", time.Since(start))
+		writeInspectedError(w, http.StatusRequestEntityTooLarge, "Request body too large to inspect")
+		return false
+	}
+
+	// After a protocol switch (e.g. WebSocket) the connection carries
+	// frames that cannot be inspected, so upgrades are refused outright.
+	if req.Header.Get("Upgrade") != "" {
+		p.logRequest(req.Method, rawURL, host, 501, "BLOCKED", time.Since(start))
+		p.logDebug("Refusing %s upgrade to %s: upgraded connections cannot be inspected", req.Header.Get("Upgrade"), host)
+		writeInspectedError(w, http.StatusNotImplemented, "Protocol upgrades (e.g. WebSocket) are not supported with TLS inspection")
+		return false
+	}
+
+	plaintext, err := decodeInspectedBody(body, req.Header.Get("Content-Encoding"))
+	if err != nil {
+		p.logRequest(req.Method, rawURL, host, 415, "BLOCKED", time.Since(start))
+		p.logDebug("Cannot decode request body to %s: %v", host, err)
+		writeInspectedError(w, http.StatusUnsupportedMediaType, "Request body encoding cannot be inspected")
+		return !req.Close
+	}
+
+	var headers bytes.Buffer
+	_ = req.Header.Write(&headers)
+	for _, data := range [][]byte{[]byte(req.URL.RequestURI()), headers.Bytes(), plaintext} {
+		if pattern, matched := p.inspector.Match(data); matched {
+			p.logRequest(req.Method, rawURL, host, 403, "BLOCKED", time.Since(start))
+			p.logDebug("Request to %s matched deny pattern %q", host, pattern)
+			writeInspectedError(w, http.StatusForbidden, "Request blocked by content inspection")
+			return !req.Close
+		}
+	}
+
+	outReq, err := http.NewRequestWithContext(req.Context(), req.Method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		writeInspectedError(w, http.StatusBadRequest, "Bad Request")
+		return false
+	}
+	outReq.Header = req.Header.Clone()
+	outReq.Header.Del("Proxy-Connection")
+	outReq.Header.Del("Proxy-Authorization")
+	outReq.Host = req.Host
+
+	resp, err := p.inspectTransport.RoundTrip(outReq)
+	if err != nil {
+		p.logDebug("Upstream request to %s failed: %v", target, err)
+		p.logRequest(req.Method, rawURL, host, 502, "ERROR", time.Since(start))
+		writeInspectedError(w, http.StatusBadGateway, "Bad Gateway")
+		return false
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if err := resp.Write(w); err != nil {
+		return false
+	}
+	p.logRequest(req.Method, rawURL, host, resp.StatusCode, "ALLOWED", time.Since(start))
+	return !req.Close && !resp.Close
+}
+
+// errUnsupportedEncoding is returned by decodeInspectedBody for content
+// codings it cannot undo.
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// decodeInspectedBody undoes the Content-Encoding of a request body so the
+// plaintext can be matched; compressed bodies would otherwise hide it.
+// Decoded output is capped like the body itself.
+func decodeInspectedBody(body []byte, contentEncoding string) ([]byte, error) {
+	decoded := body
+	codings := strings.Split(contentEncoding, ",")
+	// Codings are listed in the order they were applied
+	for i := len(codings) - 1; i >= 0; i-- {
+		var r io.Reader
+		switch coding := strings.ToLower(strings.TrimSpace(codings[i])); coding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			gr, err := gzip.NewReader(bytes.NewReader(decoded))
+			if err != nil {
+				return nil, err
+			}
+			r = gr
+		case "deflate":
+			zr, err := zlib.NewReader(bytes.NewReader(decoded))
+			if err != nil {
+				// Some clients send raw DEFLATE without the zlib wrapper
+				zr = flate.NewReader(bytes.NewReader(decoded))
+			}
+			r = zr
+		default:
+			return nil, fmt.Errorf("%w %q", errUnsupportedEncoding, coding)
+		}
+		out, err := io.ReadAll(io.LimitReader(r, maxInspectedBodySize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(out) > maxInspectedBodySize {
+			return nil, errors.New("decoded body too large to inspect")
+		}
+		decoded = out
+	}
+	return decoded, nil
+}
+
+// writeInspectedError writes a plain-text error response on an intercepted connection.
+func writeInspectedError(w io.Writer, status int, msg string) {
+	resp := &http.Response{
+		StatusCode:    status,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(bytes.NewBufferString(msg + "\n")),
+		ContentLength: int64(len(msg) + 1),
+	}
+	_ = resp.Write(w)
+}
@@ -0,0 +1,221 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeTestCA writes a self-signed CA certificate and key to dir.
+func writeTestCA(t *testing.T, dir string, isCA bool) (certPath, keyPath string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fence test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() error = %v", err)
+	}
+
+	certPath = filepath.Join(dir, "ca.pem")
+	keyPath = filepath.Join(dir, "ca-key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath, cert
+}
+
+func TestNewTLSInspectorRejectsNonCA(t *testing.T) {
+	certPath, keyPath, _ := writeTestCA(t, t.TempDir(), false)
+	if _, err := NewTLSInspector(certPath, keyPath, nil); err == nil {
+		t.Error("NewTLSInspector() with a non-CA certificate should fail")
+	}
+}
+
+func TestNewTLSInspectorInvalidPattern(t *testing.T) {
+	certPath, keyPath, _ := writeTestCA(t, t.TempDir(), true)
+	if _, err := NewTLSInspector(certPath, keyPath, []string{"("}); err == nil {
+		t.Error("NewTLSInspector() with an invalid pattern should fail")
+	}
+}
+
+func TestHTTPProxyTLSInspection(t *testing.T) {
+	var upstreamHits atomic.Int32
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		_, _ = io.WriteString(w, "upstream:"+r.Method+":"+string(body))
+	}))
+	defer upstream.Close()
+
+	certPath, keyPath, ca := writeTestCA(t, t.TempDir(), true)
+	inspector, err := NewTLSInspector(certPath, keyPath, []string{`SECRET-[0-9]+`})
+	if err != nil {
+		t.Fatalf("NewTLSInspector() error = %v", err)
+	}
+	upstreamRoots := x509.NewCertPool()
+	upstreamRoots.AddCert(upstream.Certificate())
+	inspector.SetUpstreamRoots(upstreamRoots)
+
+	proxy := NewHTTPProxy(func(string, int) bool { return true }, false, false)
+	proxy.SetTLSInspector(inspector)
+	port, err := proxy.Start()
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer func() { _ = proxy.Stop() }()
+
+	// The client only trusts the inspection CA, so a successful request
+	// proves the proxy terminated TLS with a certificate it minted.
+	clientRoots := x509.NewCertPool()
+	clientRoots.AddCert(ca)
+	proxyURL, _ := url.Parse("http://127.0.0.1:" + strconv.Itoa(port))
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(proxyURL),
+			TLSClientConfig: &tls.Config{RootCAs: clientRoots, MinVersion: tls.VersionTLS12},
+		},
+	}
+
+	gzipped := func(s string) string {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write([]byte(s))
+		_ = zw.Close()
+		return buf.String()
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		header     http.Header
+		wantStatus int
+		wantBody   string
+		wantHit    bool
+	}{
+		{"clean GET", http.MethodGet, "/", "", nil, http.StatusOK, "upstream:GET:", true},
+		{"clean POST", http.MethodPost, "/upload", "hello", nil, http.StatusOK, "upstream:POST:hello", true},
+		{"secret in body", http.MethodPost, "/upload", "token=SECRET-1234", nil, http.StatusForbidden, "", false},
+		{"secret in query", http.MethodGet, "/?q=SECRET-42", "", nil, http.StatusForbidden, "", false},
+		{"clean gzip body", http.MethodPost, "/upload", gzipped("hello"), http.Header{"Content-Encoding": {"gzip"}}, http.StatusOK, "", true},
+		{"secret in gzip body", http.MethodPost, "/upload", gzipped("token=SECRET-1234"), http.Header{"Content-Encoding": {"gzip"}}, http.StatusForbidden, "", false},
+		{"unsupported encoding", http.MethodPost, "/upload", "opaque", http.Header{"Content-Encoding": {"br"}}, http.StatusUnsupportedMediaType, "", false},
+		{"websocket upgrade", http.MethodGet, "/ws", "", http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}}, http.StatusNotImplemented, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := upstreamHits.Load()
+			req, err := http.NewRequest(tt.method, upstream.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request error = %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantBody != "" && string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if hit := upstreamHits.Load() > before; hit != tt.wantHit {
+				t.Errorf("upstream reached = %v, want %v", hit, tt.wantHit)
+			}
+		})
+	}
+}
+
+func TestHTTPProxyTLSInspectionUntrustedUpstream(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("upstream should not be reached with an untrusted certificate")
+	}))
+	defer upstream.Close()
+
+	certPath, keyPath, ca := writeTestCA(t, t.TempDir(), true)
+	inspector, err := NewTLSInspector(certPath, keyPath, nil)
+	if err != nil {
+		t.Fatalf("NewTLSInspector() error = %v", err)
+	}
+	// Upstream roots only contain the inspection CA, which did not sign the
+	// upstream certificate.
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	inspector.SetUpstreamRoots(roots)
+
+	proxy := NewHTTPProxy(func(string, int) bool { return true }, false, false)
+	proxy.SetTLSInspector(inspector)
+	port, err := proxy.Start()
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer func() { _ = proxy.Stop() }()
+
+	proxyURL, _ := url.Parse("http://127.0.0.1:" + strconv.Itoa(port))
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(proxyURL),
+			TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12},
+		},
+	}
+
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("request error = %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,275 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Use-Tusk/fence/internal/config"
+	"github.com/Use-Tusk/fence/internal/proxy"
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// AuditLogger receives sandbox decisions as they are made. Implementations
+// must be safe for concurrent use.
+type AuditLogger interface {
+	// LogNetworkRequest records a connection through the proxy.
+	LogNetworkRequest(domain, port string, allowed bool)
+	// LogFilesystemAccess records an access to path; op is "read" or "write".
+	LogFilesystemAccess(path, op string, allowed bool)
+	// LogCommandExec records a command that was run or refused.
+	LogCommandExec(cmd string, allowed bool)
+	// LogViolation records a match of a policy rule.
+	LogViolation(rule, detail string)
+}
+
+// Audit event types.
+const (
+	AuditEventNetworkRequest   = "network_request"
+	AuditEventFilesystemAccess = "filesystem_access"
+	AuditEventCommandExec      = "command_exec"
+	AuditEventViolation        = "violation"
+)
+
+// AuditEvent is one line of a FileAuditLogger log.
+type AuditEvent struct {
+	Timestamp time.Time         `json:"timestamp"`
+	EventType string            `json:"event_type"`
+	Allowed   bool              `json:"allowed"`
+	Detail    map[string]string `json:"detail"`
+}
+
+// FileAuditLogger is an AuditLogger that appends events to a file as JSON
+// lines.
+type FileAuditLogger struct {
+	mu   sync.Mutex
+	w    io.Writer
+	file *os.File
+	path string
+}
+
+// NewFileAuditLogger opens path for appending, creating it if needed. An
+// empty path logs to stderr.
+func NewFileAuditLogger(path string) (*FileAuditLogger, error) {
+	if path == "" {
+		return &FileAuditLogger{w: os.Stderr}, nil
+	}
+
+	path = NormalizePath(path)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:gosec // user-configured log path
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &FileAuditLogger{w: f, file: f, path: path}, nil
+}
+
+// Path returns the absolute path of the log file, or "" for stderr.
+func (l *FileAuditLogger) Path() string {
+	return l.path
+}
+
+// LogNetworkRequest implements AuditLogger.
+func (l *FileAuditLogger) LogNetworkRequest(domain, port string, allowed bool) {
+	l.log(AuditEventNetworkRequest, allowed, map[string]string{"domain": domain, "port": port})
+}
+
+// LogFilesystemAccess implements AuditLogger.
+func (l *FileAuditLogger) LogFilesystemAccess(path, op string, allowed bool) {
+	l.log(AuditEventFilesystemAccess, allowed, map[string]string{"path": path, "op": op})
+}
+
+// LogCommandExec implements AuditLogger.
+func (l *FileAuditLogger) LogCommandExec(cmd string, allowed bool) {
+	l.log(AuditEventCommandExec, allowed, map[string]string{"command": cmd})
+}
+
+// LogViolation implements AuditLogger. Violations are never allowed.
+func (l *FileAuditLogger) LogViolation(rule, detail string) {
+	l.log(AuditEventViolation, false, map[string]string{"rule": rule, "detail": detail})
+}
+
+func (l *FileAuditLogger) log(eventType string, allowed bool, detail map[string]string) {
+	data, err := json.Marshal(AuditEvent{
+		Timestamp: time.Now().UTC(),
+		EventType: eventType,
+		Allowed:   allowed,
+		Detail:    detail,
+	})
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(append(data, '\n'))
+}
+
+// Close closes the log file.
+func (l *FileAuditLogger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// auditLogDenyWritePaths returns the audit log file, if one is configured.
+// It is always write-protected so the sandboxed command cannot truncate or
+// rewrite the record of what it did.
+func auditLogDenyWritePaths(cfg *config.Config) []string {
+	if cfg == nil || cfg.AuditLogPath == "" {
+		return nil
+	}
+	return []string{NormalizePath(cfg.AuditLogPath)}
+}
+
+// multiAuditLogger sends every event to each of its loggers.
+type multiAuditLogger []AuditLogger
+
+// combineAuditLoggers returns a logger that sends events to a and b, either
+// of which may be nil.
+func combineAuditLoggers(a, b AuditLogger) AuditLogger {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	default:
+		return multiAuditLogger{a, b}
+	}
+}
+
+func (m multiAuditLogger) LogNetworkRequest(domain, port string, allowed bool) {
+	for _, l := range m {
+		l.LogNetworkRequest(domain, port, allowed)
+	}
+}
+
+func (m multiAuditLogger) LogFilesystemAccess(path, op string, allowed bool) {
+	for _, l := range m {
+		l.LogFilesystemAccess(path, op, allowed)
+	}
+}
+
+func (m multiAuditLogger) LogCommandExec(cmd string, allowed bool) {
+	for _, l := range m {
+		l.LogCommandExec(cmd, allowed)
+	}
+}
+
+func (m multiAuditLogger) LogViolation(rule, detail string) {
+	for _, l := range m {
+		l.LogViolation(rule, detail)
+	}
+}
+
+// auditFilter wraps filter so its decisions are logged. In audit mode every
+// connection is allowed, and those filter would refuse are logged once per
+// host and port; otherwise every decision is logged.
+func auditFilter(filter proxy.FilterFunc, logger AuditLogger, auditMode bool) proxy.FilterFunc {
+	var seen sync.Map
+	return func(host string, port int) bool {
+		allowed := filter(host, port)
+		if !auditMode {
+			logger.LogNetworkRequest(host, strconv.Itoa(port), allowed)
+			return allowed
+		}
+		if allowed {
+			return true
+		}
+		if _, loaded := seen.LoadOrStore(host+":"+strconv.Itoa(port), struct{}{}); !loaded {
+			logger.LogNetworkRequest(host, strconv.Itoa(port), false)
+		}
+		return true
+	}
+}
+
+// WrapCommandAudit builds the command line for audit mode: the command runs
+// without the OS sandbox, but with the proxy variables set so connections
+// from apps that honor them pass through the (non-blocking) proxies. logger
+// may be nil.
+func WrapCommandAudit(command string, httpPort, socksPort int, shellMode, customShell string, shellLogin bool, logger AuditLogger) (string, error) {
+	shellPath, shellFlag, err := ResolveExecutionShell(shellMode, customShell, shellLogin)
+	if err != nil {
+		return "", err
+	}
+
+	parts := append([]string{"env"}, GenerateProxyEnvVars(httpPort, socksPort, nil)...)
+	parts = append(parts, shellPath, shellFlag, command)
+	if logger != nil {
+		logger.LogCommandExec(command, true)
+	}
+	return ShellQuote(parts), nil
+}
+
+// WriteAllowedByPolicy reports whether the sandbox would let path (absolute)
+// be written: it must be under a default or configured writable path and not
+// under a denyWrite path.
+func WriteAllowedByPolicy(cfg *config.Config, path string) bool {
+	if cfg == nil {
+		cfg = config.Default()
+	}
+	for _, pattern := range GetEffectiveDenyWritePaths(cfg) {
+		if pathMatchesPattern(path, pattern) {
+			return false
+		}
+	}
+	for _, pattern := range append(GetDefaultWritePaths(), cfg.Filesystem.WritablePaths()...) {
+		if pathMatchesPattern(path, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathMatchesPattern reports whether path is matched by a filesystem config
+// pattern: a glob, or a path that covers everything below it.
+func pathMatchesPattern(path, pattern string) bool {
+	pattern = NormalizePath(pattern)
+	if ContainsGlobChars(pattern) {
+		matched, _ := doublestar.Match(pattern, path)
+		return matched
+	}
+	return isPathWithin(path, pattern, false)
+}
+
+// AuditWatchRoots returns the directories watched for writes in audit mode:
+// the working directory and the existing directories named by denyWrite.
+func AuditWatchRoots(cfg *config.Config) []string {
+	var roots []string
+	if cwd, err := os.Getwd(); err == nil {
+		roots = append(roots, NormalizePath(cwd))
+	}
+	if cfg == nil {
+		return roots
+	}
+
+	for _, p := range cfg.Filesystem.DenyWrite {
+		p = NormalizePath(RemoveTrailingGlobSuffix(p))
+		if ContainsGlobChars(p) {
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			p = filepath.Dir(p)
+		}
+		covered := false
+		for _, root := range roots {
+			if isPathWithin(p, root, false) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			roots = append(roots, p)
+		}
+	}
+	return roots
+}
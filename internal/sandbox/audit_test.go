@@ -0,0 +1,251 @@
+package sandbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Use-Tusk/fence/internal/config"
+	"github.com/Use-Tusk/fence/internal/proxy"
+)
+
+func readAuditLog(t *testing.T, path string) []AuditEvent {
+	t.Helper()
+	f, err := os.Open(path) //nolint:gosec // test file
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var events []AuditEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("audit log line %q is not JSON: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// recordingAuditLogger collects command events in memory.
+type recordingAuditLogger struct {
+	commands []string
+}
+
+func (r *recordingAuditLogger) LogNetworkRequest(string, string, bool)   {}
+func (r *recordingAuditLogger) LogFilesystemAccess(string, string, bool) {}
+func (r *recordingAuditLogger) LogViolation(string, string)              {}
+func (r *recordingAuditLogger) LogCommandExec(cmd string, allowed bool) {
+	r.commands = append(r.commands, fmt.Sprintf("%s allowed=%v", cmd, allowed))
+}
+
+func TestFileAuditLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger, err := NewFileAuditLogger(path)
+	if err != nil {
+		t.Fatalf("NewFileAuditLogger() error = %v", err)
+	}
+	if logger.Path() != path {
+		t.Errorf("Path() = %q, want %q", logger.Path(), path)
+	}
+	logger.LogNetworkRequest("example.com", "443", true)
+	logger.LogFilesystemAccess("/etc/hosts", "write", false)
+	logger.LogCommandExec("git push", false)
+	logger.LogViolation("git push", "command blocked")
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []AuditEvent{
+		{EventType: AuditEventNetworkRequest, Allowed: true, Detail: map[string]string{"domain": "example.com", "port": "443"}},
+		{EventType: AuditEventFilesystemAccess, Allowed: false, Detail: map[string]string{"path": "/etc/hosts", "op": "write"}},
+		{EventType: AuditEventCommandExec, Allowed: false, Detail: map[string]string{"command": "git push"}},
+		{EventType: AuditEventViolation, Allowed: false, Detail: map[string]string{"rule": "git push", "detail": "command blocked"}},
+	}
+	events := readAuditLog(t, path)
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, event := range events {
+		if event.Timestamp.IsZero() {
+			t.Errorf("event %d has no timestamp", i)
+		}
+		if event.EventType != want[i].EventType || event.Allowed != want[i].Allowed || !maps.Equal(event.Detail, want[i].Detail) {
+			t.Errorf("event %d = %+v, want %+v", i, event, want[i])
+		}
+	}
+
+	// Reopening appends
+	logger, err = NewFileAuditLogger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.LogCommandExec("ls", true)
+	_ = logger.Close()
+	if got := len(readAuditLog(t, path)); got != len(want)+1 {
+		t.Errorf("got %d events after reopening, want %d", got, len(want)+1)
+	}
+}
+
+func TestAuditLogDenyWritePaths(t *testing.T) {
+	if got := auditLogDenyWritePaths(&config.Config{}); len(got) != 0 {
+		t.Errorf("auditLogDenyWritePaths() = %v without auditLogPath, want none", got)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("home directory not available")
+	}
+	got := auditLogDenyWritePaths(&config.Config{AuditLogPath: "~/fence-audit.jsonl"})
+	if want := []string{filepath.Join(home, "fence-audit.jsonl")}; !slices.Equal(got, want) {
+		t.Errorf("auditLogDenyWritePaths() = %v, want %v", got, want)
+	}
+}
+
+func TestAuditFilter(t *testing.T) {
+	cfg := &config.Config{Network: config.NetworkConfig{AllowedDomains: []string{"example.com"}}}
+	hosts := []string{"example.com", "evil.example", "evil.example"}
+
+	tests := []struct {
+		name      string
+		auditMode bool
+		want      []string // domain:allowed for each logged request
+	}{
+		{"enforce logs every decision", false, []string{"example.com:true", "evil.example:false", "evil.example:false"}},
+		{"audit logs refused hosts once", true, []string{"evil.example:false"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			logger, err := NewFileAuditLogger(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter := auditFilter(proxy.CreateDomainFilter(cfg, false), logger, tt.auditMode)
+			for _, host := range hosts {
+				allowed := filter(host, 443)
+				if wantAllowed := tt.auditMode || host == "example.com"; allowed != wantAllowed {
+					t.Errorf("filter(%s) = %v, want %v", host, allowed, wantAllowed)
+				}
+			}
+			_ = logger.Close()
+
+			var got []string
+			for _, event := range readAuditLog(t, path) {
+				if event.EventType != AuditEventNetworkRequest || event.Detail["port"] != "443" {
+					t.Errorf("unexpected event %+v", event)
+				}
+				got = append(got, fmt.Sprintf("%s:%v", event.Detail["domain"], event.Allowed))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("logged %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapCommandMacOSWithOptionsLogsCommand(t *testing.T) {
+	logger := &recordingAuditLogger{}
+	if _, err := WrapCommandMacOSWithOptions(&config.Config{}, "echo hi", MacOSSandboxOptions{AuditLogger: logger}); err != nil {
+		t.Skipf("cannot wrap command: %v", err)
+	}
+	if !slices.Equal(logger.commands, []string{"echo hi allowed=true"}) {
+		t.Errorf("logged commands = %v", logger.commands)
+	}
+}
+
+func TestWriteAllowedByPolicy(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Filesystem: config.FilesystemConfig{
+			AllowWrite: []string{dir},
+			DenyWrite:  []string{filepath.Join(dir, "secrets"), filepath.Join(dir, "*.pem")},
+		},
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(dir, "out.txt"), true},
+		{filepath.Join(dir, "sub", "out.txt"), true},
+		{filepath.Join(dir, "secrets"), false},
+		{filepath.Join(dir, "secrets", "key"), false},
+		{filepath.Join(dir, "cert.pem"), false},
+		{"/etc/fence-audit-test", false},
+		{"/dev/null", true},
+	}
+	for _, tt := range tests {
+		if got := WriteAllowedByPolicy(cfg, tt.path); got != tt.want {
+			t.Errorf("WriteAllowedByPolicy(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAuditModeLogsWithoutBlocking(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "audit.jsonl")
+	marker := filepath.Join(dir, "marker")
+	cfg := &config.Config{
+		EnforcementMode: config.EnforcementModeAudit,
+		AuditLogPath:    logPath,
+		Command:         config.CommandConfig{Deny: []string{"touch"}},
+	}
+
+	m := NewManager(cfg, false, false)
+	if err := m.Initialize(); err != nil {
+		t.Skipf("cannot initialize manager: %v", err)
+	}
+	defer m.Cleanup()
+	if !m.AuditMode() {
+		t.Fatal("AuditMode() = false in audit mode")
+	}
+
+	wrapped, err := m.WrapCommand("touch " + ShellQuote([]string{marker}))
+	if err != nil {
+		t.Fatalf("WrapCommand() blocked the command in audit mode: %v", err)
+	}
+	if strings.Contains(wrapped, "bwrap") || strings.Contains(wrapped, "sandbox-exec") {
+		t.Errorf("audit mode command is sandboxed: %s", wrapped)
+	}
+
+	out, err := exec.Command("sh", "-c", wrapped).CombinedOutput() //nolint:gosec // test command
+	if err != nil {
+		t.Fatalf("command failed: %v\n%s", err, out)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("command did not run: %v", err)
+	}
+
+	m.AuditWrite("/etc/fence-audit-test")
+	m.AuditWrite("/etc/fence-audit-test") // Logged once
+	m.AuditWrite(logPath)                 // The log itself is ignored
+	m.Cleanup()
+
+	events := readAuditLog(t, logPath)
+	if len(events) != 3 {
+		t.Fatalf("audit log = %+v, want a violation, a command and a filesystem event", events)
+	}
+	if events[0].EventType != AuditEventViolation || events[0].Detail["rule"] != "touch" {
+		t.Errorf("first event = %+v, want a violation of the touch rule", events[0])
+	}
+	if events[1].EventType != AuditEventCommandExec || !events[1].Allowed || events[1].Detail["command"] != "touch "+ShellQuote([]string{marker}) {
+		t.Errorf("second event = %+v, want the command to be logged as run", events[1])
+	}
+	if events[2].EventType != AuditEventFilesystemAccess || events[2].Allowed || events[2].Detail["path"] != "/etc/fence-audit-test" || events[2].Detail["op"] != "write" {
+		t.Errorf("third event = %+v, want the refused write", events[2])
+	}
+}
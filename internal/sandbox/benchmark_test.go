@@ -117,6 +117,26 @@ func BenchmarkWrapCommand(b *testing.B) {
 	}
 }
 
+// BenchmarkFindDangerousFiles compares the single walk with the parallel scan
+// on a tree of 500 project directories.
+func BenchmarkFindDangerousFiles(b *testing.B) {
+	root := makeDangerousScanTree(b, 500)
+
+	for _, bm := range []struct {
+		name    string
+		workers int
+	}{
+		{"sequential", 1},
+		{"parallel", runtime.NumCPU()},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _, _ = findDangerousFiles(context.Background(), root, DefaultMaxDangerousFileDepth, ScanOptions{}, bm.workers)
+			}
+		})
+	}
+}
+
 // ============================================================================
 // Cold Sandbox Benchmarks (full init + wrap + exec each iteration)
 // ============================================================================
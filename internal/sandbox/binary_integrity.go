@@ -0,0 +1,188 @@
+package sandbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
+
+// binaryHashesFileName is the hash store kept under ~/.fence. That directory
+// is write-protected inside the sandbox, so sandboxed processes cannot
+// re-record a tampered binary.
+const binaryHashesFileName = "binary-hashes.json"
+
+// DefaultBinaryHashesPath returns the path of the binary hash store.
+func DefaultBinaryHashesPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".fence", binaryHashesFileName), nil
+}
+
+// BinaryIntegrityError is returned when an executable no longer matches the
+// hash recorded the first time it was run.
+type BinaryIntegrityError struct {
+	Command  string
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *BinaryIntegrityError) Error() string {
+	return fmt.Sprintf("command blocked by binary integrity check: %s has changed since it was first run (sha256 %s, expected %s)", e.Path, e.Actual, e.Expected)
+}
+
+// binaryHashes is the on-disk format of the hash store.
+type binaryHashes struct {
+	Hashes map[string]string `json:"hashes"` // Executable path to hex SHA-256
+}
+
+// CheckBinaryIntegrity verifies the executable of every sub-command against
+// the hash store at storePath, recording hashes for executables seen for the
+// first time. Commands that do not resolve to a file (builtins, functions)
+// are skipped. Failures are reported to audit.
+func CheckBinaryIntegrity(command string, cfg *config.Config, storePath string, audit CommandAuditFunc) error {
+	if cfg == nil || !cfg.Command.VerifyBinaryIntegrity {
+		return nil
+	}
+
+	store, err := loadBinaryHashes(storePath)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, subCmd := range parseShellCommand(command) {
+		path := resolveExecutable(subCmd)
+		if path == "" {
+			continue
+		}
+
+		actual, err := hashFile(path)
+		if err != nil {
+			continue
+		}
+
+		expected, ok := store.Hashes[path]
+		if !ok {
+			store.Hashes[path] = actual
+			changed = true
+			continue
+		}
+		if expected != actual {
+			integrityErr := &BinaryIntegrityError{
+				Command:  subCmd,
+				Path:     path,
+				Expected: expected,
+				Actual:   actual,
+			}
+			if audit != nil {
+				audit(CommandAuditEvent{
+					Command:    command,
+					SubCommand: subCmd,
+					Reason:     integrityErr.Error(),
+					Alert:      true,
+				})
+			}
+			return integrityErr
+		}
+	}
+
+	if changed {
+		return saveBinaryHashes(storePath, store)
+	}
+	return nil
+}
+
+// resolveExecutable returns the absolute path of the program a sub-command
+// runs, or "" if it cannot be resolved.
+func resolveExecutable(command string) string {
+	for _, token := range tokenizeCommand(command) {
+		// Skip leading environment assignments such as "FOO=1 cmd"
+		if isEnvAssignment(token) {
+			continue
+		}
+		path, err := exec.LookPath(token)
+		if err != nil {
+			return ""
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return ""
+		}
+		return abs
+	}
+	return ""
+}
+
+// isEnvAssignment reports whether token has the form NAME=value.
+func isEnvAssignment(token string) bool {
+	name, _, ok := strings.Cut(token, "=")
+	if !ok || name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for _, c := range name {
+		if c != '_' && (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// hashFile returns the hex SHA-256 of the file at path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path) //nolint:gosec // executable resolved from PATH
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func loadBinaryHashes(path string) (*binaryHashes, error) {
+	store := &binaryHashes{Hashes: make(map[string]string)}
+
+	data, err := os.ReadFile(path) //nolint:gosec // fence-owned state file
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binary hashes: %w", err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("invalid binary hashes file %s: %w", path, err)
+	}
+	if store.Hashes == nil {
+		store.Hashes = make(map[string]string)
+	}
+	return store, nil
+}
+
+func saveBinaryHashes(path string, store *binaryHashes) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create binary hashes directory: %w", err)
+	}
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write binary hashes: %w", err)
+	}
+	return nil
+}
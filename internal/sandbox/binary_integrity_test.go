@@ -0,0 +1,94 @@
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
+
+func TestCheckBinaryIntegrity(t *testing.T) {
+	binDir := t.TempDir()
+	binPath := filepath.Join(binDir, "fence-integrity-tool")
+	if err := os.WriteFile(binPath, []byte("#!/bin/sh\necho original\n"), 0o755); err != nil { //nolint:gosec // test executable
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir)
+
+	storePath := filepath.Join(t.TempDir(), "binary-hashes.json")
+	cfg := &config.Config{Command: config.CommandConfig{VerifyBinaryIntegrity: true}}
+
+	var alerts []CommandAuditEvent
+	audit := func(e CommandAuditEvent) { alerts = append(alerts, e) }
+
+	// First run records the hash
+	if err := CheckBinaryIntegrity("fence-integrity-tool --flag", cfg, storePath, audit); err != nil {
+		t.Fatalf("first run error = %v", err)
+	}
+	store, err := loadBinaryHashes(storePath)
+	if err != nil {
+		t.Fatalf("loadBinaryHashes() error = %v", err)
+	}
+	if store.Hashes[binPath] == "" {
+		t.Fatalf("hash for %s was not recorded: %v", binPath, store.Hashes)
+	}
+
+	// Unchanged binary passes, including behind env assignments and in chains
+	for _, cmd := range []string{"fence-integrity-tool", "FOO=1 fence-integrity-tool", "cd /tmp && fence-integrity-tool"} {
+		if err := CheckBinaryIntegrity(cmd, cfg, storePath, audit); err != nil {
+			t.Errorf("CheckBinaryIntegrity(%q) error = %v", cmd, err)
+		}
+	}
+
+	if err := os.WriteFile(binPath, []byte("#!/bin/sh\necho tampered\n"), 0o755); err != nil { //nolint:gosec // test executable
+		t.Fatal(err)
+	}
+
+	err = CheckBinaryIntegrity("echo hi | fence-integrity-tool", cfg, storePath, audit)
+	var integrityErr *BinaryIntegrityError
+	if !errors.As(err, &integrityErr) {
+		t.Fatalf("modified binary error = %v, want BinaryIntegrityError", err)
+	}
+	if integrityErr.Path != binPath {
+		t.Errorf("Path = %q, want %q", integrityErr.Path, binPath)
+	}
+	if len(alerts) != 1 || !alerts[0].Alert {
+		t.Errorf("expected one alert event, got %+v", alerts)
+	}
+
+	// The stored hash must not be replaced by the tampered one
+	if err := CheckBinaryIntegrity("fence-integrity-tool", cfg, storePath, nil); err == nil {
+		t.Error("modified binary should keep failing the integrity check")
+	}
+}
+
+func TestCheckBinaryIntegrityDisabled(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "binary-hashes.json")
+	if err := CheckBinaryIntegrity("ls", &config.Config{}, storePath, nil); err != nil {
+		t.Errorf("CheckBinaryIntegrity() error = %v", err)
+	}
+	if _, err := os.Stat(storePath); !os.IsNotExist(err) {
+		t.Error("hash store should not be written when verification is disabled")
+	}
+}
+
+func TestIsEnvAssignment(t *testing.T) {
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{"FOO=1", true},
+		{"_X=/usr/bin", true},
+		{"=value", false},
+		{"1FOO=bar", false},
+		{"--flag=value", false},
+		{"git", false},
+	}
+	for _, tt := range tests {
+		if got := isEnvAssignment(tt.token); got != tt.want {
+			t.Errorf("isEnvAssignment(%q) = %v, want %v", tt.token, got, tt.want)
+		}
+	}
+}
@@ -1,11 +1,19 @@
 package sandbox
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/Use-Tusk/fence/internal/config"
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 // CommandBlockedError is returned when a command is blocked by policy.
@@ -13,27 +21,81 @@ type CommandBlockedError struct {
 	Command       string
 	BlockedPrefix string
 	IsDefault     bool
+	Source        PolicySource
+	RuleReason    string // Text from the deny rule's reason: suffix, if any
 }
 
 func (e *CommandBlockedError) Error() string {
 	if e.IsDefault {
 		return fmt.Sprintf("command blocked by default sandbox command policy: %q matches %q", e.Command, e.BlockedPrefix)
 	}
+	if e.RuleReason != "" {
+		return fmt.Sprintf("command blocked by sandbox command policy: %q matches %q: %s", e.Command, e.BlockedPrefix, e.RuleReason)
+	}
 	return fmt.Sprintf("command blocked by sandbox command policy: %q matches %q", e.Command, e.BlockedPrefix)
 }
 
+// CommandAuditEvent describes a command that the policy would have blocked.
+type CommandAuditEvent struct {
+	Command             string       // Full command line as submitted
+	SubCommand          string       // Sub-command that matched a deny rule
+	BlockedPrefix       string       // Matching deny rule (empty for SSH policy blocks)
+	IsDefault           bool         // Whether the rule came from the default deny list
+	Source              PolicySource // Which rule list blocked the command (empty for SSH policy blocks)
+	Reason              string       // Human-readable block reason
+	RuleReason          string       // Text from the deny rule's reason: suffix, if any
+	BlockedInShadowMode bool         // The command was allowed to run because command.shadowMode is set
+	Alert               bool         // A security alert, such as a modified binary, rather than a policy match
+}
+
+// CommandAuditFunc receives command audit events.
+type CommandAuditFunc func(CommandAuditEvent)
+
 // CheckCommand checks if a command is allowed by the configuration.
 // It parses shell command strings and checks each sub-command in pipelines/chains.
 // Returns nil if allowed, or CommandBlockedError if blocked.
 func CheckCommand(command string, cfg *config.Config) error {
+	return CheckCommandWithAudit(command, cfg, nil)
+}
+
+// CheckCommandWithAudit is like CheckCommand but reports blocked sub-commands
+// to audit. When command.shadowMode is enabled, every sub-command is still
+// evaluated and reported with BlockedInShadowMode set, but nil is returned so
+// the command runs.
+func CheckCommandWithAudit(command string, cfg *config.Config, audit CommandAuditFunc) error {
 	if cfg == nil {
 		cfg = config.Default()
 	}
 
 	subCommands := parseShellCommand(command)
 
+	// Heredoc bodies fed to a shell (e.g. "bash <<'EOF'") are scripts, so
+	// commands inside them are checked as well.
+	subCommands = append(subCommands, ExtractHeredocCommands(command)...)
+
 	for _, subCmd := range subCommands {
-		if err := checkSingleCommand(subCmd, cfg); err != nil {
+		err := checkSingleCommand(subCmd, cfg)
+		if err == nil {
+			continue
+		}
+
+		if audit != nil {
+			event := CommandAuditEvent{
+				Command:             command,
+				SubCommand:          subCmd,
+				Reason:              err.Error(),
+				BlockedInShadowMode: cfg.Command.ShadowMode,
+			}
+			if blocked, ok := err.(*CommandBlockedError); ok {
+				event.BlockedPrefix = blocked.BlockedPrefix
+				event.IsDefault = blocked.IsDefault
+				event.Source = blocked.Source
+				event.RuleReason = blocked.RuleReason
+			}
+			audit(event)
+		}
+
+		if !cfg.Command.ShadowMode {
 			return err
 		}
 	}
@@ -41,47 +103,113 @@ func CheckCommand(command string, cfg *config.Config) error {
 	return nil
 }
 
-// checkSingleCommand checks a single command (not a chain) against the policy.
-func checkSingleCommand(command string, cfg *config.Config) error {
+// PolicySource identifies which rule list produced a policy decision.
+type PolicySource string
+
+const (
+	// SourceNone means no rule matched and the command is allowed.
+	SourceNone PolicySource = ""
+	// SourceAllow means a command.allow rule matched. The decision is a
+	// block if the rule's constraints were violated.
+	SourceAllow PolicySource = "allow"
+	// SourceExplicit means a command.deny rule from the config matched.
+	SourceExplicit PolicySource = "explicit"
+	// SourceDefault means a rule from the default deny list matched.
+	SourceDefault PolicySource = "default"
+	// SourceDenyExecute means a filesystem.denyExecute entry matched.
+	SourceDenyExecute PolicySource = "denyExecute"
+)
+
+// PolicyDecision is the result of evaluating one command against the
+// allow, deny and default deny rules.
+type PolicyDecision struct {
+	Allowed    bool
+	Rule       string       // Matching rule as written (without any reason), empty if none matched
+	Source     PolicySource // Rule list the matching rule came from
+	RuleReason string       // Reason attached to a matching deny rule
+}
+
+// EvaluateCommandPolicy evaluates a single command (not a chain) against the
+// command allow and deny rules. SSH policy is not considered.
+func EvaluateCommandPolicy(command string, cfg *config.Config) PolicyDecision {
+	if cfg == nil {
+		cfg = config.Default()
+	}
 	command = strings.TrimSpace(command)
 	if command == "" {
-		return nil
+		return PolicyDecision{Allowed: true}
 	}
 
 	// Normalize the command for matching
 	normalized := normalizeCommand(command)
 
-	// Check if explicitly allowed (takes precedence over deny)
-	for _, allow := range cfg.Command.Allow {
-		if matchesPrefix(normalized, allow) {
-			return nil
+	// Check user-defined rules in priority order. The first matching rule
+	// decides; at equal priority allow takes precedence over deny.
+	// A matching allow rule whose constraints are violated blocks the command
+	// unless another allow rule admits it.
+	var violatedAllow string
+	for _, r := range orderedCommandRules(cfg) {
+		if !matchesPrefix(normalized, r.rule.Prefix) {
+			continue
 		}
-	}
-
-	// Check user-defined deny list
-	for _, deny := range cfg.Command.Deny {
-		if matchesPrefix(normalized, deny) {
-			return &CommandBlockedError{
-				Command:       command,
-				BlockedPrefix: deny,
-				IsDefault:     false,
+		if r.rule.When != "" && !ruleConditionHolds(r.rule) {
+			// A rule whose when: condition fails does not apply at all
+			continue
+		}
+		if !r.allow {
+			if violatedAllow != "" {
+				return PolicyDecision{Rule: violatedAllow, Source: SourceAllow}
 			}
+			return PolicyDecision{Rule: r.rule.WithoutReason(), Source: SourceExplicit, RuleReason: r.rule.Reason}
+		}
+		if r.rule.HasMaxArgs() && countCommandArgs(normalized, cfg) > r.rule.MaxArgs {
+			violatedAllow = r.rule.Raw
+			continue
 		}
+		if r.rule.Workdir != "" && !inWorkdir(r.rule.Workdir) {
+			violatedAllow = r.rule.Raw
+			continue
+		}
+		return PolicyDecision{Allowed: true, Rule: r.rule.Raw, Source: SourceAllow}
+	}
+	if violatedAllow != "" {
+		return PolicyDecision{Rule: violatedAllow, Source: SourceAllow}
 	}
 
 	// Check default deny list (if enabled)
 	if cfg.Command.UseDefaultDeniedCommands() {
 		for _, deny := range config.DefaultDeniedCommands {
 			if matchesPrefix(normalized, deny) {
-				return &CommandBlockedError{
-					Command:       command,
-					BlockedPrefix: deny,
-					IsDefault:     true,
-				}
+				return PolicyDecision{Rule: deny, Source: SourceDefault}
 			}
 		}
 	}
 
+	return PolicyDecision{Allowed: true}
+}
+
+// checkSingleCommand checks a single command (not a chain) against the policy.
+func checkSingleCommand(command string, cfg *config.Config) error {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return nil
+	}
+
+	decision := EvaluateCommandPolicy(command, cfg)
+	if !decision.Allowed {
+		return &CommandBlockedError{
+			Command:       command,
+			BlockedPrefix: decision.Rule,
+			IsDefault:     decision.Source == SourceDefault,
+			Source:        decision.Source,
+			RuleReason:    decision.RuleReason,
+		}
+	}
+	if decision.Source == SourceAllow {
+		// Explicitly allowed commands skip further checks
+		return nil
+	}
+
 	// Check SSH-specific policies if this is an SSH command
 	if err := CheckSSHCommand(command, cfg); err != nil {
 		return err
@@ -90,6 +218,154 @@ func checkSingleCommand(command string, cfg *config.Config) error {
 	return nil
 }
 
+// commandPolicyRule is a parsed user allow or deny rule.
+type commandPolicyRule struct {
+	rule  config.CommandRule
+	allow bool
+}
+
+// orderedCommandRules returns the user's allow and deny rules sorted by
+// descending priority. Ties keep config order with allow rules first, which
+// is the behavior when no priorities are set. Unparseable rules are skipped.
+func orderedCommandRules(cfg *config.Config) []commandPolicyRule {
+	rules := make([]commandPolicyRule, 0, len(cfg.Command.Allow)+len(cfg.Command.Deny))
+	for _, allow := range cfg.Command.Allow {
+		if rule, err := config.ParseCommandRule(allow); err == nil {
+			rules = append(rules, commandPolicyRule{rule: rule, allow: true})
+		}
+	}
+	for _, deny := range cfg.Command.Deny {
+		if rule, err := config.ParseCommandRule(deny); err == nil {
+			rules = append(rules, commandPolicyRule{rule: rule})
+		}
+	}
+
+	slices.SortStableFunc(rules, func(a, b commandPolicyRule) int {
+		return cmp.Compare(b.rule.Priority, a.rule.Priority)
+	})
+	return rules
+}
+
+// getwd returns the directory commands run from. Tests override it.
+var getwd = os.Getwd
+
+// gitExactTag returns the tag pointing at HEAD in the directory commands run
+// from, as reported by "git describe --tags --exact-match". Tests override it.
+var gitExactTag = func() (string, error) {
+	cmd := exec.Command("git", "describe", "--tags", "--exact-match")
+	if cwd, err := getwd(); err == nil {
+		cmd.Dir = cwd
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ruleConditionHolds evaluates a rule's when: condition. Conditions that
+// cannot be evaluated do not hold. The tag is not checked for a signature or
+// against a remote, so a local tag, possibly created inside the sandbox,
+// satisfies git-tag-matches: it is not a security boundary.
+func ruleConditionHolds(rule config.CommandRule) bool {
+	pattern, ok := rule.GitTagCondition()
+	if !ok {
+		return false
+	}
+	tag, err := gitExactTag()
+	if err != nil || tag == "" {
+		return false
+	}
+	matched, err := path.Match(pattern, tag)
+	return err == nil && matched
+}
+
+// caseInsensitivePaths reports whether workdir matching ignores case, as
+// macOS filesystems are case-insensitive by default. Tests override it.
+var caseInsensitivePaths = runtime.GOOS == "darwin"
+
+// inWorkdir reports whether the current directory is dir or below it.
+func inWorkdir(dir string) bool {
+	cwd, err := getwd()
+	if err != nil {
+		return false
+	}
+
+	candidates := []string{filepath.Clean(cwd)}
+	if resolved, err := filepath.EvalSymlinks(cwd); err == nil && resolved != candidates[0] {
+		candidates = append(candidates, resolved)
+	}
+
+	dir = filepath.Clean(NormalizePath(dir))
+	for _, c := range candidates {
+		if isPathWithin(c, dir, caseInsensitivePaths) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPathWithin reports whether path equals dir or is inside it.
+func isPathWithin(path, dir string, caseInsensitive bool) bool {
+	if caseInsensitive {
+		path, dir = strings.ToLower(path), strings.ToLower(dir)
+	}
+	if path == dir || dir == "/" {
+		return true
+	}
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// countCommandArgs counts the arguments of a normalized command, excluding
+// the command name and any argument that names an existing path readable
+// under filesystem.allowRead (those are already covered by filesystem policy).
+func countCommandArgs(normalized string, cfg *config.Config) int {
+	tokens := tokenizeCommand(normalized)
+	if len(tokens) <= 1 {
+		return 0
+	}
+
+	count := 0
+	for _, arg := range tokens[1:] {
+		if !isAllowReadPath(arg, cfg.Filesystem.AllowRead) {
+			count++
+		}
+	}
+	return count
+}
+
+// isAllowReadPath reports whether arg names an existing path covered by
+// allowRead. Only path-like arguments (containing a slash, or starting with
+// "." or "~") qualify: NormalizePath would turn any bare word into a path
+// under the working directory, so free text would otherwise go uncounted
+// whenever allowRead includes ".".
+func isAllowReadPath(arg string, allowRead []string) bool {
+	if len(allowRead) == 0 || arg == "" || strings.HasPrefix(arg, "-") {
+		return false
+	}
+	if !strings.Contains(arg, "/") && !strings.HasPrefix(arg, ".") && !strings.HasPrefix(arg, "~") {
+		return false
+	}
+
+	path := NormalizePath(arg)
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	for _, pattern := range allowRead {
+		normalized := NormalizePath(pattern)
+		if ContainsGlobChars(normalized) {
+			if matched, _ := doublestar.Match(normalized, path); matched {
+				return true
+			}
+			continue
+		}
+		if path == normalized || strings.HasPrefix(path, normalized+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
 // parseShellCommand splits a shell command string into individual commands.
 // Handles: pipes (|), logical operators (&&, ||), semicolons (;), and subshells.
 func parseShellCommand(command string) []string {
@@ -102,6 +378,14 @@ func parseShellCommand(command string) []string {
 	for i := 0; i < len(runes); i++ {
 		c := runes[i]
 
+		// Keep escaped characters, such as \; or \", with their backslash
+		if c == '\\' && !inSingleQuote && i+1 < len(runes) {
+			current.WriteRune(c)
+			current.WriteRune(runes[i+1])
+			i++
+			continue
+		}
+
 		// Handle quotes
 		if c == '\'' && !inDoubleQuote {
 			inSingleQuote = !inSingleQuote
@@ -206,15 +490,12 @@ func expandShellInvocation(command string) []string {
 	}
 
 	// Check for shell -c pattern
-	shell := filepath.Base(tokens[0])
-	isShell := shell == "sh" || shell == "bash" || shell == "zsh" ||
-		shell == "ksh" || shell == "dash" || shell == "fish"
-
-	if !isShell {
+	if !isShellName(filepath.Base(tokens[0])) {
 		return []string{command}
 	}
 
-	// Look for -c flag (could be combined with other flags like -lc, -ic, etc.)
+	// Look for -c flag (could be combined with other flags like -lc, -ic, etc.,
+	// or spelled --commands as nu does)
 	for i := 1; i < len(tokens)-1; i++ {
 		flag := tokens[i]
 		// Check for -c, -lc, -ic, -ilc, etc. (any flag containing 'c')
@@ -234,35 +515,320 @@ func expandShellInvocation(command string) []string {
 	return []string{command}
 }
 
-// tokenizeCommand splits a command string into tokens, respecting quotes.
-func tokenizeCommand(command string) []string {
+// ErrUnterminatedQuote is returned by ParseCommandTokens for a command with
+// an unclosed single or double quote.
+var ErrUnterminatedQuote = errors.New("unterminated quote")
+
+// ParseCommandTokens splits a command into words as a POSIX shell does,
+// without expansion: whitespace separates words, single quotes keep
+// everything literally, double quotes keep everything but backslash escapes
+// of $, `, ", \ and newline, and a backslash outside quotes escapes the next
+// character. Quotes are removed, and "" yields an empty word. Operators such
+// as && or | are not split out; parseShellCommand does that first.
+//
+// On an unterminated quote, the words read so far (including the open one)
+// are returned along with ErrUnterminatedQuote.
+func ParseCommandTokens(command string) ([]string, error) {
 	var tokens []string
 	var current strings.Builder
 	var inSingleQuote, inDoubleQuote bool
+	inToken := false // set by quotes too, so "" is a word
 
-	for _, c := range command {
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
 		switch {
-		case c == '\'' && !inDoubleQuote:
-			inSingleQuote = !inSingleQuote
-		case c == '"' && !inSingleQuote:
-			inDoubleQuote = !inDoubleQuote
-		case (c == ' ' || c == '\t') && !inSingleQuote && !inDoubleQuote:
-			if current.Len() > 0 {
+		case inSingleQuote:
+			if c == '\'' {
+				inSingleQuote = false
+			} else {
+				current.WriteRune(c)
+			}
+		case inDoubleQuote:
+			switch {
+			case c == '"':
+				inDoubleQuote = false
+			case c == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[i+1]):
+				i++
+				if runes[i] != '\n' {
+					current.WriteRune(runes[i])
+				}
+			default:
+				current.WriteRune(c)
+			}
+		case c == '\'':
+			inSingleQuote, inToken = true, true
+		case c == '"':
+			inDoubleQuote, inToken = true, true
+		case c == '\\':
+			if i+1 == len(runes) {
+				// A trailing backslash has nothing to escape
+				current.WriteRune(c)
+				inToken = true
+				break
+			}
+			i++
+			if runes[i] == '\n' {
+				// Line continuation
+				break
+			}
+			current.WriteRune(runes[i])
+			inToken = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inToken {
 				tokens = append(tokens, current.String())
 				current.Reset()
+				inToken = false
 			}
 		default:
 			current.WriteRune(c)
+			inToken = true
 		}
 	}
 
-	if current.Len() > 0 {
+	if inToken {
 		tokens = append(tokens, current.String())
 	}
+	if inSingleQuote || inDoubleQuote {
+		return tokens, ErrUnterminatedQuote
+	}
+	return tokens, nil
+}
 
+// tokenizeCommand splits a command string into words with
+// ParseCommandTokens. A command with an unterminated quote is not valid
+// shell, so the words read so far are good enough for policy checks.
+func tokenizeCommand(command string) []string {
+	tokens, _ := ParseCommandTokens(command)
 	return tokens
 }
 
+// heredocDelimiter describes a heredoc redirection found on a command line.
+type heredocDelimiter struct {
+	word      string
+	stripTabs bool // <<- strips leading tabs from body lines and the delimiter
+	pos       int  // rune offset of the << operator in the line
+}
+
+// ExtractHeredocCommands finds heredocs (<<EOF, <<'EOF', <<"EOF", <<-EOF)
+// that are fed to a shell, as in "bash <<EOF" or "cat <<EOF | sh", and
+// returns the commands in their bodies. Other heredocs, such as
+// "cat <<EOF > notes.txt", are data and are skipped. Heredocs inside a
+// shell-fed body are extracted recursively.
+func ExtractHeredocCommands(cmdLine string) []string {
+	if !strings.Contains(cmdLine, "<<") {
+		return nil
+	}
+	return extractHeredocCommands(cmdLine, false)
+}
+
+// extractHeredocCommands walks the lines of src, skipping heredoc bodies.
+// When src is a shell script (the body of a shell-fed heredoc), its other
+// lines are commands too.
+func extractHeredocCommands(src string, isScript bool) []string {
+	var commands []string
+	lines := strings.Split(src, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if isScript {
+			commands = append(commands, parseShellCommand(line)...)
+		}
+		for _, delim := range findHeredocDelimiters(line) {
+			var body []string
+			for i+1 < len(lines) {
+				i++
+				bodyLine := strings.TrimRight(lines[i], "\r")
+				if delim.stripTabs {
+					bodyLine = strings.TrimLeft(bodyLine, "\t")
+				}
+				if bodyLine == delim.word {
+					break
+				}
+				body = append(body, lines[i])
+			}
+
+			if heredocFeedsShell(line, delim.pos) {
+				commands = append(commands, extractHeredocCommands(strings.Join(body, "\n"), true)...)
+			}
+		}
+	}
+
+	return commands
+}
+
+// heredocFeedsShell reports whether the heredoc starting at rune offset pos
+// of line ends up as a shell's input: its own command, or a later command in
+// the same pipeline, is a shell.
+func heredocFeedsShell(line string, pos int) bool {
+	// Split the line into commands, noting which follow a single pipe
+	type stage struct {
+		text  string
+		start int
+		piped bool
+	}
+	var stages []stage
+	var current strings.Builder
+	var inSingleQuote, inDoubleQuote bool
+	start, piped := 0, false
+	flush := func(next int, nextPiped bool) {
+		stages = append(stages, stage{text: current.String(), start: start, piped: piped})
+		current.Reset()
+		start, piped = next, nextPiped
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\'' && !inDoubleQuote:
+			inSingleQuote = !inSingleQuote
+		case c == '"' && !inSingleQuote:
+			inDoubleQuote = !inDoubleQuote
+		case inSingleQuote || inDoubleQuote:
+		case c == '|' && i+1 < len(runes) && runes[i+1] == '|',
+			c == '&' && i+1 < len(runes) && runes[i+1] == '&':
+			flush(i+2, false)
+			i++
+			continue
+		case c == '|':
+			flush(i+1, true)
+			continue
+		case c == ';' || c == '&':
+			flush(i+1, false)
+			continue
+		}
+		current.WriteRune(c)
+	}
+	flush(len(runes), false)
+
+	for i, st := range stages {
+		if pos < st.start || (i+1 < len(stages) && pos >= stages[i+1].start) {
+			continue
+		}
+		for j := i; j < len(stages); j++ {
+			if j > i && !stages[j].piped {
+				break
+			}
+			if isShellCommand(stages[j].text) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// isShellCommand reports whether command runs a shell, possibly through
+// wrappers such as sudo or env.
+func isShellCommand(command string) bool {
+	tokens := tokenizeCommand(command)
+	for len(tokens) > 0 {
+		name := filepath.Base(tokens[0])
+		switch {
+		case name == "sudo" || name == "env" || name == "exec" || name == "command" || name == "nohup" || name == "time":
+			tokens = tokens[1:]
+			for len(tokens) > 0 && strings.HasPrefix(tokens[0], "-") {
+				tokens = tokens[1:]
+			}
+		case strings.Contains(tokens[0], "=") && !strings.HasPrefix(tokens[0], "-"):
+			// Variable assignment, e.g. "FOO=1 bash"
+			tokens = tokens[1:]
+		case strings.HasPrefix(tokens[0], "<") || strings.HasPrefix(tokens[0], ">"):
+			// Leading redirection, e.g. "<<EOF bash"
+			tokens = tokens[1:]
+		default:
+			return isShellName(name)
+		}
+	}
+	return false
+}
+
+// isShellName reports whether name is the base name of a shell.
+func isShellName(name string) bool {
+	switch name {
+	case "sh", "bash", "zsh", "ksh", "dash", "fish", "nu", "elvish", "tcsh", "csh":
+		return true
+	}
+	return false
+}
+
+// findHeredocDelimiters returns the heredoc delimiters on a single line,
+// in order. Here-strings (<<<) and operators inside quotes are ignored.
+func findHeredocDelimiters(line string) []heredocDelimiter {
+	var delims []heredocDelimiter
+	var inSingleQuote, inDoubleQuote bool
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+
+		if c == '\'' && !inDoubleQuote {
+			inSingleQuote = !inSingleQuote
+			continue
+		}
+		if c == '"' && !inSingleQuote {
+			inDoubleQuote = !inDoubleQuote
+			continue
+		}
+		if inSingleQuote || inDoubleQuote {
+			continue
+		}
+
+		if c != '<' || i+1 >= len(runes) || runes[i+1] != '<' {
+			continue
+		}
+		if i+2 < len(runes) && runes[i+2] == '<' {
+			// Here-string, not a heredoc
+			i += 2
+			continue
+		}
+
+		j := i + 2
+		delim := heredocDelimiter{pos: i}
+		if j < len(runes) && runes[j] == '-' {
+			delim.stripTabs = true
+			j++
+		}
+		for j < len(runes) && (runes[j] == ' ' || runes[j] == '\t') {
+			j++
+		}
+
+		var word strings.Builder
+		var quote rune
+		for ; j < len(runes); j++ {
+			r := runes[j]
+			if quote != 0 {
+				if r == quote {
+					quote = 0
+				} else {
+					word.WriteRune(r)
+				}
+				continue
+			}
+			if r == '\'' || r == '"' {
+				quote = r
+				continue
+			}
+			if r == '\\' {
+				continue
+			}
+			if strings.ContainsRune(" \t;|&<>()", r) {
+				break
+			}
+			word.WriteRune(r)
+		}
+
+		if word.Len() > 0 {
+			delim.word = word.String()
+			delims = append(delims, delim)
+		}
+		i = j - 1
+	}
+
+	return delims
+}
+
 // normalizeCommand normalizes a command for matching.
 // - Strips leading path from the command (e.g., /usr/bin/git -> git)
 // - Collapses multiple spaces
@@ -282,6 +848,15 @@ func normalizeCommand(command string) string {
 	return strings.Join(tokens, " ")
 }
 
+// commandRulePrefix returns the matchable prefix of a rule, without any
+// trailing constraints.
+func commandRulePrefix(rule string) string {
+	if parsed, err := config.ParseCommandRule(rule); err == nil {
+		return parsed.Prefix
+	}
+	return rule
+}
+
 // matchesPrefix checks if a command matches a blocked prefix.
 // The prefix matches if the command starts with the prefix followed by
 // end of string, a space, or other argument.
@@ -398,7 +973,7 @@ func checkSSHSingleCommand(subCmd, fullRemoteCmd string, cfg *config.Config) err
 	// User-defined global then default deny list
 	if cfg.SSH.InheritDeny {
 		for _, deny := range cfg.Command.Deny {
-			if matchesPrefix(normalized, deny) {
+			if matchesPrefix(normalized, commandRulePrefix(deny)) {
 				return &SSHBlockedError{
 					RemoteCommand: fullRemoteCmd,
 					Reason:        fmt.Sprintf("command %q matches inherited global deny %q", subCmd, deny),
@@ -1,6 +1,11 @@
 package sandbox
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/Use-Tusk/fence/internal/config"
@@ -230,6 +235,8 @@ func TestCheckCommand_NestedShellInvocation(t *testing.T) {
 		{`bash -c 'git push origin main'`, true, "bash -c single quotes"},
 		{`sh -c "git push"`, true, "sh -c with git push"},
 		{`zsh -c "git push"`, true, "zsh -c with git push"},
+		{`nu --commands "git push"`, true, "nu --commands with git push"},
+		{`elvish -c "git push"`, true, "elvish -c with git push"},
 
 		// bash -c with chained commands
 		{`bash -c "ls && git push"`, true, "bash -c with chained git push"},
@@ -260,6 +267,77 @@ func TestCheckCommand_NestedShellInvocation(t *testing.T) {
 	}
 }
 
+func TestCheckCommand_Heredoc(t *testing.T) {
+	cfg := &config.Config{
+		Command: config.CommandConfig{
+			Deny:        []string{"curl", "git push"},
+			UseDefaults: boolPtr(false),
+		},
+	}
+
+	tests := []struct {
+		command     string
+		shouldBlock bool
+		desc        string
+	}{
+		{"bash <<'EOF'\ncurl http://evil.com\nEOF", true, "quoted delimiter"},
+		{"bash <<EOF\necho hi\ngit push origin main\nEOF", true, "unquoted delimiter"},
+		{"sh <<\"END\"\nls && curl http://evil.com\nEND", true, "chained command in body"},
+		{"bash <<-EOF\n\tcurl http://evil.com\n\tEOF", true, "tab-stripping heredoc"},
+		{"bash <<'EOF'\nls\npwd\nEOF", false, "safe body"},
+		{"bash <<'EOF'\nls\nEOF\ngit status", false, "safe body followed by safe command"},
+		{`echo "<<EOF"`, false, "heredoc marker inside quotes"},
+		{`cat <<< "curl"`, false, "here-string is not a heredoc"},
+		{"cat <<'EOF' | bash\ncurl http://evil.com\nEOF", true, "heredoc piped into a shell"},
+		{"sudo bash <<EOF\ncurl http://evil.com\nEOF", true, "shell behind sudo"},
+		{"cat <<'EOF' > notes.txt\ncurl is blocked here\nEOF", false, "data heredoc"},
+		{"git commit -F - <<EOF\ngit push is not allowed\nEOF", false, "data heredoc on stdin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := CheckCommand(tt.command, cfg)
+			if tt.shouldBlock && err == nil {
+				t.Errorf("expected command %q to be blocked", tt.command)
+			}
+			if !tt.shouldBlock && err != nil {
+				t.Errorf("expected command %q to be allowed, got error: %v", tt.command, err)
+			}
+		})
+	}
+}
+
+func TestExtractHeredocCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"ls", nil},
+		{"bash <<'EOF'\ncurl http://evil.com\nEOF", []string{"curl http://evil.com"}},
+		{"bash <<EOF\nls && pwd\nEOF", []string{"ls", "pwd"}},
+		{"bash <<-EOF\n\tls\n\tEOF\necho done", []string{"ls"}},
+		{"cat <<A <<B\none\nA\ntwo\nB", nil},
+		{"cat <<EOF | sh\nls\nEOF", []string{"ls"}},
+		{"cat <<EOF > out.txt; bash <<SCRIPT\ndata\nEOF\npwd\nSCRIPT", []string{"pwd"}},
+		{"bash <<OUTER\nsh <<INNER\ncurl x\nINNER\nOUTER", []string{"sh <<INNER", "curl x"}},
+		{"bash <<OUTER\ncat <<DATA\ncurl x\nDATA\nOUTER", []string{"cat <<DATA"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got := ExtractHeredocCommands(tt.input)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("ExtractHeredocCommands(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("ExtractHeredocCommands(%q)[%d] = %q, want %q", tt.input, i, got[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
 func TestCheckCommand_PathNormalization(t *testing.T) {
 	cfg := &config.Config{
 		Command: config.CommandConfig{
@@ -405,6 +483,90 @@ func TestParseShellCommand(t *testing.T) {
 	}
 }
 
+func TestParseCommandTokens(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"git push origin", []string{"git", "push", "origin"}, false},
+		{"  ls\t-la \n", []string{"ls", "-la"}, false},
+		{`bash -c 'npm run && echo done'`, []string{"bash", "-c", "npm run && echo done"}, false},
+		{`echo "it's here"`, []string{"echo", "it's here"}, false},
+		{`echo 'say "hi"'`, []string{"echo", `say "hi"`}, false},
+		{`echo "a \"b\" c"`, []string{"echo", `a "b" c`}, false},
+		{`echo "\$HOME \n"`, []string{"echo", `$HOME \n`}, false},
+		{`echo 'a\b'`, []string{"echo", `a\b`}, false},
+		{`echo a\ b c`, []string{"echo", "a b", "c"}, false},
+		{`echo \"quoted\"`, []string{"echo", `"quoted"`}, false},
+		{`grep foo'bar'"baz"`, []string{"grep", "foobarbaz"}, false},
+		{`echo "" ''`, []string{"echo", "", ""}, false},
+		{"echo one \\\ntwo", []string{"echo", "one", "two"}, false},
+		{`echo trailing\`, []string{"echo", `trailing\`}, false},
+		{`ls && rm -rf / ; echo $(id) | cat > out`, []string{"ls", "&&", "rm", "-rf", "/", ";", "echo", "$(id)", "|", "cat", ">", "out"}, false},
+		{"", nil, false},
+		{`echo 'unterminated`, []string{"echo", "unterminated"}, true},
+		{`echo "open \"`, []string{"echo", `open "`}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseCommandTokens(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCommandTokens(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrUnterminatedQuote) {
+				t.Errorf("ParseCommandTokens(%q) error = %v, want ErrUnterminatedQuote", tt.input, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseCommandTokens(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzParseCommandTokens(f *testing.F) {
+	for _, seed := range []string{
+		"git push",
+		`bash -c 'npm run && echo done'`,
+		`echo "a \"b\" c" \' 'x`,
+		"echo \\\n\\",
+		`"unterminated`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, command string) {
+		tokens, err := ParseCommandTokens(command)
+		if err != nil && !errors.Is(err, ErrUnterminatedQuote) {
+			t.Fatalf("ParseCommandTokens(%q) unexpected error %v", command, err)
+		}
+		// Re-quoting the words must give them back unchanged
+		if err == nil {
+			again, err := ParseCommandTokens(ShellQuote(tokens))
+			if err != nil || !slices.Equal(again, tokens) {
+				t.Fatalf("ParseCommandTokens(ShellQuote(%q)) = %q, %v", tokens, again, err)
+			}
+		}
+	})
+}
+
+func TestCheckCommand_EscapedOperators(t *testing.T) {
+	cfg := &config.Config{
+		Command: config.CommandConfig{
+			Deny:        []string{"git push"},
+			UseDefaults: boolPtr(false),
+		},
+	}
+
+	// An escaped quote must not hide the operator that follows it
+	if err := CheckCommand(`echo \"; git push`, cfg); err == nil {
+		t.Error(`expected 'echo \"; git push' to be blocked`)
+	}
+	if err := CheckCommand(`echo \; git push`, cfg); err != nil {
+		t.Errorf(`expected 'echo \; git push' (a single echo) to be allowed, got %v`, err)
+	}
+}
+
 func TestNormalizeCommand(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -824,3 +986,399 @@ func TestCheckSSHCommand_CommandChainingDenylist(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckCommandWithAudit_ShadowMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		shadowMode bool
+		command    string
+		wantErr    bool
+		wantEvents int
+	}{
+		{"enforced deny", false, "git push origin main", true, 1},
+		{"shadow deny", true, "git push origin main", false, 1},
+		{"shadow deny reports every sub-command", true, "git push && rm -rf /tmp/x", false, 2},
+		{"shadow allowed command", true, "git status", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Command: config.CommandConfig{
+					Deny:        []string{"git push", "rm -rf"},
+					UseDefaults: boolPtr(false),
+					ShadowMode:  tt.shadowMode,
+				},
+			}
+
+			var events []CommandAuditEvent
+			err := CheckCommandWithAudit(tt.command, cfg, func(e CommandAuditEvent) {
+				events = append(events, e)
+			})
+
+			if tt.wantErr && err == nil {
+				t.Errorf("expected command %q to be blocked", tt.command)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("expected command %q to run, got error: %v", tt.command, err)
+			}
+			if len(events) != tt.wantEvents {
+				t.Fatalf("got %d audit events, want %d: %+v", len(events), tt.wantEvents, events)
+			}
+			for _, e := range events {
+				if e.BlockedInShadowMode != tt.shadowMode {
+					t.Errorf("BlockedInShadowMode = %v, want %v", e.BlockedInShadowMode, tt.shadowMode)
+				}
+				if e.Command != tt.command {
+					t.Errorf("event Command = %q, want %q", e.Command, tt.command)
+				}
+				if e.BlockedPrefix == "" {
+					t.Errorf("event for %q missing BlockedPrefix", e.SubCommand)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckCommand_AllowMaxArgs(t *testing.T) {
+	dataDir := t.TempDir()
+	input := filepath.Join(dataDir, "input.csv")
+	if err := os.WriteFile(input, []byte("a,b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Command: config.CommandConfig{
+			Deny:        []string{"python3"},
+			Allow:       []string{"python3 maxArgs:2", "ls maxArgs:0"},
+			UseDefaults: boolPtr(false),
+		},
+		Filesystem: config.FilesystemConfig{
+			AllowRead: []string{dataDir},
+		},
+	}
+
+	tests := []struct {
+		command     string
+		shouldBlock bool
+	}{
+		{"python3", false},
+		{"python3 script.py", false},
+		{"python3 script.py arg1", false},
+		{`python3 -c "import sys; print(sys.argv[1])" "secret data"`, true},
+		{"python3 script.py a b", true},
+		{"python3 script.py a " + input, false},                            // allowRead paths don't count
+		{"python3 script.py a " + filepath.Join(dataDir, "missing"), true}, // only existing paths are skipped
+		{"python3 script.py a /etc/passwd", true},
+		{"ls", false},
+		{"ls -la", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			err := CheckCommand(tt.command, cfg)
+			if tt.shouldBlock && err == nil {
+				t.Errorf("expected command %q to be blocked", tt.command)
+			}
+			if !tt.shouldBlock && err != nil {
+				t.Errorf("expected command %q to be allowed, got error: %v", tt.command, err)
+			}
+		})
+	}
+}
+
+func TestCheckCommand_AllowMaxArgsFreeTextWithAllowReadCwd(t *testing.T) {
+	t.Chdir(t.TempDir())
+	cfg := &config.Config{
+		Command: config.CommandConfig{
+			Deny:        []string{"python3"},
+			Allow:       []string{"python3 maxArgs:2"},
+			UseDefaults: boolPtr(false),
+		},
+		Filesystem: config.FilesystemConfig{
+			AllowRead: []string{"."},
+		},
+	}
+
+	// Bare words resolve under the working directory, but are not paths and
+	// must still be counted.
+	if err := CheckCommand(`python3 -c "print(1)" "secret data" more stuff`, cfg); err == nil {
+		t.Error("expected free-text arguments to count toward maxArgs when allowRead is \".\"")
+	}
+}
+
+func TestCheckCommand_RulePriority(t *testing.T) {
+	tests := []struct {
+		name        string
+		allow       []string
+		deny        []string
+		command     string
+		shouldBlock bool
+		wantPrefix  string
+	}{
+		{
+			name:        "allow wins at equal default priority",
+			allow:       []string{"npm run"},
+			deny:        []string{"npm run deploy"},
+			command:     "npm run deploy",
+			shouldBlock: false,
+		},
+		{
+			name:        "high-priority deny beats low-priority allow",
+			allow:       []string{"npm run priority:5"},
+			deny:        []string{"npm run deploy priority:10"},
+			command:     "npm run deploy --prod",
+			shouldBlock: true,
+			wantPrefix:  "npm run deploy priority:10",
+		},
+		{
+			name:        "high-priority deny does not affect other commands",
+			allow:       []string{"npm run priority:5"},
+			deny:        []string{"npm run deploy priority:10"},
+			command:     "npm run build",
+			shouldBlock: false,
+		},
+		{
+			name:        "high-priority allow beats deny",
+			allow:       []string{"git push origin docs priority:1"},
+			deny:        []string{"git push"},
+			command:     "git push origin docs",
+			shouldBlock: false,
+		},
+		{
+			name:        "negative priority allow loses to default-priority deny",
+			allow:       []string{"rm priority:-1"},
+			deny:        []string{"rm -rf"},
+			command:     "rm -rf build",
+			shouldBlock: true,
+			wantPrefix:  "rm -rf",
+		},
+		{
+			name:        "higher-priority allow checked first",
+			allow:       []string{"npm run priority:5", "npm run build priority:10"},
+			deny:        []string{"npm"},
+			command:     "npm run build",
+			shouldBlock: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Command: config.CommandConfig{
+					Allow:       tt.allow,
+					Deny:        tt.deny,
+					UseDefaults: boolPtr(false),
+				},
+			}
+			err := CheckCommand(tt.command, cfg)
+			if !tt.shouldBlock {
+				if err != nil {
+					t.Errorf("expected %q to be allowed, got: %v", tt.command, err)
+				}
+				return
+			}
+			blocked, ok := err.(*CommandBlockedError)
+			if !ok {
+				t.Fatalf("expected CommandBlockedError for %q, got %T (%v)", tt.command, err, err)
+			}
+			if blocked.BlockedPrefix != tt.wantPrefix {
+				t.Errorf("BlockedPrefix = %q, want %q", blocked.BlockedPrefix, tt.wantPrefix)
+			}
+		})
+	}
+}
+
+func TestEvaluateCommandPolicy_Source(t *testing.T) {
+	cfg := &config.Config{
+		Command: config.CommandConfig{
+			Allow: []string{"git push origin docs", "python3 maxArgs:1"},
+			Deny:  []string{"git push", "python3"},
+		},
+	}
+
+	tests := []struct {
+		command     string
+		wantAllowed bool
+		wantRule    string
+		wantSource  PolicySource
+	}{
+		{"ls -la", true, "", SourceNone},
+		{"git push origin docs", true, "git push origin docs", SourceAllow},
+		{"git push origin main", false, "git push", SourceExplicit},
+		{"python3 script.py", true, "python3 maxArgs:1", SourceAllow},
+		{"python3 script.py extra", false, "python3 maxArgs:1", SourceAllow},
+		{"shutdown -h now", false, "shutdown", SourceDefault},
+		{"base64 ~/.ssh/id_rsa", false, "base64", SourceDefault},
+		{"base64 -d payload.txt", false, "base64", SourceDefault},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			got := EvaluateCommandPolicy(tt.command, cfg)
+			if got.Allowed != tt.wantAllowed || got.Rule != tt.wantRule || got.Source != tt.wantSource {
+				t.Errorf("EvaluateCommandPolicy(%q) = %+v, want {Allowed:%v Rule:%s Source:%s}",
+					tt.command, got, tt.wantAllowed, tt.wantRule, tt.wantSource)
+			}
+
+			err := CheckCommand(tt.command, cfg)
+			if tt.wantAllowed {
+				if err != nil {
+					t.Errorf("CheckCommand(%q) = %v, want nil", tt.command, err)
+				}
+				return
+			}
+			blocked, ok := err.(*CommandBlockedError)
+			if !ok {
+				t.Fatalf("CheckCommand(%q) = %T, want *CommandBlockedError", tt.command, err)
+			}
+			if blocked.Source != tt.wantSource {
+				t.Errorf("CommandBlockedError.Source = %q, want %q", blocked.Source, tt.wantSource)
+			}
+			if blocked.IsDefault != (tt.wantSource == SourceDefault) {
+				t.Errorf("CommandBlockedError.IsDefault = %v for source %q", blocked.IsDefault, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestCheckCommand_AllowWorkdir(t *testing.T) {
+	origGetwd, origCaseInsensitive := getwd, caseInsensitivePaths
+	t.Cleanup(func() { getwd, caseInsensitivePaths = origGetwd, origCaseInsensitive })
+
+	cfg := &config.Config{
+		Command: config.CommandConfig{
+			Allow:       []string{"workdir:/workspace npm publish"},
+			UseDefaults: boolPtr(false),
+		},
+	}
+
+	tests := []struct {
+		name            string
+		cwd             string
+		caseInsensitive bool
+		command         string
+		shouldBlock     bool
+	}{
+		{"subdirectory", "/workspace/myproject", false, "npm publish", false},
+		{"workdir itself", "/workspace", false, "npm publish --tag next", false},
+		{"outside workdir", "/tmp", false, "npm publish", true},
+		{"sibling with shared prefix", "/workspace-other", false, "npm publish", true},
+		{"other command unaffected", "/tmp", false, "npm install", false},
+		{"case differs on case-sensitive fs", "/Workspace/myproject", false, "npm publish", true},
+		{"case differs on case-insensitive fs", "/Workspace/myproject", true, "npm publish", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getwd = func() (string, error) { return tt.cwd, nil }
+			caseInsensitivePaths = tt.caseInsensitive
+
+			err := CheckCommand(tt.command, cfg)
+			if tt.shouldBlock && err == nil {
+				t.Errorf("expected %q to be blocked in %s", tt.command, tt.cwd)
+			}
+			if !tt.shouldBlock && err != nil {
+				t.Errorf("expected %q to be allowed in %s, got: %v", tt.command, tt.cwd, err)
+			}
+		})
+	}
+}
+
+func TestCheckCommand_DenyReason(t *testing.T) {
+	const reason = "Use fetch() instead of curl for HTTP requests"
+	cfg := &config.Config{
+		Command: config.CommandConfig{
+			Deny:        []string{"curl reason:" + reason},
+			UseDefaults: boolPtr(false),
+		},
+	}
+
+	var events []CommandAuditEvent
+	err := CheckCommandWithAudit("curl -s https://example.com", cfg, func(e CommandAuditEvent) { events = append(events, e) })
+	blocked, ok := err.(*CommandBlockedError)
+	if !ok {
+		t.Fatalf("expected CommandBlockedError, got %T (%v)", err, err)
+	}
+	if blocked.BlockedPrefix != "curl" {
+		t.Errorf("BlockedPrefix = %q, want %q", blocked.BlockedPrefix, "curl")
+	}
+	if !strings.Contains(err.Error(), reason) {
+		t.Errorf("error %q should contain the rule reason", err.Error())
+	}
+	if len(events) != 1 || events[0].RuleReason != reason {
+		t.Errorf("audit events = %+v, want one with RuleReason %q", events, reason)
+	}
+
+	// The reason is not part of the prefix
+	for _, cmd := range []string{"curl", "/usr/bin/curl -I example.com", "echo hi && curl example.com"} {
+		if err := CheckCommand(cmd, cfg); err == nil {
+			t.Errorf("expected %q to be blocked", cmd)
+		}
+	}
+	for _, cmd := range []string{"curlie example.com", "wget example.com"} {
+		if err := CheckCommand(cmd, cfg); err != nil {
+			t.Errorf("expected %q to be allowed, got: %v", cmd, err)
+		}
+	}
+}
+
+func TestCheckCommand_AllowWhenGitTag(t *testing.T) {
+	origGitExactTag := gitExactTag
+	t.Cleanup(func() { gitExactTag = origGitExactTag })
+
+	cfg := &config.Config{
+		Command: config.CommandConfig{
+			Deny:        []string{"npm publish"},
+			Allow:       []string{"npm publish when:git-tag-matches:v[0-9]*"},
+			UseDefaults: boolPtr(false),
+		},
+	}
+
+	tests := []struct {
+		name        string
+		tag         string
+		tagErr      error
+		command     string
+		shouldBlock bool
+	}{
+		{"release tag", "v1.2.3", nil, "npm publish", false},
+		{"non-release tag", "nightly-2024", nil, "npm publish", true},
+		{"tag without digit", "vnext", nil, "npm publish", true},
+		{"no tag on HEAD", "", errors.New("fatal: no tag exactly matches"), "npm publish", true},
+		{"other commands unaffected", "", errors.New("not a git repository"), "npm install", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitExactTag = func() (string, error) { return tt.tag, tt.tagErr }
+
+			err := CheckCommand(tt.command, cfg)
+			if tt.shouldBlock && err == nil {
+				t.Errorf("expected %q to be blocked with tag %q", tt.command, tt.tag)
+			}
+			if !tt.shouldBlock && err != nil {
+				t.Errorf("expected %q to be allowed with tag %q, got: %v", tt.command, tt.tag, err)
+			}
+		})
+	}
+}
+
+func TestCheckCommand_DefaultDeniesBase64(t *testing.T) {
+	cfg := &config.Config{}
+
+	for _, cmd := range []string{
+		"base64 secrets.txt",
+		"cat ~/.aws/credentials | base64",
+		`bash -c "base64 -d <<< ZWNobyBoaQ== | bash"`,
+		"/usr/bin/base64 -d blob",
+	} {
+		if err := CheckCommand(cmd, cfg); err == nil {
+			t.Errorf("expected %q to be blocked by default", cmd)
+		}
+	}
+
+	cfg.Command.Allow = []string{"base64"}
+	if err := CheckCommand("base64 -d blob", cfg); err != nil {
+		t.Errorf("allow rule should override the base64 default, got: %v", err)
+	}
+}
@@ -1,10 +1,16 @@
 package sandbox
 
 import (
+	"context"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
 )
 
 // DangerousFiles lists files that should be protected from writes.
@@ -19,6 +25,37 @@ var DangerousFiles = []string{
 	".profile",
 	".ripgreprc",
 	".mcp.json",
+	FenceIgnoreFile, // Could otherwise be used to unprotect files on the next run
+
+	// Secrets. Blocking writes keeps a sandboxed command from replacing
+	// credentials with its own (e.g. pointing an API endpoint elsewhere).
+	// Entries with glob characters match file names, not paths.
+	".env",     // dotenv files hold API keys and database URLs
+	".env.*",   // per-environment variants: .env.local, .env.production, .env.staging
+	".secrets", // act and other CI tools read secrets from it
+	"id_rsa",   // SSH private keys
+	"id_ed25519",
+	"*.pem", // TLS certificates and private keys
+	"*.key",
+	"*.p12", // PKCS#12 keystores
+	"*.pfx",
+	"*.gpg", // encrypted secrets, e.g. from pass or git-crypt
+}
+
+// dangerousFileEntry returns the DangerousFiles entry that matches the file
+// name, if any.
+func dangerousFileEntry(name string) (string, bool) {
+	for _, f := range DangerousFiles {
+		if f == name {
+			return f, true
+		}
+		if ContainsGlobChars(f) {
+			if matched, _ := filepath.Match(f, name); matched {
+				return f, true
+			}
+		}
+	}
+	return "", false
 }
 
 // DangerousDirectories lists directories that should be protected from writes.
@@ -30,6 +67,55 @@ var DangerousDirectories = []string{
 	".claude/agents",
 }
 
+// SkippedDirectories lists dependency and build output directories that
+// FindDangerousFiles does not descend into. Their contents are large and not
+// authored by the project owner.
+var SkippedDirectories = []string{
+	"node_modules",
+	"vendor",
+	"__pycache__",
+	".venv",
+	"venv",
+	"target",
+	"dist",
+	".terraform",
+}
+
+// RegisterSkippedDirectory adds a directory name to SkippedDirectories.
+// It should be called during initialization, before any scans run.
+func RegisterSkippedDirectory(name string) {
+	if name == "" || slices.Contains(SkippedDirectories, name) {
+		return
+	}
+	SkippedDirectories = append(SkippedDirectories, name)
+}
+
+// GetFenceConfigPaths returns fence's own configuration locations. These are
+// always write-protected so a sandboxed process cannot weaken its own policy.
+func GetFenceConfigPaths() []string {
+	var paths []string
+
+	home, err := os.UserHomeDir()
+	if err == nil && home != "" {
+		paths = append(paths,
+			filepath.Join(home, ".config", "fence"),
+			filepath.Join(home, ".fence"),
+			filepath.Join(home, ".fence.json"),
+		)
+	}
+
+	// The OS-preferred config dir differs from ~/.config on macOS
+	// (~/Library/Application Support).
+	if configDir, err := os.UserConfigDir(); err == nil && configDir != "" {
+		p := filepath.Join(configDir, "fence")
+		if !slices.Contains(paths, p) {
+			paths = append(paths, p)
+		}
+	}
+
+	return paths
+}
+
 // GetDefaultWritePaths returns system paths that should be writable for commands to work.
 func GetDefaultWritePaths() []string {
 	home, _ := os.UserHomeDir()
@@ -46,12 +132,143 @@ func GetDefaultWritePaths() []string {
 	}
 
 	if home != "" {
-		paths = append(paths,
-			filepath.Join(home, ".npm/_logs"),
-			filepath.Join(home, ".fence/debug"),
-		)
+		paths = append(paths, filepath.Join(home, ".npm/_logs"))
+	}
+
+	return paths
+}
+
+// toolchainEnvPaths returns the toolchain directories named by environment
+// variables, where set to absolute paths: the active Conda environment
+// ($CONDA_PREFIX), the Conda installation ($CONDA_EXE is its bin/conda), the
+// Mamba root ($MAMBA_ROOT_PREFIX), the JDK ($JAVA_HOME), SDKMAN!
+// ($SDKMAN_DIR), the Bun executables ($BUN_INSTALL/bin) and the Deno cache
+// ($DENO_DIR, by default deno in the user cache directory). A variable that
+// names the root or home directory, or a directory above home, is ignored so
+// it cannot make the whole home directory readable.
+func toolchainEnvPaths() []string {
+	home, _ := os.UserHomeDir()
+	var paths []string
+	add := func(p string) {
+		if !filepath.IsAbs(p) {
+			return
+		}
+		if p = filepath.Clean(p); containsHome(p, home) {
+			return
+		}
+		if !slices.Contains(paths, p) {
+			paths = append(paths, p)
+		}
+	}
+	add(os.Getenv("CONDA_PREFIX"))
+	if exe := os.Getenv("CONDA_EXE"); exe != "" {
+		add(filepath.Dir(filepath.Dir(exe)))
+	}
+	add(os.Getenv("MAMBA_ROOT_PREFIX"))
+	add(os.Getenv("JAVA_HOME"))
+	add(os.Getenv("SDKMAN_DIR"))
+	if bunInstall := os.Getenv("BUN_INSTALL"); bunInstall != "" {
+		add(filepath.Join(bunInstall, "bin"))
+	}
+	if denoDir := os.Getenv("DENO_DIR"); denoDir != "" {
+		add(denoDir)
+	} else if cacheDir, err := os.UserCacheDir(); err == nil {
+		add(filepath.Join(cacheDir, "deno"))
+	}
+	return paths
+}
+
+// containsHome reports whether dir is the root directory, or home or one of
+// its ancestors.
+func containsHome(dir, home string) bool {
+	if dir == filepath.Dir(dir) {
+		return true
+	}
+	if home == "" {
+		return false
 	}
+	rel, err := filepath.Rel(dir, filepath.Clean(home))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
 
+// goCachePaths returns the Go module cache, build cache and go env config
+// directory, resolved as the go command does: $GOMODCACHE or the first
+// $GOPATH entry's pkg/mod (GOPATH defaults to ~/go), $GOCACHE or go-build
+// in the user cache directory, and go in the user config directory.
+func goCachePaths(home string) []string {
+	var paths []string
+	add := func(p string) {
+		if !filepath.IsAbs(p) {
+			return
+		}
+		if p = filepath.Clean(p); !slices.Contains(paths, p) {
+			paths = append(paths, p)
+		}
+	}
+
+	gopath, _, _ := strings.Cut(os.Getenv("GOPATH"), string(filepath.ListSeparator))
+	if gopath == "" && home != "" {
+		gopath = filepath.Join(home, "go")
+	}
+	if modCache := os.Getenv("GOMODCACHE"); modCache != "" {
+		add(modCache)
+	} else if gopath != "" {
+		add(filepath.Join(gopath, "pkg", "mod"))
+	}
+
+	if goCache := os.Getenv("GOCACHE"); goCache != "" {
+		add(goCache) // "off" is not absolute and is skipped
+	} else if cacheDir, err := os.UserCacheDir(); err == nil {
+		add(filepath.Join(cacheDir, "go-build"))
+	}
+
+	if configDir, err := os.UserConfigDir(); err == nil {
+		add(filepath.Join(configDir, "go"))
+	}
+	return paths
+}
+
+// xdgDir returns the directory named by the XDG base directory variable env,
+// or fallback when it is unset or not an absolute path (the XDG Base
+// Directory spec says relative paths must be ignored).
+func xdgDir(env, fallback string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Clean(dir)
+	}
+	return fallback
+}
+
+// xdgToolDirs lists, for each XDG base directory, the subdirectories where
+// package managers and version managers keep caches, installed runtimes and
+// package stores. Only these are made readable: the base directories as a
+// whole also hold browser profiles, keyrings and other tools' credentials.
+var xdgToolDirs = []struct {
+	env      string
+	fallback string // Relative to the home directory
+	names    []string
+}{
+	{"XDG_CACHE_HOME", ".cache", []string{"pip", "pypoetry", "uv", "pnpm", "yarn", "node-gyp", "ms-playwright"}},
+	{"XDG_DATA_HOME", ".local/share", []string{"pipx", "uv", "pnpm", "mise", "fnm", "virtualenvs"}},
+	{"XDG_STATE_HOME", ".local/state", []string{"pnpm", "mise"}},
+}
+
+// xdgReadablePaths returns the xdgToolDirs subdirectories of the XDG cache,
+// data and state directories, which tools such as pip expect to read.
+func xdgReadablePaths(home string) []string {
+	var paths []string
+	for _, base := range xdgToolDirs {
+		fallback := ""
+		if home != "" {
+			fallback = filepath.Join(home, base.fallback)
+		}
+		dir := xdgDir(base.env, fallback)
+		if dir == "" {
+			continue
+		}
+		for _, name := range base.names {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
 	return paths
 }
 
@@ -145,9 +362,31 @@ func GetDefaultReadablePaths() []string {
 
 			// Deno (bin only)
 			filepath.Join(home, ".deno/bin"),
+
+			// Conda and Mamba installations (need lib/ and envs/)
+			filepath.Join(home, ".conda"),
+			filepath.Join(home, "miniconda3"),
+			filepath.Join(home, "anaconda3"),
+			filepath.Join(home, "mambaforge"),
+			filepath.Join(home, "opt/miniconda3"),
+
+			// JVM build tools and SDK manager (JVM installations under
+			// /usr/lib/jvm and /Library/Java are covered by /usr and /Library)
+			filepath.Join(home, ".gradle"),
+			filepath.Join(home, ".m2"),
+			filepath.Join(home, ".sdkman"),
 		)
 	}
 
+	// Toolchains installed elsewhere, found from their environment
+	paths = append(paths, toolchainEnvPaths()...)
+
+	// Go module and build caches
+	paths = append(paths, goCachePaths(home)...)
+
+	// Package manager directories under the XDG base directories
+	paths = append(paths, xdgReadablePaths(home)...)
+
 	return paths
 }
 
@@ -164,57 +403,178 @@ const DefaultMaxDangerousFileDepth = 3
 //   - maxDepth=3: searches up to root/a/b/c/*.dangerous
 //
 // Items directly in root are not returned - the caller adds those separately.
-// node_modules directories are skipped for performance.
+// Directories named in SkippedDirectories (node_modules, vendor, ...) are
+// skipped for performance, and paths matched by ignore (which may be nil) are
+// neither returned nor descended into.
 // .git internals (hooks/, config) are handled specially: when a .git dir is found
 // within the depth range, we peek inside for hooks/ and config without counting
 // .git's internal structure against the depth limit.
-func FindDangerousFiles(root string, maxDepth int) []string {
+func FindDangerousFiles(root string, maxDepth int, ignore IgnoreRules) []string {
+	results, _, _ := FindDangerousFilesContext(context.Background(), root, maxDepth, ScanOptions{Ignore: ignore})
+	return results
+}
+
+// ScanOptions bounds and filters a FindDangerousFilesContext walk.
+type ScanOptions struct {
+	// Stop after visiting this many files and directories (0 means no limit)
+	MaxFilesScanned int
+	// Reports whether to skip a directory by name, in addition to
+	// SkippedDirectories (optional). It may be called concurrently.
+	SkipDir func(name string) bool
+	// Paths not to report or descend into (optional)
+	Ignore IgnoreRules
+}
+
+// FindDangerousFilesContext is FindDangerousFiles with cancellation and a
+// scan limit. If ctx is done or opts.MaxFilesScanned is reached, it returns
+// what was found so far with truncated set; the error is ctx.Err() in the
+// first case and nil in the second. Results are sorted.
+//
+// When root has at least parallelScanMinDirs subdirectories, they are
+// scanned concurrently by up to runtime.NumCPU() workers.
+func FindDangerousFilesContext(ctx context.Context, root string, maxDepth int, opts ScanOptions) (results []string, truncated bool, err error) {
+	return findDangerousFiles(ctx, root, maxDepth, opts, runtime.NumCPU())
+}
+
+// parallelScanMinDirs is the number of subdirectories of the scan root from
+// which FindDangerousFilesContext scans them in parallel. Below it, a single
+// walk is cheaper than starting workers.
+const parallelScanMinDirs = 4
+
+// dangerousScan holds the state shared by the walks of one scan.
+type dangerousScan struct {
+	ctx        context.Context
+	opts       ScanOptions
+	maxDepth   int
+	root       string
+	rootPrefix string
+
+	dangerousFileSet      map[string]bool
+	dangerousFilePatterns []string
+	skippedDirSet         map[string]bool
+	dangerousDirSet       map[string]bool
+
+	scanned   atomic.Int64
+	truncated atomic.Bool
+}
+
+// findDangerousFiles implements FindDangerousFilesContext, scanning with up
+// to workers goroutines (1 means a single walk).
+func findDangerousFiles(ctx context.Context, root string, maxDepth int, opts ScanOptions, workers int) ([]string, bool, error) {
 	if maxDepth <= 0 {
-		return nil
+		return nil, false, nil
 	}
 
-	// Build lookup sets for O(1) matching
-	dangerousFileSet := make(map[string]bool, len(DangerousFiles))
+	rootClean := filepath.Clean(root)
+	s := &dangerousScan{
+		ctx:        ctx,
+		opts:       opts,
+		maxDepth:   maxDepth,
+		root:       rootClean,
+		rootPrefix: rootClean + string(filepath.Separator),
+
+		// Lookup sets for O(1) matching
+		dangerousFileSet: make(map[string]bool, len(DangerousFiles)),
+		skippedDirSet:    make(map[string]bool, len(SkippedDirectories)),
+		dangerousDirSet:  make(map[string]bool, len(DangerousDirectories)),
+	}
 	for _, f := range DangerousFiles {
-		dangerousFileSet[f] = true
+		if ContainsGlobChars(f) {
+			s.dangerousFilePatterns = append(s.dangerousFilePatterns, f)
+		} else {
+			s.dangerousFileSet[f] = true
+		}
 	}
-	dangerousDirSet := make(map[string]bool, len(DangerousDirectories))
-	for _, d := range DangerousDirectories {
-		dangerousDirSet[d] = true
+	for _, d := range SkippedDirectories {
+		s.skippedDirSet[d] = true
 	}
-	// For multi-component dangerous dirs like ".claude/commands", track the
-	// first component so we enter it during the walk, then match the full path.
-	multiCompFirstComponent := make(map[string]bool)
 	for _, d := range DangerousDirectories {
-		if strings.Contains(d, string(filepath.Separator)) {
-			first := strings.SplitN(d, string(filepath.Separator), 2)[0]
-			multiCompFirstComponent[first] = true
+		s.dangerousDirSet[d] = true
+	}
+
+	var results []string
+	var dirs []string
+	if workers > 1 {
+		entries, _ := os.ReadDir(rootClean)
+		for _, e := range entries {
+			if e.IsDir() {
+				dirs = append(dirs, filepath.Join(rootClean, e.Name()))
+			}
 		}
 	}
+	if len(dirs) >= parallelScanMinDirs {
+		results = s.walkParallel(dirs, workers)
+	} else {
+		results = s.walk(rootClean)
+	}
+	slices.Sort(results)
 
-	rootClean := filepath.Clean(root)
-	rootPrefix := rootClean + string(filepath.Separator)
+	if !s.truncated.Load() {
+		return results, false, nil
+	}
+	return results, true, ctx.Err()
+}
+
+// walkParallel walks each of dirs, the subdirectories of the scan root, on
+// up to workers goroutines.
+func (s *dangerousScan) walkParallel(dirs []string, workers int) []string {
+	found := make(chan []string, len(dirs))
+	var g errgroup.Group
+	g.SetLimit(workers)
+	for _, dir := range dirs {
+		g.Go(func() error {
+			found <- s.walk(dir)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	close(found)
 
 	var results []string
+	for paths := range found {
+		results = append(results, paths...)
+	}
+	return results
+}
 
-	_ = filepath.WalkDir(rootClean, func(path string, d fs.DirEntry, err error) error {
+// walk scans the tree at start, which is the scan root or one of its
+// subdirectories, and returns the dangerous paths in it.
+func (s *dangerousScan) walk(start string) []string {
+	var results []string
+	_ = filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return filepath.SkipDir
 		}
-		if path == rootClean {
+		if path == s.root {
 			return nil
 		}
+		if s.ctx.Err() != nil {
+			s.truncated.Store(true)
+			return filepath.SkipAll
+		}
+		if n := s.scanned.Add(1); s.opts.MaxFilesScanned > 0 && n > int64(s.opts.MaxFilesScanned) {
+			s.truncated.Store(true)
+			return filepath.SkipAll
+		}
 
-		rel := strings.TrimPrefix(path, rootPrefix)
+		rel := strings.TrimPrefix(path, s.rootPrefix)
 		components := strings.Split(rel, string(filepath.Separator))
 		nComp := len(components)
 		name := d.Name()
 
-		// Skip node_modules entirely for performance
-		if d.IsDir() && name == "node_modules" {
+		// Skip dependency and build directories entirely for performance
+		if d.IsDir() && (s.skippedDirSet[name] || (s.opts.SkipDir != nil && s.opts.SkipDir(name))) {
 			return filepath.SkipDir
 		}
 
+		// Skip paths the project has marked as intentional, e.g. fixtures
+		if s.opts.Ignore.Match(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		// subdirLevel: how many user-facing subdirectory levels from root.
 		// root/sub/.bashrc -> nComp=2 -> subdirLevel=1
 		// root/a/b/.bashrc -> nComp=3 -> subdirLevel=2
@@ -226,7 +586,7 @@ func FindDangerousFiles(root string, maxDepth int) []string {
 		// root/.git (subdirLevel=0) -> skip (caller handles cwd-level .git)
 		// root/a/.git (subdirLevel=1) -> peek inside if maxDepth >= 1
 		if d.IsDir() && name == ".git" {
-			if subdirLevel >= 1 && subdirLevel <= maxDepth {
+			if subdirLevel >= 1 && subdirLevel <= s.maxDepth {
 				hooksPath := filepath.Join(path, "hooks")
 				if info, e := os.Stat(hooksPath); e == nil && info.IsDir() {
 					results = append(results, hooksPath)
@@ -249,18 +609,18 @@ func FindDangerousFiles(root string, maxDepth int) []string {
 		// Prune directories beyond our search depth.
 		// We need to descend up to maxDepth+1 components to find dangerous
 		// files/dirs at the maxDepth level (nComp = maxDepth+1).
-		if d.IsDir() && subdirLevel > maxDepth {
+		if d.IsDir() && subdirLevel > s.maxDepth {
 			return filepath.SkipDir
 		}
 
 		// Check dangerous files
-		if !d.IsDir() && dangerousFileSet[name] && subdirLevel <= maxDepth {
+		if !d.IsDir() && s.isDangerousFile(name) && subdirLevel <= s.maxDepth {
 			results = append(results, path)
 			return nil
 		}
 
 		// Check dangerous directories (single-component like ".vscode")
-		if d.IsDir() && dangerousDirSet[name] && subdirLevel <= maxDepth {
+		if d.IsDir() && s.dangerousDirSet[name] && subdirLevel <= s.maxDepth {
 			results = append(results, path)
 			return filepath.SkipDir
 		}
@@ -271,7 +631,7 @@ func FindDangerousFiles(root string, maxDepth int) []string {
 		if d.IsDir() {
 			for _, dd := range DangerousDirectories {
 				if strings.Contains(dd, string(filepath.Separator)) &&
-					subdirLevel <= maxDepth &&
+					subdirLevel <= s.maxDepth &&
 					strings.HasSuffix(rel, dd) &&
 					(rel == dd || rel[len(rel)-len(dd)-1] == filepath.Separator) {
 					results = append(results, path)
@@ -282,22 +642,47 @@ func FindDangerousFiles(root string, maxDepth int) []string {
 
 		return nil
 	})
-
 	return results
 }
 
+// isDangerousFile reports whether name is in DangerousFiles or matches one
+// of its glob entries.
+func (s *dangerousScan) isDangerousFile(name string) bool {
+	if s.dangerousFileSet[name] {
+		return true
+	}
+	for _, pattern := range s.dangerousFilePatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
 // GetMandatoryDenyPatterns returns glob patterns for paths that must always be protected.
-func GetMandatoryDenyPatterns(cwd string, allowGitConfig bool) []string {
+// Names in trustedFiles and trustedDirs (from filesystem.trustedDangerousFiles and
+// trustedDangerousDirectories) are left out, except in the home directory.
+func GetMandatoryDenyPatterns(cwd string, allowGitConfig bool, trustedFiles, trustedDirs []string) []string {
 	var patterns []string
 
 	// Dangerous files - in CWD and all subdirectories
+	home, _ := os.UserHomeDir()
 	for _, f := range DangerousFiles {
+		if slices.Contains(trustedFiles, f) {
+			if home != "" {
+				patterns = append(patterns, filepath.Join(home, f))
+			}
+			continue
+		}
 		patterns = append(patterns, filepath.Join(cwd, f))
 		patterns = append(patterns, "**/"+f)
 	}
 
 	// Dangerous directories
 	for _, d := range DangerousDirectories {
+		if slices.Contains(trustedDirs, d) {
+			continue
+		}
 		patterns = append(patterns, filepath.Join(cwd, d))
 		patterns = append(patterns, "**/"+d+"/**")
 	}
@@ -306,11 +691,33 @@ func GetMandatoryDenyPatterns(cwd string, allowGitConfig bool) []string {
 	patterns = append(patterns, filepath.Join(cwd, ".git/hooks"))
 	patterns = append(patterns, "**/.git/hooks/**")
 
+	// The ignore file decides what later runs protect, so it cannot be trusted
+	patterns = append(patterns, filepath.Join(cwd, FenceIgnoreFile))
+
 	// Git config is conditionally blocked
 	if !allowGitConfig {
 		patterns = append(patterns, filepath.Join(cwd, ".git/config"))
 		patterns = append(patterns, "**/.git/config")
 	}
 
+	// Fence's own config is always blocked, regardless of allowWrite or allowGitConfig
+	patterns = append(patterns, GetFenceConfigPaths()...)
+
 	return patterns
 }
+
+// isTrustedDangerousPath reports whether path, a dangerous file or
+// directory found in the project, has a name in trustedFiles or trustedDirs.
+// A file matched by a glob entry such as "*.pem" is trusted when the entry
+// itself is.
+func isTrustedDangerousPath(path string, trustedFiles, trustedDirs []string) bool {
+	if entry, ok := dangerousFileEntry(filepath.Base(path)); ok && slices.Contains(trustedFiles, entry) {
+		return true
+	}
+	for _, d := range trustedDirs {
+		if slices.Contains(DangerousDirectories, d) && strings.HasSuffix(path, string(filepath.Separator)+d) {
+			return true
+		}
+	}
+	return false
+}
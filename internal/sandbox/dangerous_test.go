@@ -1,11 +1,17 @@
 package sandbox
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
 	"strings"
 	"testing"
+
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 func TestGetDefaultWritePaths(t *testing.T) {
@@ -24,6 +30,293 @@ func TestGetDefaultWritePaths(t *testing.T) {
 	}
 }
 
+func TestDefaultPathsIncludeXDGToolDirs(t *testing.T) {
+	dirs := map[string]string{}
+	for _, env := range []string{"XDG_CACHE_HOME", "XDG_STATE_HOME", "XDG_DATA_HOME", "XDG_RUNTIME_DIR"} {
+		dirs[env] = t.TempDir()
+		t.Setenv(env, dirs[env])
+	}
+
+	readable := GetDefaultReadablePaths()
+	for _, want := range []string{
+		filepath.Join(dirs["XDG_CACHE_HOME"], "pip"),
+		filepath.Join(dirs["XDG_DATA_HOME"], "pipx"),
+		filepath.Join(dirs["XDG_STATE_HOME"], "pnpm"),
+	} {
+		if !slices.Contains(readable, want) {
+			t.Errorf("GetDefaultReadablePaths() missing %q", want)
+		}
+	}
+
+	// The base directories themselves also hold credentials (keyrings,
+	// browser profiles, agent sockets), so none of them is exposed whole.
+	writable := GetDefaultWritePaths()
+	for env, dir := range dirs {
+		if slices.Contains(readable, dir) {
+			t.Errorf("GetDefaultReadablePaths() should not include all of %s", env)
+		}
+		if slices.Contains(writable, dir) {
+			t.Errorf("GetDefaultWritePaths() should not include %s", env)
+		}
+	}
+}
+
+func TestGetDefaultReadablePathsIncludesConda(t *testing.T) {
+	prefix := t.TempDir()
+	install := t.TempDir()
+	mambaRoot := t.TempDir()
+	t.Setenv("CONDA_PREFIX", prefix)
+	t.Setenv("CONDA_EXE", filepath.Join(install, "bin", "conda"))
+	t.Setenv("MAMBA_ROOT_PREFIX", mambaRoot)
+
+	paths := GetDefaultReadablePaths()
+	for _, want := range []string{prefix, install, mambaRoot} {
+		if !slices.Contains(paths, want) {
+			t.Errorf("GetDefaultReadablePaths() missing %q", want)
+		}
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, rel := range []string{"miniconda3", "anaconda3", "mambaforge", "opt/miniconda3"} {
+			if want := filepath.Join(home, rel); !slices.Contains(paths, want) {
+				t.Errorf("GetDefaultReadablePaths() missing %q", want)
+			}
+		}
+	}
+}
+
+func TestToolchainEnvPathsIgnoresHomeAndRoot(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		env, value string
+	}{
+		{"CONDA_PREFIX", "/"},
+		{"CONDA_PREFIX", home},
+		{"CONDA_PREFIX", home + "/"},
+		{"CONDA_EXE", filepath.Join(home, "bin", "conda")},
+		{"CONDA_EXE", "/bin/conda"},
+		{"MAMBA_ROOT_PREFIX", filepath.Dir(home)},
+		{"JAVA_HOME", home},
+		{"JAVA_HOME", "/"},
+		{"SDKMAN_DIR", filepath.Dir(home)},
+		{"DENO_DIR", home},
+		{"DENO_DIR", "/"},
+		{"BUN_INSTALL", home},
+		{"BUN_INSTALL", "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env+"="+tt.value, func(t *testing.T) {
+			for _, env := range []string{"CONDA_PREFIX", "CONDA_EXE", "MAMBA_ROOT_PREFIX", "JAVA_HOME", "SDKMAN_DIR", "BUN_INSTALL", "DENO_DIR"} {
+				t.Setenv(env, "")
+			}
+			t.Setenv(tt.env, tt.value)
+			for _, p := range toolchainEnvPaths() {
+				if containsHome(p, home) {
+					t.Errorf("toolchainEnvPaths() = %v, includes %q which exposes the home directory", toolchainEnvPaths(), p)
+				}
+			}
+		})
+	}
+
+	// Directories inside home are still added.
+	prefix := filepath.Join(home, "envs", "ml")
+	t.Setenv("CONDA_PREFIX", prefix)
+	if got := toolchainEnvPaths(); !slices.Contains(got, prefix) {
+		t.Errorf("toolchainEnvPaths() = %v, want %q", got, prefix)
+	}
+}
+
+func TestContainsHome(t *testing.T) {
+	tests := []struct {
+		dir  string
+		want bool
+	}{
+		{"/", true},
+		{"/home", true},
+		{"/home/user", true},
+		{"/home/user/.conda", false},
+		{"/home/username", false},
+		{"/opt/conda", false},
+	}
+	for _, tt := range tests {
+		if got := containsHome(tt.dir, "/home/user"); got != tt.want {
+			t.Errorf("containsHome(%q) = %v, want %v", tt.dir, got, tt.want)
+		}
+	}
+	if !containsHome("/", "") || containsHome("/opt", "") {
+		t.Error("containsHome without a home directory should only match the root")
+	}
+}
+
+func TestGetDefaultReadablePathsIncludesJVM(t *testing.T) {
+	javaHome := t.TempDir()
+	sdkman := t.TempDir()
+	t.Setenv("JAVA_HOME", javaHome)
+	t.Setenv("SDKMAN_DIR", sdkman)
+
+	paths := GetDefaultReadablePaths()
+	for _, want := range []string{javaHome, sdkman} {
+		if !slices.Contains(paths, want) {
+			t.Errorf("GetDefaultReadablePaths() missing %q", want)
+		}
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, rel := range []string{".gradle", ".m2", ".sdkman"} {
+			if want := filepath.Join(home, rel); !slices.Contains(paths, want) {
+				t.Errorf("GetDefaultReadablePaths() missing %q", want)
+			}
+		}
+	}
+
+	// System JVM installations are readable through their parent directories
+	for _, jvmDir := range []string{"/usr/lib/jvm", "/Library/Java/JavaVirtualMachines"} {
+		if !slices.ContainsFunc(paths, func(p string) bool { return isPathWithin(jvmDir, p, false) }) {
+			t.Errorf("GetDefaultReadablePaths() does not cover %q", jvmDir)
+		}
+	}
+}
+
+func TestGetDefaultReadablePathsIncludesGoCaches(t *testing.T) {
+	gopath := t.TempDir()
+	goCache := t.TempDir()
+	configHome := t.TempDir()
+	t.Setenv("GOPATH", gopath+string(filepath.ListSeparator)+"/other/gopath")
+	t.Setenv("GOMODCACHE", "")
+	t.Setenv("GOCACHE", goCache)
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	paths := GetDefaultReadablePaths()
+	want := []string{filepath.Join(gopath, "pkg", "mod"), goCache}
+	if runtime.GOOS == "linux" {
+		want = append(want, filepath.Join(configHome, "go"))
+	}
+	for _, p := range want {
+		if !slices.Contains(paths, p) {
+			t.Errorf("GetDefaultReadablePaths() missing %q", p)
+		}
+	}
+}
+
+func TestGoCachePaths(t *testing.T) {
+	tests := []struct {
+		name       string
+		gopath     string
+		goModCache string
+		goCache    string
+		want       []string
+		notWant    []string
+	}{
+		{
+			name:    "GOPATH defaults to ~/go",
+			want:    []string{"/home/user/go/pkg/mod"},
+			notWant: []string{"/gopath/pkg/mod"},
+		},
+		{
+			name:       "GOMODCACHE overrides GOPATH",
+			gopath:     "/gopath",
+			goModCache: "/modcache",
+			want:       []string{"/modcache"},
+			notWant:    []string{"/gopath/pkg/mod"},
+		},
+		{
+			name:    "GOCACHE off is skipped",
+			gopath:  "/gopath",
+			goCache: "off",
+			want:    []string{"/gopath/pkg/mod"},
+			notWant: []string{"off"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GOPATH", tt.gopath)
+			t.Setenv("GOMODCACHE", tt.goModCache)
+			t.Setenv("GOCACHE", tt.goCache)
+
+			got := goCachePaths("/home/user")
+			for _, p := range tt.want {
+				if !slices.Contains(got, p) {
+					t.Errorf("goCachePaths() = %v, missing %q", got, p)
+				}
+			}
+			for _, p := range tt.notWant {
+				if slices.Contains(got, p) {
+					t.Errorf("goCachePaths() = %v, should not contain %q", got, p)
+				}
+			}
+		})
+	}
+}
+
+func TestGetDefaultReadablePathsIncludesDenoAndBun(t *testing.T) {
+	denoDir := t.TempDir()
+	bunInstall := t.TempDir()
+	t.Setenv("DENO_DIR", denoDir)
+	t.Setenv("BUN_INSTALL", bunInstall)
+
+	paths := GetDefaultReadablePaths()
+	want := []string{denoDir, filepath.Join(bunInstall, "bin")}
+	if home, err := os.UserHomeDir(); err == nil {
+		want = append(want, filepath.Join(home, ".bun/bin"))
+		if slices.Contains(paths, filepath.Join(home, ".bun")) {
+			t.Error("GetDefaultReadablePaths() should only include ~/.bun/bin")
+		}
+	}
+	for _, p := range want {
+		if !slices.Contains(paths, p) {
+			t.Errorf("GetDefaultReadablePaths() missing %q", p)
+		}
+	}
+	if slices.Contains(paths, bunInstall) {
+		t.Errorf("GetDefaultReadablePaths() should only include bin of BUN_INSTALL %q", bunInstall)
+	}
+
+	// Without DENO_DIR, Deno's default cache in the user cache directory
+	cacheHome := t.TempDir()
+	t.Setenv("DENO_DIR", "")
+	t.Setenv("XDG_CACHE_HOME", cacheHome)
+	if runtime.GOOS == "linux" {
+		if p := filepath.Join(cacheHome, "deno"); !slices.Contains(GetDefaultReadablePaths(), p) {
+			t.Errorf("GetDefaultReadablePaths() missing default DENO_DIR %q", p)
+		}
+	}
+}
+
+func TestToolchainEnvPathsIgnoresRelative(t *testing.T) {
+	for _, env := range []string{"CONDA_EXE", "MAMBA_ROOT_PREFIX", "JAVA_HOME", "SDKMAN_DIR", "BUN_INSTALL"} {
+		t.Setenv(env, "")
+	}
+	t.Setenv("CONDA_PREFIX", "envs/base")
+	t.Setenv("DENO_DIR", "deno")
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("HOME", "")
+	if got := toolchainEnvPaths(); len(got) != 0 {
+		t.Errorf("toolchainEnvPaths() = %v, want none", got)
+	}
+}
+
+func TestXDGDir(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"absolute", "/data/cache/", "/data/cache"},
+		{"unset", "", "/home/user/.cache"},
+		{"relative is ignored", "cache", "/home/user/.cache"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", tt.value)
+			if got := xdgDir("XDG_CACHE_HOME", "/home/user/.cache"); got != tt.want {
+				t.Errorf("xdgDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGetMandatoryDenyPatterns(t *testing.T) {
 	cwd := "/home/user/project"
 
@@ -67,7 +360,7 @@ func TestGetMandatoryDenyPatterns(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			patterns := GetMandatoryDenyPatterns(tt.cwd, tt.allowGitConfig)
+			patterns := GetMandatoryDenyPatterns(tt.cwd, tt.allowGitConfig, nil, nil)
 
 			for _, expected := range tt.shouldContain {
 				found := slices.Contains(patterns, expected)
@@ -88,7 +381,7 @@ func TestGetMandatoryDenyPatterns(t *testing.T) {
 
 func TestGetMandatoryDenyPatternsContainsDangerousFiles(t *testing.T) {
 	cwd := "/test/project"
-	patterns := GetMandatoryDenyPatterns(cwd, false)
+	patterns := GetMandatoryDenyPatterns(cwd, false, nil, nil)
 
 	// Each dangerous file should appear both as a cwd-relative path and as a glob pattern
 	for _, file := range DangerousFiles {
@@ -114,11 +407,25 @@ func TestGetMandatoryDenyPatternsContainsDangerousFiles(t *testing.T) {
 			t.Errorf("Missing glob pattern for dangerous file %q", file)
 		}
 	}
+
+	// Glob entries are kept as globs, so they cover every matching name
+	for _, rel := range []string{".env.staging", "deploy/.env.production", "certs/server.pem", "a/b/tls.key", "secrets.gpg"} {
+		covered := false
+		for _, p := range patterns {
+			if matched, _ := doublestar.Match(p, filepath.Join(cwd, rel)); matched {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			t.Errorf("No pattern covers secrets file %q", rel)
+		}
+	}
 }
 
 func TestGetMandatoryDenyPatternsContainsDangerousDirectories(t *testing.T) {
 	cwd := "/test/project"
-	patterns := GetMandatoryDenyPatterns(cwd, false)
+	patterns := GetMandatoryDenyPatterns(cwd, false, nil, nil)
 
 	for _, dir := range DangerousDirectories {
 		cwdPath := filepath.Join(cwd, dir)
@@ -145,12 +452,70 @@ func TestGetMandatoryDenyPatternsContainsDangerousDirectories(t *testing.T) {
 	}
 }
 
+func TestGetMandatoryDenyPatternsTrusted(t *testing.T) {
+	cwd := "/home/user/project"
+	patterns := GetMandatoryDenyPatterns(cwd, false, []string{".bashrc"}, []string{".vscode"})
+
+	for _, trusted := range []string{
+		filepath.Join(cwd, ".bashrc"),
+		"**/.bashrc",
+		filepath.Join(cwd, ".vscode"),
+		"**/.vscode/**",
+	} {
+		if slices.Contains(patterns, trusted) {
+			t.Errorf("GetMandatoryDenyPatterns() should not contain trusted pattern %q", trusted)
+		}
+	}
+	for _, kept := range []string{filepath.Join(cwd, ".zshrc"), "**/.zshrc", "**/.idea/**", "**/.git/hooks/**"} {
+		if !slices.Contains(patterns, kept) {
+			t.Errorf("GetMandatoryDenyPatterns() missing pattern %q", kept)
+		}
+	}
+
+	// The home directory copy stays protected
+	if home, err := os.UserHomeDir(); err == nil {
+		if want := filepath.Join(home, ".bashrc"); !slices.Contains(patterns, want) {
+			t.Errorf("GetMandatoryDenyPatterns() missing home pattern %q", want)
+		}
+	}
+}
+
+func TestGetMandatoryDenyPatternsFenceIgnoreAlwaysBlocked(t *testing.T) {
+	cwd := "/home/user/project"
+	patterns := GetMandatoryDenyPatterns(cwd, true, []string{FenceIgnoreFile}, nil)
+	if want := filepath.Join(cwd, FenceIgnoreFile); !slices.Contains(patterns, want) {
+		t.Errorf("GetMandatoryDenyPatterns() missing %q when trusted", want)
+	}
+}
+
+func TestIsTrustedDangerousPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/p/testdata/.bashrc", true},
+		{"/p/testdata/.zshrc", false},
+		{"/p/sub/.claude/commands", true},
+		{"/p/sub/.claude/agents", false},
+		{"/p/sub/.git/config", false},
+		{"/p/certs/server.pem", true},
+		{"/p/certs/server.key", false},
+		{"/p/server.pem.txt", false},
+	}
+	for _, tt := range tests {
+		got := isTrustedDangerousPath(tt.path, []string{".bashrc", "config", "*.pem"}, []string{".claude/commands"})
+		if got != tt.want {
+			t.Errorf("isTrustedDangerousPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
 func TestGetMandatoryDenyPatternsGitHooksAlwaysBlocked(t *testing.T) {
 	cwd := "/test/project"
 
 	// Git hooks should be blocked regardless of allowGitConfig
 	for _, allowGitConfig := range []bool{true, false} {
-		patterns := GetMandatoryDenyPatterns(cwd, allowGitConfig)
+		patterns := GetMandatoryDenyPatterns(cwd, allowGitConfig, nil, nil)
 
 		foundHooksPath := false
 		foundHooksGlob := false
@@ -222,11 +587,17 @@ func TestFindDangerousFiles(t *testing.T) {
 	// on a path boundary (e.g. "not.claude/commands" should NOT match ".claude/commands")
 	mkdir("sub/not.claude/commands")
 
+	// Secrets files, matched by name and by glob entries
+	mkfile("subdir/.env")
+	mkfile("subdir/.env.staging")
+	mkfile("a/certs/server.pem")
+	mkfile("a/certs/server.pem.txt")
+
 	// Safe file — should not appear
 	mkfile("subdir/safe.txt")
 
 	t.Run("depth 3 finds nested dangerous files but not beyond limit", func(t *testing.T) {
-		results := FindDangerousFiles(tmpDir, 3)
+		results := FindDangerousFiles(tmpDir, 3, nil)
 
 		shouldFind := []string{
 			filepath.Join(tmpDir, "subdir/.zshrc"),
@@ -235,9 +606,14 @@ func TestFindDangerousFiles(t *testing.T) {
 			filepath.Join(tmpDir, "subdir/.vscode"),
 			filepath.Join(tmpDir, "a/.git/hooks"),
 			filepath.Join(tmpDir, "a/.git/config"),
+			filepath.Join(tmpDir, "subdir/.env"),
+			filepath.Join(tmpDir, "subdir/.env.staging"),
+			filepath.Join(tmpDir, "a/certs/server.pem"),
 		}
 
 		shouldNotFind := []string{
+			// globs match whole names only
+			filepath.Join(tmpDir, "a/certs/server.pem.txt"),
 			// depth 0 files are not returned (cwd-level files are added separately)
 			filepath.Join(tmpDir, ".bashrc"),
 			// depth 4 is beyond limit
@@ -264,7 +640,7 @@ func TestFindDangerousFiles(t *testing.T) {
 	})
 
 	t.Run("depth 1 only finds immediate subdirectory files", func(t *testing.T) {
-		results := FindDangerousFiles(tmpDir, 1)
+		results := FindDangerousFiles(tmpDir, 1, nil)
 
 		shouldFind := []string{
 			filepath.Join(tmpDir, "subdir/.zshrc"),
@@ -292,9 +668,303 @@ func TestFindDangerousFiles(t *testing.T) {
 	})
 
 	t.Run("depth 0 returns nothing", func(t *testing.T) {
-		results := FindDangerousFiles(tmpDir, 0)
+		results := FindDangerousFiles(tmpDir, 0, nil)
 		if len(results) != 0 {
 			t.Errorf("FindDangerousFiles(depth=0) should return empty, got %v", results)
 		}
 	})
 }
+
+func TestFindDangerousFiles_SkippedDirectories(t *testing.T) {
+	tmpDir := t.TempDir()
+	mkfile := func(rel string) {
+		abs := filepath.Join(tmpDir, rel)
+		if err := os.MkdirAll(filepath.Dir(abs), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(abs, []byte("test"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mkfile("vendor/github.com/foo/.bashrc")
+	mkfile("vendor/.vscode/settings.json")
+	mkfile(".venv/lib/.profile")
+	mkfile("svc/target/.zshrc")
+	mkfile("svc/__pycache__/.bashrc")
+	mkfile("infra/.terraform/.bashrc")
+	mkfile("vendored/.bashrc") // only exact names are skipped
+	mkfile("custom_deps/.bashrc")
+
+	results := FindDangerousFiles(tmpDir, 3, nil)
+
+	for _, notWant := range []string{
+		filepath.Join(tmpDir, "vendor/github.com/foo/.bashrc"),
+		filepath.Join(tmpDir, "vendor/.vscode"),
+		filepath.Join(tmpDir, ".venv/lib/.profile"),
+		filepath.Join(tmpDir, "svc/target/.zshrc"),
+		filepath.Join(tmpDir, "svc/__pycache__/.bashrc"),
+		filepath.Join(tmpDir, "infra/.terraform/.bashrc"),
+	} {
+		if slices.Contains(results, notWant) {
+			t.Errorf("FindDangerousFiles() should skip %q", notWant)
+		}
+	}
+	for _, want := range []string{
+		filepath.Join(tmpDir, "vendored/.bashrc"),
+		filepath.Join(tmpDir, "custom_deps/.bashrc"),
+	} {
+		if !slices.Contains(results, want) {
+			t.Errorf("FindDangerousFiles() should find %q.\nGot: %v", want, results)
+		}
+	}
+
+	orig := slices.Clone(SkippedDirectories)
+	t.Cleanup(func() { SkippedDirectories = orig })
+
+	RegisterSkippedDirectory("custom_deps")
+	RegisterSkippedDirectory("custom_deps")
+	RegisterSkippedDirectory("")
+	if n := len(SkippedDirectories); n != len(orig)+1 {
+		t.Errorf("expected one directory to be registered, got %v", SkippedDirectories)
+	}
+
+	results = FindDangerousFiles(tmpDir, 3, nil)
+	if slices.Contains(results, filepath.Join(tmpDir, "custom_deps/.bashrc")) {
+		t.Error("FindDangerousFiles() should skip registered directory custom_deps")
+	}
+}
+
+func TestFindDangerousFiles_FenceIgnore(t *testing.T) {
+	tmpDir := t.TempDir()
+	mkfile := func(rel, content string) {
+		abs := filepath.Join(tmpDir, rel)
+		if err := os.MkdirAll(filepath.Dir(abs), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(abs, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mkfile("test/fixtures/.gitconfig", "test")
+	mkfile("testdata/repo/.bashrc", "test")
+	mkfile("testdata/keep/.bashrc", "test")
+	mkfile("src/.gitconfig", "test")
+	mkfile(FenceIgnoreFile, "# checked-in fixtures\ntest/fixtures/.gitconfig\ntestdata/\n!testdata/keep/\n")
+
+	ignore, err := ParseFenceIgnore(filepath.Join(tmpDir, FenceIgnoreFile))
+	if err != nil {
+		t.Fatalf("ParseFenceIgnore() error = %v", err)
+	}
+	results := FindDangerousFiles(tmpDir, 3, ignore)
+
+	// A negated child cannot re-include a file under an ignored directory
+	for _, notWant := range []string{"test/fixtures/.gitconfig", "testdata/repo/.bashrc", "testdata/keep/.bashrc"} {
+		if slices.Contains(results, filepath.Join(tmpDir, notWant)) {
+			t.Errorf("FindDangerousFiles() should ignore %q.\nGot: %v", notWant, results)
+		}
+	}
+	if want := filepath.Join(tmpDir, "src/.gitconfig"); !slices.Contains(results, want) {
+		t.Errorf("FindDangerousFiles() should find %q.\nGot: %v", want, results)
+	}
+}
+
+func TestFindDangerousFilesContext(t *testing.T) {
+	tmpDir := t.TempDir()
+	for i := range 20 {
+		abs := filepath.Join(tmpDir, fmt.Sprintf("pkg%02d", i), ".bashrc")
+		if err := os.MkdirAll(filepath.Dir(abs), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(abs, []byte("test"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	all := FindDangerousFiles(tmpDir, 3, nil)
+	if len(all) != 20 {
+		t.Fatalf("FindDangerousFiles() found %d files, want 20", len(all))
+	}
+
+	t.Run("cancelled mid-walk", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// SkipDir is consulted for each directory, so it can cancel the walk
+		// part of the way through.
+		opts := ScanOptions{SkipDir: func(name string) bool {
+			if name == "pkg10" {
+				cancel()
+			}
+			return false
+		}}
+		results, truncated, err := FindDangerousFilesContext(ctx, tmpDir, 3, opts)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error = %v, want context.Canceled", err)
+		}
+		if !truncated {
+			t.Error("truncated = false, want true")
+		}
+		if len(results) == 0 || len(results) >= len(all) {
+			t.Errorf("got %d results, want a partial list", len(results))
+		}
+	})
+
+	t.Run("scan limit", func(t *testing.T) {
+		results, truncated, err := FindDangerousFilesContext(context.Background(), tmpDir, 3, ScanOptions{MaxFilesScanned: 10})
+		if err != nil {
+			t.Errorf("error = %v", err)
+		}
+		if !truncated {
+			t.Error("truncated = false, want true")
+		}
+		if len(results) == 0 || len(results) >= len(all) {
+			t.Errorf("got %d results, want a partial list", len(results))
+		}
+	})
+
+	t.Run("skip dir", func(t *testing.T) {
+		results, truncated, err := FindDangerousFilesContext(context.Background(), tmpDir, 3, ScanOptions{
+			SkipDir: func(name string) bool { return name == "pkg03" },
+		})
+		if err != nil || truncated {
+			t.Errorf("truncated = %v, error = %v", truncated, err)
+		}
+		if len(results) != len(all)-1 || slices.Contains(results, filepath.Join(tmpDir, "pkg03", ".bashrc")) {
+			t.Errorf("results = %v, want all but pkg03", results)
+		}
+	})
+}
+
+// makeDangerousScanTree creates dirs project directories, each with a few
+// nested levels, some dangerous files and a nested git repo.
+func makeDangerousScanTree(tb testing.TB, dirs int) string {
+	tb.Helper()
+	root := tb.TempDir()
+	for i := range dirs {
+		pkg := filepath.Join(root, fmt.Sprintf("pkg%03d", i))
+		files := []string{"src/main.go", "src/lib/util.go", "README.md"}
+		switch i % 5 {
+		case 0:
+			files = append(files, ".bashrc", "src/lib/.zshrc")
+		case 1:
+			files = append(files, ".vscode/settings.json", ".git/config")
+		case 2:
+			files = append(files, "node_modules/dep/.bashrc")
+		}
+		for _, f := range files {
+			abs := filepath.Join(pkg, f)
+			if err := os.MkdirAll(filepath.Dir(abs), 0o750); err != nil {
+				tb.Fatal(err)
+			}
+			if err := os.WriteFile(abs, []byte("test"), 0o600); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+func TestFindDangerousFilesParallelMatchesSequential(t *testing.T) {
+	root := makeDangerousScanTree(t, 50)
+	ignore := IgnoreRules{{pattern: "pkg007", dirOnly: true}}
+
+	for _, opts := range []ScanOptions{{}, {Ignore: ignore}} {
+		sequential, _, _ := findDangerousFiles(context.Background(), root, 3, opts, 1)
+		parallel, truncated, err := findDangerousFiles(context.Background(), root, 3, opts, 4)
+		if err != nil || truncated {
+			t.Fatalf("parallel scan: truncated = %v, error = %v", truncated, err)
+		}
+		if len(sequential) == 0 {
+			t.Fatal("sequential scan found nothing")
+		}
+		if !slices.Equal(parallel, sequential) {
+			t.Errorf("parallel results differ from sequential:\nparallel:   %v\nsequential: %v", parallel, sequential)
+		}
+	}
+
+	// Fewer than parallelScanMinDirs subdirectories use a single walk
+	small := makeDangerousScanTree(t, parallelScanMinDirs-1)
+	sequential, _, _ := findDangerousFiles(context.Background(), small, 3, ScanOptions{}, 1)
+	parallel, _, _ := findDangerousFiles(context.Background(), small, 3, ScanOptions{}, 4)
+	if !slices.Equal(parallel, sequential) {
+		t.Errorf("results differ for a small tree: %v vs %v", parallel, sequential)
+	}
+}
+
+func TestIgnoreRulesMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"exact path", []string{"test/fixtures/.gitconfig"}, "test/fixtures/.gitconfig", false, true},
+		{"name matches at any depth", []string{".bashrc"}, "a/b/.bashrc", false, true},
+		{"anchored path only at root", []string{"/fixtures"}, "a/fixtures", true, false},
+		{"parent directory ignored", []string{"fixtures/"}, "test/fixtures/repo/.gitconfig", false, true},
+		{"directory-only pattern skips files", []string{"fixtures/"}, "test/fixtures", false, false},
+		{"glob", []string{"test/**/.zshrc"}, "test/a/b/.zshrc", false, true},
+		{"later negation wins", []string{"*.json", "!.mcp.json"}, "sub/.mcp.json", false, false},
+		{"comments and blanks", []string{"# .bashrc", "", "   "}, "sub/.bashrc", false, false},
+		{"escaped hash", []string{`\#notes`}, "sub/#notes", false, true},
+		{"no rules", nil, "sub/.bashrc", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules IgnoreRules
+			for _, line := range tt.lines {
+				if rule, ok := parseIgnoreLine(line); ok {
+					rules = append(rules, rule)
+				}
+			}
+			if got := rules.Match(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMandatoryDenyPatternsContainsFenceConfig(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("home directory not available")
+	}
+
+	for _, allowGitConfig := range []bool{false, true} {
+		patterns := GetMandatoryDenyPatterns("/test/project", allowGitConfig, nil, nil)
+		for _, want := range []string{
+			filepath.Join(home, ".config", "fence"),
+			filepath.Join(home, ".fence"),
+		} {
+			if !slices.Contains(patterns, want) {
+				t.Errorf("GetMandatoryDenyPatterns(allowGitConfig=%v) missing fence config path %q", allowGitConfig, want)
+			}
+		}
+	}
+}
+
+func TestFenceConfigDenyOverridesAllowWrite(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("home directory not available")
+	}
+	fenceDir := NormalizePath(filepath.Join(home, ".fence"))
+
+	rules := strings.Join(generateWriteRules([]string{"~/.fence"}, nil, true, nil, nil, "test"), "\n")
+
+	allowRule := "(allow file-write*\n  (subpath " + escapePath(fenceDir) + ")"
+	denyRule := "(deny file-write*\n  (subpath " + escapePath(fenceDir) + ")"
+
+	allowIdx := strings.Index(rules, allowRule)
+	denyIdx := strings.Index(rules, denyRule)
+	if allowIdx == -1 {
+		t.Fatalf("expected allow rule for %s, got:\n%s", fenceDir, rules)
+	}
+	if denyIdx == -1 {
+		t.Fatalf("expected mandatory deny rule for %s, got:\n%s", fenceDir, rules)
+	}
+	if denyIdx < allowIdx {
+		t.Errorf("mandatory deny for %s must come after allowWrite so it takes precedence", fenceDir)
+	}
+}
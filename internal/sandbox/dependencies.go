@@ -0,0 +1,114 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/Use-Tusk/fence/internal/config"
+	"github.com/Use-Tusk/fence/internal/platform"
+)
+
+// dependency is an external program the sandbox runs.
+type dependency struct {
+	name string // Binary looked up in PATH
+	why  string
+	hint string // How to install it
+}
+
+var (
+	bwrapDependency = dependency{
+		name: "bwrap",
+		why:  "the Linux sandbox runs commands in bubblewrap",
+		hint: "install bubblewrap (apt install bubblewrap, dnf install bubblewrap or pacman -S bubblewrap)",
+	}
+	socatDependency = dependency{
+		name: "socat",
+		why:  "the Linux sandbox reaches the network proxy through socat bridges",
+		hint: "install socat (apt install socat, dnf install socat or pacman -S socat)",
+	}
+	sandboxExecDependency = dependency{
+		name: "sandbox-exec",
+		why:  "the macOS sandbox runs commands with sandbox-exec",
+		hint: "sandbox-exec ships with macOS in /usr/bin; make sure /usr/bin is in PATH",
+	}
+)
+
+// MissingDependencyError reports a required program that is not in PATH.
+type MissingDependencyError struct {
+	Name string
+	Why  string
+	Hint string
+}
+
+func (e *MissingDependencyError) Error() string {
+	return fmt.Sprintf("%s not found in PATH (%s); %s", e.Name, e.Why, e.Hint)
+}
+
+// VerifyDependencies checks that the programs the sandbox needs for cfg and
+// the exposed inbound ports on this platform are installed, so a missing
+// one is reported before anything runs. It returns every missing program,
+// joined with errors.Join.
+func VerifyDependencies(cfg *config.Config, exposedPorts []int) error {
+	return verifyDependencies(cfg, exposedPorts, platform.Detect())
+}
+
+func verifyDependencies(cfg *config.Config, exposedPorts []int, plat platform.Type) error {
+	var errs []error
+	for _, dep := range requiredDependencies(cfg, exposedPorts, plat) {
+		if _, err := exec.LookPath(dep.name); err != nil {
+			errs = append(errs, &MissingDependencyError{Name: dep.name, Why: dep.why, Hint: dep.hint})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// requiredDependencies lists the programs the sandbox runs for cfg and
+// exposedPorts. Audit mode runs commands without the OS sandbox, so it
+// needs none.
+func requiredDependencies(cfg *config.Config, exposedPorts []int, plat platform.Type) []dependency {
+	if cfg.AuditMode() {
+		return nil
+	}
+	switch plat {
+	case platform.Linux:
+		// The manager bridges the proxies into the sandbox's network
+		// namespace, and exposed ports out of it, with socat, unless no
+		// network access is configured at all and the namespace alone
+		// blocks everything.
+		if len(exposedPorts) == 0 && socatOptional(cfg) {
+			return []dependency{bwrapDependency}
+		}
+		return []dependency{bwrapDependency, socatDependency}
+	case platform.MacOS:
+		return []dependency{sandboxExecDependency}
+	default:
+		return nil
+	}
+}
+
+// canIsolateNetwork reports whether the sandbox gets its own network
+// namespace. Tests override it.
+var canIsolateNetwork = func() bool {
+	return DetectLinuxFeatures().CanUnshareNet
+}
+
+// networkRulesEmpty reports whether cfg configures no network access: no
+// allowed (including allowACMEChallenge's) or denied domains and no
+// upstream proxy ports.
+func networkRulesEmpty(cfg *config.Config) bool {
+	if cfg == nil {
+		return true
+	}
+	n := cfg.Network
+	return len(n.EffectiveAllowedDomains()) == 0 && len(n.DeniedDomains) == 0 &&
+		n.HTTPProxyPort == 0 && n.SOCKSProxyPort == 0
+}
+
+// socatOptional reports whether the Linux sandbox can run without the socat
+// bridges: with no network access configured, an isolated network namespace
+// blocks every connection without the proxies. Without a namespace the
+// command would share the host network, so the bridges stay required.
+func socatOptional(cfg *config.Config) bool {
+	return networkRulesEmpty(cfg) && canIsolateNetwork()
+}
@@ -0,0 +1,128 @@
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Use-Tusk/fence/internal/config"
+	"github.com/Use-Tusk/fence/internal/platform"
+)
+
+// fakePath creates executables named names in a temp directory and makes it
+// the only entry in PATH.
+func fakePath(t *testing.T, names ...string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o755); err != nil { //nolint:gosec // test executable
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+}
+
+func missingNames(err error) []string {
+	var names []string
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			var missing *MissingDependencyError
+			if errors.As(e, &missing) {
+				names = append(names, missing.Name)
+			}
+		}
+	}
+	return names
+}
+
+// isolateNetwork sets whether the sandbox appears to get its own network
+// namespace.
+func isolateNetwork(t *testing.T, isolated bool) {
+	t.Helper()
+	orig := canIsolateNetwork
+	canIsolateNetwork = func() bool { return isolated }
+	t.Cleanup(func() { canIsolateNetwork = orig })
+}
+
+func TestVerifyDependencies(t *testing.T) {
+	isolateNetwork(t, false)
+
+	tests := []struct {
+		name      string
+		plat      platform.Type
+		cfg       *config.Config
+		installed []string
+		want      []string // Missing programs
+	}{
+		{"linux with everything", platform.Linux, config.Default(), []string{"bwrap", "socat"}, nil},
+		{"linux without anything", platform.Linux, config.Default(), nil, []string{"bwrap", "socat"}},
+		{"linux without socat", platform.Linux, &config.Config{Network: config.NetworkConfig{AllowedDomains: []string{"github.com"}}}, []string{"bwrap"}, []string{"socat"}},
+		{"nil config", platform.Linux, nil, []string{"socat"}, []string{"bwrap"}},
+		{"macos", platform.MacOS, config.Default(), nil, []string{"sandbox-exec"}},
+		{"macos with sandbox-exec", platform.MacOS, config.Default(), []string{"sandbox-exec"}, nil},
+		{"audit mode needs nothing", platform.Linux, &config.Config{EnforcementMode: config.EnforcementModeAudit}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakePath(t, tt.installed...)
+			err := verifyDependencies(tt.cfg, nil, tt.plat)
+			if got := missingNames(err); !slices.Equal(got, tt.want) {
+				t.Errorf("missing = %v, want %v (error: %v)", got, tt.want, err)
+			}
+			if (err != nil) != (len(tt.want) > 0) {
+				t.Errorf("verifyDependencies() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyDependenciesSocatOptional(t *testing.T) {
+	isolateNetwork(t, true)
+	fakePath(t)
+
+	// No network access configured: the namespace blocks everything
+	if got := missingNames(verifyDependencies(config.Default(), nil, platform.Linux)); !slices.Equal(got, []string{"bwrap"}) {
+		t.Errorf("missing = %v, want [bwrap]", got)
+	}
+
+	withDomains := &config.Config{Network: config.NetworkConfig{AllowedDomains: []string{"github.com"}}}
+	if got := missingNames(verifyDependencies(withDomains, nil, platform.Linux)); !slices.Equal(got, []string{"bwrap", "socat"}) {
+		t.Errorf("missing = %v, want [bwrap socat]", got)
+	}
+
+	withACME := &config.Config{Network: config.NetworkConfig{AllowACMEChallenge: true}}
+	if got := missingNames(verifyDependencies(withACME, nil, platform.Linux)); !slices.Equal(got, []string{"bwrap", "socat"}) {
+		t.Errorf("missing = %v, want [bwrap socat] with allowACMEChallenge", got)
+	}
+
+	if got := missingNames(verifyDependencies(config.Default(), []int{3000}, platform.Linux)); !slices.Equal(got, []string{"bwrap", "socat"}) {
+		t.Errorf("missing = %v, want [bwrap socat] with an exposed port", got)
+	}
+
+	withProxy := &config.Config{Network: config.NetworkConfig{HTTPProxyPort: 8080}}
+	if !slices.Contains(missingNames(verifyDependencies(withProxy, nil, platform.Linux)), "socat") {
+		t.Error("socat should be required with a fixed proxy port")
+	}
+}
+
+func TestVerifyDependenciesMessages(t *testing.T) {
+	isolateNetwork(t, false)
+	fakePath(t)
+	err := verifyDependencies(config.Default(), nil, platform.Linux)
+	if err == nil {
+		t.Fatal("expected an error with an empty PATH")
+	}
+	msg := err.Error()
+	for _, want := range []string{"bwrap not found", "apt install bubblewrap", "socat not found", "apt install socat"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q should mention %q", msg, want)
+		}
+	}
+	if strings.Count(msg, "\n") != 1 {
+		t.Errorf("error should list one missing program per line:\n%s", msg)
+	}
+}
@@ -0,0 +1,108 @@
+package sandbox
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// FenceIgnoreFile is the name of the file in the working directory that
+// lists paths FindDangerousFiles should not report, such as test fixtures.
+const FenceIgnoreFile = ".fenceignore"
+
+// IgnoreRules is a parsed .fenceignore file. A nil IgnoreRules ignores
+// nothing.
+type IgnoreRules []ignoreRule
+
+type ignoreRule struct {
+	pattern string // doublestar pattern, relative to the root
+	negate  bool   // "!pattern" re-includes paths
+	dirOnly bool   // "pattern/" only matches directories
+}
+
+// ParseFenceIgnore reads ignore rules from path. The file uses gitignore
+// syntax: blank lines and lines starting with # are skipped, a leading !
+// negates a pattern, a trailing / matches only directories, and patterns
+// without a slash (other than a trailing one) match at any depth.
+func ParseFenceIgnore(path string) (IgnoreRules, error) {
+	f, err := os.Open(path) //nolint:gosec // user-provided ignore file
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var rules IgnoreRules
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	if strings.Contains(line, "/") {
+		// Anchored to the root
+		rule.pattern = strings.TrimPrefix(line, "/")
+	} else {
+		rule.pattern = "**/" + line
+	}
+	return rule, true
+}
+
+// Match reports whether rel, a path relative to the root, is ignored. As in
+// git, a path is ignored when its last matching rule is not negated, or when
+// any of its parent directories is ignored.
+func (r IgnoreRules) Match(rel string, isDir bool) bool {
+	if len(r) == 0 {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if r.match(dir, true) {
+			return true
+		}
+	}
+	return r.match(rel, isDir)
+}
+
+func (r IgnoreRules) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range r {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matched, _ := doublestar.Match(rule.pattern, rel); matched {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
@@ -6,9 +6,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/Use-Tusk/fence/internal/config"
 )
 
 // ============================================================================
@@ -605,3 +608,240 @@ func TestLinux_GlobPatternAllowsWriteToMatchingFile(t *testing.T) {
 func lookPathLinux(cmd string) (string, error) {
 	return exec.LookPath(cmd)
 }
+
+// TestLinux_ShellLoginSourcesProfile verifies that login shell mode sources
+// ~/.profile inside the sandbox, and that the default mode does not.
+func TestLinux_ShellLoginSourcesProfile(t *testing.T) {
+	skipIfAlreadySandboxed(t)
+	skipIfCommandNotFound(t, "bash")
+	skipIfCommandNotFound(t, "bwrap")
+	skipIfCommandNotFound(t, "socat")
+
+	home := createTempWorkspace(t)
+	createTestFile(t, home, ".profile", "export FENCE_LOGIN_TEST=from-profile\n")
+	t.Setenv("HOME", home)
+
+	for _, login := range []bool{true, false} {
+		manager := NewManager(testConfig(), false, false)
+		manager.SetShellOptions(ShellModeDefault, "", login)
+
+		if err := manager.Initialize(); err != nil {
+			manager.Cleanup()
+			t.Fatalf("sandbox initialization failed: %v", err)
+		}
+		wrappedCmd, err := manager.WrapCommand(`echo "value=$FENCE_LOGIN_TEST"`)
+		if err != nil {
+			manager.Cleanup()
+			t.Fatalf("failed to wrap command: %v", err)
+		}
+		result := executeShellCommand(t, wrappedCmd, home)
+		manager.Cleanup()
+
+		assertAllowed(t, result)
+		if login {
+			assertContains(t, result.Stdout, "value=from-profile")
+		} else if strings.Contains(result.Stdout, "from-profile") {
+			t.Errorf("expected non-login shell not to source ~/.profile, got stdout: %s", result.Stdout)
+		}
+	}
+}
+
+// TestLinux_RenameIntoDenyWriteBlocked verifies that writing a file in an
+// allowed location and renaming it onto a denyWrite path is blocked.
+func TestLinux_RenameIntoDenyWriteBlocked(t *testing.T) {
+	skipIfAlreadySandboxed(t)
+	skipIfCommandNotFound(t, "bwrap")
+	skipIfCommandNotFound(t, "socat")
+
+	workspace := createTempWorkspace(t)
+	protectedDir := filepath.Join(workspace, "protected")
+	if err := os.MkdirAll(protectedDir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	protectedFile := createTestFile(t, protectedDir, "settings.json", "original")
+
+	cfg := testConfigWithWorkspace(workspace)
+	cfg.Filesystem.AllowWrite = append(cfg.Filesystem.AllowWrite, "/tmp/fence")
+	cfg.Filesystem.DenyWrite = []string{protectedDir}
+
+	tmpFile := "/tmp/fence/rename-" + filepath.Base(workspace)
+	t.Cleanup(func() { _ = os.Remove(tmpFile) })
+
+	for _, target := range []string{protectedFile, filepath.Join(protectedDir, "new.json")} {
+		result := runUnderSandbox(t, cfg, "mkdir -p /tmp/fence && echo malicious > "+tmpFile+" && mv -f "+tmpFile+" "+target, workspace)
+		assertBlocked(t, result)
+	}
+
+	if content, _ := os.ReadFile(protectedFile); string(content) != "original" { //nolint:gosec
+		t.Errorf("protected file was replaced: %q", content)
+	}
+	assertFileNotExists(t, filepath.Join(protectedDir, "new.json"))
+}
+
+// RunInBwrapForTest runs command under the bwrap sandbox from the current
+// directory and returns its output. Commands rejected by the command policy
+// report exit code 1 with the policy error on stderr. The test is skipped
+// when bwrap or socat (used for the proxy bridge) is not installed.
+func RunInBwrapForTest(t *testing.T, cfg *config.Config, command string) (stdout, stderr string, exitCode int) {
+	t.Helper()
+	skipIfAlreadySandboxed(t)
+	skipIfCommandNotFound(t, "bwrap")
+	skipIfCommandNotFound(t, "socat")
+
+	result := runUnderSandbox(t, cfg, command, "")
+	if result.Error != nil && result.ExitCode == 0 {
+		t.Fatalf("failed to run %q in bwrap: %v", command, result.Error)
+	}
+	return result.Stdout, result.Stderr, result.ExitCode
+}
+
+func TestLinux_Bwrap_DeniedCommandFails(t *testing.T) {
+	cfg := testConfig()
+	cfg.Command.Deny = []string{"git push"}
+
+	_, stderr, exitCode := RunInBwrapForTest(t, cfg, "git push origin main")
+	if exitCode == 0 {
+		t.Fatal("expected denied command to fail")
+	}
+	assertContains(t, stderr, "git push")
+}
+
+func TestLinux_Bwrap_AllowedWriteSucceeds(t *testing.T) {
+	workspace := createTempWorkspace(t)
+	target := filepath.Join(workspace, "out.txt")
+
+	_, stderr, exitCode := RunInBwrapForTest(t, testConfigWithWorkspace(workspace), "echo ok > "+target)
+	if exitCode != 0 {
+		t.Fatalf("expected write to allowed path to succeed, exit %d: %s", exitCode, stderr)
+	}
+	assertFileExists(t, target)
+}
+
+func TestLinux_Bwrap_DeniedWriteFails(t *testing.T) {
+	workspace := createTempWorkspace(t)
+	protected := filepath.Join(workspace, "protected")
+	if err := os.MkdirAll(protected, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(protected, "out.txt")
+
+	cfg := testConfigWithWorkspace(workspace)
+	cfg.Filesystem.DenyWrite = []string{protected}
+
+	_, _, exitCode := RunInBwrapForTest(t, cfg, "echo bad > "+target)
+	if exitCode == 0 {
+		t.Error("expected write to denyWrite path to fail")
+	}
+	assertFileNotExists(t, target)
+}
+
+func TestLinux_Bwrap_ProxyEnvVarsSet(t *testing.T) {
+	stdout, stderr, exitCode := RunInBwrapForTest(t, testConfigWithNetwork("example.com"), `echo "$HTTP_PROXY|$HTTPS_PROXY|$ALL_PROXY"`)
+	if exitCode != 0 {
+		t.Fatalf("exit %d: %s", exitCode, stderr)
+	}
+
+	parts := strings.Split(strings.TrimSpace(stdout), "|")
+	if len(parts) != 3 {
+		t.Fatalf("unexpected output %q", stdout)
+	}
+	for i, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY"} {
+		if parts[i] == "" {
+			t.Errorf("%s is not set inside the sandbox", name)
+		}
+	}
+	if !strings.HasPrefix(parts[2], "socks5h://") {
+		t.Errorf("ALL_PROXY = %q, want a socks5h:// URL", parts[2])
+	}
+}
+
+func TestLinux_Bwrap_TMPDIRIsFenceTmp(t *testing.T) {
+	stdout, stderr, exitCode := RunInBwrapForTest(t, testConfig(), `echo "$TMPDIR"`)
+	if exitCode != 0 {
+		t.Fatalf("exit %d: %s", exitCode, stderr)
+	}
+	if got, want := strings.TrimSpace(stdout), ensureSandboxTMPDIR(); got != want {
+		t.Errorf("TMPDIR = %q, want %q", got, want)
+	}
+}
+
+func TestLinux_Bwrap_ScratchDirIsTMPDIR(t *testing.T) {
+	cfg := testConfig()
+	cfg.Filesystem.ScratchDir = "/tmp/fence-scratch"
+
+	stdout, stderr, exitCode := RunInBwrapForTest(t, cfg, `echo "$TMPDIR" && touch "$TMPDIR/file" && ls -A "$TMPDIR"`)
+	if exitCode != 0 {
+		t.Fatalf("exit %d: %s", exitCode, stderr)
+	}
+	if got, want := strings.Fields(stdout), []string{"/tmp/fence-scratch", "file"}; !slices.Equal(got, want) {
+		t.Errorf("output = %q, want TMPDIR followed by only the new file", stdout)
+	}
+}
+
+func TestLinux_Bwrap_ClearEnvKeepsOnlyEssentialVars(t *testing.T) {
+	skipIfAlreadySandboxed(t)
+	skipIfCommandNotFound(t, "bwrap")
+	t.Setenv("FENCE_TEST_SECRET", "do-not-leak")
+
+	cmd, err := WrapCommandLinuxWithOptions(testConfig(), "env", nil, nil, LinuxSandboxOptions{ClearEnv: true})
+	if err != nil {
+		t.Fatalf("WrapCommandLinuxWithOptions() error = %v", err)
+	}
+	result := executeShellCommand(t, cmd, "")
+	if result.ExitCode != 0 {
+		t.Fatalf("exit %d: %s", result.ExitCode, result.Stderr)
+	}
+
+	// Variables the shell itself maintains
+	allowed := append([]string{"FENCE_SANDBOX", "PWD", "OLDPWD", "SHLVL", "_"}, essentialEnvVars...)
+	for _, line := range strings.Split(strings.TrimSpace(result.Stdout), "\n") {
+		key, _, _ := strings.Cut(line, "=")
+		if !slices.Contains(allowed, key) {
+			t.Errorf("unexpected variable in sandbox environment: %s", line)
+		}
+	}
+}
+
+func TestLinux_Bwrap_UnshareUserRunsAsRoot(t *testing.T) {
+	if os.Getenv("FENCE_TEST_BWRAP") != "1" {
+		t.Skip("skipping: set FENCE_TEST_BWRAP=1 to run user namespace tests")
+	}
+	skipIfAlreadySandboxed(t)
+	skipIfCommandNotFound(t, "bwrap")
+
+	cmd, err := WrapCommandLinuxWithOptions(testConfig(), "id -u && id -g", nil, nil, LinuxSandboxOptions{UnshareUser: true})
+	if err != nil {
+		t.Fatalf("WrapCommandLinuxWithOptions() error = %v", err)
+	}
+	result := executeShellCommand(t, cmd, "")
+	if result.ExitCode != 0 {
+		t.Fatalf("exit %d: %s", result.ExitCode, result.Stderr)
+	}
+	if got := strings.Fields(result.Stdout); !slices.Equal(got, []string{"0", "0"}) {
+		t.Errorf("uid and gid = %v, want [0 0]", got)
+	}
+}
+
+func TestLinux_Bwrap_UserSwitchingMasked(t *testing.T) {
+	for _, name := range userSwitchExecutables {
+		t.Run(name, func(t *testing.T) {
+			paths := resolveExecutablePaths(name)
+			if len(paths) == 0 {
+				t.Skipf("%s not installed", name)
+			}
+			bin := paths[0]
+
+			stdout, stderr, exitCode := RunInBwrapForTest(t, testConfig(), "stat -c %a "+bin)
+			if exitCode != 0 {
+				t.Fatalf("stat %s failed, exit %d: %s", bin, exitCode, stderr)
+			}
+			if mode := strings.TrimSpace(stdout); len(mode) > 3 {
+				t.Errorf("%s mode inside sandbox = %s, want no setuid bit", bin, mode)
+			}
+
+			if _, _, exitCode := RunInBwrapForTest(t, testConfig(), bin+" id"); exitCode == 0 {
+				t.Errorf("%s id should fail inside the sandbox", bin)
+			}
+		})
+	}
+}
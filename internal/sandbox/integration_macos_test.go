@@ -404,3 +404,33 @@ func TestMacOS_MultipleWritableRoots(t *testing.T) {
 	result = runUnderSandbox(t, cfg, "echo 'test' > "+outsideFile, workspace1)
 	assertBlocked(t, result)
 }
+
+// TestMacOS_RenameIntoDenyWriteBlocked verifies that writing a file in an
+// allowed location and renaming it onto a denyWrite path is blocked.
+func TestMacOS_RenameIntoDenyWriteBlocked(t *testing.T) {
+	skipIfAlreadySandboxed(t)
+
+	workspace := createTempWorkspace(t)
+	protectedDir := filepath.Join(workspace, "protected")
+	if err := os.MkdirAll(protectedDir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	protectedFile := createTestFile(t, protectedDir, "settings.json", "original")
+
+	cfg := testConfigWithWorkspace(workspace)
+	cfg.Filesystem.AllowWrite = append(cfg.Filesystem.AllowWrite, "/tmp/fence")
+	cfg.Filesystem.DenyWrite = []string{protectedDir}
+
+	tmpFile := "/tmp/fence/rename-" + filepath.Base(workspace)
+	t.Cleanup(func() { _ = os.Remove(tmpFile) })
+
+	for _, target := range []string{protectedFile, filepath.Join(protectedDir, "new.json")} {
+		result := runUnderSandbox(t, cfg, "mkdir -p /tmp/fence && echo malicious > "+tmpFile+" && mv -f "+tmpFile+" "+target, workspace)
+		assertBlocked(t, result)
+	}
+
+	if content, _ := os.ReadFile(protectedFile); string(content) != "original" { //nolint:gosec
+		t.Errorf("protected file was replaced: %q", content)
+	}
+	assertFileNotExists(t, filepath.Join(protectedDir, "new.json"))
+}
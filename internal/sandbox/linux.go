@@ -3,14 +3,21 @@
 package sandbox
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -22,9 +29,16 @@ import (
 type LinuxBridge struct {
 	HTTPSocketPath  string
 	SOCKSSocketPath string
-	httpProcess     *exec.Cmd
-	socksProcess    *exec.Cmd
+	HTTPProxyPort   int // Host port of the HTTP proxy the bridge forwards to
+	SOCKSProxyPort  int // Host port of the SOCKS proxy the bridge forwards to
+	httpProcess     *bridgeProcess
+	socksProcess    *bridgeProcess
 	debug           bool
+
+	ready    chan struct{} // Closed when readiness is known; see readyErr
+	readyErr error         // Why the bridge will never be ready; set before ready is closed
+	stopPoll chan struct{} // Closed by Cleanup to stop the readiness poll and health monitors
+	pollDone chan struct{} // Closed when the readiness poll returns
 }
 
 // ReverseBridge holds the socat bridge processes for inbound connections.
@@ -47,19 +61,196 @@ type LinuxSandboxOptions struct {
 	Monitor bool
 	// Debug mode
 	Debug bool
-	// Shell selection mode (default|user)
+	// Shell selection mode (default|user|custom)
 	ShellMode string
-	// Whether to run shell as login shell.
+	// Shell path for custom mode
+	ShellPath string
+	// Whether to run shell as login shell (e.g. bash -lc). Both the bwrap
+	// inner script and the Landlock wrapper's shell get the login flag, so
+	// /etc/profile and ~/.profile are sourced before the user command.
 	ShellLogin bool
+	// Receives an event for each command that is wrapped (optional)
+	AuditLogger AuditLogger
+	// Drop every capability in the sandbox (bwrap --cap-drop ALL). This
+	// matters when fence runs as root; unprivileged bwrap keeps none anyway.
+	DropAllCapabilities bool
+	// Capabilities to keep or grant, e.g. CAP_NET_RAW for ping. Only the
+	// names in addableCapabilities are accepted.
+	AddCapabilities []string
+	// Start the sandbox with an empty environment plus the variables from
+	// getEssentialEnvVars, so API keys and other secrets in the caller's
+	// environment are not passed through (bwrap --clearenv).
+	ClearEnv bool
+	// Run the command in a new user namespace as uid and gid 0, for tools
+	// that only enable features when running as root. Root in a user
+	// namespace holds every capability over that namespace's mounts, so the
+	// command could otherwise remount or unmount what fence set up, e.g.
+	// the read-only binds that protect denyWrite paths. All capabilities
+	// are therefore dropped (--cap-drop ALL), keeping only AddCapabilities.
+	// A user namespace also exposes more of the kernel: on older kernels it
+	// has been the entry point for privilege escalation bugs. The seccomp
+	// filter and no_new_privs (set by bwrap) still apply.
+	UnshareUser bool
+	// Give the sandbox its own System V IPC and POSIX message queue
+	// namespace, so it cannot reach shared memory of host processes. Some
+	// tools, such as Wine and PostgreSQL, need the host's IPC namespace.
+	UnshareIPC bool
+	// Give the sandbox its own hostname (SandboxHostname, or "sandbox")
+	UnshareUTS      bool
+	SandboxHostname string
+}
+
+// defaultSandboxHostname is the hostname inside the sandbox with UnshareUTS.
+const defaultSandboxHostname = "sandbox"
+
+// LinuxBridgeOptions configures NewLinuxBridge. Zero values use the defaults.
+type LinuxBridgeOptions struct {
+	// How long to wait for socat to create the bridge sockets (default 5s)
+	SocketReadyTimeout time.Duration
+	// First interval between socket checks; it doubles after each check,
+	// up to one second (default 100ms)
+	SocketPollInterval time.Duration
+	// Start again with new socket paths if socat cannot bind a socket
+	// because the address is in use
+	RetryOnBusyPort bool
+	// Debug mode
+	Debug bool
+}
+
+const (
+	defaultSocketReadyTimeout = 5 * time.Second
+	defaultSocketPollInterval = 100 * time.Millisecond
+	maxSocketPollInterval     = time.Second
+	// maxBridgeAttempts bounds the starts with RetryOnBusyPort.
+	maxBridgeAttempts = 3
+	// maxBridgeStderr bounds how much socat output is kept per process.
+	maxBridgeStderr = 4096
+)
+
+// bridgeProcess is a running socat bridge. Its stderr is kept for error
+// messages and may only be read once done is closed.
+type bridgeProcess struct {
+	cmd    *exec.Cmd
+	stderr cappedBuffer
+	done   chan struct{}
+}
+
+// cappedBuffer keeps the first maxBridgeStderr bytes written to it.
+type cappedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := maxBridgeStderr - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func startBridgeProcess(args []string) (*bridgeProcess, error) {
+	p := &bridgeProcess{
+		cmd:  exec.Command("socat", args...), //nolint:gosec // args constructed from trusted input
+		done: make(chan struct{}),
+	}
+	p.cmd.Stderr = &p.stderr
+	if err := p.cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		_ = p.cmd.Wait()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+func (p *bridgeProcess) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop kills the process and waits for it to exit.
+func (p *bridgeProcess) stop() {
+	if p == nil {
+		return
+	}
+	_ = p.cmd.Process.Kill()
+	<-p.done
 }
 
+// output returns the process's stderr, trimmed. Call it after stop.
+func (p *bridgeProcess) output() string {
+	if p == nil {
+		return ""
+	}
+	return strings.TrimSpace(p.stderr.String())
+}
+
+var (
+	// errBusySocket means socat could not bind a bridge socket.
+	errBusySocket = errors.New("bridge socket address in use")
+	// errBridgeExited means a socat process exited before the sockets were
+	// created.
+	errBridgeExited = errors.New("socat exited before the bridge sockets were created")
+	// errBridgeClosed means Cleanup ran before the bridge was ready.
+	errBridgeClosed = errors.New("bridge cleaned up before it was ready")
+)
+
 // NewLinuxBridge creates Unix socket bridges to the proxy servers.
-// This allows sandboxed processes to communicate with the host's proxy (outbound).
-func NewLinuxBridge(httpProxyPort, socksProxyPort int, debug bool) (*LinuxBridge, error) {
+// This allows sandboxed processes to communicate with the host's proxy
+// (outbound). It returns once the bridge is ready, or after
+// opts.SocketReadyTimeout.
+func NewLinuxBridge(httpProxyPort, socksProxyPort int, opts LinuxBridgeOptions) (*LinuxBridge, error) {
 	if _, err := exec.LookPath("socat"); err != nil {
 		return nil, fmt.Errorf("socat is required on Linux but not found: %w", err)
 	}
+	if opts.SocketReadyTimeout <= 0 {
+		opts.SocketReadyTimeout = defaultSocketReadyTimeout
+	}
+	if opts.SocketPollInterval <= 0 {
+		opts.SocketPollInterval = defaultSocketPollInterval
+	}
+
+	attempts := 1
+	if opts.RetryOnBusyPort {
+		attempts = maxBridgeAttempts
+	}
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		var bridge *LinuxBridge
+		bridge, err = startLinuxBridge(httpProxyPort, socksProxyPort, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), opts.SocketReadyTimeout)
+		err = bridge.WaitReady(ctx)
+		cancel()
+		if err == nil {
+			if opts.Debug {
+				fmt.Fprintf(os.Stderr, "[fence:linux] Bridges ready (HTTP: %s, SOCKS: %s)\n", bridge.HTTPSocketPath, bridge.SOCKSSocketPath)
+			}
+			return bridge, nil
+		}
+
+		bridge.Cleanup()
+		err = bridgeStartError(bridge, err, opts.SocketReadyTimeout)
+		if !errors.Is(err, errBusySocket) {
+			return nil, err
+		}
+		if opts.Debug && attempt < attempts {
+			fmt.Fprintf(os.Stderr, "[fence:linux] Bridge socket in use, retrying with new socket paths\n")
+		}
+	}
+	return nil, err
+}
 
+// startLinuxBridge starts both bridges on new socket paths, along with the
+// goroutine that waits for their sockets.
+func startLinuxBridge(httpProxyPort, socksProxyPort int, opts LinuxBridgeOptions) (*LinuxBridge, error) {
 	id := make([]byte, 8)
 	if _, err := rand.Read(id); err != nil {
 		return nil, fmt.Errorf("failed to generate socket ID: %w", err)
@@ -73,7 +264,9 @@ func NewLinuxBridge(httpProxyPort, socksProxyPort int, debug bool) (*LinuxBridge
 	bridge := &LinuxBridge{
 		HTTPSocketPath:  httpSocketPath,
 		SOCKSSocketPath: socksSocketPath,
-		debug:           debug,
+		HTTPProxyPort:   httpProxyPort,
+		SOCKSProxyPort:  socksProxyPort,
+		debug:           opts.Debug,
 	}
 
 	// Start HTTP bridge: Unix socket -> TCP proxy
@@ -81,11 +274,11 @@ func NewLinuxBridge(httpProxyPort, socksProxyPort int, debug bool) (*LinuxBridge
 		fmt.Sprintf("UNIX-LISTEN:%s,fork,reuseaddr", httpSocketPath),
 		fmt.Sprintf("TCP:localhost:%d", httpProxyPort),
 	}
-	bridge.httpProcess = exec.Command("socat", httpArgs...) //nolint:gosec // args constructed from trusted input
-	if debug {
+	if opts.Debug {
 		fmt.Fprintf(os.Stderr, "[fence:linux] Starting HTTP bridge: socat %s\n", strings.Join(httpArgs, " "))
 	}
-	if err := bridge.httpProcess.Start(); err != nil {
+	var err error
+	if bridge.httpProcess, err = startBridgeProcess(httpArgs); err != nil {
 		return nil, fmt.Errorf("failed to start HTTP bridge: %w", err)
 	}
 
@@ -94,42 +287,162 @@ func NewLinuxBridge(httpProxyPort, socksProxyPort int, debug bool) (*LinuxBridge
 		fmt.Sprintf("UNIX-LISTEN:%s,fork,reuseaddr", socksSocketPath),
 		fmt.Sprintf("TCP:localhost:%d", socksProxyPort),
 	}
-	bridge.socksProcess = exec.Command("socat", socksArgs...) //nolint:gosec // args constructed from trusted input
-	if debug {
+	if opts.Debug {
 		fmt.Fprintf(os.Stderr, "[fence:linux] Starting SOCKS bridge: socat %s\n", strings.Join(socksArgs, " "))
 	}
-	if err := bridge.socksProcess.Start(); err != nil {
+	if bridge.socksProcess, err = startBridgeProcess(socksArgs); err != nil {
 		bridge.Cleanup()
 		return nil, fmt.Errorf("failed to start SOCKS bridge: %w", err)
 	}
 
-	// Wait for sockets to be created, up to 5 seconds
-	for range 50 {
-		httpExists := fileExists(httpSocketPath)
-		socksExists := fileExists(socksSocketPath)
-		if httpExists && socksExists {
-			if debug {
-				fmt.Fprintf(os.Stderr, "[fence:linux] Bridges ready (HTTP: %s, SOCKS: %s)\n", httpSocketPath, socksSocketPath)
+	bridge.ready = make(chan struct{})
+	bridge.stopPoll = make(chan struct{})
+	bridge.pollDone = make(chan struct{})
+	go bridge.pollReady(opts.SocketPollInterval)
+	return bridge, nil
+}
+
+// pollReady waits for both sockets to exist, checking at interval and
+// backing off exponentially, then closes b.ready. It gives up when a socat
+// process exits or Cleanup is called.
+func (b *LinuxBridge) pollReady(interval time.Duration) {
+	defer close(b.pollDone)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-b.stopPoll:
+			b.readyErr = errBridgeClosed
+			close(b.ready)
+			return
+		case <-b.httpProcess.done:
+		case <-b.socksProcess.done:
+		case <-timer.C:
+		}
+
+		if fileExists(b.HTTPSocketPath) && fileExists(b.SOCKSSocketPath) {
+			close(b.ready)
+			return
+		}
+		if b.httpProcess.exited() || b.socksProcess.exited() {
+			b.readyErr = errBridgeExited
+			close(b.ready)
+			return
+		}
+		timer.Reset(interval)
+		interval = min(interval*2, maxSocketPollInterval)
+	}
+}
+
+// WaitReady waits until socat has created both bridge sockets. It returns
+// an error if a socat process exits first or the bridge is cleaned up, and
+// ctx.Err() if ctx is done first; the bridge keeps starting in that case,
+// so WaitReady can be called again.
+func (b *LinuxBridge) WaitReady(ctx context.Context) error {
+	select {
+	case <-b.ready:
+		return b.readyErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// HealthCheck returns an error if a socat process has exited or a bridge
+// socket has been removed. Call it once the bridge is ready.
+func (b *LinuxBridge) HealthCheck() error {
+	if b.httpProcess.exited() {
+		return fmt.Errorf("HTTP bridge process exited: %s", exitReason(b.httpProcess))
+	}
+	if b.socksProcess.exited() {
+		return fmt.Errorf("SOCKS bridge process exited: %s", exitReason(b.socksProcess))
+	}
+	for _, path := range []string{b.HTTPSocketPath, b.SOCKSSocketPath} {
+		if !fileExists(path) {
+			return fmt.Errorf("bridge socket %s no longer exists", path)
+		}
+	}
+	return nil
+}
+
+// exitReason describes how an exited process ended, with its output if any.
+func exitReason(p *bridgeProcess) string {
+	reason := p.cmd.ProcessState.String()
+	if out := p.output(); out != "" {
+		reason += "; socat output:\n" + out
+	}
+	return reason
+}
+
+// StartHealthMonitor runs HealthCheck every interval in a goroutine and calls
+// onFailure with the first error. The monitor stops after a failure, when
+// ctx is done, or when the bridge is cleaned up; onFailure is not called for
+// processes stopped by Cleanup.
+func (b *LinuxBridge) StartHealthMonitor(ctx context.Context, interval time.Duration, onFailure func(error)) {
+	stop := b.stopPoll
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
 			}
-			return bridge, nil
+
+			if err := b.HealthCheck(); err != nil {
+				// Cleanup closes stop before killing the processes
+				select {
+				case <-stop:
+				default:
+					onFailure(err)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// bridgeStartError describes why the bridge did not become ready, given the
+// WaitReady error, including what socat printed. Call it after Cleanup.
+func bridgeStartError(bridge *LinuxBridge, waitErr error, timeout time.Duration) error {
+	var output []string
+	for _, p := range []*bridgeProcess{bridge.httpProcess, bridge.socksProcess} {
+		if out := p.output(); out != "" {
+			output = append(output, out)
 		}
-		time.Sleep(100 * time.Millisecond)
 	}
+	socatOutput := strings.Join(output, "\n")
 
-	bridge.Cleanup()
-	return nil, fmt.Errorf("timeout waiting for bridge sockets to be created")
+	err := waitErr
+	if errors.Is(waitErr, context.DeadlineExceeded) {
+		err = fmt.Errorf("timeout after %s waiting for bridge sockets to be created", timeout)
+	}
+	if strings.Contains(socatOutput, "Address already in use") {
+		err = fmt.Errorf("%w: %w", errBusySocket, err)
+	}
+	if socatOutput != "" {
+		err = fmt.Errorf("%w; socat output:\n%s", err, socatOutput)
+	}
+	return err
 }
 
 // Cleanup stops the bridge processes and removes socket files.
 func (b *LinuxBridge) Cleanup() {
-	if b.httpProcess != nil && b.httpProcess.Process != nil {
-		_ = b.httpProcess.Process.Kill()
-		_ = b.httpProcess.Wait()
-	}
-	if b.socksProcess != nil && b.socksProcess.Process != nil {
-		_ = b.socksProcess.Process.Kill()
-		_ = b.socksProcess.Wait()
+	if b.stopPoll != nil {
+		select {
+		case <-b.stopPoll:
+			// Already cleaned up
+		default:
+			close(b.stopPoll)
+			<-b.pollDone
+		}
 	}
+	b.httpProcess.stop()
+	b.socksProcess.stop()
 
 	// Clean up socket files
 	_ = os.Remove(b.HTTPSocketPath)
@@ -295,36 +608,78 @@ func intermediaryDirs(root, targetDir string) []string {
 // getMandatoryDenyPaths returns concrete paths (not globs) that must be protected.
 // Covers dangerous files/dirs in cwd, home, and subdirectories up to
 // DefaultMaxDangerousFileDepth levels deep (using a depth-limited walk).
-func getMandatoryDenyPaths(cwd string) []string {
+// The walk skips paths listed in cwd's .fenceignore only when useFenceIgnore
+// is set, since the file comes with the project rather than from the user.
+func getMandatoryDenyPaths(cwd string, trustedFiles, trustedDirs []string, useFenceIgnore bool) []string {
 	var paths []string
 
 	// Dangerous files in cwd
 	for _, f := range DangerousFiles {
-		paths = append(paths, filepath.Join(cwd, f))
+		if !slices.Contains(trustedFiles, f) {
+			paths = append(paths, dangerousFilePaths(cwd, f)...)
+		}
 	}
 
 	// Dangerous directories in cwd
 	for _, d := range DangerousDirectories {
-		paths = append(paths, filepath.Join(cwd, d))
+		if !slices.Contains(trustedDirs, d) {
+			paths = append(paths, filepath.Join(cwd, d))
+		}
 	}
 
 	// Git hooks and config in cwd
 	paths = append(paths, filepath.Join(cwd, ".git/hooks"))
 	paths = append(paths, filepath.Join(cwd, ".git/config"))
 
+	// The ignore file decides what later runs protect, so it cannot be trusted
+	paths = append(paths, filepath.Join(cwd, FenceIgnoreFile))
+
 	// Dangerous files in home directory
 	home, err := os.UserHomeDir()
 	if err == nil {
 		for _, f := range DangerousFiles {
-			paths = append(paths, filepath.Join(home, f))
+			paths = append(paths, dangerousFilePaths(home, f)...)
 		}
 	}
 
+	// Fence's own config directories
+	paths = append(paths, GetFenceConfigPaths()...)
+
 	// Depth-limited walk to find dangerous files in subdirectories.
 	// This catches .bashrc, .zshrc, .git/hooks, etc. in nested project dirs
 	// without the cost of a full recursive glob expansion.
-	paths = append(paths, FindDangerousFiles(cwd, DefaultMaxDangerousFileDepth)...)
+	var ignore IgnoreRules
+	if useFenceIgnore {
+		var err error
+		ignore, err = ParseFenceIgnore(filepath.Join(cwd, FenceIgnoreFile))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "[fence:linux] Warning: failed to read %s: %v\n", FenceIgnoreFile, err)
+		}
+	}
+	for _, p := range FindDangerousFiles(cwd, DefaultMaxDangerousFileDepth, ignore) {
+		if !isTrustedDangerousPath(p, trustedFiles, trustedDirs) {
+			paths = append(paths, p)
+		}
+	}
+
+	return paths
+}
 
+// dangerousFilePaths returns the path of DangerousFiles entry f in dir, or
+// for a glob entry such as "*.pem", the matching files that exist in dir.
+func dangerousFilePaths(dir, f string) []string {
+	if !ContainsGlobChars(f) {
+		return []string{filepath.Join(dir, f)}
+	}
+	// Match names rather than using filepath.Glob, which would treat glob
+	// characters in dir as a pattern too.
+	entries, _ := os.ReadDir(dir)
+	var paths []string
+	for _, e := range entries {
+		if matched, _ := filepath.Match(f, e.Name()); matched && !e.IsDir() {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
 	return paths
 }
 
@@ -340,37 +695,59 @@ func WrapCommandLinux(cfg *config.Config, command string, bridge *LinuxBridge, r
 }
 
 // WrapCommandLinuxWithShell wraps a command with configurable shell selection.
+// The custom shell path comes from cfg.Shell.Path.
 func WrapCommandLinuxWithShell(cfg *config.Config, command string, bridge *LinuxBridge, reverseBridge *ReverseBridge, debug bool, shellMode string, shellLogin bool) (string, error) {
-	return WrapCommandLinuxWithOptions(cfg, command, bridge, reverseBridge, LinuxSandboxOptions{
+	opts := LinuxSandboxOptions{
 		UseLandlock: true,
 		UseSeccomp:  true,
 		UseEBPF:     true,
 		Debug:       debug,
 		ShellMode:   shellMode,
 		ShellLogin:  shellLogin,
-	})
+	}
+	if cfg != nil {
+		opts.ShellPath = cfg.Shell.Path
+	}
+	return WrapCommandLinuxWithOptions(cfg, command, bridge, reverseBridge, opts)
 }
 
 // WrapCommandLinuxWithOptions wraps a command with configurable sandbox options.
 func WrapCommandLinuxWithOptions(cfg *config.Config, command string, bridge *LinuxBridge, reverseBridge *ReverseBridge, opts LinuxSandboxOptions) (string, error) {
+	if cfg.AuditMode() {
+		var httpPort, socksPort int
+		if bridge != nil {
+			httpPort, socksPort = bridge.HTTPProxyPort, bridge.SOCKSProxyPort
+		}
+		return WrapCommandAudit(command, httpPort, socksPort, opts.ShellMode, opts.ShellPath, opts.ShellLogin, opts.AuditLogger)
+	}
+
 	if _, err := exec.LookPath("bwrap"); err != nil {
 		return "", fmt.Errorf("bubblewrap (bwrap) is required on Linux but not found: %w", err)
 	}
 
-	shellPath, shellFlag, err := ResolveExecutionShell(opts.ShellMode, opts.ShellLogin)
+	shellPath, shellFlag, err := ResolveExecutionShell(opts.ShellMode, opts.ShellPath, opts.ShellLogin)
 	if err != nil {
 		return "", err
 	}
 
+	// The setup script run inside the sandbox is sh syntax. Shells such as
+	// fish or nu only run the user command, started from /bin/sh.
+	scriptShell, scriptFlag := shellPath, shellFlag
+	if !isPOSIXShell(shellPath) {
+		scriptShell, scriptFlag = "/bin/sh", "-c"
+	}
+
 	deniedExecPaths := GetRuntimeDeniedExecutablePaths(cfg)
-	if resolvedShellPath, err := filepath.EvalSymlinks(shellPath); err == nil {
-		deniedExecPaths = slices.DeleteFunc(deniedExecPaths, func(p string) bool {
-			return p == shellPath || p == resolvedShellPath
-		})
-	} else {
-		deniedExecPaths = slices.DeleteFunc(deniedExecPaths, func(p string) bool {
-			return p == shellPath
-		})
+	for _, sh := range []string{shellPath, scriptShell} {
+		if resolvedShellPath, err := filepath.EvalSymlinks(sh); err == nil {
+			deniedExecPaths = slices.DeleteFunc(deniedExecPaths, func(p string) bool {
+				return p == sh || p == resolvedShellPath
+			})
+		} else {
+			deniedExecPaths = slices.DeleteFunc(deniedExecPaths, func(p string) bool {
+				return p == sh
+			})
+		}
 	}
 
 	cwd, _ := os.Getwd()
@@ -389,11 +766,27 @@ func WrapCommandLinuxWithOptions(cfg *config.Config, command string, bridge *Lin
 		fmt.Fprintf(os.Stderr, "[fence:linux] Note: deniedDomains only enforced for apps that respect HTTP_PROXY\n")
 	}
 
-	// Build bwrap args with filesystem restrictions
-	bwrapArgs := []string{
-		"bwrap",
+	// Root in a new user namespace would hold every capability over the
+	// sandbox's own mounts, so UnshareUser always drops them.
+	capArgs, err := capabilityArgs(opts.DropAllCapabilities || opts.UnshareUser, opts.AddCapabilities)
+	if err != nil {
+		return "", err
+	}
+
+	// Build bwrap args with filesystem restrictions. Capability changes come
+	// first, ahead of every other option.
+	bwrapArgs := append([]string{"bwrap"}, capArgs...)
+	bwrapArgs = append(bwrapArgs,
 		"--new-session",
 		"--die-with-parent",
+	)
+
+	if opts.ClearEnv {
+		bwrapArgs = append(bwrapArgs, "--clearenv")
+		env := getEssentialEnvVars(cfg)
+		for _, key := range slices.Sorted(maps.Keys(env)) {
+			bwrapArgs = append(bwrapArgs, "--setenv", key, env[key])
+		}
 	}
 
 	// Only use --unshare-net if:
@@ -408,6 +801,17 @@ func WrapCommandLinuxWithOptions(cfg *config.Config, command string, bridge *Lin
 
 	bwrapArgs = append(bwrapArgs, "--unshare-pid") // PID namespace isolation
 
+	nsArgs, err := namespaceArgs(opts)
+	if err != nil {
+		return "", err
+	}
+	bwrapArgs = append(bwrapArgs, nsArgs...)
+
+	if opts.UnshareUser {
+		// User namespace, appearing as root inside the sandbox
+		bwrapArgs = append(bwrapArgs, "--unshare-user", "--uid", "0", "--gid", "0")
+	}
+
 	// Generate seccomp filter if available and requested
 	var seccompFilterPath string
 	if opts.UseSeccomp && features.HasSeccomp {
@@ -579,13 +983,14 @@ func WrapCommandLinuxWithOptions(cfg *config.Config, command string, bridge *Lin
 
 	// Add user-specified allowWrite paths
 	if cfg != nil && cfg.Filesystem.AllowWrite != nil {
-		expandedPaths := ExpandGlobPatterns(cfg.Filesystem.AllowWrite)
+		allowWrite := cfg.Filesystem.WritablePaths()
+		expandedPaths := ExpandGlobPatterns(allowWrite)
 		for _, p := range expandedPaths {
 			writablePaths[p] = true
 		}
 
 		// Add non-glob paths
-		for _, p := range cfg.Filesystem.AllowWrite {
+		for _, p := range allowWrite {
 			normalized := NormalizePath(p)
 			if !ContainsGlobChars(normalized) {
 				writablePaths[normalized] = true
@@ -626,14 +1031,15 @@ func WrapCommandLinuxWithOptions(cfg *config.Config, command string, bridge *Lin
 		crossMountPaths = append(crossMountPaths, ExpandGlobPatterns(cfg.Filesystem.AllowRead)...)
 
 		// Collect allowWrite paths and mark them as writable
-		for _, p := range cfg.Filesystem.AllowWrite {
+		allowWrite := cfg.Filesystem.WritablePaths()
+		for _, p := range allowWrite {
 			if !ContainsGlobChars(p) {
 				np := NormalizePath(p)
 				crossMountPaths = append(crossMountPaths, np)
 				crossMountWritable[np] = true
 			}
 		}
-		for _, p := range ExpandGlobPatterns(cfg.Filesystem.AllowWrite) {
+		for _, p := range ExpandGlobPatterns(allowWrite) {
 			crossMountPaths = append(crossMountPaths, p)
 			crossMountWritable[p] = true
 		}
@@ -689,8 +1095,8 @@ func WrapCommandLinuxWithOptions(cfg *config.Config, command string, bridge *Lin
 	// For directories: use --tmpfs to replace with empty tmpfs
 	// For files: use --ro-bind /dev/null to mask with empty file
 	// Skip symlinks: they may point outside the sandbox and cause mount errors
-	if cfg != nil && cfg.Filesystem.DenyRead != nil {
-		expandedDenyRead := ExpandGlobPatterns(cfg.Filesystem.DenyRead)
+	if denyRead := effectiveDenyRead(cfg); len(denyRead) > 0 {
+		expandedDenyRead := ExpandGlobPatterns(denyRead)
 		for _, p := range expandedDenyRead {
 			denyReadPaths[p] = true
 			if canMountOver(p) {
@@ -704,7 +1110,7 @@ func WrapCommandLinuxWithOptions(cfg *config.Config, command string, bridge *Lin
 		}
 
 		// Add non-glob paths
-		for _, p := range cfg.Filesystem.DenyRead {
+		for _, p := range denyRead {
 			normalized := NormalizePath(p)
 			if !ContainsGlobChars(normalized) {
 				denyReadPaths[normalized] = true
@@ -726,7 +1132,14 @@ func WrapCommandLinuxWithOptions(cfg *config.Config, command string, bridge *Lin
 	// getMandatoryDenyPaths covers: cwd-level files, home dir files, and a
 	// depth-limited walk (DefaultMaxDangerousFileDepth levels) to find dangerous
 	// files in subdirectories without full tree walks that hang on large dirs.
-	mandatoryDeny := getMandatoryDenyPaths(cwd)
+	var trustedFiles, trustedDirs []string
+	useFenceIgnore := false
+	if cfg != nil {
+		trustedFiles, trustedDirs = cfg.Filesystem.TrustedDangerousFiles, cfg.Filesystem.TrustedDangerousDirectories
+		useFenceIgnore = cfg.Filesystem.UseFenceIgnore
+	}
+	mandatoryDeny := getMandatoryDenyPaths(cwd, trustedFiles, trustedDirs, useFenceIgnore)
+	mandatoryDeny = append(mandatoryDeny, auditLogDenyWritePaths(cfg)...)
 
 	// Deduplicate
 	seen := make(map[string]bool)
@@ -754,28 +1167,17 @@ func WrapCommandLinuxWithOptions(cfg *config.Config, command string, bridge *Lin
 		}
 	}
 
-	// Handle explicit denyWrite paths (make them read-only)
-	if cfg != nil && cfg.Filesystem.DenyWrite != nil {
-		expandedDenyWrite := ExpandGlobPatterns(cfg.Filesystem.DenyWrite)
-		for _, p := range expandedDenyWrite {
-			if fileExists(p) && !seen[p] {
-				seen[p] = true
-				bwrapArgs = append(bwrapArgs, "--ro-bind", p, p)
-			}
-		}
-		// Add non-glob paths
-		for _, p := range cfg.Filesystem.DenyWrite {
-			normalized := NormalizePath(p)
-			if !ContainsGlobChars(normalized) && fileExists(normalized) && !seen[normalized] {
-				seen[normalized] = true
-				bwrapArgs = append(bwrapArgs, "--ro-bind", normalized, normalized)
-			}
-		}
+	// Handle explicit denyWrite paths (make them read-only). Renaming a file
+	// onto a read-only mount, or into a directory on one, fails with EBUSY,
+	// EXDEV or EROFS, so write-then-rename cannot bypass this.
+	if cfg != nil {
+		bwrapArgs = append(bwrapArgs, denyWriteMountArgs(cfg.Filesystem.DenyWrite, seen)...)
 	}
 
 	// Runtime executable deny (applies to child processes).
 	// This masks resolved executable paths so execve fails even when launched
-	// from an allowed wrapper process (e.g., agent subprocesses).
+	// from an allowed wrapper process (e.g., agent subprocesses). The mask is
+	// a read-only mount, so it also covers filesystem.protectDeniedExecutables.
 	for _, p := range deniedExecPaths {
 		if fileExists(p) && !seen[p] {
 			seen[p] = true
@@ -783,6 +1185,15 @@ func WrapCommandLinuxWithOptions(cfg *config.Config, command string, bridge *Lin
 		}
 	}
 
+	// Mask sudo and su as well, whether or not command.deny lists them, so
+	// user switching cannot be attempted from inside the sandbox.
+	for _, p := range GetUserSwitchExecutablePaths(cfg) {
+		if fileExists(p) && !seen[p] {
+			seen[p] = true
+			bwrapArgs = append(bwrapArgs, "--ro-bind", "/dev/null", p)
+		}
+	}
+
 	// Bind the outbound Unix sockets into the sandbox (need to be writable)
 	if bridge != nil {
 		bwrapArgs = append(bwrapArgs,
@@ -815,7 +1226,15 @@ func WrapCommandLinuxWithOptions(cfg *config.Config, command string, bridge *Lin
 		fmt.Fprintf(os.Stderr, "[fence:linux] Skipping Landlock wrapper (running as library, not fence CLI)\n")
 	}
 
-	bwrapArgs = append(bwrapArgs, "--", shellPath, shellFlag)
+	// Scratch directory, mounted last so that binds of its parent directories
+	// cannot hide it
+	scratchArgs, err := scratchDirMountArgs(cfg)
+	if err != nil {
+		return "", err
+	}
+	bwrapArgs = append(bwrapArgs, scratchArgs...)
+
+	bwrapArgs = append(bwrapArgs, "--", scriptShell, scriptFlag)
 
 	// Build the inner command that sets up socat listeners and runs the user command
 	var innerScript strings.Builder
@@ -838,11 +1257,25 @@ export http_proxy=http://127.0.0.1:3128
 export https_proxy=http://127.0.0.1:3128
 export ALL_PROXY=socks5h://127.0.0.1:1080
 export all_proxy=socks5h://127.0.0.1:1080
-export NO_PROXY=localhost,127.0.0.1
-export no_proxy=localhost,127.0.0.1
+export NO_PROXY=localhost,127.0.0.1,::1
+export no_proxy=localhost,127.0.0.1,::1
 export FENCE_SANDBOX=1
 
 `, bridge.HTTPSocketPath, bridge.SOCKSSocketPath))
+
+		// Tool-specific proxy settings; JVM options are appended to the
+		// caller's own
+		for _, env := range toolProxyEnvVars("127.0.0.1", 3128, nil) {
+			key, value, _ := strings.Cut(env, "=")
+			if strings.HasSuffix(key, "_OPTS") {
+				innerScript.WriteString(fmt.Sprintf("export %s=\"${%s:+$%s }%s\"\n", key, key, key, value))
+			} else {
+				innerScript.WriteString(fmt.Sprintf("export %s=%s\n", key, ShellQuoteSingle(value)))
+			}
+		}
+		innerScript.WriteString("\n")
+	} else {
+		innerScript.WriteString("export FENCE_SANDBOX=1\n")
 	}
 
 	// Set up reverse (inbound) socat listeners inside the sandbox
@@ -874,6 +1307,13 @@ sleep 0.1
 # Run the user command
 `)
 
+	if cfg != nil && cfg.Filesystem.ScratchDir != "" {
+		innerScript.WriteString(fmt.Sprintf("export TMPDIR=%s\n", ShellQuoteSingle(filepath.Clean(cfg.Filesystem.ScratchDir))))
+	}
+	if cfg != nil {
+		innerScript.WriteString(resourceLimitCommands(cfg.ResourceLimits, "linux"))
+	}
+
 	// Use Landlock wrapper if available
 	if useLandlockWrapper {
 		// Pass config via environment variable (serialized as JSON)
@@ -895,6 +1335,9 @@ sleep 0.1
 
 		// Use exec to replace bash with the wrapper (which will exec the command)
 		innerScript.WriteString(fmt.Sprintf("exec %s\n", ShellQuote(wrapperArgs)))
+	} else if scriptShell != shellPath {
+		innerScript.WriteString(ShellQuote([]string{shellPath, shellFlag, command}))
+		innerScript.WriteString("\n")
 	} else {
 		innerScript.WriteString(command)
 		innerScript.WriteString("\n")
@@ -923,6 +1366,10 @@ sleep 0.1
 		fmt.Fprintf(os.Stderr, "[fence:linux] Sandbox: %s\n", strings.Join(featureList, ", "))
 	}
 
+	if opts.AuditLogger != nil {
+		opts.AuditLogger.LogCommandExec(command, true)
+	}
+
 	// Build the final command
 	bwrapCmd := ShellQuote(bwrapArgs)
 
@@ -937,6 +1384,149 @@ sleep 0.1
 	return bwrapCmd, nil
 }
 
+// hostnamePattern matches a single DNS label, as accepted by sethostname.
+var hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// namespaceArgs returns the bwrap arguments for the optional IPC and UTS
+// namespaces.
+func namespaceArgs(opts LinuxSandboxOptions) ([]string, error) {
+	var args []string
+	if opts.UnshareIPC {
+		args = append(args, "--unshare-ipc")
+	}
+	if !opts.UnshareUTS {
+		if opts.SandboxHostname != "" {
+			return nil, errors.New("SandboxHostname requires UnshareUTS")
+		}
+		return args, nil
+	}
+
+	hostname := opts.SandboxHostname
+	if hostname == "" {
+		hostname = defaultSandboxHostname
+	}
+	if !hostnamePattern.MatchString(hostname) {
+		return nil, fmt.Errorf("invalid sandbox hostname %q", hostname)
+	}
+	return append(args, "--unshare-uts", "--hostname", hostname), nil
+}
+
+// essentialEnvVars are the variables passed into the sandbox with ClearEnv.
+// The proxy variables are exported by the sandbox's setup script, which
+// points them at the in-sandbox listeners.
+var essentialEnvVars = []string{"HOME", "PATH", "USER", "LOGNAME", "TERM", "COLORTERM", "LANG"}
+
+// getEssentialEnvVars returns the environment kept with ClearEnv: the
+// essentialEnvVars that are set, FENCE_SANDBOX=1 and, when cfg sets a
+// scratch directory, TMPDIR.
+func getEssentialEnvVars(cfg *config.Config) map[string]string {
+	env := map[string]string{"FENCE_SANDBOX": "1"}
+	for _, key := range essentialEnvVars {
+		if value, ok := os.LookupEnv(key); ok {
+			env[key] = value
+		}
+	}
+	if cfg != nil && cfg.Filesystem.ScratchDir != "" {
+		env["TMPDIR"] = filepath.Clean(cfg.Filesystem.ScratchDir)
+	}
+	return env
+}
+
+// linuxCapabilities lists the capability names bwrap knows.
+var linuxCapabilities = []string{
+	"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_DAC_READ_SEARCH", "CAP_FOWNER",
+	"CAP_FSETID", "CAP_KILL", "CAP_SETGID", "CAP_SETUID", "CAP_SETPCAP",
+	"CAP_LINUX_IMMUTABLE", "CAP_NET_BIND_SERVICE", "CAP_NET_BROADCAST",
+	"CAP_NET_ADMIN", "CAP_NET_RAW", "CAP_IPC_LOCK", "CAP_IPC_OWNER",
+	"CAP_SYS_MODULE", "CAP_SYS_RAWIO", "CAP_SYS_CHROOT", "CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT", "CAP_SYS_ADMIN", "CAP_SYS_BOOT", "CAP_SYS_NICE",
+	"CAP_SYS_RESOURCE", "CAP_SYS_TIME", "CAP_SYS_TTY_CONFIG", "CAP_MKNOD",
+	"CAP_LEASE", "CAP_AUDIT_WRITE", "CAP_AUDIT_CONTROL", "CAP_SETFCAP",
+	"CAP_MAC_OVERRIDE", "CAP_MAC_ADMIN", "CAP_SYSLOG", "CAP_WAKE_ALARM",
+	"CAP_BLOCK_SUSPEND", "CAP_AUDIT_READ", "CAP_PERFMON", "CAP_BPF",
+	"CAP_CHECKPOINT_RESTORE",
+}
+
+// addableCapabilities are the capabilities AddCapabilities may grant: ones
+// that tools such as ping, servers on low ports and package managers need,
+// and that do not reach past the namespaces, mounts and seccomp filter.
+// Others, such as CAP_SYS_ADMIN, CAP_SYS_PTRACE, CAP_DAC_READ_SEARCH or
+// CAP_BPF, could be used to escape the sandbox or read what it hides.
+var addableCapabilities = []string{
+	"CAP_NET_RAW", "CAP_NET_BIND_SERVICE", "CAP_CHOWN", "CAP_FOWNER",
+	"CAP_SETUID", "CAP_SETGID",
+}
+
+// capabilityArgs returns the bwrap arguments that drop all capabilities
+// and/or add the named ones.
+func capabilityArgs(dropAll bool, add []string) ([]string, error) {
+	var args []string
+	if dropAll {
+		args = append(args, "--cap-drop", "ALL")
+	}
+	for _, name := range add {
+		capName := strings.ToUpper(name)
+		if !strings.HasPrefix(capName, "CAP_") {
+			capName = "CAP_" + capName
+		}
+		if !slices.Contains(linuxCapabilities, capName) {
+			return nil, fmt.Errorf("unknown capability %q", name)
+		}
+		if !slices.Contains(addableCapabilities, capName) {
+			return nil, fmt.Errorf("capability %s cannot be added: only %s are allowed", capName, strings.Join(addableCapabilities, ", "))
+		}
+		args = append(args, "--cap-add", capName)
+	}
+	return args, nil
+}
+
+// scratchDirMountArgs returns the bwrap arguments that mount an empty tmpfs
+// at filesystem.scratchDir, limited to scratchDirSizeMB when set. The root is
+// mounted read-only, so bwrap can only create the mount point under /tmp
+// (itself a tmpfs); elsewhere the directory must already exist on the host.
+func scratchDirMountArgs(cfg *config.Config) ([]string, error) {
+	if cfg == nil || cfg.Filesystem.ScratchDir == "" {
+		return nil, nil
+	}
+
+	dir := filepath.Clean(cfg.Filesystem.ScratchDir)
+	if !isPathWithin(dir, "/tmp", false) {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("filesystem.scratchDir %q must be an existing directory or a path under /tmp", dir)
+		}
+	}
+
+	var args []string
+	if size := cfg.Filesystem.ScratchDirSizeMB; size > 0 {
+		// --size applies to the next --tmpfs and is given in bytes
+		args = append(args, "--size", strconv.Itoa(size*1024*1024))
+	}
+	return append(args, "--tmpfs", dir), nil
+}
+
+// denyWriteMountArgs returns the bwrap arguments that mount denyWrite paths
+// read-only over the writable binds. Glob patterns can only be mounted as the
+// files they match when the sandbox starts; paths that do not exist yet are
+// skipped. seen records the paths already mounted.
+func denyWriteMountArgs(denyWrite []string, seen map[string]bool) []string {
+	var args []string
+	for _, p := range ExpandGlobPatterns(denyWrite) {
+		if fileExists(p) && !seen[p] {
+			seen[p] = true
+			args = append(args, "--ro-bind", p, p)
+		}
+	}
+	// Add non-glob paths
+	for _, p := range denyWrite {
+		normalized := NormalizePath(p)
+		if !ContainsGlobChars(normalized) && fileExists(normalized) && !seen[normalized] {
+			seen[normalized] = true
+			args = append(args, "--ro-bind", normalized, normalized)
+		}
+	}
+	return args
+}
+
 // StartLinuxMonitor starts violation monitoring for a Linux sandbox.
 // Returns monitors that should be stopped when the sandbox exits.
 func StartLinuxMonitor(pid int, opts LinuxSandboxOptions) (*LinuxMonitors, error) {
@@ -0,0 +1,129 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// AtomicWriteWatcher enforces atomic:true allowWrite entries on Linux. bwrap
+// cannot refuse an in-place write without also refusing the rename that
+// replaces the file, so the parent directories are watched with inotify and
+// the sandboxed process group is killed when a watched file is modified in
+// place. Renaming a finished file onto the path (IN_MOVED_TO) is allowed.
+//
+// Detection happens after the write, so the file may already hold the
+// partial content when the command is killed.
+type AtomicWriteWatcher struct {
+	file    *os.File
+	targets map[int32]map[string]string // Watch descriptor to base name to full path
+	debug   bool
+
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewAtomicWriteWatcher adds IN_MODIFY watches on the directories holding
+// paths. Paths whose directory does not exist are skipped. Call it before
+// starting the sandboxed command so no write is missed.
+func NewAtomicWriteWatcher(paths []string, debug bool) (*AtomicWriteWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify_init1: %w", err)
+	}
+
+	w := &AtomicWriteWatcher{
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		targets: make(map[int32]map[string]string),
+		debug:   debug,
+		done:    make(chan struct{}),
+	}
+
+	for _, p := range paths {
+		p = NormalizePath(p)
+		wd, err := unix.InotifyAddWatch(fd, filepath.Dir(p), unix.IN_MODIFY)
+		if err != nil {
+			w.logDebug("Cannot watch %s: %v", filepath.Dir(p), err)
+			continue
+		}
+		key := int32(wd) //nolint:gosec // watch descriptors fit in int32
+		if w.targets[key] == nil {
+			w.targets[key] = make(map[string]string)
+		}
+		w.targets[key][filepath.Base(p)] = p
+	}
+
+	if len(w.targets) == 0 {
+		_ = w.file.Close()
+		return nil, errors.New("no atomic:true paths could be watched")
+	}
+	w.logDebug("Watching %d directory(ies) for in-place writes to atomic:true files", len(w.targets))
+	return w, nil
+}
+
+// Start kills the process tree of pid on the first in-place write to a
+// watched file, then calls onViolation with that path. The watcher stops
+// after triggering.
+func (w *AtomicWriteWatcher) Start(pid int, onViolation func(path string)) {
+	go func() {
+		path, ok := w.waitForModify()
+		if !ok {
+			return
+		}
+		killProcessTree(pid)
+		if onViolation != nil {
+			onViolation(path)
+		}
+		w.Stop()
+	}()
+}
+
+// Stop removes all watches.
+func (w *AtomicWriteWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+		_ = w.file.Close()
+	})
+}
+
+// waitForModify blocks until a watched file is modified or the watcher stops.
+func (w *AtomicWriteWatcher) waitForModify() (string, bool) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			select {
+			case <-w.done:
+			default:
+				w.logDebug("inotify read failed: %v", err)
+			}
+			return "", false
+		}
+
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off])) //nolint:gosec // kernel-provided event layout
+			nameStart := off + unix.SizeofInotifyEvent
+			off = nameStart + int(event.Len)
+			if event.Mask&unix.IN_MODIFY == 0 || event.Len == 0 {
+				continue
+			}
+			name := unix.ByteSliceToString(buf[nameStart:off])
+			if path, ok := w.targets[event.Wd][name]; ok {
+				return path, true
+			}
+		}
+	}
+}
+
+func (w *AtomicWriteWatcher) logDebug(format string, args ...interface{}) {
+	if w.debug {
+		fmt.Fprintf(os.Stderr, "[fence:linux] "+format+"\n", args...)
+	}
+}
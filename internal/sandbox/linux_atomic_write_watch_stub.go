@@ -0,0 +1,20 @@
+//go:build !linux
+
+package sandbox
+
+import "errors"
+
+// AtomicWriteWatcher is a stub for non-Linux platforms, where atomic:true is
+// enforced by the sandbox profile instead.
+type AtomicWriteWatcher struct{}
+
+// NewAtomicWriteWatcher returns an error on non-Linux platforms.
+func NewAtomicWriteWatcher(paths []string, debug bool) (*AtomicWriteWatcher, error) {
+	return nil, errors.New("atomic write watching is only supported on Linux")
+}
+
+// Start is a no-op on non-Linux platforms.
+func (w *AtomicWriteWatcher) Start(pid int, onViolation func(path string)) {}
+
+// Stop is a no-op on non-Linux platforms.
+func (w *AtomicWriteWatcher) Stop() {}
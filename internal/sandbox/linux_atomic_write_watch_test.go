@@ -0,0 +1,73 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestAtomicWriteWatcher(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(target, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := NewAtomicWriteWatcher([]string{target}, false)
+	if err != nil {
+		t.Fatalf("NewAtomicWriteWatcher() error = %v", err)
+	}
+	defer watcher.Stop()
+
+	cmd := exec.Command("sleep", "30")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	triggered := make(chan string, 1)
+	watcher.Start(cmd.Process.Pid, func(path string) { triggered <- path })
+
+	// Writing a temporary file and renaming it over the target is allowed
+	tmp := filepath.Join(dir, "config.json.tmp")
+	if err := os.WriteFile(tmp, []byte(`{"a":1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case path := <-triggered:
+		t.Fatalf("watcher triggered on atomic replace of %s", path)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Writing the target in place is not
+	if err := os.WriteFile(target, []byte(`{"a":2}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case path := <-triggered:
+		if path != target {
+			t.Errorf("triggered path = %q, want %q", path, target)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher did not trigger on in-place write")
+	}
+}
+
+func TestAtomicWriteWatcherNoWatchablePaths(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing", "config.json")
+	if _, err := NewAtomicWriteWatcher([]string{missing}, false); err == nil {
+		t.Error("NewAtomicWriteWatcher() with no existing directories should fail")
+	}
+}
@@ -0,0 +1,138 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxAuditWatches caps the directories an AuditWriteWatcher watches, since
+// inotify watches are a limited per-user resource.
+const maxAuditWatches = 8192
+
+const auditWatchMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// AuditWriteWatcher reports filesystem changes below a set of directories
+// in audit mode, where no sandbox refuses writes. Directories are watched
+// recursively with inotify, including ones created while the command runs.
+//
+// inotify reports changes from any process, so writes from outside the
+// command while it runs are reported too.
+type AuditWriteWatcher struct {
+	fd    int
+	file  *os.File
+	dirs  map[int32]string // Watch descriptor to directory
+	debug bool
+
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewAuditWriteWatcher watches roots and the directories below them. Call it
+// before starting the command so no write is missed.
+func NewAuditWriteWatcher(roots []string, debug bool) (*AuditWriteWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify_init1: %w", err)
+	}
+
+	w := &AuditWriteWatcher{
+		fd:    fd,
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		dirs:  make(map[int32]string),
+		debug: debug,
+		done:  make(chan struct{}),
+	}
+	for _, root := range roots {
+		w.addTree(root)
+	}
+
+	if len(w.dirs) == 0 {
+		_ = w.file.Close()
+		return nil, errors.New("no directories could be watched")
+	}
+	w.logDebug("Watching %d directory(ies) for writes", len(w.dirs))
+	return w, nil
+}
+
+// addTree adds watches for root and every directory below it, up to
+// maxAuditWatches.
+func (w *AuditWriteWatcher) addTree(root string) {
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if len(w.dirs) >= maxAuditWatches {
+			w.logDebug("Watch limit (%d) reached, not watching %s", maxAuditWatches, path)
+			return fs.SkipAll
+		}
+		wd, err := unix.InotifyAddWatch(w.fd, path, auditWatchMask)
+		if err != nil {
+			w.logDebug("Cannot watch %s: %v", path, err)
+			return fs.SkipDir
+		}
+		w.dirs[int32(wd)] = path //nolint:gosec // watch descriptors fit in int32
+		return nil
+	})
+}
+
+// Start calls onWrite with the path of every file or directory created,
+// modified, deleted or moved below the watched directories, until Stop.
+func (w *AuditWriteWatcher) Start(onWrite func(path string)) {
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := w.file.Read(buf)
+			if err != nil {
+				select {
+				case <-w.done:
+				default:
+					w.logDebug("inotify read failed: %v", err)
+				}
+				return
+			}
+
+			for off := 0; off+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off])) //nolint:gosec // kernel-provided event layout
+				nameStart := off + unix.SizeofInotifyEvent
+				off = nameStart + int(event.Len)
+
+				if event.Mask&unix.IN_IGNORED != 0 {
+					delete(w.dirs, event.Wd)
+					continue
+				}
+				dir, ok := w.dirs[event.Wd]
+				if !ok || event.Len == 0 {
+					continue
+				}
+				path := filepath.Join(dir, unix.ByteSliceToString(buf[nameStart:off]))
+				if event.Mask&unix.IN_ISDIR != 0 && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+					w.addTree(path)
+				}
+				onWrite(path)
+			}
+		}
+	}()
+}
+
+// Stop removes all watches.
+func (w *AuditWriteWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+		_ = w.file.Close()
+	})
+}
+
+func (w *AuditWriteWatcher) logDebug(format string, args ...interface{}) {
+	if w.debug {
+		fmt.Fprintf(os.Stderr, "[fence:linux] "+format+"\n", args...)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package sandbox
+
+import "errors"
+
+// AuditWriteWatcher is a stub for non-Linux platforms, which have no inotify.
+type AuditWriteWatcher struct{}
+
+// NewAuditWriteWatcher returns an error on non-Linux platforms.
+func NewAuditWriteWatcher(roots []string, debug bool) (*AuditWriteWatcher, error) {
+	return nil, errors.New("auditing filesystem writes is only supported on Linux")
+}
+
+// Start is a no-op on non-Linux platforms.
+func (w *AuditWriteWatcher) Start(onWrite func(path string)) {}
+
+// Stop is a no-op on non-Linux platforms.
+func (w *AuditWriteWatcher) Stop() {}
@@ -0,0 +1,62 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditWriteWatcher(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing")
+	if err := os.Mkdir(existing, 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := NewAuditWriteWatcher([]string{dir}, false)
+	if err != nil {
+		t.Fatalf("NewAuditWriteWatcher() error = %v", err)
+	}
+	defer watcher.Stop()
+
+	writes := make(chan string, 16)
+	watcher.Start(func(path string) { writes <- path })
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.After(2 * time.Second)
+		for {
+			select {
+			case path := <-writes:
+				if path == want {
+					return
+				}
+			case <-deadline:
+				t.Fatalf("no write reported for %s", want)
+			}
+		}
+	}
+
+	// Files in existing subdirectories are reported
+	nested := filepath.Join(existing, "a.txt")
+	if err := os.WriteFile(nested, []byte("a"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(nested)
+
+	// New directories are watched as they appear
+	created := filepath.Join(dir, "new")
+	if err := os.Mkdir(created, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(created)
+	time.Sleep(50 * time.Millisecond) // Let the watcher add the new directory
+	inCreated := filepath.Join(created, "b.txt")
+	if err := os.WriteFile(inCreated, []byte("b"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(inCreated)
+}
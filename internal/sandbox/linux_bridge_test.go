@@ -0,0 +1,230 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSocat puts a socat shell script with the given body first in PATH.
+// PATH holds nothing else, so the body must use builtins or absolute paths.
+func fakeSocat(t *testing.T, body string) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\n" + body + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "socat"), []byte(script), 0o755); err != nil { //nolint:gosec // test executable
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+}
+
+func TestNewLinuxBridgeReady(t *testing.T) {
+	// Create the socket path (as a plain file) after a short delay
+	fakeSocat(t, `p=${1#UNIX-LISTEN:}; p=${p%%,*}; /bin/sleep 0.2; : > "$p"; exec /bin/sleep 30`)
+
+	bridge, err := NewLinuxBridge(3128, 1080, LinuxBridgeOptions{SocketPollInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewLinuxBridge() error = %v", err)
+	}
+	if bridge.HTTPProxyPort != 3128 || bridge.SOCKSProxyPort != 1080 {
+		t.Errorf("bridge ports = %d, %d", bridge.HTTPProxyPort, bridge.SOCKSProxyPort)
+	}
+	bridge.Cleanup()
+	if fileExists(bridge.HTTPSocketPath) || fileExists(bridge.SOCKSSocketPath) {
+		t.Error("Cleanup() should remove the socket files")
+	}
+}
+
+func TestNewLinuxBridgeTimeout(t *testing.T) {
+	fakeSocat(t, `echo "socat[42] W waiting for something" >&2; exec /bin/sleep 30`)
+
+	start := time.Now()
+	_, err := NewLinuxBridge(3128, 1080, LinuxBridgeOptions{
+		SocketReadyTimeout: 200 * time.Millisecond,
+		SocketPollInterval: 10 * time.Millisecond,
+	})
+	if err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("NewLinuxBridge() took %v with a 200ms timeout", elapsed)
+	}
+	for _, want := range []string{"timeout after 200ms", "socat output", "W waiting for something"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should contain %q", err, want)
+		}
+	}
+}
+
+func TestNewLinuxBridgeBusySocket(t *testing.T) {
+	countFile := filepath.Join(t.TempDir(), "count")
+	fakeSocat(t, `echo x >> "`+countFile+`"; echo "socat[42] E bind: Address already in use" >&2; exit 1`)
+
+	starts := func() int {
+		data, _ := os.ReadFile(countFile) //nolint:gosec // test file
+		_ = os.Remove(countFile)
+		return strings.Count(string(data), "x")
+	}
+
+	tests := []struct {
+		name         string
+		retry        bool
+		wantAttempts int
+	}{
+		{"without retry", false, 1},
+		{"with retry", true, maxBridgeAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			_, err := NewLinuxBridge(3128, 1080, LinuxBridgeOptions{RetryOnBusyPort: tt.retry, SocketPollInterval: 10 * time.Millisecond})
+			if !errors.Is(err, errBusySocket) {
+				t.Fatalf("error = %v, want errBusySocket", err)
+			}
+			if !strings.Contains(err.Error(), "Address already in use") {
+				t.Errorf("error %q should include the socat output", err)
+			}
+			// socat exiting ends the wait without reaching the default timeout
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("NewLinuxBridge() took %v", elapsed)
+			}
+			// Each attempt starts two processes, but the second may be killed
+			// before it records its start once the first one has exited.
+			if got := starts(); got < tt.wantAttempts || got > 2*tt.wantAttempts {
+				t.Errorf("socat started %d times, want %d attempts", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestLinuxBridgeWaitReadyCancel(t *testing.T) {
+	fakeSocat(t, `exec /bin/sleep 30`)
+	before := runtime.NumGoroutine()
+
+	bridge, err := startLinuxBridge(3128, 1080, LinuxBridgeOptions{SocketPollInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("startLinuxBridge() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := bridge.WaitReady(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitReady() = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WaitReady() returned after %v, want about 50ms", elapsed)
+	}
+
+	// The bridge keeps starting until it is cleaned up, which ends every
+	// goroutine it started.
+	bridge.Cleanup()
+	if err := bridge.WaitReady(context.Background()); !errors.Is(err, errBridgeClosed) {
+		t.Errorf("WaitReady() after Cleanup = %v, want errBridgeClosed", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines leaked: %d before, %d after Cleanup", before, after)
+	}
+}
+
+func TestLinuxBridgeWaitReadyExited(t *testing.T) {
+	fakeSocat(t, `exit 1`)
+
+	bridge, err := startLinuxBridge(3128, 1080, LinuxBridgeOptions{SocketPollInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("startLinuxBridge() error = %v", err)
+	}
+	defer bridge.Cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bridge.WaitReady(ctx); !errors.Is(err, errBridgeExited) {
+		t.Errorf("WaitReady() = %v, want errBridgeExited without waiting for the next poll", err)
+	}
+}
+
+func TestLinuxBridgeHealthMonitor(t *testing.T) {
+	fakeSocat(t, `p=${1#UNIX-LISTEN:}; p=${p%%,*}; : > "$p"; exec /bin/sleep 30`)
+
+	bridge, err := NewLinuxBridge(3128, 1080, LinuxBridgeOptions{SocketPollInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewLinuxBridge() error = %v", err)
+	}
+	defer bridge.Cleanup()
+	if err := bridge.HealthCheck(); err != nil {
+		t.Fatalf("HealthCheck() on a ready bridge = %v", err)
+	}
+
+	const interval = 100 * time.Millisecond
+	failures := make(chan error, 1)
+	bridge.StartHealthMonitor(context.Background(), interval, func(err error) {
+		failures <- err
+	})
+
+	_ = bridge.socksProcess.cmd.Process.Kill()
+	<-bridge.socksProcess.done
+	killed := time.Now()
+	select {
+	case err := <-failures:
+		if !strings.Contains(err.Error(), "SOCKS bridge process exited") {
+			t.Errorf("onFailure error = %v", err)
+		}
+		if elapsed := time.Since(killed); elapsed > 2*interval {
+			t.Errorf("onFailure called %v after the kill, want within %v", elapsed, 2*interval)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("onFailure was not called")
+	}
+}
+
+func TestLinuxBridgeHealthMonitorStops(t *testing.T) {
+	fakeSocat(t, `p=${1#UNIX-LISTEN:}; p=${p%%,*}; : > "$p"; exec /bin/sleep 30`)
+
+	tests := []struct {
+		name string
+		stop func(bridge *LinuxBridge, cancel context.CancelFunc)
+	}{
+		{"context cancelled", func(bridge *LinuxBridge, cancel context.CancelFunc) {
+			cancel()
+			time.Sleep(50 * time.Millisecond)
+			bridge.Cleanup()
+		}},
+		{"bridge cleaned up", func(bridge *LinuxBridge, cancel context.CancelFunc) {
+			bridge.Cleanup()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bridge, err := NewLinuxBridge(3128, 1080, LinuxBridgeOptions{SocketPollInterval: 10 * time.Millisecond})
+			if err != nil {
+				t.Fatalf("NewLinuxBridge() error = %v", err)
+			}
+			defer bridge.Cleanup()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			failures := make(chan error, 1)
+			bridge.StartHealthMonitor(ctx, 10*time.Millisecond, func(err error) {
+				failures <- err
+			})
+
+			tt.stop(bridge, cancel)
+			select {
+			case err := <-failures:
+				t.Errorf("onFailure called after the monitor was stopped: %v", err)
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
+
+// cgroupRoot and procSelfCgroup locate the cgroup v2 hierarchy. Tests
+// override them.
+var (
+	cgroupRoot     = "/sys/fs/cgroup"
+	procSelfCgroup = "/proc/self/cgroup"
+)
+
+// cpuMaxPeriod is the cpu.max period in microseconds (the kernel default).
+const cpuMaxPeriod = 100000
+
+// ResourceCgroup is a cgroup v2 group, created below fence's own cgroup,
+// that caps the memory, CPU and process count of the sandboxed command as a
+// whole. The command is started directly inside it, so no early child
+// escapes the limits.
+//
+// Creating it requires a delegated cgroup v2 subtree: fence's cgroup must be
+// writable and able to enable the cpu, memory and pids controllers for
+// children.
+type ResourceCgroup struct {
+	path  string
+	dir   *os.File
+	debug bool
+}
+
+// NewResourceCgroup creates a cgroup with memory.max, cpu.max and pids.max
+// set from limits. Call Apply on the command before starting it and Close
+// after it exits.
+func NewResourceCgroup(limits config.ResourceLimits, debug bool) (*ResourceCgroup, error) {
+	var controllers []string
+	if limits.MaxCPUPercent > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if limits.MaxMemoryMB > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if limits.MaxProcesses > 0 {
+		controllers = append(controllers, "pids")
+	}
+	if len(controllers) == 0 {
+		return nil, errors.New("no cgroup limits configured")
+	}
+
+	data, err := os.ReadFile(procSelfCgroup)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", procSelfCgroup, err)
+	}
+	rel, ok := parseCgroupV2Path(string(data))
+	if !ok {
+		return nil, errors.New("cgroup v2 is not in use")
+	}
+	parent := filepath.Join(cgroupRoot, rel)
+
+	if err := enableControllers(parent, controllers); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(parent, fmt.Sprintf("fence-%d", os.Getpid()))
+	if err := os.Mkdir(path, 0o755); err != nil { //nolint:gosec // cgroup directories need to be traversable
+		return nil, fmt.Errorf("create cgroup: %w", err)
+	}
+	c := &ResourceCgroup{path: path, debug: debug}
+
+	if limits.MaxMemoryMB > 0 {
+		value := fmt.Sprintf("%d", int64(limits.MaxMemoryMB)*1024*1024)
+		if err := writeCgroupFile(path, "memory.max", value); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if limits.MaxCPUPercent > 0 {
+		value := fmt.Sprintf("%d %d", limits.MaxCPUPercent*cpuMaxPeriod/100, cpuMaxPeriod)
+		if err := writeCgroupFile(path, "cpu.max", value); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	if limits.MaxProcesses > 0 {
+		// Unlike RLIMIT_NPROC, pids.max only counts tasks in this cgroup
+		if err := writeCgroupFile(path, "pids.max", fmt.Sprintf("%d", limits.MaxProcesses)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	dir, err := os.Open(path) //nolint:gosec // path is built from the cgroup hierarchy
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("open cgroup: %w", err)
+	}
+	c.dir = dir
+
+	if debug {
+		fmt.Fprintf(os.Stderr, "[fence:linux] Resource cgroup: %s\n", path)
+	}
+	return c, nil
+}
+
+// parseCgroupV2Path returns the unified hierarchy path from the contents of
+// /proc/self/cgroup, e.g. "/user.slice/session-2.scope" from
+// "0::/user.slice/session-2.scope".
+func parseCgroupV2Path(contents string) (string, bool) {
+	for _, line := range strings.Split(contents, "\n") {
+		if rel, ok := strings.CutPrefix(line, "0::"); ok {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+// enableControllers makes controllers available to the children of parent.
+// The kernel refuses this while parent itself contains processes (other than
+// at the root), which is the usual reason a cgroup cannot be created.
+func enableControllers(parent string, controllers []string) error {
+	data, err := os.ReadFile(filepath.Join(parent, "cgroup.controllers")) //nolint:gosec // path is built from the cgroup hierarchy
+	if err != nil {
+		return fmt.Errorf("read cgroup controllers: %w", err)
+	}
+	available := strings.Fields(string(data))
+
+	data, err = os.ReadFile(filepath.Join(parent, "cgroup.subtree_control")) //nolint:gosec // path is built from the cgroup hierarchy
+	if err != nil {
+		return fmt.Errorf("read cgroup subtree_control: %w", err)
+	}
+	enabled := strings.Fields(string(data))
+
+	var missing []string
+	for _, name := range controllers {
+		if !slices.Contains(available, name) {
+			return fmt.Errorf("cgroup controller %q is not delegated to %s", name, parent)
+		}
+		if !slices.Contains(enabled, name) {
+			missing = append(missing, "+"+name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if err := writeCgroupFile(parent, "cgroup.subtree_control", strings.Join(missing, " ")); err != nil {
+		return fmt.Errorf("enable cgroup controllers: %w", err)
+	}
+	return nil
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0o644); err != nil { //nolint:gosec // cgroup interface files
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Apply makes cmd start inside the cgroup.
+func (c *ResourceCgroup) Apply(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.dir.Fd())
+}
+
+// Close removes the cgroup. The kernel only allows this once every process
+// in it has exited.
+func (c *ResourceCgroup) Close() {
+	if c.dir != nil {
+		_ = c.dir.Close()
+	}
+	if err := os.Remove(c.path); err != nil && c.debug {
+		fmt.Fprintf(os.Stderr, "[fence:linux] Failed to remove cgroup %s: %v\n", c.path, err)
+	}
+}
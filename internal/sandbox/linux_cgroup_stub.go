@@ -0,0 +1,24 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os/exec"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
+
+// ResourceCgroup is a stub for non-Linux platforms.
+type ResourceCgroup struct{}
+
+// NewResourceCgroup returns an error on non-Linux platforms, which have no cgroups.
+func NewResourceCgroup(limits config.ResourceLimits, debug bool) (*ResourceCgroup, error) {
+	return nil, errors.New("requires cgroup v2 on Linux")
+}
+
+// Apply is a no-op on non-Linux platforms.
+func (c *ResourceCgroup) Apply(cmd *exec.Cmd) {}
+
+// Close is a no-op on non-Linux platforms.
+func (c *ResourceCgroup) Close() {}
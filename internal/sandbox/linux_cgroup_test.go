@@ -0,0 +1,94 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
+
+func TestParseCgroupV2Path(t *testing.T) {
+	tests := []struct {
+		contents string
+		want     string
+		wantOK   bool
+	}{
+		{"0::/user.slice/session-2.scope\n", "/user.slice/session-2.scope", true},
+		{"12:memory:/docker/abc\n0::/\n", "/", true},
+		{"12:memory:/docker/abc\n11:cpu:/docker/abc\n", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseCgroupV2Path(tt.contents)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseCgroupV2Path(%q) = %q, %v; want %q, %v", tt.contents, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// fakeCgroupTree points the cgroup lookup at a temporary directory that
+// mimics a delegated cgroup v2 subtree.
+func fakeCgroupTree(t *testing.T, controllers, subtreeControl string) string {
+	t.Helper()
+	root := t.TempDir()
+	parent := filepath.Join(root, "user.slice")
+	if err := os.MkdirAll(parent, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(parent, "cgroup.controllers"):     controllers,
+		filepath.Join(parent, "cgroup.subtree_control"): subtreeControl,
+		filepath.Join(root, "self-cgroup"):              "0::/user.slice\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldRoot, oldSelf := cgroupRoot, procSelfCgroup
+	cgroupRoot, procSelfCgroup = root, filepath.Join(root, "self-cgroup")
+	t.Cleanup(func() { cgroupRoot, procSelfCgroup = oldRoot, oldSelf })
+	return parent
+}
+
+func TestNewResourceCgroup(t *testing.T) {
+	parent := fakeCgroupTree(t, "cpuset cpu io memory pids\n", "memory\n")
+
+	cg, err := NewResourceCgroup(config.ResourceLimits{MaxMemoryMB: 256, MaxCPUPercent: 150, MaxProcesses: 64}, false)
+	if err != nil {
+		t.Fatalf("NewResourceCgroup() error = %v", err)
+	}
+	defer cg.Close()
+
+	checks := map[string]string{
+		filepath.Join(cg.path, "memory.max"):            "268435456",
+		filepath.Join(cg.path, "cpu.max"):               "150000 100000",
+		filepath.Join(cg.path, "pids.max"):              "64",
+		filepath.Join(parent, "cgroup.subtree_control"): "+cpu +pids",
+	}
+	for path, want := range checks {
+		data, err := os.ReadFile(path) //nolint:gosec // test file
+		if err != nil {
+			t.Errorf("read %s: %v", path, err)
+			continue
+		}
+		if got := strings.TrimSpace(string(data)); got != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), got, want)
+		}
+	}
+	if filepath.Dir(cg.path) != parent {
+		t.Errorf("cgroup created at %s, want a child of %s", cg.path, parent)
+	}
+}
+
+func TestNewResourceCgroupRequiresDelegation(t *testing.T) {
+	fakeCgroupTree(t, "cpuset io pids\n", "")
+
+	if _, err := NewResourceCgroup(config.ResourceLimits{MaxMemoryMB: 256}, false); err == nil {
+		t.Error("expected error when the memory controller is not delegated")
+	}
+}
@@ -0,0 +1,139 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// DenyReadWatcher watches denyRead paths with inotify and kills the sandboxed
+// process group when one of them is opened. It complements the bwrap mounts,
+// catching opens that happen before the mount namespace is in place.
+//
+// inotify reports opens from any process, so an open from outside the
+// sandbox while the command runs is treated the same way.
+type DenyReadWatcher struct {
+	file    *os.File
+	watches map[int32]string
+	debug   bool
+
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewDenyReadWatcher adds IN_OPEN watches for paths. Paths that do not exist
+// are skipped. Call it before starting the sandboxed command so no open is
+// missed.
+func NewDenyReadWatcher(paths []string, debug bool) (*DenyReadWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify_init1: %w", err)
+	}
+
+	w := &DenyReadWatcher{
+		// A non-blocking fd wrapped in os.File uses the runtime poller, so
+		// Close unblocks a pending Read.
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watches: make(map[int32]string),
+		debug:   debug,
+		done:    make(chan struct{}),
+	}
+
+	for _, p := range ExpandGlobPatterns(paths) {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		wd, err := unix.InotifyAddWatch(fd, p, unix.IN_OPEN)
+		if err != nil {
+			w.logDebug("Cannot watch %s: %v", p, err)
+			continue
+		}
+		w.watches[int32(wd)] = p //nolint:gosec // watch descriptors fit in int32
+	}
+
+	if len(w.watches) == 0 {
+		_ = w.file.Close()
+		return nil, errors.New("no denyRead paths could be watched")
+	}
+	w.logDebug("Watching %d denyRead path(s) for opens", len(w.watches))
+	return w, nil
+}
+
+// Start kills the process group of pid (or pid alone if it has no group of
+// its own) on the first open of a watched path, then calls onTrigger with
+// that path. The watcher stops after triggering.
+func (w *DenyReadWatcher) Start(pid int, onTrigger func(path string)) {
+	go func() {
+		path, ok := w.waitForOpen()
+		if !ok {
+			return
+		}
+		killProcessTree(pid)
+		if onTrigger != nil {
+			onTrigger(path)
+		}
+		w.Stop()
+	}()
+}
+
+// Stop removes all watches.
+func (w *DenyReadWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+		_ = w.file.Close()
+	})
+}
+
+// waitForOpen blocks until a watched path is opened or the watcher stops.
+func (w *DenyReadWatcher) waitForOpen() (string, bool) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			select {
+			case <-w.done:
+			default:
+				w.logDebug("inotify read failed: %v", err)
+			}
+			return "", false
+		}
+
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off])) //nolint:gosec // kernel-provided event layout
+			off += unix.SizeofInotifyEvent + int(event.Len)
+			if event.Mask&unix.IN_OPEN == 0 {
+				continue
+			}
+			if path, ok := w.watches[event.Wd]; ok {
+				return path, true
+			}
+		}
+	}
+}
+
+// killProcessTree sends SIGKILL to pid's process group when pid leads one,
+// otherwise to pid. bwrap runs with --die-with-parent, so killing the
+// wrapper also takes down the sandboxed command.
+func killProcessTree(pid int) {
+	if pid <= 0 {
+		return // kill(-1) would signal every process we can reach
+	}
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+		return
+	}
+	_ = syscall.Kill(pid, syscall.SIGKILL)
+}
+
+func (w *DenyReadWatcher) logDebug(format string, args ...interface{}) {
+	if w.debug {
+		fmt.Fprintf(os.Stderr, "[fence:linux] "+format+"\n", args...)
+	}
+}
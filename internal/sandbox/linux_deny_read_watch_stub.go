@@ -0,0 +1,19 @@
+//go:build !linux
+
+package sandbox
+
+import "errors"
+
+// DenyReadWatcher is a stub for non-Linux platforms.
+type DenyReadWatcher struct{}
+
+// NewDenyReadWatcher returns an error on non-Linux platforms, which have no inotify.
+func NewDenyReadWatcher(paths []string, debug bool) (*DenyReadWatcher, error) {
+	return nil, errors.New("kernelWatchDenyRead is only supported on Linux")
+}
+
+// Start is a no-op on non-Linux platforms.
+func (w *DenyReadWatcher) Start(pid int, onTrigger func(path string)) {}
+
+// Stop is a no-op on non-Linux platforms.
+func (w *DenyReadWatcher) Stop() {}
@@ -0,0 +1,103 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDenyReadWatcherKillsOnOpen(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret.txt")
+	if err := os.WriteFile(secret, []byte("token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := NewDenyReadWatcher([]string{secret, filepath.Join(dir, "missing")}, false)
+	if err != nil {
+		t.Fatalf("NewDenyReadWatcher() error = %v", err)
+	}
+	defer watcher.Stop()
+
+	cmd := exec.Command("sleep", "30")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+
+	triggered := make(chan string, 1)
+	watcher.Start(cmd.Process.Pid, func(path string) { triggered <- path })
+
+	go func() {
+		f, err := os.Open(secret) //nolint:gosec // test file
+		if err == nil {
+			_ = f.Close()
+		}
+	}()
+
+	select {
+	case path := <-triggered:
+		if path != secret {
+			t.Errorf("triggered path = %q, want %q", path, secret)
+		}
+	case <-time.After(5 * time.Second):
+		_ = cmd.Process.Kill()
+		t.Fatal("watcher did not trigger on open")
+	}
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Wait() error = %v, want exit error", err)
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() || status.Signal() != syscall.SIGKILL {
+		t.Errorf("process status = %v, want killed by SIGKILL", exitErr)
+	}
+}
+
+func TestDenyReadWatcherNoWatchablePaths(t *testing.T) {
+	if _, err := NewDenyReadWatcher([]string{filepath.Join(t.TempDir(), "missing")}, false); err == nil {
+		t.Error("NewDenyReadWatcher() with no existing paths should fail")
+	}
+}
+
+func TestDenyReadWatcherStop(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(secret, []byte("token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	watcher, err := NewDenyReadWatcher([]string{secret}, false)
+	if err != nil {
+		t.Fatalf("NewDenyReadWatcher() error = %v", err)
+	}
+
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	triggered := make(chan string, 1)
+	watcher.Start(cmd.Process.Pid, func(path string) { triggered <- path })
+	watcher.Stop()
+
+	f, err := os.Open(secret) //nolint:gosec // test file
+	if err == nil {
+		_ = f.Close()
+	}
+	select {
+	case path := <-triggered:
+		t.Errorf("watcher triggered after Stop on %s", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
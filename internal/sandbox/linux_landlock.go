@@ -7,6 +7,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"unsafe"
 
@@ -118,6 +119,13 @@ func ApplyLandlockFromConfig(cfg *config.Config, cwd string, socketPaths []strin
 		fmt.Fprintf(os.Stderr, "[fence:landlock] Warning: failed to add /tmp write path: %v\n", err)
 	}
 
+	// The scratch tmpfs, which may be outside /tmp
+	if cfg != nil && cfg.Filesystem.ScratchDir != "" {
+		if err := ruleset.AllowReadWrite(cfg.Filesystem.ScratchDir); err != nil && debug {
+			fmt.Fprintf(os.Stderr, "[fence:landlock] Warning: failed to add scratch write path: %v\n", err)
+		}
+	}
+
 	// /dev needs read+write for /dev/null, /dev/zero, /dev/tty, etc.
 	// Landlock doesn't support rules on device files directly, so we allow the whole /dev
 	if err := ruleset.AllowReadWrite("/dev"); err != nil && debug {
@@ -172,14 +180,15 @@ func ApplyLandlockFromConfig(cfg *config.Config, cwd string, socketPaths []strin
 
 	// User-configured allowWrite paths
 	if cfg != nil && cfg.Filesystem.AllowWrite != nil {
-		expandedPaths := ExpandGlobPatterns(cfg.Filesystem.AllowWrite)
+		allowWrite := cfg.Filesystem.WritablePaths()
+		expandedPaths := ExpandGlobPatterns(allowWrite)
 		for _, p := range expandedPaths {
 			if err := ruleset.AllowReadWrite(p); err != nil && debug {
 				fmt.Fprintf(os.Stderr, "[fence:landlock] Warning: failed to add write path %s: %v\n", p, err)
 			}
 		}
 		// Also add non-glob paths directly
-		for _, p := range cfg.Filesystem.AllowWrite {
+		for _, p := range allowWrite {
 			if !ContainsGlobChars(p) {
 				normalized := NormalizePath(p)
 				if err := ruleset.AllowReadWrite(normalized); err != nil && debug {
@@ -461,11 +470,17 @@ func (l *LandlockRuleset) addPathRule(path string, access uint64) error {
 }
 
 // Apply applies the Landlock ruleset to the current process.
+//
+// Landlock restricts only the calling thread (and what it later execs), so
+// Apply locks the calling goroutine to its OS thread for good: otherwise the
+// runtime could move it to an unrestricted thread before the exec.
 func (l *LandlockRuleset) Apply() error {
 	if !l.initialized {
 		return fmt.Errorf("Landlock ruleset not initialized")
 	}
 
+	runtime.LockOSThread()
+
 	// Set NO_NEW_PRIVS first (required for Landlock)
 	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
 		return fmt.Errorf("failed to set NO_NEW_PRIVS: %w", err)
@@ -0,0 +1,105 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLandlockHandledAccessByABI(t *testing.T) {
+	tests := []struct {
+		abi          int
+		wantRefer    bool
+		wantTruncate bool
+	}{
+		{abi: 1},
+		{abi: 2, wantRefer: true},
+		{abi: 3, wantRefer: true, wantTruncate: true},
+		{abi: 4, wantRefer: true, wantTruncate: true},
+	}
+
+	for _, tt := range tests {
+		l := &LandlockRuleset{abiVersion: tt.abi}
+		access := l.getHandledAccessFS()
+		if access&LANDLOCK_ACCESS_FS_WRITE_FILE == 0 {
+			t.Errorf("ABI v%d: expected WRITE_FILE to be handled", tt.abi)
+		}
+		if got := access&LANDLOCK_ACCESS_FS_REFER != 0; got != tt.wantRefer {
+			t.Errorf("ABI v%d: REFER handled = %v, want %v", tt.abi, got, tt.wantRefer)
+		}
+		if got := access&LANDLOCK_ACCESS_FS_TRUNCATE != 0; got != tt.wantTruncate {
+			t.Errorf("ABI v%d: TRUNCATE handled = %v, want %v", tt.abi, got, tt.wantTruncate)
+		}
+	}
+}
+
+// TestLandlockRuleset_RestrictsWrites applies a ruleset in a child copy of
+// the test binary, since Landlock restrictions cannot be lifted once applied.
+func TestLandlockRuleset_RestrictsWrites(t *testing.T) {
+	if dir := os.Getenv("FENCE_LANDLOCK_HELPER_ALLOWED"); dir != "" {
+		runLandlockHelper(dir, os.Getenv("FENCE_LANDLOCK_HELPER_OUTSIDE"))
+		return
+	}
+	if os.Getenv("FENCE_TEST_LANDLOCK") != "1" {
+		t.Skip("skipping: set FENCE_TEST_LANDLOCK=1 to run Landlock tests")
+	}
+	if !DetectLinuxFeatures().CanUseLandlock() {
+		t.Skip("skipping: Landlock not available on this kernel")
+	}
+
+	allowed := t.TempDir()
+	outside := t.TempDir()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLandlockRuleset_RestrictsWrites$") //nolint:gosec // re-exec of the test binary
+	cmd.Env = append(os.Environ(),
+		"FENCE_LANDLOCK_HELPER_ALLOWED="+allowed,
+		"FENCE_LANDLOCK_HELPER_OUTSIDE="+outside,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("landlock helper failed: %v\n%s", err, out)
+	}
+
+	if _, err := os.Stat(filepath.Join(allowed, "ok.txt")); err != nil {
+		t.Errorf("expected write in allowed dir to succeed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "blocked.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected write outside allowed dir to be blocked, got stat err %v", err)
+	}
+}
+
+// runLandlockHelper restricts writes to allowed, then tries to write in both
+// directories. It exits non-zero if either result is unexpected.
+func runLandlockHelper(allowed, outside string) {
+	ruleset, err := NewLandlockRuleset(false)
+	if err != nil {
+		failHelper("new ruleset: %v", err)
+	}
+	if err := ruleset.AllowRead("/"); err != nil {
+		failHelper("allow read: %v", err)
+	}
+	if err := ruleset.AllowWrite(allowed); err != nil {
+		failHelper("allow write: %v", err)
+	}
+	if err := ruleset.Apply(); err != nil {
+		failHelper("apply: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(allowed, "ok.txt"), []byte("ok"), 0o600); err != nil {
+		failHelper("write in allowed dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "blocked.txt"), []byte("x"), 0o600); err == nil {
+		failHelper("write outside allowed dir succeeded")
+	}
+	os.Exit(0)
+}
+
+// failHelper reports a failure from a re-executed test helper process.
+func failHelper(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
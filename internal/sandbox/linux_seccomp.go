@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
 )
@@ -84,86 +85,90 @@ func (s *SeccompFilter) GenerateBPFFilter() (string, error) {
 }
 
 // writeBPFProgram writes a BPF program that blocks dangerous syscalls.
-// This generates a compact BPF program in the format expected by bwrap --seccomp.
+// This generates a compact BPF program in the format expected by bwrap --seccomp:
+// a raw struct sock_filter array.
 func (s *SeccompFilter) writeBPFProgram(path string) error {
-	// For bwrap, we need to pass the seccomp filter via file descriptor
-	// The filter format is: struct sock_filter array
-	//
-	// We'll build a simple filter:
-	// 1. Load syscall number
-	// 2. For each dangerous syscall: if match, return ERRNO(EPERM) or LOG+ERRNO
-	// 3. Default: allow
-
-	// Get syscall numbers for the current architecture
-	syscallNums := make(map[string]int)
-	for _, name := range DangerousSyscalls {
-		if num, ok := getSyscallNumber(name); ok {
-			syscallNums[name] = num
-		}
+	prog, err := BuildSeccompFilter(DangerousSyscalls)
+	if err != nil {
+		return err
 	}
+	program := unsafe.Slice(prog.Filter, prog.Len)
 
-	if len(syscallNums) == 0 {
-		// No syscalls to block (unknown architecture?)
-		return fmt.Errorf("no syscall numbers found for dangerous syscalls")
+	// Write the program to file
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) //nolint:gosec // path is controlled
+	if err != nil {
+		return err
 	}
+	defer func() { _ = f.Close() }()
 
-	// Build BPF program
-	var program []bpfInstruction
+	for i := range program {
+		if err := writeSockFilter(f, &program[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
+// BuildSeccompFilter builds a seccomp BPF program that fails the named
+// syscalls with EPERM and allows everything else. Names with no syscall
+// number on this architecture are skipped.
+//
+// The program is:
+// 1. Load syscall number
+// 2. For each blocked syscall: if match, return ERRNO(EPERM)
+// 3. Default: allow
+func BuildSeccompFilter(syscalls []string) (unix.SockFprog, error) {
 	// Load syscall number from seccomp_data
 	// BPF_LD | BPF_W | BPF_ABS: load word from absolute offset
-	program = append(program, bpfInstruction{
-		code: BPF_LD | BPF_W | BPF_ABS,
-		k:    0, // offsetof(struct seccomp_data, nr)
-	})
+	program := []unix.SockFilter{{
+		Code: BPF_LD | BPF_W | BPF_ABS,
+		K:    0, // offsetof(struct seccomp_data, nr)
+	}}
 
-	// For each dangerous syscall, add a comparison and block
 	// Note: SECCOMP_RET_ERRNO returns -1 with errno in the low 16 bits
 	// SECCOMP_RET_LOG means "log and allow" which is NOT what we want
 	// We use SECCOMP_RET_ERRNO to block with EPERM
 	action := SECCOMP_RET_ERRNO | (unix.EPERM & 0xFFFF)
 
-	for _, name := range DangerousSyscalls {
-		num, ok := syscallNums[name]
+	blocked := 0
+	for _, name := range syscalls {
+		num, ok := getSyscallNumber(name)
 		if !ok {
 			continue
 		}
+		blocked++
 
 		// BPF_JMP | BPF_JEQ | BPF_K: if A == K, jump jt else jump jf
-		program = append(program, bpfInstruction{
-			code: BPF_JMP | BPF_JEQ | BPF_K,
-			jt:   0,           // if match, go to next instruction (block)
-			jf:   1,           // if not match, skip the block instruction
-			k:    uint32(num), //nolint:gosec // syscall numbers fit in uint32
+		program = append(program, unix.SockFilter{
+			Code: BPF_JMP | BPF_JEQ | BPF_K,
+			Jt:   0,           // if match, go to next instruction (block)
+			Jf:   1,           // if not match, skip the block instruction
+			K:    uint32(num), //nolint:gosec // syscall numbers fit in uint32
 		})
 
 		// Return action (block with EPERM)
-		program = append(program, bpfInstruction{
-			code: BPF_RET | BPF_K,
-			k:    uint32(action),
+		program = append(program, unix.SockFilter{
+			Code: BPF_RET | BPF_K,
+			K:    uint32(action),
 		})
 	}
 
-	// Default: allow
-	program = append(program, bpfInstruction{
-		code: BPF_RET | BPF_K,
-		k:    SECCOMP_RET_ALLOW,
-	})
-
-	// Write the program to file
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) //nolint:gosec // path is controlled
-	if err != nil {
-		return err
+	if blocked == 0 {
+		// No syscalls to block (unknown architecture?)
+		return unix.SockFprog{}, fmt.Errorf("no syscall numbers found for dangerous syscalls")
 	}
-	defer func() { _ = f.Close() }()
 
-	for _, inst := range program {
-		if err := inst.writeTo(f); err != nil {
-			return err
-		}
-	}
+	// Default: allow
+	program = append(program, unix.SockFilter{
+		Code: BPF_RET | BPF_K,
+		K:    SECCOMP_RET_ALLOW,
+	})
 
-	return nil
+	return unix.SockFprog{
+		Len:    uint16(len(program)), //nolint:gosec // bounded by the syscall list
+		Filter: &program[0],
+	}, nil
 }
 
 // CleanupFilter removes a generated filter file.
@@ -191,25 +196,18 @@ const (
 	SECCOMP_RET_LOG   = 0x7ffc0000
 )
 
-// bpfInstruction represents a single BPF instruction
-type bpfInstruction struct {
-	code uint16
-	jt   uint8
-	jf   uint8
-	k    uint32
-}
-
-func (i *bpfInstruction) writeTo(f *os.File) error {
-	// BPF instruction is 8 bytes: code(2) + jt(1) + jf(1) + k(4)
+// writeSockFilter writes one instruction in the kernel's layout: 8 bytes,
+// code(2) + jt(1) + jf(1) + k(4), little-endian.
+func writeSockFilter(f *os.File, inst *unix.SockFilter) error {
 	buf := make([]byte, 8)
-	buf[0] = byte(i.code)
-	buf[1] = byte(i.code >> 8)
-	buf[2] = i.jt
-	buf[3] = i.jf
-	buf[4] = byte(i.k)
-	buf[5] = byte(i.k >> 8)
-	buf[6] = byte(i.k >> 16)
-	buf[7] = byte(i.k >> 24)
+	buf[0] = byte(inst.Code)
+	buf[1] = byte(inst.Code >> 8)
+	buf[2] = inst.Jt
+	buf[3] = inst.Jf
+	buf[4] = byte(inst.K)
+	buf[5] = byte(inst.K >> 8)
+	buf[6] = byte(inst.K >> 16)
+	buf[7] = byte(inst.K >> 24)
 	_, err := f.Write(buf)
 	return err
 }
@@ -0,0 +1,88 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestBuildSeccompFilter(t *testing.T) {
+	prog, err := BuildSeccompFilter([]string{"ptrace", "reboot", "not_a_syscall"})
+	if err != nil {
+		t.Fatalf("BuildSeccompFilter() error = %v", err)
+	}
+
+	// Load, two instructions per known syscall, then the default allow
+	program := unsafe.Slice(prog.Filter, prog.Len)
+	if len(program) != 6 {
+		t.Fatalf("program has %d instructions, want 6", len(program))
+	}
+	if last := program[len(program)-1]; last.Code != BPF_RET|BPF_K || last.K != SECCOMP_RET_ALLOW {
+		t.Errorf("last instruction = %+v, want default allow", last)
+	}
+	if block := program[2]; block.K != SECCOMP_RET_ERRNO|uint32(unix.EPERM) {
+		t.Errorf("block instruction = %+v, want ERRNO(EPERM)", block)
+	}
+
+	if _, err := BuildSeccompFilter([]string{"not_a_syscall"}); err == nil {
+		t.Error("expected an error when no syscall is known")
+	}
+}
+
+// TestSeccompFilter_BlocksSyscalls installs the filter in a child copy of
+// the test binary, since a seccomp filter cannot be removed once installed.
+func TestSeccompFilter_BlocksSyscalls(t *testing.T) {
+	if os.Getenv("FENCE_SECCOMP_HELPER") == "1" {
+		runSeccompHelper()
+		return
+	}
+	if os.Getenv("FENCE_TEST_SECCOMP") != "1" {
+		t.Skip("skipping: set FENCE_TEST_SECCOMP=1 to run seccomp tests")
+	}
+	if !DetectLinuxFeatures().HasSeccomp {
+		t.Skip("skipping: seccomp not available on this kernel")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSeccompFilter_BlocksSyscalls$") //nolint:gosec // re-exec of the test binary
+	cmd.Env = append(os.Environ(), "FENCE_SECCOMP_HELPER=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("seccomp helper failed: %v\n%s", err, out)
+	}
+}
+
+// runSeccompHelper installs the default filter and checks that a blocked
+// syscall fails with EPERM while an allowed one still works.
+func runSeccompHelper() {
+	prog, err := BuildSeccompFilter(DangerousSyscalls)
+	if err != nil {
+		failHelper("build filter: %v", err)
+	}
+
+	// Seccomp filters apply to the calling thread only
+	runtime.LockOSThread()
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		failHelper("set NO_NEW_PRIVS: %v", err)
+	}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0); err != nil { //nolint:gosec // required for prctl
+		failHelper("install filter: %v", err)
+	}
+
+	// personality(0xffffffff) only queries the current persona
+	if _, _, errno := unix.Syscall(unix.SYS_PERSONALITY, 0xffffffff, 0, 0); !errors.Is(errno, unix.EPERM) {
+		failHelper("personality: got errno %v, want EPERM", errno)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_PTRACE, unix.PTRACE_TRACEME, 0, 0); !errors.Is(errno, unix.EPERM) {
+		failHelper("ptrace: got errno %v, want EPERM", errno)
+	}
+	if _, err := os.Getwd(); err != nil {
+		failHelper("getcwd should be allowed: %v", err)
+	}
+	os.Exit(0)
+}
@@ -3,7 +3,9 @@
 package sandbox
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/Use-Tusk/fence/internal/config"
 )
@@ -12,6 +14,8 @@ import (
 type LinuxBridge struct {
 	HTTPSocketPath  string
 	SOCKSSocketPath string
+	HTTPProxyPort   int
+	SOCKSProxyPort  int
 }
 
 // ReverseBridge is a stub for non-Linux platforms.
@@ -28,17 +32,49 @@ type LinuxSandboxOptions struct {
 	Monitor     bool
 	Debug       bool
 	ShellMode   string
+	ShellPath   string
 	ShellLogin  bool
+	AuditLogger AuditLogger
+
+	DropAllCapabilities bool
+	AddCapabilities     []string
+	ClearEnv            bool
+	UnshareUser         bool
+	UnshareIPC          bool
+	UnshareUTS          bool
+	SandboxHostname     string
+}
+
+// LinuxBridgeOptions is a stub for non-Linux platforms.
+type LinuxBridgeOptions struct {
+	SocketReadyTimeout time.Duration
+	SocketPollInterval time.Duration
+	RetryOnBusyPort    bool
+	Debug              bool
 }
 
 // NewLinuxBridge returns an error on non-Linux platforms.
-func NewLinuxBridge(httpProxyPort, socksProxyPort int, debug bool) (*LinuxBridge, error) {
+func NewLinuxBridge(httpProxyPort, socksProxyPort int, opts LinuxBridgeOptions) (*LinuxBridge, error) {
 	return nil, fmt.Errorf("Linux bridge not available on this platform")
 }
 
 // Cleanup is a no-op on non-Linux platforms.
 func (b *LinuxBridge) Cleanup() {}
 
+// WaitReady returns an error on non-Linux platforms.
+func (b *LinuxBridge) WaitReady(ctx context.Context) error {
+	return fmt.Errorf("Linux bridge not available on this platform")
+}
+
+// HealthCheck returns an error on non-Linux platforms.
+func (b *LinuxBridge) HealthCheck() error {
+	return fmt.Errorf("Linux bridge not available on this platform")
+}
+
+// StartHealthMonitor is a no-op on non-Linux platforms.
+func (b *LinuxBridge) StartHealthMonitor(ctx context.Context, interval time.Duration, onFailure func(error)) {
+}
+
 // NewReverseBridge returns an error on non-Linux platforms.
 func NewReverseBridge(ports []int, debug bool) (*ReverseBridge, error) {
 	return nil, fmt.Errorf("reverse bridge not available on this platform")
@@ -5,7 +5,11 @@ package sandbox
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/Use-Tusk/fence/internal/config"
 )
 
 func TestResolvePathForMount_RegularPath(t *testing.T) {
@@ -55,3 +59,299 @@ func TestResolvePathForMount_BrokenSymlink(t *testing.T) {
 		t.Fatalf("expected broken symlink to be skipped, got %q", got)
 	}
 }
+
+func TestGetMandatoryDenyPathsTrusted(t *testing.T) {
+	cwd := t.TempDir()
+	for _, rel := range []string{"testdata/.bashrc", "testdata/.zshrc"} {
+		abs := filepath.Join(cwd, rel)
+		if err := os.MkdirAll(filepath.Dir(abs), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(abs, []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths := getMandatoryDenyPaths(cwd, []string{".bashrc"}, nil, false)
+	for _, trusted := range []string{filepath.Join(cwd, ".bashrc"), filepath.Join(cwd, "testdata/.bashrc")} {
+		if slices.Contains(paths, trusted) {
+			t.Errorf("getMandatoryDenyPaths() should not contain trusted path %q", trusted)
+		}
+	}
+	if want := filepath.Join(cwd, "testdata/.zshrc"); !slices.Contains(paths, want) {
+		t.Errorf("getMandatoryDenyPaths() missing %q", want)
+	}
+}
+
+func TestGetMandatoryDenyPathsFenceIgnoreOptIn(t *testing.T) {
+	cwd := t.TempDir()
+	fixture := filepath.Join(cwd, "testdata", ".bashrc")
+	if err := os.MkdirAll(filepath.Dir(fixture), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fixture, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cwd, FenceIgnoreFile), []byte("testdata/\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A checked-in .fenceignore has no effect unless the user opts in.
+	if paths := getMandatoryDenyPaths(cwd, nil, nil, false); !slices.Contains(paths, fixture) {
+		t.Errorf("getMandatoryDenyPaths() without useFenceIgnore missing %q", fixture)
+	}
+	if paths := getMandatoryDenyPaths(cwd, nil, nil, true); slices.Contains(paths, fixture) {
+		t.Errorf("getMandatoryDenyPaths() with useFenceIgnore should skip %q", fixture)
+	}
+
+	// The ignore file itself stays protected, even when trusted.
+	want := filepath.Join(cwd, FenceIgnoreFile)
+	if paths := getMandatoryDenyPaths(cwd, []string{FenceIgnoreFile}, nil, true); !slices.Contains(paths, want) {
+		t.Errorf("getMandatoryDenyPaths() missing %q", want)
+	}
+}
+
+func TestGetMandatoryDenyPathsExpandsGlobEntries(t *testing.T) {
+	cwd := t.TempDir()
+	for _, name := range []string{"server.pem", "notes.txt", ".env.local"} {
+		if err := os.WriteFile(filepath.Join(cwd, name), []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths := getMandatoryDenyPaths(cwd, nil, nil, false)
+	for _, want := range []string{filepath.Join(cwd, "server.pem"), filepath.Join(cwd, ".env.local"), filepath.Join(cwd, ".env")} {
+		if !slices.Contains(paths, want) {
+			t.Errorf("getMandatoryDenyPaths() missing %q", want)
+		}
+	}
+	for _, p := range paths {
+		if ContainsGlobChars(p) || p == filepath.Join(cwd, "notes.txt") {
+			t.Errorf("getMandatoryDenyPaths() returned unexpected path %q", p)
+		}
+	}
+}
+
+func TestDenyWriteMountArgs(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "settings.json")
+	sub := filepath.Join(dir, "hooks")
+	logFile := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(file, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(sub, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(logFile, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	args := denyWriteMountArgs([]string{file, sub, filepath.Join(dir, "*.log"), filepath.Join(dir, "missing")}, seen)
+
+	var mounted []string
+	for i := 0; i+2 < len(args); i += 3 {
+		if args[i] != "--ro-bind" || args[i+1] != args[i+2] {
+			t.Fatalf("unexpected mount args %v", args[i:i+3])
+		}
+		mounted = append(mounted, args[i+1])
+	}
+	if len(mounted)*3 != len(args) {
+		t.Fatalf("args %v are not --ro-bind triples", args)
+	}
+	for _, want := range []string{file, sub, logFile} {
+		if !slices.Contains(mounted, want) {
+			t.Errorf("denyWriteMountArgs() did not mount %q read-only: %v", want, args)
+		}
+	}
+	if slices.Contains(mounted, filepath.Join(dir, "missing")) {
+		t.Errorf("denyWriteMountArgs() mounted a missing path: %v", args)
+	}
+
+	// Paths already mounted are not mounted again
+	if again := denyWriteMountArgs([]string{file}, seen); len(again) != 0 {
+		t.Errorf("denyWriteMountArgs() remounted %q: %v", file, again)
+	}
+}
+
+func TestScratchDirMountArgs(t *testing.T) {
+	existing := t.TempDir()
+	tests := []struct {
+		name    string
+		fs      config.FilesystemConfig
+		want    []string
+		wantErr bool
+	}{
+		{name: "unset", fs: config.FilesystemConfig{}},
+		{name: "under tmp", fs: config.FilesystemConfig{ScratchDir: "/tmp/scratch/"}, want: []string{"--tmpfs", "/tmp/scratch"}},
+		{name: "sized", fs: config.FilesystemConfig{ScratchDir: "/tmp/scratch", ScratchDirSizeMB: 64}, want: []string{"--size", "67108864", "--tmpfs", "/tmp/scratch"}},
+		{name: "existing dir", fs: config.FilesystemConfig{ScratchDir: existing}, want: []string{"--tmpfs", existing}},
+		{name: "missing dir outside tmp", fs: config.FilesystemConfig{ScratchDir: "/nonexistent-fence-scratch"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scratchDirMountArgs(&config.Config{Filesystem: tt.fs})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("scratchDirMountArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("scratchDirMountArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapCommandLinux_ScratchDir(t *testing.T) {
+	skipIfCommandNotFound(t, "bwrap")
+
+	cfg := config.Default()
+	cfg.Filesystem.ScratchDir = "/tmp/scratch"
+	cfg.Filesystem.ScratchDirSizeMB = 16
+
+	cmd, err := WrapCommandLinuxWithOptions(cfg, "true", nil, nil, LinuxSandboxOptions{})
+	if err != nil {
+		t.Fatalf("WrapCommandLinuxWithOptions() error = %v", err)
+	}
+	for _, want := range []string{"--size 16777216 --tmpfs /tmp/scratch", "export TMPDIR=/tmp/scratch"} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("command does not contain %q:\n%s", want, cmd)
+		}
+	}
+}
+
+func TestCapabilityArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		dropAll bool
+		add     []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "drop all", dropAll: true, want: []string{"--cap-drop", "ALL"}},
+		{name: "drop all and add", dropAll: true, add: []string{"CAP_NET_RAW", "net_bind_service"}, want: []string{"--cap-drop", "ALL", "--cap-add", "CAP_NET_RAW", "--cap-add", "CAP_NET_BIND_SERVICE"}},
+		{name: "unknown", add: []string{"CAP_FLY"}, wantErr: true},
+		{name: "sys admin", add: []string{"CAP_SYS_ADMIN"}, wantErr: true},
+		{name: "sys ptrace", dropAll: true, add: []string{"sys_ptrace"}, wantErr: true},
+		{name: "dac read search", add: []string{"CAP_DAC_READ_SEARCH"}, wantErr: true},
+		{name: "bpf", add: []string{"CAP_BPF"}, wantErr: true},
+		{name: "setuid and chown", add: []string{"CAP_SETUID", "CAP_CHOWN"}, want: []string{"--cap-add", "CAP_SETUID", "--cap-add", "CAP_CHOWN"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := capabilityArgs(tt.dropAll, tt.add)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("capabilityArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("capabilityArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapCommandLinux_DropAllCapabilities(t *testing.T) {
+	skipIfCommandNotFound(t, "bwrap")
+
+	cmd, err := WrapCommandLinuxWithOptions(config.Default(), "true", nil, nil, LinuxSandboxOptions{
+		DropAllCapabilities: true,
+		AddCapabilities:     []string{"CAP_NET_RAW"},
+	})
+	if err != nil {
+		t.Fatalf("WrapCommandLinuxWithOptions() error = %v", err)
+	}
+	if !strings.HasPrefix(cmd, "bwrap --cap-drop ALL --cap-add CAP_NET_RAW --new-session") {
+		t.Errorf("capability flags should come before other bwrap flags:\n%s", cmd)
+	}
+}
+
+func TestGetEssentialEnvVars(t *testing.T) {
+	t.Setenv("HOME", "/home/test")
+	t.Setenv("LANG", "C.UTF-8")
+	t.Setenv("OPENAI_API_KEY", "sk-secret")
+
+	cfg := config.Default()
+	cfg.Filesystem.ScratchDir = "/tmp/scratch"
+	env := getEssentialEnvVars(cfg)
+
+	for key, want := range map[string]string{"HOME": "/home/test", "LANG": "C.UTF-8", "FENCE_SANDBOX": "1", "TMPDIR": "/tmp/scratch"} {
+		if env[key] != want {
+			t.Errorf("env[%s] = %q, want %q", key, env[key], want)
+		}
+	}
+	if _, ok := env["OPENAI_API_KEY"]; ok {
+		t.Error("getEssentialEnvVars() passed through OPENAI_API_KEY")
+	}
+}
+
+func TestWrapCommandLinux_UnshareUser(t *testing.T) {
+	skipIfCommandNotFound(t, "bwrap")
+
+	cmd, err := WrapCommandLinuxWithOptions(config.Default(), "true", nil, nil, LinuxSandboxOptions{UnshareUser: true})
+	if err != nil {
+		t.Fatalf("WrapCommandLinuxWithOptions() error = %v", err)
+	}
+	if !strings.Contains(cmd, "--unshare-user --uid 0 --gid 0") {
+		t.Errorf("command does not map the user to root:\n%s", cmd)
+	}
+	if !strings.HasPrefix(cmd, "bwrap --cap-drop ALL --new-session") {
+		t.Errorf("root in the user namespace should have its capabilities dropped:\n%s", cmd)
+	}
+
+	cmd, err = WrapCommandLinuxWithOptions(config.Default(), "true", nil, nil, LinuxSandboxOptions{
+		UnshareUser:     true,
+		AddCapabilities: []string{"CAP_CHOWN"},
+	})
+	if err != nil {
+		t.Fatalf("WrapCommandLinuxWithOptions() error = %v", err)
+	}
+	if !strings.HasPrefix(cmd, "bwrap --cap-drop ALL --cap-add CAP_CHOWN --new-session") {
+		t.Errorf("only the added capabilities should be kept:\n%s", cmd)
+	}
+}
+
+func TestNamespaceArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    LinuxSandboxOptions
+		want    []string
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "ipc", opts: LinuxSandboxOptions{UnshareIPC: true}, want: []string{"--unshare-ipc"}},
+		{name: "uts default hostname", opts: LinuxSandboxOptions{UnshareUTS: true}, want: []string{"--unshare-uts", "--hostname", "sandbox"}},
+		{name: "ipc and uts", opts: LinuxSandboxOptions{UnshareIPC: true, UnshareUTS: true, SandboxHostname: "agent-1"}, want: []string{"--unshare-ipc", "--unshare-uts", "--hostname", "agent-1"}},
+		{name: "hostname without uts", opts: LinuxSandboxOptions{SandboxHostname: "agent-1"}, wantErr: true},
+		{name: "invalid hostname", opts: LinuxSandboxOptions{UnshareUTS: true, SandboxHostname: "bad host"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := namespaceArgs(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("namespaceArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("namespaceArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapCommandLinux_NoBridgeHasNoSocat(t *testing.T) {
+	skipIfCommandNotFound(t, "bwrap")
+
+	cmd, err := WrapCommandLinuxWithOptions(config.Default(), "true", nil, nil, LinuxSandboxOptions{})
+	if err != nil {
+		t.Fatalf("WrapCommandLinuxWithOptions() error = %v", err)
+	}
+	if strings.Contains(cmd, "socat") {
+		t.Errorf("command without a bridge should not start socat:\n%s", cmd)
+	}
+	if !strings.Contains(cmd, "export FENCE_SANDBOX=1") {
+		t.Errorf("command should still mark the sandbox:\n%s", cmd)
+	}
+}
@@ -34,14 +34,163 @@ type MacOSSandboxParams struct {
 	AllowAllUnixSockets     bool
 	AllowLocalBinding       bool
 	AllowLocalOutbound      bool
+	AllowLoopbackOutbound   bool     // allowedDomains lists a loopback address such as "::1" and allowLocalOutbound is on
+	DirectProtocols         []string // Protocols allowed to any host by "proto:<protocol> *"
 	DefaultDenyRead         bool
 	ReadAllowPaths          []string
 	ReadDenyPaths           []string
 	WriteAllowPaths         []string
 	WriteDenyPaths          []string
+	AtomicWritePaths        []string
 	DeniedExecPaths         []string
 	AllowPty                bool
+	AllowGPU                bool     // Metal, OpenCL and CoreML access to the GPU and Neural Engine
+	AllowKeychain           bool     // Keychain item access, including iCloud Keychain
+	AllowMachServices       []string // Extra Mach services for Go callers, e.g. GetCommonDevToolMachServices; no config field sets it, configs use sandboxProfileExtra
 	AllowGitConfig          bool
+	TrustedDangerousFiles   []string
+	TrustedDangerousDirs    []string
+
+	// ExtraAllowRules are "(allow ...)" rules added after the built-in
+	// permissions and before fence's explicit deny rules, which still win.
+	// The profile is deny-by-default, though, so they can grant anything
+	// fence merely leaves unallowed, including network access around the
+	// proxies and reads outside allowRead under defaultDenyRead.
+	// ExtraDenyRules are "(deny ...)" rules added at the end of the
+	// profile, so they override everything above them.
+	ExtraAllowRules []string
+	ExtraDenyRules  []string
+}
+
+// Validate checks that each extra rule is a single (allow ...) or
+// (deny ...) form, as its list requires, and that each Mach service is a
+// reverse-DNS name.
+func (p *MacOSSandboxParams) Validate() error {
+	for _, service := range p.AllowMachServices {
+		if !machServicePattern.MatchString(service) {
+			return fmt.Errorf("invalid mach service %q: must be a reverse-DNS name such as com.apple.pasteboard.1", service)
+		}
+	}
+
+	check := func(rules []string, want string) error {
+		for _, rule := range rules {
+			allow, deny, err := config.ParseSandboxProfileExtra(rule)
+			if err != nil {
+				return fmt.Errorf("invalid extra %s rule %q: %w", want, rule, err)
+			}
+			got := allow
+			if want == "deny" {
+				got = deny
+			}
+			if len(got) != 1 || len(allow)+len(deny) != 1 {
+				return fmt.Errorf("invalid extra %s rule %q: must be a single (%s ...) rule", want, rule, want)
+			}
+		}
+		return nil
+	}
+	if err := check(p.ExtraAllowRules, "allow"); err != nil {
+		return err
+	}
+	return check(p.ExtraDenyRules, "deny")
+}
+
+// machServicePattern matches reverse-DNS Mach service names, with an
+// optional per-session suffix as in "com.apple.distributed_notifications@Uv3".
+var machServicePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*(\.[A-Za-z0-9_-]+)+(@[A-Za-z0-9_-]+)?$`)
+
+// GetCommonDevToolMachServices returns Mach services that common development
+// tools use and the base profile does not allow: the pasteboard (pbcopy,
+// pbpaste), CoreServices and the quarantine resolver for downloaded files.
+// Services every tool needs, such as launchservicesd and distributed
+// notifications, are always allowed. Apple Events are left out: they let
+// the command make other apps, such as Terminal, run commands outside the
+// sandbox.
+func GetCommonDevToolMachServices() []string {
+	return []string{
+		"com.apple.pasteboard.1",
+		"com.apple.CoreServices.coreservicesd",
+		"com.apple.coreservices.quarantine-resolver",
+	}
+}
+
+// macOSGPUIOKitClasses are the IOKit user client classes that Metal,
+// OpenCL and CoreML open to reach the GPU and the Neural Engine.
+var macOSGPUIOKitClasses = []string{
+	// Apple silicon GPU
+	"AGXDeviceUserClient",
+	"AGXSharedUserClient",
+	"IOGPUDeviceUserClient",
+	// Intel and AMD GPUs
+	"IOAccelerationUserClient",
+	"IOAccelDevice2",
+	"IOAccelContext2",
+	"IOAccelSharedUserClient2",
+	// GPU selection and power management
+	"AGPMClient",
+	"AppleGraphicsControlClient",
+	"AppleGraphicsPolicyClient",
+	"AppleMGPUPowerControlClient",
+	// Neural Engine, used by CoreML
+	"H11ANEInDirectPathClient",
+}
+
+// macOSGPUMachServices are the services GPU computation talks to: the Metal
+// and OpenCL compilers, GPU memory accounting and the Neural Engine daemon.
+var macOSGPUMachServices = []string{
+	"com.apple.MTLCompilerService",
+	"com.apple.cvmsServ",
+	"com.apple.gpumemd.source",
+	"com.apple.appleneuralengine",
+}
+
+// macOSKeychainMachServices are the Security framework services that reading
+// and writing Keychain items goes through. Certificate trust evaluation uses
+// com.apple.trustd.agent, which every profile allows.
+var macOSKeychainMachServices = []string{
+	"com.apple.SecurityServer",               // file-based keychains (securityd)
+	"com.apple.secd",                         // data protection and iCloud Keychain items
+	"com.apple.security.agent",               // unlock and access prompts
+	"com.apple.security.authhost",            // authorization prompts
+	"com.apple.security.cloudkeychainproxy3", // iCloud Keychain sync
+	"com.apple.security.octagon",             // iCloud Keychain trust
+	"com.apple.ctkd.token-client",            // smart card and token keychains
+}
+
+// macOSKeychainPaths returns the user's keychain directory, under both the
+// home directory and its /System/Volumes/Data path.
+func macOSKeychainPaths() []string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return nil
+	}
+	return expandMacOSDataVolumePaths([]string{filepath.Join(home, "Library", "Keychains")})
+}
+
+// Normalize sorts the path lists and removes duplicate entries, such as a
+// path that is both a default write path and in the config, so each one
+// produces a single rule. Rule precedence in the profile comes from the
+// order of the rule groups, not from the order within a list.
+func (p *MacOSSandboxParams) Normalize() {
+	p.AllowUnixSockets = deduplicateAndSort(p.AllowUnixSockets)
+	p.AllowMachServices = deduplicateAndSort(p.AllowMachServices)
+	p.ReadAllowPaths = deduplicateAndSort(p.ReadAllowPaths)
+	p.ReadDenyPaths = deduplicateAndSort(p.ReadDenyPaths)
+	p.WriteAllowPaths = deduplicateAndSort(p.WriteAllowPaths)
+	p.WriteDenyPaths = deduplicateAndSort(p.WriteDenyPaths)
+	p.AtomicWritePaths = deduplicateAndSort(p.AtomicWritePaths)
+	p.DeniedExecPaths = deduplicateAndSort(p.DeniedExecPaths)
+	p.TrustedDangerousFiles = deduplicateAndSort(p.TrustedDangerousFiles)
+	p.TrustedDangerousDirs = deduplicateAndSort(p.TrustedDangerousDirs)
+}
+
+// deduplicateAndSort returns a sorted copy of paths without duplicates.
+func deduplicateAndSort(paths []string) []string {
+	if len(paths) == 0 {
+		return paths
+	}
+	sorted := slices.Clone(paths)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
 }
 
 // GlobToRegex converts a glob pattern to a regex for macOS sandbox profiles.
@@ -85,10 +234,43 @@ func getAncestorDirectories(pathStr string) []string {
 	return ancestors
 }
 
-// expandMacOSTmpPaths mirrors /tmp paths to /private/tmp equivalents and vice versa.
-// On macOS, /tmp is a symlink to /private/tmp, and symlink resolution can fail if paths
-// don't exist yet. Adding both variants ensures sandbox rules match kernel-resolved paths.
+// expandMacOSTmpPaths mirrors /tmp paths to /private/tmp equivalents and vice versa,
+// and likewise /var/folders and /private/var/folders. It also adds $TMPDIR when it is
+// under /var/folders (as NSTemporaryDirectory() is), in both its raw and resolved forms.
+// On macOS, /tmp and /var are symlinks into /private, and symlink resolution can fail if
+// paths don't exist yet. Adding both variants ensures sandbox rules match kernel-resolved paths.
 func expandMacOSTmpPaths(paths []string) []string {
+	expanded := slices.Clone(paths)
+	for _, p := range macOSTmpdirPaths() {
+		if !slices.Contains(expanded, p) {
+			expanded = append(expanded, p)
+		}
+	}
+	return mirrorMacOSTmpPaths(expanded)
+}
+
+// macOSTmpdirPaths returns $TMPDIR and its symlink-resolved form if it is a
+// per-user temporary directory under /var/folders.
+func macOSTmpdirPaths() []string {
+	tmpdir := os.Getenv("TMPDIR")
+	if tmpdir == "" {
+		return nil
+	}
+	tmpdir = filepath.Clean(tmpdir)
+	if !strings.HasPrefix(tmpdir, "/var/folders/") && !strings.HasPrefix(tmpdir, "/private/var/folders/") {
+		return nil
+	}
+
+	paths := []string{tmpdir}
+	if resolved, err := filepath.EvalSymlinks(tmpdir); err == nil && resolved != tmpdir {
+		paths = append(paths, resolved)
+	}
+	return paths
+}
+
+// mirrorMacOSTmpPaths adds the /private counterpart of each /tmp and
+// /var/folders path, and the reverse.
+func mirrorMacOSTmpPaths(paths []string) []string {
 	seen := make(map[string]bool)
 	for _, p := range paths {
 		seen[p] = true
@@ -98,13 +280,13 @@ func expandMacOSTmpPaths(paths []string) []string {
 	for _, p := range paths {
 		var mirror string
 		switch {
-		case p == "/tmp":
-			mirror = "/private/tmp"
-		case p == "/private/tmp":
-			mirror = "/tmp"
-		case strings.HasPrefix(p, "/tmp/"):
+		case p == "/tmp", p == "/var/folders":
+			mirror = "/private" + p
+		case p == "/private/tmp", p == "/private/var/folders":
+			mirror = strings.TrimPrefix(p, "/private")
+		case strings.HasPrefix(p, "/tmp/"), strings.HasPrefix(p, "/var/folders/"):
 			mirror = "/private" + p
-		case strings.HasPrefix(p, "/private/tmp/"):
+		case strings.HasPrefix(p, "/private/tmp/"), strings.HasPrefix(p, "/private/var/folders/"):
 			mirror = strings.TrimPrefix(p, "/private")
 		}
 
@@ -117,6 +299,56 @@ func expandMacOSTmpPaths(paths []string) []string {
 	return append(paths, additions...)
 }
 
+// macOSDataVolume is where the writable APFS data volume is mounted on macOS
+// Catalina and later. /Users is firmlinked into it.
+const macOSDataVolume = "/System/Volumes/Data"
+
+// expandMacOSDataVolumePaths mirrors /Users paths to their
+// /System/Volumes/Data equivalents and vice versa. Symlink resolution can
+// yield either form, so both are needed for sandbox rules to match.
+// Home-relative paths (~/...) are expanded so they can be mirrored too.
+func expandMacOSDataVolumePaths(paths []string) []string {
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		seen[p] = true
+	}
+
+	home, _ := os.UserHomeDir()
+
+	var additions []string
+	add := func(p string) {
+		if p != "" && !seen[p] {
+			seen[p] = true
+			additions = append(additions, p)
+		}
+	}
+
+	for _, p := range paths {
+		expanded := p
+		switch {
+		case p == "~" && home != "":
+			expanded = home
+		case strings.HasPrefix(p, "~/") && home != "":
+			expanded = filepath.Join(home, p[2:])
+		}
+
+		switch {
+		case expanded == "/Users" || strings.HasPrefix(expanded, "/Users/"):
+			if expanded != p {
+				add(expanded)
+			}
+			add(macOSDataVolume + expanded)
+		case strings.HasPrefix(expanded, macOSDataVolume+"/Users"):
+			rest := strings.TrimPrefix(expanded, macOSDataVolume)
+			if rest == "/Users" || strings.HasPrefix(rest, "/Users/") {
+				add(rest)
+			}
+		}
+	}
+
+	return append(paths, additions...)
+}
+
 // getTmpdirParent gets the TMPDIR parent if it matches macOS pattern.
 func getTmpdirParent() []string {
 	tmpdir := os.Getenv("TMPDIR")
@@ -189,9 +421,9 @@ func generateReadRules(defaultDenyRead bool, allowPaths, denyPaths []string, log
 
 	// In both modes, deny specific paths (denyRead takes precedence).
 	// Note: We use file-read* (not file-read-data) so denied paths are fully hidden.
-	// In defaultDenyRead mode, this overrides the global file-read-metadata allow,
-	// meaning denied paths can't even be listed or stat'd - more restrictive than
-	// default mode where denied paths are still visible but unreadable.
+	// file-read* covers file-read-data, file-read-metadata and file-read-xattr, so
+	// stat() on a denied path fails too and its existence and size are not leaked.
+	// In defaultDenyRead mode, this also overrides the global file-read-metadata allow.
 	for _, pathPattern := range denyPaths {
 		normalized := NormalizePath(pathPattern)
 
@@ -218,7 +450,7 @@ func generateReadRules(defaultDenyRead bool, allowPaths, denyPaths []string, log
 }
 
 // generateWriteRules generates filesystem write rules for the sandbox profile.
-func generateWriteRules(allowPaths, denyPaths []string, allowGitConfig bool, logTag string) []string {
+func generateWriteRules(allowPaths, denyPaths []string, allowGitConfig bool, trustedFiles, trustedDirs []string, logTag string) []string {
 	var rules []string
 
 	// Allow TMPDIR parent on macOS
@@ -253,11 +485,14 @@ func generateWriteRules(allowPaths, denyPaths []string, allowGitConfig bool, log
 
 	// Combine user-specified and mandatory deny patterns
 	cwd, _ := os.Getwd()
-	mandatoryDeny := GetMandatoryDenyPatterns(cwd, allowGitConfig)
+	mandatoryDeny := GetMandatoryDenyPatterns(cwd, allowGitConfig, trustedFiles, trustedDirs)
 	allDenyPaths := make([]string, 0, len(denyPaths)+len(mandatoryDeny))
 	allDenyPaths = append(allDenyPaths, denyPaths...)
 	allDenyPaths = append(allDenyPaths, mandatoryDeny...)
 
+	// file-write* covers every write operation, including file-write-create
+	// (the destination of a rename), file-write-flags and file-write-mode, so
+	// writing elsewhere and renaming onto a denied path is also blocked.
 	for _, pathPattern := range allDenyPaths {
 		normalized := NormalizePath(pathPattern)
 
@@ -283,6 +518,22 @@ func generateWriteRules(allowPaths, denyPaths []string, allowGitConfig bool, log
 	return rules
 }
 
+// generateAtomicWriteRules denies in-place writes to atomic:true files.
+// file-write-data covers opening the file for writing and truncating it,
+// while a rename onto the path only needs file-write-create, so replacing
+// the file with a finished temporary file still works.
+func generateAtomicWriteRules(paths []string, logTag string) []string {
+	var rules []string
+	for _, path := range paths {
+		rules = append(rules,
+			"(deny file-write-data",
+			fmt.Sprintf("  (literal %s)", escapePath(NormalizePath(path))),
+			fmt.Sprintf("  (with message %q))", logTag),
+		)
+	}
+	return rules
+}
+
 // generateMoveBlockingRules generates rules to prevent file movement bypasses.
 func generateMoveBlockingRules(pathPatterns []string, logTag string) []string {
 	var rules []string
@@ -344,6 +595,8 @@ func generateMoveBlockingRules(pathPatterns []string, logTag string) []string {
 
 // GenerateSandboxProfile generates a complete macOS sandbox profile.
 func GenerateSandboxProfile(params MacOSSandboxParams) string {
+	params.Normalize()
+
 	logTag := "CMD64_" + EncodeSandboxedCommand(params.Command) + "_END" + sessionSuffix
 
 	var profile strings.Builder
@@ -472,9 +725,6 @@ func GenerateSandboxProfile(params MacOSSandboxParams) string {
 ; Distributed notifications
 (allow distributed-notification-post)
 
-; Security server
-(allow mach-lookup (global-name "com.apple.SecurityServer"))
-
 ; Device I/O
 (allow file-ioctl (literal "/dev/null"))
 (allow file-ioctl (literal "/dev/zero"))
@@ -498,6 +748,14 @@ func GenerateSandboxProfile(params MacOSSandboxParams) string {
 
 `)
 
+	if len(params.ExtraAllowRules) > 0 {
+		profile.WriteString("; Extra allow rules (sandboxProfileExtra)\n")
+		for _, rule := range params.ExtraAllowRules {
+			profile.WriteString(rule + "\n")
+		}
+		profile.WriteString("\n")
+	}
+
 	if len(params.DeniedExecPaths) > 0 {
 		profile.WriteString("; Runtime executable deny (applies to child processes)\n")
 		for _, execPath := range params.DeniedExecPaths {
@@ -534,6 +792,21 @@ func GenerateSandboxProfile(params MacOSSandboxParams) string {
 			}
 		}
 
+		// Seatbelt only accepts "localhost" or "*" as a remote host, and
+		// "localhost" covers both 127.0.0.1 and ::1.
+		if params.AllowLoopbackOutbound {
+			profile.WriteString(`(allow network-outbound (remote ip "localhost:*"))
+`)
+		}
+
+		// Seatbelt filters tcp and udp remotes; ICMP has no filter of its own,
+		// so "proto:icmp *" stays blocked.
+		for _, proto := range params.DirectProtocols {
+			if proto == "tcp" || proto == "udp" {
+				profile.WriteString(fmt.Sprintf("(allow network-outbound (remote %s \"*:*\"))\n", proto))
+			}
+		}
+
 		if params.HTTPProxyPort > 0 {
 			profile.WriteString(fmt.Sprintf(`(allow network-bind (local ip "localhost:%d"))
 (allow network-inbound (local ip "localhost:%d"))
@@ -550,19 +823,64 @@ func GenerateSandboxProfile(params MacOSSandboxParams) string {
 	}
 	profile.WriteString("\n")
 
+	// Keychain files are only readable and writable with AllowKeychain. As
+	// ordinary path rules, explicit denies still take precedence.
+	readAllow, readDeny := params.ReadAllowPaths, params.ReadDenyPaths
+	writeAllow, writeDeny := params.WriteAllowPaths, params.WriteDenyPaths
+	if keychains := macOSKeychainPaths(); params.AllowKeychain {
+		readAllow = append(slices.Clone(readAllow), keychains...)
+		writeAllow = append(slices.Clone(writeAllow), keychains...)
+	} else {
+		readDeny = append(slices.Clone(readDeny), keychains...)
+		writeDeny = append(slices.Clone(writeDeny), keychains...)
+	}
+
 	// Read rules
 	profile.WriteString("; File read\n")
-	for _, rule := range generateReadRules(params.DefaultDenyRead, params.ReadAllowPaths, params.ReadDenyPaths, logTag) {
+	for _, rule := range generateReadRules(params.DefaultDenyRead, readAllow, readDeny, logTag) {
 		profile.WriteString(rule + "\n")
 	}
 	profile.WriteString("\n")
 
 	// Write rules
 	profile.WriteString("; File write\n")
-	for _, rule := range generateWriteRules(params.WriteAllowPaths, params.WriteDenyPaths, params.AllowGitConfig, logTag) {
+	for _, rule := range generateWriteRules(writeAllow, writeDeny, params.AllowGitConfig, params.TrustedDangerousFiles, params.TrustedDangerousDirs, logTag) {
+		profile.WriteString(rule + "\n")
+	}
+	for _, rule := range generateAtomicWriteRules(params.AtomicWritePaths, logTag) {
 		profile.WriteString(rule + "\n")
 	}
 
+	// GPU support
+	if params.AllowGPU {
+		profile.WriteString("\n; GPU (Metal, OpenCL, CoreML)\n(allow iokit-open\n")
+		for _, class := range macOSGPUIOKitClasses {
+			profile.WriteString(fmt.Sprintf("  (iokit-user-client-class %q)\n", class))
+		}
+		profile.WriteString(")\n(allow mach-lookup\n")
+		for _, service := range macOSGPUMachServices {
+			profile.WriteString(fmt.Sprintf("  (global-name %q)\n", service))
+		}
+		profile.WriteString(")\n")
+	}
+
+	// Keychain support
+	if params.AllowKeychain {
+		profile.WriteString("\n; Keychain\n(allow mach-lookup\n")
+		for _, service := range macOSKeychainMachServices {
+			profile.WriteString(fmt.Sprintf("  (global-name %q)\n", service))
+		}
+		profile.WriteString(")\n")
+	}
+
+	if len(params.AllowMachServices) > 0 {
+		profile.WriteString("\n; Extra Mach services\n(allow mach-lookup\n")
+		for _, service := range params.AllowMachServices {
+			profile.WriteString(fmt.Sprintf("  (global-name %q)\n", service))
+		}
+		profile.WriteString(")\n")
+	}
+
 	// PTY support
 	if params.AllowPty {
 		profile.WriteString(`
@@ -579,25 +897,70 @@ func GenerateSandboxProfile(params MacOSSandboxParams) string {
 `)
 	}
 
+	// Last, so these override everything above
+	if len(params.ExtraDenyRules) > 0 {
+		profile.WriteString("\n; Extra deny rules (sandboxProfileExtra)\n")
+		for _, rule := range params.ExtraDenyRules {
+			profile.WriteString(rule + "\n")
+		}
+	}
+
 	return profile.String()
 }
 
-// WrapCommandMacOS wraps a command with macOS sandbox restrictions.
+// MacOSSandboxOptions contains options for the macOS sandbox.
+type MacOSSandboxOptions struct {
+	// Ports of the host HTTP and SOCKS proxies
+	HTTPPort  int
+	SOCKSPort int
+	// Ports exposed for inbound connections
+	ExposedPorts []int
+	// Debug mode
+	Debug bool
+	// Shell selection mode (default|user|custom)
+	ShellMode string
+	// Shell path for custom mode
+	ShellPath string
+	// Whether to run shell as login shell (e.g. bash -lc)
+	ShellLogin bool
+	// Receives an event for each command that is wrapped (optional)
+	AuditLogger AuditLogger
+}
+
+// WrapCommandMacOS wraps a command with macOS sandbox restrictions. The
+// custom shell path comes from cfg.Shell.Path.
 func WrapCommandMacOS(cfg *config.Config, command string, httpPort, socksPort int, exposedPorts []int, debug bool, shellMode string, shellLogin bool) (string, error) {
+	return WrapCommandMacOSWithOptions(cfg, command, MacOSSandboxOptions{
+		HTTPPort:     httpPort,
+		SOCKSPort:    socksPort,
+		ExposedPorts: exposedPorts,
+		Debug:        debug,
+		ShellMode:    shellMode,
+		ShellPath:    cfg.Shell.Path,
+		ShellLogin:   shellLogin,
+	})
+}
+
+// WrapCommandMacOSWithOptions wraps a command with configurable sandbox options.
+func WrapCommandMacOSWithOptions(cfg *config.Config, command string, opts MacOSSandboxOptions) (string, error) {
+	if cfg.AuditMode() {
+		return WrapCommandAudit(command, opts.HTTPPort, opts.SOCKSPort, opts.ShellMode, opts.ShellPath, opts.ShellLogin, opts.AuditLogger)
+	}
+
 	// In wildcard mode ("*"), still run the proxy for apps that respect
 	// HTTP_PROXY, but allow direct connections for apps that don't.
 	hasWildcardAllow := hasWildcardAllowedDomain(cfg)
 
-	needsNetwork := len(cfg.Network.AllowedDomains) > 0 || len(cfg.Network.DeniedDomains) > 0
+	needsNetwork := len(cfg.Network.EffectiveAllowedDomains()) > 0 || len(cfg.Network.DeniedDomains) > 0
 
 	// Build allow paths: default + configured
-	allowPaths := append(GetDefaultWritePaths(), cfg.Filesystem.AllowWrite...)
+	allowPaths := append(GetDefaultWritePaths(), cfg.Filesystem.WritablePaths()...)
 
-	// Expand /tmp <-> /private/tmp for macOS symlink compatibility
+	// Expand /tmp <-> /private/tmp (and $TMPDIR) for macOS symlink compatibility
 	allowPaths = expandMacOSTmpPaths(allowPaths)
 
 	// Enable local binding if ports are exposed or if explicitly configured
-	allowLocalBinding := cfg.Network.AllowLocalBinding || len(exposedPorts) > 0
+	allowLocalBinding := cfg.Network.AllowLocalBinding || len(opts.ExposedPorts) > 0
 
 	allowLocalOutbound := allowLocalBinding
 	if cfg.Network.AllowLocalOutbound != nil {
@@ -606,14 +969,14 @@ func WrapCommandMacOS(cfg *config.Config, command string, httpPort, socksPort in
 
 	// If wildcard allow, don't restrict network at sandbox level (allow direct connections).
 	// Otherwise, restrict to localhost/proxy only (strict mode).
-	needsNetworkRestriction := !hasWildcardAllow && (needsNetwork || len(cfg.Network.AllowedDomains) == 0)
+	needsNetworkRestriction := !hasWildcardAllow && (needsNetwork || len(cfg.Network.EffectiveAllowedDomains()) == 0)
 
-	if debug && hasWildcardAllow {
+	if opts.Debug && hasWildcardAllow {
 		fmt.Fprintf(os.Stderr, "[fence:macos] Wildcard allowedDomains detected - allowing direct network connections\n")
 		fmt.Fprintf(os.Stderr, "[fence:macos] Note: deniedDomains only enforced for apps that respect HTTP_PROXY\n")
 	}
 
-	shellPath, shellFlag, err := ResolveExecutionShell(shellMode, shellLogin)
+	shellPath, shellFlag, err := ResolveExecutionShell(opts.ShellMode, opts.ShellPath, opts.ShellLogin)
 	if err != nil {
 		return "", err
 	}
@@ -632,39 +995,57 @@ func WrapCommandMacOS(cfg *config.Config, command string, httpPort, socksPort in
 	params := MacOSSandboxParams{
 		Command:                 command,
 		NeedsNetworkRestriction: needsNetworkRestriction,
-		HTTPProxyPort:           httpPort,
-		SOCKSProxyPort:          socksPort,
+		HTTPProxyPort:           opts.HTTPPort,
+		SOCKSProxyPort:          opts.SOCKSPort,
 		AllowUnixSockets:        cfg.Network.AllowUnixSockets,
 		AllowAllUnixSockets:     cfg.Network.AllowAllUnixSockets,
 		AllowLocalBinding:       allowLocalBinding,
 		AllowLocalOutbound:      allowLocalOutbound,
+		AllowLoopbackOutbound:   allowLocalOutbound && allowsLoopbackAddress(cfg),
+		DirectProtocols:         wildcardProtocols(cfg),
 		DefaultDenyRead:         cfg.Filesystem.DefaultDenyRead,
-		ReadAllowPaths:          cfg.Filesystem.AllowRead,
-		ReadDenyPaths:           cfg.Filesystem.DenyRead,
+		ReadAllowPaths:          expandMacOSDataVolumePaths(slices.Clone(cfg.Filesystem.AllowRead)),
+		ReadDenyPaths:           expandMacOSDataVolumePaths(effectiveDenyRead(cfg)),
 		WriteAllowPaths:         allowPaths,
-		WriteDenyPaths:          cfg.Filesystem.DenyWrite,
+		WriteDenyPaths:          append(GetEffectiveDenyWritePaths(cfg), auditLogDenyWritePaths(cfg)...),
+		AtomicWritePaths:        mirrorMacOSTmpPaths(cfg.Filesystem.AtomicWritePaths()),
 		DeniedExecPaths:         deniedExecPaths,
 		AllowPty:                cfg.AllowPty,
+		AllowGPU:                cfg.AllowGPU,
+		AllowKeychain:           cfg.AllowKeychain,
 		AllowGitConfig:          cfg.Filesystem.AllowGitConfig,
+		TrustedDangerousFiles:   cfg.Filesystem.TrustedDangerousFiles,
+		TrustedDangerousDirs:    cfg.Filesystem.TrustedDangerousDirectories,
 	}
 
-	if debug && len(exposedPorts) > 0 {
-		fmt.Fprintf(os.Stderr, "[fence:macos] Enabling local binding for exposed ports: %v\n", exposedPorts)
+	params.ExtraAllowRules, params.ExtraDenyRules, err = config.ParseSandboxProfileExtra(cfg.SandboxProfileExtra)
+	if err != nil {
+		return "", fmt.Errorf("invalid sandboxProfileExtra: %w", err)
+	}
+	if err := params.Validate(); err != nil {
+		return "", err
 	}
-	if debug && allowLocalBinding && !allowLocalOutbound {
+
+	if opts.Debug && len(opts.ExposedPorts) > 0 {
+		fmt.Fprintf(os.Stderr, "[fence:macos] Enabling local binding for exposed ports: %v\n", opts.ExposedPorts)
+	}
+	if opts.Debug && allowLocalBinding && !allowLocalOutbound {
 		fmt.Fprintf(os.Stderr, "[fence:macos] Blocking localhost outbound (AllowLocalOutbound=false)\n")
 	}
 
 	profile := GenerateSandboxProfile(params)
 
-	proxyEnvs := GenerateProxyEnvVars(httpPort, socksPort)
+	proxyEnvs := GenerateProxyEnvVars(opts.HTTPPort, opts.SOCKSPort, nil)
 
 	// Build the command
 	// env VAR1=val1 VAR2=val2 sandbox-exec -p 'profile' shell -c 'command'
 	var parts []string
 	parts = append(parts, "env")
 	parts = append(parts, proxyEnvs...)
-	parts = append(parts, "sandbox-exec", "-p", profile, shellPath, shellFlag, command)
+	parts = append(parts, "sandbox-exec", "-p", profile, shellPath, shellFlag, resourceLimitCommands(cfg.ResourceLimits, "darwin")+command)
 
+	if opts.AuditLogger != nil {
+		opts.AuditLogger.LogCommandExec(command, true)
+	}
 	return ShellQuote(parts), nil
 }
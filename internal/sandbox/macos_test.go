@@ -1,6 +1,9 @@
 package sandbox
 
 import (
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -96,15 +99,15 @@ func buildMacOSParamsForTest(cfg *config.Config) MacOSSandboxParams {
 		}
 	}
 
-	needsNetwork := len(cfg.Network.AllowedDomains) > 0 || len(cfg.Network.DeniedDomains) > 0
-	allowPaths := append(GetDefaultWritePaths(), cfg.Filesystem.AllowWrite...)
+	needsNetwork := len(cfg.Network.EffectiveAllowedDomains()) > 0 || len(cfg.Network.DeniedDomains) > 0
+	allowPaths := append(GetDefaultWritePaths(), cfg.Filesystem.WritablePaths()...)
 	allowLocalBinding := cfg.Network.AllowLocalBinding
 	allowLocalOutbound := allowLocalBinding
 	if cfg.Network.AllowLocalOutbound != nil {
 		allowLocalOutbound = *cfg.Network.AllowLocalOutbound
 	}
 
-	needsNetworkRestriction := !hasWildcardAllow && (needsNetwork || len(cfg.Network.AllowedDomains) == 0)
+	needsNetworkRestriction := !hasWildcardAllow && (needsNetwork || len(cfg.Network.EffectiveAllowedDomains()) == 0)
 
 	return MacOSSandboxParams{
 		Command:                 "echo test",
@@ -115,12 +118,17 @@ func buildMacOSParamsForTest(cfg *config.Config) MacOSSandboxParams {
 		AllowAllUnixSockets:     cfg.Network.AllowAllUnixSockets,
 		AllowLocalBinding:       allowLocalBinding,
 		AllowLocalOutbound:      allowLocalOutbound,
+		DirectProtocols:         wildcardProtocols(cfg),
 		DefaultDenyRead:         cfg.Filesystem.DefaultDenyRead,
-		ReadAllowPaths:          cfg.Filesystem.AllowRead,
-		ReadDenyPaths:           cfg.Filesystem.DenyRead,
+		ReadAllowPaths:          expandMacOSDataVolumePaths(slices.Clone(cfg.Filesystem.AllowRead)),
+		ReadDenyPaths:           expandMacOSDataVolumePaths(slices.Clone(cfg.Filesystem.DenyRead)),
 		WriteAllowPaths:         allowPaths,
 		WriteDenyPaths:          cfg.Filesystem.DenyWrite,
+		AtomicWritePaths:        cfg.Filesystem.AtomicWritePaths(),
+		DeniedExecPaths:         GetRuntimeDeniedExecutablePaths(cfg),
 		AllowPty:                cfg.AllowPty,
+		AllowGPU:                cfg.AllowGPU,
+		AllowKeychain:           cfg.AllowKeychain,
 		AllowGitConfig:          cfg.Filesystem.AllowGitConfig,
 	}
 }
@@ -130,6 +138,8 @@ func TestMacOS_ProfileNetworkSection(t *testing.T) {
 	tests := []struct {
 		name           string
 		restricted     bool
+		protos         []string
+		loopback       bool
 		wantContains   []string
 		wantNotContain []string
 	}{
@@ -151,6 +161,30 @@ func TestMacOS_ProfileNetworkSection(t *testing.T) {
 				"(allow network*)", // Should NOT have blanket allow
 			},
 		},
+		{
+			name:       "tcp wildcard allows direct tcp only",
+			restricted: true,
+			protos:     []string{"tcp", "icmp"},
+			wantContains: []string{
+				`(allow network-outbound (remote tcp "*:*"))`,
+			},
+			wantNotContain: []string{
+				"(allow network*)",
+				"(remote udp",
+				"icmp",
+			},
+		},
+		{
+			name:       "loopback address allows direct localhost",
+			restricted: true,
+			loopback:   true,
+			wantContains: []string{
+				`(allow network-outbound (remote ip "localhost:*"))`,
+			},
+			wantNotContain: []string{
+				`"::1`,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -160,6 +194,8 @@ func TestMacOS_ProfileNetworkSection(t *testing.T) {
 				NeedsNetworkRestriction: tt.restricted,
 				HTTPProxyPort:           8080,
 				SOCKSProxyPort:          1080,
+				DirectProtocols:         tt.protos,
+				AllowLoopbackOutbound:   tt.loopback,
 			}
 
 			profile := GenerateSandboxProfile(params)
@@ -265,9 +301,10 @@ func TestMacOS_DefaultDenyRead(t *testing.T) {
 // TestExpandMacOSTmpPaths verifies that /tmp and /private/tmp paths are properly mirrored.
 func TestExpandMacOSTmpPaths(t *testing.T) {
 	tests := []struct {
-		name  string
-		input []string
-		want  []string
+		name   string
+		tmpdir string
+		input  []string
+		want   []string
 	}{
 		{
 			name:  "mirrors /tmp to /private/tmp",
@@ -309,10 +346,45 @@ func TestExpandMacOSTmpPaths(t *testing.T) {
 			input: []string{".", "/tmp/fence", "/private/tmp/fence"},
 			want:  []string{".", "/tmp/fence", "/private/tmp/fence"},
 		},
+		{
+			name:  "mirrors /var/folders to /private/var/folders",
+			input: []string{".", "/var/folders/ab/cd123/T/build"},
+			want:  []string{".", "/var/folders/ab/cd123/T/build", "/private/var/folders/ab/cd123/T/build"},
+		},
+		{
+			name:  "mirrors /private/var/folders to /var/folders",
+			input: []string{".", "/private/var/folders/ab/cd123/T"},
+			want:  []string{".", "/private/var/folders/ab/cd123/T", "/var/folders/ab/cd123/T"},
+		},
+		{
+			name:   "adds TMPDIR under /var/folders",
+			tmpdir: "/var/folders/ab/cd123/T/",
+			input:  []string{"."},
+			want:   []string{".", "/var/folders/ab/cd123/T", "/private/var/folders/ab/cd123/T"},
+		},
+		{
+			name:   "adds TMPDIR under /private/var/folders",
+			tmpdir: "/private/var/folders/ab/cd123/T",
+			input:  []string{".", "/tmp"},
+			want:   []string{".", "/tmp", "/private/var/folders/ab/cd123/T", "/private/tmp", "/var/folders/ab/cd123/T"},
+		},
+		{
+			name:   "no duplicate when TMPDIR already present",
+			tmpdir: "/var/folders/ab/cd123/T",
+			input:  []string{".", "/var/folders/ab/cd123/T"},
+			want:   []string{".", "/var/folders/ab/cd123/T", "/private/var/folders/ab/cd123/T"},
+		},
+		{
+			name:   "ignores TMPDIR outside /var/folders",
+			tmpdir: "/Users/me/tmp",
+			input:  []string{"."},
+			want:   []string{"."},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TMPDIR", tt.tmpdir)
 			got := expandMacOSTmpPaths(tt.input)
 
 			if len(got) != len(tt.want) {
@@ -328,3 +400,562 @@ func TestExpandMacOSTmpPaths(t *testing.T) {
 		})
 	}
 }
+
+func TestExpandMacOSDataVolumePaths(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+
+	tests := []struct {
+		name  string
+		input []string
+		want  []string
+	}{
+		{
+			name:  "mirrors /Users path to data volume",
+			input: []string{"/Users/alice/project"},
+			want:  []string{"/Users/alice/project", "/System/Volumes/Data/Users/alice/project"},
+		},
+		{
+			name:  "mirrors data volume path to /Users",
+			input: []string{"/System/Volumes/Data/Users/alice"},
+			want:  []string{"/System/Volumes/Data/Users/alice", "/Users/alice"},
+		},
+		{
+			name:  "mirrors /Users itself",
+			input: []string{"/Users"},
+			want:  []string{"/Users", "/System/Volumes/Data/Users"},
+		},
+		{
+			name:  "no duplicate when mirror already present",
+			input: []string{"/Users/alice", "/System/Volumes/Data/Users/alice"},
+			want:  []string{"/Users/alice", "/System/Volumes/Data/Users/alice"},
+		},
+		{
+			name:  "ignores other paths",
+			input: []string{"/usr/local", "/Usersfoo", "/System/Volumes/Data/Applications", "."},
+			want:  []string{"/usr/local", "/Usersfoo", "/System/Volumes/Data/Applications", "."},
+		},
+		{
+			name:  "ignores lookalike data volume path",
+			input: []string{"/System/Volumes/Data/Usersfoo"},
+			want:  []string{"/System/Volumes/Data/Usersfoo"},
+		},
+	}
+
+	if strings.HasPrefix(home, "/Users/") {
+		tests = append(tests, struct {
+			name  string
+			input []string
+			want  []string
+		}{
+			name:  "expands and mirrors home-relative path",
+			input: []string{"~/code"},
+			want:  []string{"~/code", filepath.Join(home, "code"), "/System/Volumes/Data" + filepath.Join(home, "code")},
+		})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := expandMacOSDataVolumePaths(tt.input)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expandMacOSDataVolumePaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMacOS_AllowReadIncludesDataVolumePaths verifies that with
+// defaultDenyRead, both the /Users and /System/Volumes/Data forms of an
+// allowRead path are allowed in the generated profile.
+func TestMacOS_AllowReadIncludesDataVolumePaths(t *testing.T) {
+	cfg := config.Default()
+	cfg.Filesystem.DefaultDenyRead = true
+	cfg.Filesystem.AllowRead = []string{"/Users/alice/project"}
+	cfg.Filesystem.DenyRead = []string{"/System/Volumes/Data/Users/alice/project/.env"}
+
+	profile := GenerateSandboxProfile(buildMacOSParamsForTest(cfg))
+
+	for _, want := range []string{
+		`(subpath "/Users/alice/project")`,
+		`(subpath "/System/Volumes/Data/Users/alice/project")`,
+		`(subpath "/Users/alice/project/.env")`,
+		`(subpath "/System/Volumes/Data/Users/alice/project/.env")`,
+	} {
+		if !strings.Contains(profile, want) {
+			t.Errorf("expected profile to contain %s", want)
+		}
+	}
+}
+
+// TestMacOS_DenyReadBlocksDataAndMetadata verifies that denyRead entries are
+// denied with file-read*, which covers both file-read-data and
+// file-read-metadata, in both read modes.
+func TestMacOS_DenyReadBlocksDataAndMetadata(t *testing.T) {
+	denyPaths := []string{"/Users/test/secrets", "/Users/test/**/*.pem"}
+
+	for _, defaultDenyRead := range []bool{false, true} {
+		rules := generateReadRules(defaultDenyRead, nil, denyPaths, "test")
+		profile := strings.Join(rules, "\n")
+
+		for _, p := range denyPaths {
+			var matcher string
+			if ContainsGlobChars(p) {
+				matcher = "(regex " + escapePath(GlobToRegex(p)) + ")"
+			} else {
+				matcher = "(subpath " + escapePath(p) + ")"
+			}
+			want := "(deny file-read*\n  " + matcher
+			if !strings.Contains(profile, want) {
+				t.Errorf("defaultDenyRead=%v: expected data+metadata deny for %q, got:\n%s", defaultDenyRead, p, profile)
+			}
+			if strings.Contains(profile, "(deny file-read-data\n  "+matcher) {
+				t.Errorf("defaultDenyRead=%v: deny for %q must not be limited to file-read-data", defaultDenyRead, p)
+			}
+		}
+
+		// Denies must come after any allow so they take precedence
+		lastAllow := max(strings.LastIndex(profile, "(allow file-read*)"), strings.LastIndex(profile, "(allow file-read-metadata)"))
+		firstDeny := strings.Index(profile, "(deny file-read*")
+		if firstDeny < lastAllow {
+			t.Errorf("defaultDenyRead=%v: deny rules must follow allow rules", defaultDenyRead)
+		}
+	}
+}
+
+// writeDenyTargets returns the matcher lines of every file-write* deny rule in profile.
+func writeDenyTargets(profile string) []string {
+	var targets []string
+	lines := strings.Split(profile, "\n")
+	for i := 0; i+1 < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "(deny file-write*" {
+			targets = append(targets, strings.TrimSpace(lines[i+1]))
+		}
+	}
+	return targets
+}
+
+func hasWriteDenyFor(profile, fragment string) bool {
+	for _, target := range writeDenyTargets(profile) {
+		if strings.Contains(target, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestMacOS_AllowGitConfig verifies that .git/config is write-denied unless
+// AllowGitConfig is set, while .git/hooks stays write-denied either way.
+func TestMacOS_AllowGitConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowGitConfig bool
+		wantConfigDeny bool
+	}{
+		{
+			name:           "git config denied by default",
+			allowGitConfig: false,
+			wantConfigDeny: true,
+		},
+		{
+			name:           "git config allowed when enabled",
+			allowGitConfig: true,
+			wantConfigDeny: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Filesystem.AllowWrite = []string{"."}
+			cfg.Filesystem.AllowGitConfig = tt.allowGitConfig
+
+			profile := GenerateSandboxProfile(buildMacOSParamsForTest(cfg))
+
+			if got := hasWriteDenyFor(profile, "git/config"); got != tt.wantConfigDeny {
+				t.Errorf("write deny for .git/config = %v, want %v\nwrite denies: %v",
+					got, tt.wantConfigDeny, writeDenyTargets(profile))
+			}
+			if !hasWriteDenyFor(profile, "git/hooks") {
+				t.Errorf("expected write deny for .git/hooks regardless of allowGitConfig\nwrite denies: %v",
+					writeDenyTargets(profile))
+			}
+
+			// The same must hold for the profile built from the config by
+			// WrapCommandMacOS, not only for hand-built params.
+			wrapped, err := WrapCommandMacOS(cfg, "true", 0, 0, nil, false, "", false)
+			if err != nil {
+				t.Fatalf("WrapCommandMacOS() error: %v", err)
+			}
+			if got := hasWriteDenyFor(wrapped, "git/config"); got != tt.wantConfigDeny {
+				t.Errorf("wrapped command: write deny for .git/config = %v, want %v", got, tt.wantConfigDeny)
+			}
+			if !hasWriteDenyFor(wrapped, "git/hooks") {
+				t.Error("wrapped command: expected write deny for .git/hooks regardless of allowGitConfig")
+			}
+		})
+	}
+}
+
+// TestMacOS_AllowAllUnixSockets verifies that allowAllUnixSockets allows
+// every socket path with one rule, and that otherwise only the listed
+// socket paths are allowed.
+func TestMacOS_AllowAllUnixSockets(t *testing.T) {
+	allRule := `(allow network* (subpath "/"))`
+	dockerRule := `(allow network* (subpath "/var/run/docker.sock"))`
+
+	tests := []struct {
+		name        string
+		allowAll    bool
+		sockets     []string
+		wantAll     bool
+		wantSockets []string
+	}{
+		{
+			name: "no sockets allowed",
+		},
+		{
+			name:        "listed sockets only",
+			sockets:     []string{"/var/run/docker.sock"},
+			wantSockets: []string{dockerRule},
+		},
+		{
+			name:     "all sockets",
+			allowAll: true,
+			wantAll:  true,
+		},
+		{
+			name:     "all sockets suppresses listed sockets",
+			allowAll: true,
+			sockets:  []string{"/var/run/docker.sock"},
+			wantAll:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Network.AllowUnixSockets = tt.sockets
+			cfg.Network.AllowAllUnixSockets = tt.allowAll
+
+			profile := GenerateSandboxProfile(buildMacOSParamsForTest(cfg))
+
+			if got := strings.Contains(profile, allRule); got != tt.wantAll {
+				t.Errorf("profile contains %s = %v, want %v", allRule, got, tt.wantAll)
+			}
+			for _, rule := range tt.wantSockets {
+				if !strings.Contains(profile, rule) {
+					t.Errorf("expected %s in profile", rule)
+				}
+			}
+			if len(tt.wantSockets) == 0 && strings.Contains(profile, dockerRule) {
+				t.Errorf("unexpected per-socket rule %s", dockerRule)
+			}
+		})
+	}
+}
+
+// TestMacOS_AllowGPU verifies that the GPU IOKit classes and Mach services
+// are only allowed when allowGpu is set.
+func TestMacOS_AllowGPU(t *testing.T) {
+	for _, allowGPU := range []bool{false, true} {
+		cfg := config.Default()
+		cfg.AllowGPU = allowGPU
+		profile := GenerateSandboxProfile(buildMacOSParamsForTest(cfg))
+
+		for _, class := range macOSGPUIOKitClasses {
+			if got := strings.Contains(profile, `(iokit-user-client-class "`+class+`")`); got != allowGPU {
+				t.Errorf("allowGpu=%v: IOKit class %s in profile = %v, want %v", allowGPU, class, got, allowGPU)
+			}
+		}
+		for _, service := range macOSGPUMachServices {
+			if got := strings.Contains(profile, `(global-name "`+service+`")`); got != allowGPU {
+				t.Errorf("allowGpu=%v: mach service %s in profile = %v, want %v", allowGPU, service, got, allowGPU)
+			}
+		}
+	}
+}
+
+// TestMacOS_AllowKeychain verifies that the Keychain services are only
+// reachable when allowKeychain is set, and that it is off by default.
+func TestMacOS_AllowKeychain(t *testing.T) {
+	if config.Default().AllowKeychain {
+		t.Fatal("default config has AllowKeychain = true, want false")
+	}
+
+	for _, allowKeychain := range []bool{false, true} {
+		cfg := config.Default()
+		cfg.AllowKeychain = allowKeychain
+		profile := GenerateSandboxProfile(buildMacOSParamsForTest(cfg))
+
+		for _, service := range macOSKeychainMachServices {
+			if got := strings.Contains(profile, `(global-name "`+service+`")`); got != allowKeychain {
+				t.Errorf("allowKeychain=%v: mach service %s in profile = %v, want %v", allowKeychain, service, got, allowKeychain)
+			}
+		}
+
+		home, err := os.UserHomeDir()
+		if err != nil {
+			continue
+		}
+		matcher := "(subpath " + escapePath(filepath.Join(home, "Library", "Keychains")) + ")"
+		for rule, want := range map[string]bool{
+			"(allow file-write*\n  " + matcher: allowKeychain,
+			"(deny file-write*\n  " + matcher:  !allowKeychain,
+			"(deny file-read*\n  " + matcher:   !allowKeychain,
+		} {
+			if got := strings.Contains(profile, rule); got != want {
+				t.Errorf("allowKeychain=%v: profile contains %q = %v, want %v", allowKeychain, rule, got, want)
+			}
+		}
+	}
+}
+
+func TestMacOS_AllowMachServices(t *testing.T) {
+	services := append(GetCommonDevToolMachServices(), "com.example.helper")
+	profile := GenerateSandboxProfile(MacOSSandboxParams{
+		Command:           "true",
+		AllowMachServices: services,
+	})
+
+	for _, service := range services {
+		if n := strings.Count(profile, `(global-name "`+service+`")`); n != 1 {
+			t.Errorf("profile allows mach service %s %d times, want once", service, n)
+		}
+	}
+	if !strings.Contains(profile, "; Extra Mach services") {
+		t.Error("expected an extra Mach services section")
+	}
+	if base := GenerateSandboxProfile(MacOSSandboxParams{Command: "true"}); strings.Contains(base, "; Extra Mach services") {
+		t.Error("unexpected extra Mach services section without AllowMachServices")
+	}
+	if slices.Contains(GetCommonDevToolMachServices(), "com.apple.coreservices.appleevents") {
+		t.Error("GetCommonDevToolMachServices() must not include Apple Events, which can run commands outside the sandbox")
+	}
+}
+
+func TestMacOSSandboxParamsValidateMachServices(t *testing.T) {
+	tests := []struct {
+		service string
+		wantErr bool
+	}{
+		{"com.apple.pasteboard.1", false},
+		{"com.apple.distributed_notifications@Uv3", false},
+		{"org.example.my-tool", false},
+		{"pasteboard", true},
+		{"com.apple.", true},
+		{".com.apple.x", true},
+		{`com.apple.x") (allow default`, true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		params := MacOSSandboxParams{AllowMachServices: []string{tt.service}}
+		if err := params.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate() with mach service %q error = %v, wantErr %v", tt.service, err, tt.wantErr)
+		}
+	}
+	for _, service := range GetCommonDevToolMachServices() {
+		if !machServicePattern.MatchString(service) {
+			t.Errorf("GetCommonDevToolMachServices() entry %q is not a valid service name", service)
+		}
+	}
+}
+
+// TestMacOS_DenyWriteBlocksRenameTargets verifies that denyWrite paths are
+// covered by file-write* (which includes the create, flags and mode
+// operations used by rename destinations) and by unlink rules on the path
+// and its ancestors, and that no later rule re-allows them.
+func TestMacOS_DenyWriteBlocksRenameTargets(t *testing.T) {
+	denied := "/Users/test/project/protected"
+	rules := generateWriteRules([]string{"/Users/test/project", "/tmp/fence"}, []string{denied}, false, nil, nil, "test")
+	profile := strings.Join(rules, "\n")
+
+	matcher := "(subpath " + escapePath(denied) + ")"
+	deny := strings.Index(profile, "(deny file-write*\n  "+matcher)
+	if deny == -1 {
+		t.Fatalf("expected file-write* deny for %s, got:\n%s", denied, profile)
+	}
+	if strings.LastIndex(profile, "(allow file-write") > deny {
+		t.Error("allow rules must not follow the denyWrite rule")
+	}
+	for _, op := range []string{"file-write-create", "file-write-flags", "file-write-mode", "file-write-data"} {
+		if strings.Contains(profile, "(allow "+op) {
+			t.Errorf("profile must not separately allow %s", op)
+		}
+	}
+
+	if !strings.Contains(profile, "(deny file-write-unlink\n  "+matcher) {
+		t.Errorf("expected unlink deny for %s", denied)
+	}
+	for _, ancestor := range []string{"/Users/test/project", "/Users/test"} {
+		if !strings.Contains(profile, "(deny file-write-unlink\n  (literal "+escapePath(ancestor)+")") {
+			t.Errorf("expected unlink deny for ancestor %s so it cannot be moved aside", ancestor)
+		}
+	}
+}
+
+// TestMacOS_AtomicWriteDeniesInPlaceWrites verifies that atomic:true files get
+// a file-write-data deny after the allow rules, without denying the create
+// and unlink operations a rename onto the path needs.
+func TestMacOS_AtomicWriteDeniesInPlaceWrites(t *testing.T) {
+	cfg := &config.Config{
+		Filesystem: config.FilesystemConfig{
+			AllowWrite: []string{"/Users/test/project", "/Users/test/project/config.json atomic:true"},
+		},
+	}
+	profile := GenerateSandboxProfile(buildMacOSParamsForTest(cfg))
+
+	matcher := "(literal " + escapePath("/Users/test/project/config.json") + ")"
+	deny := strings.Index(profile, "(deny file-write-data\n  "+matcher)
+	if deny == -1 {
+		t.Fatalf("expected file-write-data deny for atomic file, got:\n%s", profile)
+	}
+	if strings.LastIndex(profile, "(allow file-write") > deny {
+		t.Error("allow rules must not follow the atomic write deny")
+	}
+	for _, op := range []string{"file-write*", "file-write-create", "file-write-unlink"} {
+		if strings.Contains(profile, "(deny "+op+"\n  "+matcher) {
+			t.Errorf("atomic file must not deny %s, which rename needs", op)
+		}
+	}
+	if strings.Contains(profile, "atomic:true") {
+		t.Error("atomic:true suffix leaked into the profile")
+	}
+}
+
+func TestMacOS_DenyExecuteInProfile(t *testing.T) {
+	tool := filepath.Join(t.TempDir(), "curl")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\n"), 0o755); err != nil { //nolint:gosec // test executable
+		t.Fatal(err)
+	}
+	resolved, err := filepath.EvalSymlinks(tool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Filesystem: config.FilesystemConfig{
+			AllowWrite:  []string{filepath.Dir(tool)},
+			DenyExecute: []string{tool},
+		},
+	}
+	profile := GenerateSandboxProfile(buildMacOSParamsForTest(cfg))
+
+	if !strings.Contains(profile, "(deny process-exec\n  (literal "+escapePath(resolved)+")") {
+		t.Errorf("expected process-exec deny for %s, got:\n%s", resolved, profile)
+	}
+}
+
+// TestExpandMacOSTmpPathsResolvesTMPDIR verifies that a TMPDIR reached
+// through a symlink is added in both its raw and resolved forms.
+func TestExpandMacOSTmpPathsResolvesTMPDIR(t *testing.T) {
+	if _, err := os.Stat("/var/folders"); err != nil {
+		t.Skip("/var/folders not present")
+	}
+	tmpdir, err := os.MkdirTemp("/var/folders", "fence-test-")
+	if err != nil {
+		t.Skipf("cannot create a directory under /var/folders: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(tmpdir) })
+	resolved, err := filepath.EvalSymlinks(tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TMPDIR", tmpdir)
+	got := expandMacOSTmpPaths(nil)
+	for _, want := range []string{tmpdir, resolved} {
+		if !slices.Contains(got, want) {
+			t.Errorf("expandMacOSTmpPaths() = %v, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestGenerateSandboxProfile_DeduplicatesPaths(t *testing.T) {
+	profile := GenerateSandboxProfile(MacOSSandboxParams{
+		Command:         "true",
+		WriteAllowPaths: []string{"/opt/fence-dedup/b", "/opt/fence-dedup/a", "/opt/fence-dedup/b"},
+		ReadDenyPaths:   []string{"/opt/fence-dedup/secret", "/opt/fence-dedup/secret"},
+	})
+
+	for _, rule := range []string{
+		"(allow file-write*\n  (subpath \"/opt/fence-dedup/a\")",
+		"(allow file-write*\n  (subpath \"/opt/fence-dedup/b\")",
+		"(deny file-read*\n  (subpath \"/opt/fence-dedup/secret\")",
+	} {
+		if n := strings.Count(profile, rule); n != 1 {
+			t.Errorf("profile contains %q %d times, want once", rule, n)
+		}
+	}
+}
+
+func TestDeduplicateAndSort(t *testing.T) {
+	got := deduplicateAndSort([]string{"/b", "/a", "/b", "/c", "/a"})
+	if want := []string{"/a", "/b", "/c"}; !slices.Equal(got, want) {
+		t.Errorf("deduplicateAndSort() = %v, want %v", got, want)
+	}
+	if got := deduplicateAndSort(nil); got != nil {
+		t.Errorf("deduplicateAndSort(nil) = %v, want nil", got)
+	}
+}
+
+func TestGenerateSandboxProfile_ExtraRules(t *testing.T) {
+	allowRule := `(allow mach-lookup (global-name "com.example.helper"))`
+	denyRule := `(deny file-read* (subpath "/opt/fence-extra"))`
+	profile := GenerateSandboxProfile(MacOSSandboxParams{
+		Command:         "true",
+		DeniedExecPaths: []string{"/usr/bin/fence-denied"},
+		AllowPty:        true,
+		ExtraAllowRules: []string{allowRule},
+		ExtraDenyRules:  []string{denyRule},
+	})
+
+	allowAt := strings.Index(profile, allowRule)
+	denyAt := strings.Index(profile, denyRule)
+	if allowAt < 0 || denyAt < 0 {
+		t.Fatalf("profile is missing extra rules:\n%s", profile)
+	}
+	// Extra allows come before fence's denies; extra denies come last
+	if execDenyAt := strings.Index(profile, "(deny process-exec"); allowAt > execDenyAt {
+		t.Errorf("extra allow rule at %d should precede exec deny rules at %d", allowAt, execDenyAt)
+	}
+	if ptyAt := strings.Index(profile, "(allow pseudo-tty)"); denyAt < ptyAt {
+		t.Errorf("extra deny rule at %d should follow pty rules at %d", denyAt, ptyAt)
+	}
+}
+
+func TestMacOSSandboxParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  MacOSSandboxParams
+		wantErr bool
+	}{
+		{name: "no extra rules"},
+		{
+			name:   "valid rules",
+			params: MacOSSandboxParams{ExtraAllowRules: []string{"(allow network-outbound)"}, ExtraDenyRules: []string{"(deny file-write*)"}},
+		},
+		{name: "deny in allow list", params: MacOSSandboxParams{ExtraAllowRules: []string{"(deny file-write*)"}}, wantErr: true},
+		{name: "allow in deny list", params: MacOSSandboxParams{ExtraDenyRules: []string{"(allow network*)"}}, wantErr: true},
+		{name: "two rules in one entry", params: MacOSSandboxParams{ExtraAllowRules: []string{"(allow a) (allow b)"}}, wantErr: true},
+		{name: "unbalanced", params: MacOSSandboxParams{ExtraAllowRules: []string{"(allow a"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.params.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWrapCommandMacOS_RejectsInvalidProfileExtra(t *testing.T) {
+	cfg := config.Default()
+	cfg.SandboxProfileExtra = "(version 1)"
+	if _, err := WrapCommandMacOS(cfg, "true", 0, 0, nil, false, "", false); err == nil {
+		t.Error("expected an error for a sandboxProfileExtra form other than allow or deny")
+	}
+}
@@ -1,14 +1,23 @@
 package sandbox
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Use-Tusk/fence/internal/config"
 	"github.com/Use-Tusk/fence/internal/platform"
 	"github.com/Use-Tusk/fence/internal/proxy"
 )
 
+// bridgeHealthInterval is how often the manager checks the Linux bridge.
+const bridgeHealthInterval = 2 * time.Second
+
 // Manager handles sandbox initialization and command wrapping.
 type Manager struct {
 	config        *config.Config
@@ -16,14 +25,22 @@ type Manager struct {
 	socksProxy    *proxy.SOCKSProxy
 	linuxBridge   *LinuxBridge
 	reverseBridge *ReverseBridge
+	stopHealth    context.CancelFunc // Stops the Linux bridge health monitor
 	httpPort      int
 	socksPort     int
 	exposedPorts  []int
+	caBundlePath  string // CA bundle trusted inside the sandbox when TLS inspection is on
 	shellMode     string
+	shellPath     string // Shell for ShellModeCustom
 	shellLogin    bool
 	debug         bool
 	monitor       bool
 	initialized   bool
+
+	auditLogger   AuditLogger         // Receives sandbox decisions; nil when nothing is logged
+	auditFile     *FileAuditLogger    // Opened for auditLogPath, or stderr in audit mode
+	webhook       *WebhookAuditLogger // Set when webhookUrl is configured
+	auditedWrites sync.Map            // Paths already logged by AuditWrite
 }
 
 // NewManager creates a new sandbox manager.
@@ -41,15 +58,30 @@ func (m *Manager) SetExposedPorts(ports []int) {
 	m.exposedPorts = ports
 }
 
-// SetShellOptions sets shell selection options for command execution.
-func (m *Manager) SetShellOptions(mode string, login bool) {
+// SetShellOptions sets shell selection options for command execution. path
+// is the shell run in ShellModeCustom.
+func (m *Manager) SetShellOptions(mode, path string, login bool) {
 	if mode == "" {
 		mode = ShellModeDefault
 	}
 	m.shellMode = mode
+	m.shellPath = path
 	m.shellLogin = login
 }
 
+// SetAuditLogger sets a logger that receives sandbox decisions, such as a
+// StatsCollector. It is called in addition to the FileAuditLogger that
+// Initialize opens when auditLogPath is set or enforcementMode is audit.
+// Call it before Initialize.
+func (m *Manager) SetAuditLogger(logger AuditLogger) {
+	m.auditLogger = logger
+}
+
+// AuditLogger returns the logger that receives sandbox decisions, or nil.
+func (m *Manager) AuditLogger() AuditLogger {
+	return m.auditLogger
+}
+
 // Initialize sets up the sandbox infrastructure (proxies, etc.).
 func (m *Manager) Initialize() error {
 	if m.initialized {
@@ -60,9 +92,38 @@ func (m *Manager) Initialize() error {
 		return fmt.Errorf("sandbox is not supported on platform: %s", platform.Detect())
 	}
 
-	filter := proxy.CreateDomainFilter(m.config, m.debug)
+	m.warnUnresolvedDenyPaths()
+
+	resolver := m.newResolver()
+	filter := proxy.CreateDomainFilterWithResolver(m.config, m.debug, m.auditNetwork, resolver)
+	if m.config != nil && (m.config.AuditMode() || m.config.AuditLogPath != "") {
+		logger, err := NewFileAuditLogger(m.config.AuditLogPath)
+		if err != nil {
+			return err
+		}
+		m.auditFile = logger
+		m.auditLogger = combineAuditLoggers(m.auditLogger, logger)
+	}
+	if m.config != nil && m.config.WebhookURL != "" {
+		m.webhook = NewWebhookAuditLogger(m.config.WebhookURL)
+		m.auditLogger = combineAuditLoggers(m.auditLogger, m.webhook)
+	}
+	if m.auditLogger != nil {
+		filter = auditFilter(filter, m.auditLogger, m.config.AuditMode())
+	}
+	if m.config == nil || m.config.Network.HonorsHostsFile() {
+		// Runs before the command starts so the warning is not interleaved
+		// with its output
+		m.warnHostsOverrides(proxy.NewDNSOnlyLookup(nil))
+	}
 
 	m.httpProxy = proxy.NewHTTPProxy(filter, m.debug, m.monitor)
+	m.httpProxy.SetDialer(proxy.NewDialer(resolver.DialLookup))
+	if m.config != nil && m.config.Network.InspectTLS {
+		if err := m.setupTLSInspection(); err != nil {
+			return err
+		}
+	}
 	httpPort, err := m.httpProxy.Start()
 	if err != nil {
 		return fmt.Errorf("failed to start HTTP proxy: %w", err)
@@ -70,6 +131,8 @@ func (m *Manager) Initialize() error {
 	m.httpPort = httpPort
 
 	m.socksProxy = proxy.NewSOCKSProxy(filter, m.debug, m.monitor)
+	m.socksProxy.SetLookup(resolver.Lookup)
+	m.socksProxy.SetDialer(proxy.NewDialer(resolver.DialLookup))
 	socksPort, err := m.socksProxy.Start()
 	if err != nil {
 		_ = m.httpProxy.Stop()
@@ -77,15 +140,32 @@ func (m *Manager) Initialize() error {
 	}
 	m.socksPort = socksPort
 
-	// On Linux, set up the socat bridges
-	if platform.Detect() == platform.Linux {
-		bridge, err := NewLinuxBridge(m.httpPort, m.socksPort, m.debug)
+	// On Linux, set up the socat bridges. Audit mode runs the command in the
+	// host network namespace, where the proxies are reachable directly.
+	// Without socat, a config with no network access runs with no bridge:
+	// the network namespace already blocks every connection.
+	skipBridge := false
+	if platform.Detect() == platform.Linux && len(m.exposedPorts) == 0 && socatOptional(m.config) {
+		if _, err := exec.LookPath("socat"); err != nil {
+			skipBridge = true
+			if m.debug {
+				fmt.Fprintf(os.Stderr, "[fence] socat not found; no network access is configured, so running without proxy bridges\n")
+			}
+		}
+	}
+	if platform.Detect() == platform.Linux && !m.config.AuditMode() && !skipBridge {
+		bridge, err := NewLinuxBridge(m.httpPort, m.socksPort, LinuxBridgeOptions{RetryOnBusyPort: true, Debug: m.debug})
 		if err != nil {
 			_ = m.httpProxy.Stop()
 			_ = m.socksProxy.Stop()
 			return fmt.Errorf("failed to initialize Linux bridge: %w", err)
 		}
 		m.linuxBridge = bridge
+		var healthCtx context.Context
+		healthCtx, m.stopHealth = context.WithCancel(context.Background())
+		bridge.StartHealthMonitor(healthCtx, bridgeHealthInterval, func(err error) {
+			fmt.Fprintf(os.Stderr, "[fence] Warning: network bridge failed, sandboxed network access will not work: %v\n", err)
+		})
 
 		// Set up reverse bridge for exposed ports (inbound connections)
 		// Only needed when network namespace is available - otherwise they share the network
@@ -93,6 +173,7 @@ func (m *Manager) Initialize() error {
 		if len(m.exposedPorts) > 0 && features.CanUnshareNet {
 			reverseBridge, err := NewReverseBridge(m.exposedPorts, m.debug)
 			if err != nil {
+				m.stopHealth()
 				m.linuxBridge.Cleanup()
 				_ = m.httpProxy.Stop()
 				_ = m.socksProxy.Stop()
@@ -109,6 +190,104 @@ func (m *Manager) Initialize() error {
 	return nil
 }
 
+// effectiveDenyRead returns filesystem.denyRead plus the paths the sandbox may
+// never read: with TLS inspection on, the CA private key, which would let the
+// command sign certificates that sandboxed clients trust.
+func effectiveDenyRead(cfg *config.Config) []string {
+	if cfg == nil {
+		return nil
+	}
+	paths := slices.Clone(cfg.Filesystem.DenyRead)
+	if cfg.Network.InspectTLS && cfg.Network.InspectTLSCAKey != "" {
+		paths = append(paths, NormalizePath(cfg.Network.InspectTLSCAKey))
+	}
+	return paths
+}
+
+// setupTLSInspection enables HTTPS interception on the HTTP proxy and writes
+// the CA bundle that sandboxed processes are pointed at.
+func (m *Manager) setupTLSInspection() error {
+	caCert := NormalizePath(m.config.Network.InspectTLSCACert)
+	caKey := NormalizePath(m.config.Network.InspectTLSCAKey)
+
+	inspector, err := proxy.NewTLSInspector(caCert, caKey, m.config.Command.DenyOutputPatterns)
+	if err != nil {
+		return err
+	}
+	bundlePath, err := WriteTLSInspectionCABundle(caCert, ensureSandboxTMPDIR())
+	if err != nil {
+		return err
+	}
+
+	m.httpProxy.SetTLSInspector(inspector)
+	m.caBundlePath = bundlePath
+	m.logDebug("TLS inspection enabled (CA bundle: %s)", bundlePath)
+	return nil
+}
+
+// auditNetwork reports network audit events. New domains are always printed
+// since alertOnNewDomain exists to surface them.
+func (m *Manager) auditNetwork(event proxy.NetworkAuditEvent) {
+	if event.Type == proxy.NewDomainFirstSeen {
+		fmt.Fprintf(os.Stderr, "[fence:audit] %s: %s:%d\n", event.Type, event.Host, event.Port)
+	}
+}
+
+// newResolver returns the resolver shared by the domain filter and both
+// proxies, backed by one DNS cache. The system resolver honors /etc/hosts;
+// the DNS-only lookup bypasses it.
+func (m *Manager) newResolver() *proxy.Resolver {
+	cacheTTL := proxy.DefaultMaxCacheTTL
+	if m.config != nil && m.config.Network.DNSCacheTTL > 0 {
+		cacheTTL = time.Duration(m.config.Network.DNSCacheTTL) * time.Second
+	}
+	resolve := proxy.NewDNSOnlyTTLLookup(nil)
+	if m.config == nil || m.config.Network.HonorsHostsFile() {
+		resolve = proxy.NewSystemTTLLookup()
+	}
+	return proxy.NewResolver(proxy.NewCachingLookup(proxy.NewDNSCache(cacheTTL), resolve))
+}
+
+// hostsCheckTimeout bounds the DNS queries of warnHostsOverrides.
+const hostsCheckTimeout = 3 * time.Second
+
+// warnHostsOverrides warns when /etc/hosts points an allowed domain somewhere
+// other than DNS does, since connections would then bypass the expected host.
+// The comparison needs answers that bypass /etc/hosts, which net.Resolver
+// cannot give, so lookup queries the nameservers directly; the whole check
+// is bounded by hostsCheckTimeout, and only hosts entries for allowed
+// domains are looked up.
+func (m *Manager) warnHostsOverrides(lookup proxy.LookupFunc) {
+	if m.config == nil || len(m.config.Network.EffectiveAllowedDomains()) == 0 {
+		return
+	}
+	hosts, err := proxy.LoadHostsFile(proxy.DefaultHostsFilePath)
+	if err != nil {
+		m.logDebug("Skipping hosts file check: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hostsCheckTimeout)
+	defer cancel()
+	for _, o := range proxy.FindHostsOverrides(ctx, m.config.Network.EffectiveAllowedDomains(), hosts, lookup) {
+		fmt.Fprintf(os.Stderr, "[fence] Warning: %s (set network.honorHostsFile to false to ignore /etc/hosts)\n", o)
+	}
+}
+
+// warnUnresolvedDenyPaths warns about denyRead and denyWrite entries that
+// reference $HOME or $XDG_RUNTIME_DIR while it is unset. Such entries are
+// used literally and so protect nothing.
+func (m *Manager) warnUnresolvedDenyPaths() {
+	if m.config == nil {
+		return
+	}
+	for _, entry := range slices.Concat(m.config.Filesystem.DenyRead, m.config.Filesystem.DenyWrite) {
+		if vars := unresolvedPathVariables(entry); len(vars) > 0 {
+			fmt.Fprintf(os.Stderr, "[fence] Warning: deny entry %q uses unset %s and has no effect\n", entry, strings.Join(vars, ", "))
+		}
+	}
+}
+
 // WrapCommand wraps a command with sandbox restrictions.
 // Returns an error if the command is blocked by policy.
 func (m *Manager) WrapCommand(command string) (string, error) {
@@ -118,20 +297,73 @@ func (m *Manager) WrapCommand(command string) (string, error) {
 		}
 	}
 
-	// Check if command is blocked by policy
-	if err := CheckCommand(command, m.config); err != nil {
+	if m.webhook != nil {
+		m.webhook.SetCommand(command)
+	}
+
+	// Check if command is blocked by policy. Audit mode checks like shadow
+	// mode: every match is reported but nothing is blocked.
+	checkCfg := m.config
+	if m.config.AuditMode() {
+		shadow := *m.config
+		shadow.Command.ShadowMode = true
+		checkCfg = &shadow
+	}
+	if err := CheckCommandWithAudit(command, checkCfg, m.auditCommand); err != nil {
+		m.logCommandRefused(command)
 		return "", err
 	}
 
+	if m.config != nil && m.config.Command.VerifyBinaryIntegrity {
+		storePath, err := DefaultBinaryHashesPath()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate binary hash store: %w", err)
+		}
+		if err := CheckBinaryIntegrity(command, m.config, storePath, m.auditCommand); err != nil && !m.config.AuditMode() {
+			m.logCommandRefused(command)
+			return "", err
+		}
+	}
+
+	var wrapped string
+	var err error
 	plat := platform.Detect()
-	switch plat {
-	case platform.MacOS:
-		return WrapCommandMacOS(m.config, command, m.httpPort, m.socksPort, m.exposedPorts, m.debug, m.shellMode, m.shellLogin)
-	case platform.Linux:
-		return WrapCommandLinuxWithShell(m.config, command, m.linuxBridge, m.reverseBridge, m.debug, m.shellMode, m.shellLogin)
+	switch {
+	case m.config.AuditMode():
+		wrapped, err = WrapCommandAudit(command, m.httpPort, m.socksPort, m.shellMode, m.shellPath, m.shellLogin, m.auditLogger)
+	case plat == platform.MacOS:
+		wrapped, err = WrapCommandMacOSWithOptions(m.config, command, MacOSSandboxOptions{
+			HTTPPort:     m.httpPort,
+			SOCKSPort:    m.socksPort,
+			ExposedPorts: m.exposedPorts,
+			Debug:        m.debug,
+			ShellMode:    m.shellMode,
+			ShellPath:    m.shellPath,
+			ShellLogin:   m.shellLogin,
+			AuditLogger:  m.auditLogger,
+		})
+	case plat == platform.Linux:
+		wrapped, err = WrapCommandLinuxWithOptions(m.config, command, m.linuxBridge, m.reverseBridge, LinuxSandboxOptions{
+			UseLandlock: true,
+			UseSeccomp:  true,
+			UseEBPF:     true,
+			Debug:       m.debug,
+			ShellMode:   m.shellMode,
+			ShellPath:   m.shellPath,
+			ShellLogin:  m.shellLogin,
+			AuditLogger: m.auditLogger,
+		})
 	default:
 		return "", fmt.Errorf("unsupported platform: %s", plat)
 	}
+	if err != nil || m.caBundlePath == "" {
+		return wrapped, err
+	}
+
+	// The sandbox inherits the environment, so the CA bundle variables can be
+	// set outside the platform wrapper.
+	envArgs := append([]string{"env"}, TLSInspectionEnvVars(m.caBundlePath)...)
+	return ShellQuote(envArgs) + " " + wrapped, nil
 }
 
 // Cleanup stops the proxies and cleans up resources.
@@ -139,6 +371,9 @@ func (m *Manager) Cleanup() {
 	if m.reverseBridge != nil {
 		m.reverseBridge.Cleanup()
 	}
+	if m.stopHealth != nil {
+		m.stopHealth()
+	}
 	if m.linuxBridge != nil {
 		m.linuxBridge.Cleanup()
 	}
@@ -148,9 +383,72 @@ func (m *Manager) Cleanup() {
 	if m.socksProxy != nil {
 		_ = m.socksProxy.Stop()
 	}
+	if m.auditFile != nil {
+		_ = m.auditFile.Close()
+	}
+	if m.webhook != nil {
+		_ = m.webhook.Close()
+	}
 	m.logDebug("Sandbox manager cleaned up")
 }
 
+// auditCommand reports commands that the policy would block. In shadow mode
+// these are always printed since collecting them is the point of the mode.
+func (m *Manager) auditCommand(event CommandAuditEvent) {
+	source := ""
+	if event.Source != SourceNone {
+		source = fmt.Sprintf(" [%s rule]", event.Source)
+	}
+	if m.auditLogger != nil {
+		rule := event.BlockedPrefix
+		switch {
+		case event.Alert:
+			rule = "command.verifyBinaryIntegrity"
+		case rule == "":
+			rule = "ssh"
+		}
+		m.auditLogger.LogViolation(rule, event.Reason)
+		if m.config.AuditMode() {
+			return
+		}
+	}
+	if event.Alert {
+		fmt.Fprintf(os.Stderr, "[fence:alert] %s\n", event.Reason)
+		return
+	}
+	if event.BlockedInShadowMode {
+		fmt.Fprintf(os.Stderr, "[fence:shadow] Would block%s: %s\n", source, event.Reason)
+		return
+	}
+	m.logDebug("Command blocked%s: %s", source, event.Reason)
+}
+
+// logCommandRefused records a command the policy refused to run.
+func (m *Manager) logCommandRefused(command string) {
+	if m.auditLogger != nil {
+		m.auditLogger.LogCommandExec(command, false)
+	}
+}
+
+// AuditMode reports whether the manager logs policy violations instead of
+// enforcing the policy.
+func (m *Manager) AuditMode() bool {
+	return m.config.AuditMode()
+}
+
+// AuditWrite logs a write to path in audit mode if the filesystem policy
+// would have refused it. Each path is logged once; writes to the audit log
+// itself are ignored.
+func (m *Manager) AuditWrite(path string) {
+	if !m.config.AuditMode() || m.auditLogger == nil || (m.auditFile != nil && path == m.auditFile.Path()) || WriteAllowedByPolicy(m.config, path) {
+		return
+	}
+	if _, loaded := m.auditedWrites.LoadOrStore(path, struct{}{}); loaded {
+		return
+	}
+	m.auditLogger.LogFilesystemAccess(path, "write", false)
+}
+
 func (m *Manager) logDebug(format string, args ...interface{}) {
 	if m.debug {
 		fmt.Fprintf(os.Stderr, "[fence] "+format+"\n", args...)
@@ -1,17 +1,44 @@
 package sandbox
 
-import "github.com/Use-Tusk/fence/internal/config"
+import (
+	"slices"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
 
 // hasWildcardAllowedDomain reports whether direct network access should be
-// allowed by sandbox-level network restrictions.
+// allowed by sandbox-level network restrictions. A "*" restricted to one
+// protocol does not count; see wildcardProtocols.
 func hasWildcardAllowedDomain(cfg *config.Config) bool {
 	if cfg == nil {
 		return false
 	}
-	for _, d := range cfg.Network.AllowedDomains {
-		if d == "*" {
-			return true
+	return cfg.Network.AllowsDirectNetwork()
+}
+
+// wildcardProtocols returns the protocols of "proto:<protocol> *" entries,
+// which allow direct traffic of that protocol only where the platform can
+// filter by protocol (macOS Seatbelt).
+func wildcardProtocols(cfg *config.Config) []string {
+	if cfg == nil {
+		return nil
+	}
+	var protos []string
+	for _, entry := range cfg.Network.EffectiveAllowedDomains() {
+		proto := config.DomainProto(entry)
+		if proto != "" && config.BareDomain(entry) == "*" && !slices.Contains(protos, proto) {
+			protos = append(protos, proto)
 		}
 	}
-	return false
+	return protos
+}
+
+// allowsLoopbackAddress reports whether allowedDomains lists a loopback IP
+// address such as "127.0.0.1" or "::1". Loopback addresses are in NO_PROXY,
+// so clients connect to them directly rather than through the proxy.
+func allowsLoopbackAddress(cfg *config.Config) bool {
+	if cfg == nil {
+		return false
+	}
+	return len(cfg.Network.LoopbackAllowedDomains()) > 0
 }
@@ -38,6 +38,16 @@ func TestWildcardAllowedDomainsSkipsUnshareNet(t *testing.T) {
 			allowedDomains: []string{"*.openai.com"},
 			wantWildcard:   false,
 		},
+		{
+			name:           "protocol-restricted wildcard is not full wildcard",
+			allowedDomains: []string{"proto:tcp *"},
+			wantWildcard:   false,
+		},
+		{
+			name:           "ipv6 loopback is not a wildcard",
+			allowedDomains: []string{"::1", "[::1]"},
+			wantWildcard:   false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -135,3 +145,25 @@ func TestWildcardDetectionLogic(t *testing.T) {
 		})
 	}
 }
+
+func TestAllowsLoopbackAddress(t *testing.T) {
+	tests := []struct {
+		allowedDomains []string
+		want           bool
+	}{
+		{[]string{"::1"}, true},
+		{[]string{"[::1]"}, true},
+		{[]string{"127.0.0.1"}, true},
+		{[]string{"::ffff:127.0.0.1"}, true},
+		{[]string{"proto:udp ::1"}, false},
+		{[]string{"localhost"}, false},
+		{[]string{"10.0.0.1", "*.example.com"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{Network: config.NetworkConfig{AllowedDomains: tt.allowedDomains}}
+		if got := allowsLoopbackAddress(cfg); got != tt.want {
+			t.Errorf("allowsLoopbackAddress(%v) = %v, want %v", tt.allowedDomains, got, tt.want)
+		}
+	}
+}
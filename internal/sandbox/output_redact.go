@@ -0,0 +1,145 @@
+package sandbox
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// RedactedLine replaces output lines that match command.denyOutputPatterns.
+const RedactedLine = "[REDACTED]"
+
+// OutputRedactor replaces lines of command output that match any of a set
+// of patterns.
+type OutputRedactor struct {
+	patterns []*regexp.Regexp
+}
+
+// OutputRedactionEvent describes output that was redacted.
+type OutputRedactionEvent struct {
+	Lines    int      // Number of lines replaced
+	Patterns []string // Patterns that matched, in config order
+}
+
+// NewOutputRedactor compiles patterns. It returns nil if there are none.
+func NewOutputRedactor(patterns []string) (*OutputRedactor, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	r := &OutputRedactor{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Redact returns output with every matching line replaced by RedactedLine.
+// Line endings are preserved. The event has Lines == 0 if nothing matched.
+func (r *OutputRedactor) Redact(output []byte) ([]byte, OutputRedactionEvent) {
+	if r == nil || len(output) == 0 {
+		return output, OutputRedactionEvent{}
+	}
+	var out bytes.Buffer
+	out.Grow(len(output))
+	w := r.NewWriter(&out)
+	_, _ = w.Write(output)
+	_ = w.Close()
+	return out.Bytes(), w.Event()
+}
+
+// maxRedactLineLength bounds how much of a single line a RedactingWriter
+// holds. Longer lines are checked and written in pieces of this size.
+const maxRedactLineLength = 1 << 20
+
+// RedactingWriter redacts output line by line as it is written, so only the
+// current line is held in memory. Close writes a final unterminated line.
+type RedactingWriter struct {
+	r       *OutputRedactor
+	w       io.Writer
+	line    []byte
+	matched []bool
+	lines   int
+}
+
+// NewWriter returns a RedactingWriter that writes redacted output to w.
+func (r *OutputRedactor) NewWriter(w io.Writer) *RedactingWriter {
+	return &RedactingWriter{r: r, w: w, matched: make([]bool, len(r.patterns))}
+}
+
+// Write implements io.Writer.
+func (rw *RedactingWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		idx := bytes.IndexByte(p, '\n')
+		if idx == -1 {
+			rw.line = append(rw.line, p...)
+			if len(rw.line) >= maxRedactLineLength {
+				if err := rw.flush(nil); err != nil {
+					return 0, err
+				}
+			}
+			break
+		}
+		rw.line = append(rw.line, p[:idx]...)
+		p = p[idx+1:]
+		if err := rw.flush([]byte("\n")); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Close writes any buffered partial line.
+func (rw *RedactingWriter) Close() error {
+	if len(rw.line) == 0 {
+		return nil
+	}
+	return rw.flush(nil)
+}
+
+// Event reports what has been redacted so far.
+func (rw *RedactingWriter) Event() OutputRedactionEvent {
+	event := OutputRedactionEvent{Lines: rw.lines}
+	for i, ok := range rw.matched {
+		if ok {
+			event.Patterns = append(event.Patterns, rw.r.patterns[i].String())
+		}
+	}
+	return event
+}
+
+// flush writes the buffered line, redacted if it matches, followed by eol.
+func (rw *RedactingWriter) flush(eol []byte) error {
+	line := rw.line
+	rw.line = rw.line[:0]
+
+	hit := false
+	for i, re := range rw.r.patterns {
+		if re.Match(line) {
+			rw.matched[i] = true
+			hit = true
+		}
+	}
+	if hit {
+		rw.lines++
+		redacted := []byte(RedactedLine)
+		if bytes.HasSuffix(line, []byte("\r")) {
+			redacted = append(redacted, '\r')
+		}
+		line = redacted
+	}
+	if _, err := rw.w.Write(line); err != nil {
+		return err
+	}
+	if len(eol) > 0 {
+		if _, err := rw.w.Write(eol); err != nil {
+			return err
+		}
+	}
+	return nil
+}
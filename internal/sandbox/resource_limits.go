@@ -0,0 +1,36 @@
+package sandbox
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
+
+// resourceLimitCommands returns shell lines that lower the rlimits of the
+// shell that runs the sandboxed command, so every process it starts inherits
+// them. Soft and hard limits are both set, so the command cannot raise them
+// again. If a limit cannot be applied the shell exits instead of running the
+// command unrestricted. The lines only use ulimit flags that POSIX shells
+// such as dash accept on the platform they are emitted for.
+//
+// maxMemoryMB maps to RLIMIT_DATA, which Linux applies to the heap and all
+// private writable mappings. macOS does not enforce it, so it is only emitted
+// for Linux. maxProcesses maps to RLIMIT_NPROC only on macOS: the kernel
+// counts every process of the user against it, so on Linux it is enforced
+// with the pids.max of the resource cgroup instead. maxCPUPercent and
+// maxWallSeconds have no rlimit equivalent and are enforced outside the
+// sandbox.
+func resourceLimitCommands(limits config.ResourceLimits, goos string) string {
+	var b strings.Builder
+	if limits.MaxProcesses > 0 && goos == "darwin" {
+		fmt.Fprintf(&b, "ulimit -u %d || exit 1\n", limits.MaxProcesses)
+	}
+	if limits.MaxOpenFiles > 0 {
+		fmt.Fprintf(&b, "ulimit -n %d || exit 1\n", limits.MaxOpenFiles)
+	}
+	if limits.MaxMemoryMB > 0 && goos == "linux" {
+		fmt.Fprintf(&b, "ulimit -d %d || exit 1\n", limits.MaxMemoryMB*1024)
+	}
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package sandbox
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
+
+func TestResourceLimitCommands(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits config.ResourceLimits
+		goos   string
+		want   string
+	}{
+		{
+			name: "no limits",
+			goos: "linux",
+			want: "",
+		},
+		{
+			name:   "processes and files",
+			limits: config.ResourceLimits{MaxProcesses: 64, MaxOpenFiles: 256},
+			goos:   "darwin",
+			want:   "ulimit -u 64 || exit 1\nulimit -n 256 || exit 1\n",
+		},
+		{
+			name:   "processes are limited by the cgroup on linux",
+			limits: config.ResourceLimits{MaxProcesses: 64, MaxOpenFiles: 256},
+			goos:   "linux",
+			want:   "ulimit -n 256 || exit 1\n",
+		},
+		{
+			name:   "memory on linux",
+			limits: config.ResourceLimits{MaxMemoryMB: 512},
+			goos:   "linux",
+			want:   "ulimit -d 524288 || exit 1\n",
+		},
+		{
+			name:   "memory not enforceable on darwin",
+			limits: config.ResourceLimits{MaxMemoryMB: 512},
+			goos:   "darwin",
+			want:   "",
+		},
+		{
+			name:   "cpu and wall time are enforced elsewhere",
+			limits: config.ResourceLimits{MaxCPUPercent: 50, MaxWallSeconds: 10},
+			goos:   "linux",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resourceLimitCommands(tt.limits, tt.goos); got != tt.want {
+				t.Errorf("resourceLimitCommands() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestResourceLimitCommandsRun runs the generated lines in every shell the
+// sandbox may use, since a flag one shell rejects would stop the command.
+func TestResourceLimitCommandsRun(t *testing.T) {
+	limits := config.ResourceLimits{MaxProcesses: 256, MaxOpenFiles: 256, MaxMemoryMB: 4096}
+	for _, shell := range []string{"sh", "dash", "bash", "zsh"} {
+		path, err := exec.LookPath(shell)
+		if err != nil {
+			continue
+		}
+		t.Run(shell, func(t *testing.T) {
+			script := resourceLimitCommands(limits, runtime.GOOS) + "ulimit -n"
+			out, err := exec.Command(path, "-c", script).CombinedOutput() //nolint:gosec // test shells
+			if err != nil {
+				t.Fatalf("%s -c %q failed: %v\n%s", shell, script, err, out)
+			}
+			if got := strings.TrimSpace(string(out)); got != "256" {
+				t.Errorf("ulimit -n = %q, want 256", got)
+			}
+		})
+	}
+}
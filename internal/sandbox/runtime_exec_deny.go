@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
 
@@ -18,6 +19,14 @@ var commonExecutableDirs = []string{
 	"/opt/local/bin",
 }
 
+// RuntimeDeniedExecutable is an executable path blocked at exec-time along
+// with the deny rule that produced it.
+type RuntimeDeniedExecutable struct {
+	Path   string
+	Rule   string
+	Source PolicySource // SourceExplicit, SourceDenyExecute or SourceDefault
+}
+
 // GetRuntimeDeniedExecutablePaths returns absolute executable paths that should
 // be blocked at exec-time for this config.
 //
@@ -25,21 +34,81 @@ var commonExecutableDirs = []string{
 // - Only deny entries that are a single executable token are included.
 // - Prefix rules with arguments (e.g. "git push", "dd if=") remain preflight-only.
 func GetRuntimeDeniedExecutablePaths(cfg *config.Config) []string {
+	denied := GetRuntimeDeniedExecutables(cfg)
+	if len(denied) == 0 {
+		return nil
+	}
+
+	paths := make([]string, 0, len(denied))
+	for _, d := range denied {
+		paths = append(paths, d.Path)
+	}
+	return paths
+}
+
+// GetEffectiveDenyWritePaths returns filesystem.denyWrite plus, unless
+// filesystem.protectDeniedExecutables is false, the runtime-denied executable
+// paths. Without this a command could overwrite a blocked binary such as
+// /usr/local/bin/curl and run the replacement under an allowed name.
+func GetEffectiveDenyWritePaths(cfg *config.Config) []string {
 	if cfg == nil {
 		return nil
 	}
+	paths := slices.Clone(cfg.Filesystem.DenyWrite)
+	if !cfg.Filesystem.ProtectsDeniedExecutables() {
+		return paths
+	}
+	for _, p := range GetRuntimeDeniedExecutablePaths(cfg) {
+		if !slices.Contains(paths, p) {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
 
-	var denyRules []string
-	denyRules = append(denyRules, cfg.Command.Deny...)
-	if cfg.Command.UseDefaultDeniedCommands() {
-		denyRules = append(denyRules, config.DefaultDeniedCommands...)
+// GetRuntimeDeniedExecutables is like GetRuntimeDeniedExecutablePaths but
+// reports which rule, and which rule list, each path came from. When a path
+// is denied by several lists, command.deny is reported first, then
+// filesystem.denyExecute, then the defaults.
+//
+// filesystem.denyExecute entries are executable names or paths and apply
+// even in shadow mode, which only affects command rules.
+func GetRuntimeDeniedExecutables(cfg *config.Config) []RuntimeDeniedExecutable {
+	if cfg == nil {
+		return nil
 	}
 
-	var paths []string
+	type denyRule struct {
+		rule   string
+		source PolicySource
+	}
+	var denyRules []denyRule
+	if !cfg.Command.ShadowMode {
+		// In shadow mode denied commands must still run
+		for _, deny := range cfg.Command.Deny {
+			denyRules = append(denyRules, denyRule{rule: deny, source: SourceExplicit})
+		}
+	}
+	for _, entry := range cfg.Filesystem.DenyExecute {
+		denyRules = append(denyRules, denyRule{rule: entry, source: SourceDenyExecute})
+	}
+	if !cfg.Command.ShadowMode && cfg.Command.UseDefaultDeniedCommands() {
+		for _, deny := range config.DefaultDeniedCommands {
+			if slices.Contains(allowLiftedDefaults, deny) && allowsExecutable(cfg, deny) {
+				continue
+			}
+			denyRules = append(denyRules, denyRule{rule: deny, source: SourceDefault})
+		}
+	}
+
+	var denied []RuntimeDeniedExecutable
 	seen := make(map[string]bool)
 
-	for _, rule := range denyRules {
-		token, ok := runtimeExecutableToken(rule)
+	for _, r := range denyRules {
+		token, ok := r.rule, true
+		if r.source != SourceDenyExecute {
+			token, ok = runtimeExecutableToken(commandRulePrefix(r.rule))
+		}
 		if !ok {
 			continue
 		}
@@ -49,12 +118,56 @@ func GetRuntimeDeniedExecutablePaths(cfg *config.Config) []string {
 				continue
 			}
 			seen[resolved] = true
-			paths = append(paths, resolved)
+			denied = append(denied, RuntimeDeniedExecutable{Path: resolved, Rule: r.rule, Source: r.source})
 		}
 	}
 
+	slices.SortFunc(denied, func(a, b RuntimeDeniedExecutable) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return denied
+}
+
+// userSwitchExecutables are setuid programs that switch users. bwrap sets
+// no_new_privs, so they cannot gain privileges inside the sandbox anyway;
+// masking them means "sudo" fails immediately instead of prompting.
+var userSwitchExecutables = []string{"sudo", "su"}
+
+// GetUserSwitchExecutablePaths returns the resolved sudo and su paths to mask
+// inside the Linux sandbox, independently of command.deny. A command.allow
+// rule for the bare executable opts out.
+func GetUserSwitchExecutablePaths(cfg *config.Config) []string {
+	var paths []string
+	for _, name := range userSwitchExecutables {
+		if cfg != nil && allowsExecutable(cfg, name) {
+			continue
+		}
+		paths = append(paths, resolveExecutablePaths(name)...)
+	}
 	slices.Sort(paths)
-	return paths
+	return slices.Compact(paths)
+}
+
+// allowLiftedDefaults are the default-denied executables that an allow
+// rule for the bare executable also removes from the runtime deny list, as
+// it does for the preflight check. base64 is an everyday tool that some
+// workflows need; the other defaults stay blocked at exec time even when
+// allowed, so a nested shell cannot reach them.
+var allowLiftedDefaults = []string{"base64"}
+
+// allowsExecutable reports whether a command.allow rule is exactly the
+// executable named by the single-token rule.
+func allowsExecutable(cfg *config.Config, rule string) bool {
+	token, ok := runtimeExecutableToken(rule)
+	if !ok {
+		return false
+	}
+	for _, allow := range cfg.Command.Allow {
+		if parsed, err := config.ParseCommandRule(allow); err == nil && parsed.Prefix == token {
+			return true
+		}
+	}
+	return false
 }
 
 func runtimeExecutableToken(rule string) (string, bool) {
@@ -126,16 +239,42 @@ func resolveExecutablePaths(token string) []string {
 		addCanonicalPath(resolved)
 	}
 
+	// Versioned aliases such as python3.12 would otherwise run the same
+	// interpreter under a name the rule does not cover.
+	versioned := versionedExecutablePattern(token)
 	for _, dir := range commonExecutableDirs {
 		candidate := filepath.Join(dir, token)
 		if executablePathExists(candidate) {
 			addCanonicalPath(candidate)
 		}
+		for _, name := range readDirNames(dir) {
+			if versioned.MatchString(name) && executablePathExists(filepath.Join(dir, name)) {
+				addCanonicalPath(filepath.Join(dir, name))
+			}
+		}
 	}
 
 	return paths
 }
 
+// versionedExecutablePattern matches token followed by a version suffix:
+// "python" matches python3 and python3.12, "python3" matches python3.12.
+func versionedExecutablePattern(token string) *regexp.Regexp {
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(token) + `\.?\d+(?:\.\d+)*$`)
+}
+
+// readDirNames returns the names in dir, unsorted, or nil if it cannot be
+// read.
+func readDirNames(dir string) []string {
+	f, err := os.Open(dir) //nolint:gosec // fixed list of executable directories
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+	names, _ := f.Readdirnames(-1)
+	return names
+}
+
 func executablePathExists(path string) bool {
 	if path == "" {
 		return false
@@ -74,3 +74,248 @@ func TestResolveExecutablePaths_CanonicalizesSymlinkAliases(t *testing.T) {
 		}
 	}
 }
+
+func TestResolveExecutablePaths_VersionedAliases(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"python3", "python3.11", "python3.12", "python3-config", "python3.12-config", "python31x"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o700); err != nil { //nolint:gosec // test binary
+			t.Fatal(err)
+		}
+	}
+	orig := commonExecutableDirs
+	commonExecutableDirs = []string{dir}
+	t.Cleanup(func() { commonExecutableDirs = orig })
+
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := resolveExecutablePaths("python3")
+	for _, want := range []string{"python3", "python3.11", "python3.12"} {
+		if !slices.Contains(paths, filepath.Join(dir, want)) {
+			t.Errorf("resolveExecutablePaths(python3) missing %s, got %v", want, paths)
+		}
+	}
+	for _, notWant := range []string{"python3-config", "python3.12-config", "python31x"} {
+		if slices.Contains(paths, filepath.Join(dir, notWant)) {
+			t.Errorf("resolveExecutablePaths(python3) should not include %s", notWant)
+		}
+	}
+}
+
+func TestVersionedExecutablePattern(t *testing.T) {
+	tests := []struct {
+		token string
+		name  string
+		want  bool
+	}{
+		{"python", "python3", true},
+		{"python", "python3.12", true},
+		{"python3", "python3.12", true},
+		{"python3", "python3.12.1", true},
+		{"gpg", "gpg2", true},
+		{"python3", "python3", false},
+		{"python3", "python3-config", false},
+		{"python3", "python3.", false},
+		{"node", "nodejs", false},
+		{"c++", "c++14", true},
+	}
+	for _, tt := range tests {
+		if got := versionedExecutablePattern(tt.token).MatchString(tt.name); got != tt.want {
+			t.Errorf("versionedExecutablePattern(%q).MatchString(%q) = %v, want %v", tt.token, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetRuntimeDeniedExecutablePaths_ShadowMode(t *testing.T) {
+	cfg := &config.Config{
+		Command: config.CommandConfig{
+			Deny:       []string{"python3", "true"},
+			ShadowMode: true,
+		},
+	}
+
+	if got := GetRuntimeDeniedExecutablePaths(cfg); len(got) != 0 {
+		t.Fatalf("expected no runtime-denied paths in shadow mode, got %v", got)
+	}
+}
+
+func TestGetRuntimeDeniedExecutables_Source(t *testing.T) {
+	tool := filepath.Join(t.TempDir(), "mytool")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Command: config.CommandConfig{
+			Deny: []string{tool + " priority:3"},
+		},
+	}
+
+	got := GetRuntimeDeniedExecutables(cfg)
+
+	var foundExplicit bool
+	for _, d := range got {
+		switch {
+		case d.Rule == tool+" priority:3":
+			foundExplicit = true
+			if d.Source != SourceExplicit {
+				t.Errorf("%s: Source = %q, want %q", d.Path, d.Source, SourceExplicit)
+			}
+		case slices.Contains(config.DefaultDeniedCommands, d.Rule):
+			if d.Source != SourceDefault {
+				t.Errorf("%s (rule %q): Source = %q, want %q", d.Path, d.Rule, d.Source, SourceDefault)
+			}
+		default:
+			t.Errorf("unexpected entry %+v", d)
+		}
+	}
+	if !foundExplicit {
+		t.Fatalf("expected explicit deny for %s in %+v", tool, got)
+	}
+
+	paths := GetRuntimeDeniedExecutablePaths(cfg)
+	if len(paths) != len(got) {
+		t.Fatalf("GetRuntimeDeniedExecutablePaths() returned %d paths, want %d", len(paths), len(got))
+	}
+	for i, d := range got {
+		if paths[i] != d.Path {
+			t.Errorf("paths[%d] = %q, want %q", i, paths[i], d.Path)
+		}
+	}
+}
+
+func TestGetRuntimeDeniedExecutables_DenyExecute(t *testing.T) {
+	dir := t.TempDir()
+	tool := filepath.Join(dir, "nc")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\n"), 0o755); err != nil { //nolint:gosec // test executable
+		t.Fatal(err)
+	}
+	resolved, err := filepath.EvalSymlinks(tool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findTool := func(cfg *config.Config) (RuntimeDeniedExecutable, bool) {
+		for _, d := range GetRuntimeDeniedExecutables(cfg) {
+			if d.Path == resolved {
+				return d, true
+			}
+		}
+		return RuntimeDeniedExecutable{}, false
+	}
+
+	cfg := &config.Config{
+		Filesystem: config.FilesystemConfig{DenyExecute: []string{tool, filepath.Join(dir, "missing")}},
+	}
+	d, ok := findTool(cfg)
+	if !ok {
+		t.Fatalf("expected %s in runtime deny list, got %v", resolved, GetRuntimeDeniedExecutablePaths(cfg))
+	}
+	if d.Source != SourceDenyExecute || d.Rule != tool {
+		t.Errorf("got %+v, want Source %q and Rule %q", d, SourceDenyExecute, tool)
+	}
+
+	// Shadow mode only relaxes command rules
+	cfg.Command.ShadowMode = true
+	if _, ok := findTool(cfg); !ok {
+		t.Error("denyExecute should still apply in shadow mode")
+	}
+
+	// command.deny is reported first when both lists block the path
+	cfg = &config.Config{
+		Command:    config.CommandConfig{Deny: []string{tool}},
+		Filesystem: config.FilesystemConfig{DenyExecute: []string{tool}},
+	}
+	if d, _ := findTool(cfg); d.Source != SourceExplicit {
+		t.Errorf("Source = %q, want %q", d.Source, SourceExplicit)
+	}
+}
+
+func TestGetRuntimeDeniedExecutables_AllowOverridesDefault(t *testing.T) {
+	if len(resolveExecutablePaths("base64")) == 0 {
+		t.Skip("base64 not installed")
+	}
+
+	hasBase64 := func(cfg *config.Config) bool {
+		for _, d := range GetRuntimeDeniedExecutables(cfg) {
+			if d.Rule == "base64" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !hasBase64(&config.Config{}) {
+		t.Error("base64 should be runtime-denied by default")
+	}
+	if hasBase64(&config.Config{Command: config.CommandConfig{Allow: []string{"base64"}}}) {
+		t.Error("allow rule for base64 should lift the default runtime deny")
+	}
+	if !hasBase64(&config.Config{Command: config.CommandConfig{Allow: []string{"base64 --wrap=0"}}}) {
+		t.Error("allow rule with arguments should not lift the runtime deny")
+	}
+}
+
+func TestGetRuntimeDeniedExecutables_AllowKeepsOtherDefaults(t *testing.T) {
+	if len(resolveExecutablePaths("unshare")) == 0 {
+		t.Skip("unshare not installed")
+	}
+
+	cfg := &config.Config{Command: config.CommandConfig{Allow: []string{"unshare"}}}
+	for _, d := range GetRuntimeDeniedExecutables(cfg) {
+		if d.Rule == "unshare" {
+			return
+		}
+	}
+	t.Error("allow rule should not lift the runtime deny of defaults other than base64")
+}
+
+func TestGetUserSwitchExecutablePaths(t *testing.T) {
+	suPaths := resolveExecutablePaths("su")
+	if len(suPaths) == 0 {
+		t.Skip("su not installed")
+	}
+
+	got := GetUserSwitchExecutablePaths(&config.Config{})
+	for _, p := range suPaths {
+		if !slices.Contains(got, p) {
+			t.Errorf("GetUserSwitchExecutablePaths() = %v, want it to include %s", got, p)
+		}
+	}
+
+	got = GetUserSwitchExecutablePaths(&config.Config{Command: config.CommandConfig{Allow: []string{"su"}}})
+	for _, p := range suPaths {
+		if slices.Contains(got, p) {
+			t.Errorf("allow rule for su should unmask %s, got %v", p, got)
+		}
+	}
+}
+
+func TestGetEffectiveDenyWritePaths(t *testing.T) {
+	curlPaths := resolveExecutablePaths("curl")
+	if len(curlPaths) == 0 {
+		t.Skip("curl not installed")
+	}
+
+	cfg := &config.Config{
+		Filesystem: config.FilesystemConfig{DenyWrite: []string{"/etc/hosts"}},
+		Command:    config.CommandConfig{Deny: []string{"curl"}},
+	}
+	got := GetEffectiveDenyWritePaths(cfg)
+	if !slices.Contains(got, "/etc/hosts") {
+		t.Errorf("GetEffectiveDenyWritePaths() = %v, want configured denyWrite kept", got)
+	}
+	for _, p := range curlPaths {
+		if !slices.Contains(got, p) {
+			t.Errorf("GetEffectiveDenyWritePaths() = %v, want denied executable %s", got, p)
+		}
+	}
+
+	disabled := false
+	cfg.Filesystem.ProtectDeniedExecutables = &disabled
+	got = GetEffectiveDenyWritePaths(cfg)
+	if want := []string{"/etc/hosts"}; !slices.Equal(got, want) {
+		t.Errorf("with protectDeniedExecutables=false, GetEffectiveDenyWritePaths() = %v, want %v", got, want)
+	}
+}
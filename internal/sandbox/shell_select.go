@@ -13,22 +13,67 @@ const (
 	ShellModeDefault = "default"
 	// ShellModeUser uses the caller's SHELL env var with validation.
 	ShellModeUser = "user"
+	// ShellModeCustom uses a configured absolute path with validation.
+	ShellModeCustom = "custom"
 )
 
 var allowedUserShells = map[string]bool{
-	"sh":   true,
-	"bash": true,
-	"zsh":  true,
-	"ksh":  true,
-	"dash": true,
-	"fish": true,
+	"sh":     true,
+	"bash":   true,
+	"zsh":    true,
+	"ksh":    true,
+	"dash":   true,
+	"fish":   true,
+	"nu":     true,
+	"elvish": true,
+	"tcsh":   true,
+	"csh":    true,
+}
+
+// nonPOSIXShells are allowed shells that cannot run sh scripts, such as the
+// setup script of the Linux sandbox.
+var nonPOSIXShells = map[string]bool{
+	"fish":   true,
+	"nu":     true,
+	"elvish": true,
+	"tcsh":   true,
+	"csh":    true,
+}
+
+// shellCommandFlag returns the flag that makes shellName run a command
+// string, as a login shell if login is set.
+func shellCommandFlag(shellName string, login bool) (string, error) {
+	switch shellName {
+	case "nu":
+		if login {
+			// nu needs separate --login and --commands flags
+			return "", fmt.Errorf("shell %q does not support login mode", shellName)
+		}
+		return "--commands", nil
+	case "elvish", "tcsh", "csh":
+		// elvish has no login flag; csh only honors -l as its sole argument
+		if login {
+			return "", fmt.Errorf("shell %q does not support login mode", shellName)
+		}
+		return "-c", nil
+	}
+	if login {
+		return "-lc", nil
+	}
+	return "-c", nil
+}
+
+// isPOSIXShell reports whether the shell at path runs sh scripts.
+func isPOSIXShell(path string) bool {
+	return !nonPOSIXShells[filepath.Base(path)]
 }
 
 // ResolveExecutionShell returns the shell executable path and invocation flag.
 // Supported modes:
 //   - default: deterministic bash
 //   - user: validated absolute path from $SHELL
-func ResolveExecutionShell(mode string, login bool) (string, string, error) {
+//   - custom: validated absolute path from customPath (shell.path)
+func ResolveExecutionShell(mode, customPath string, login bool) (string, string, error) {
 	if mode == "" {
 		mode = ShellModeDefault
 	}
@@ -46,29 +91,46 @@ func ResolveExecutionShell(mode string, login bool) (string, string, error) {
 		if envShell == "" {
 			return "", "", fmt.Errorf("shell mode %q requires $SHELL to be set", ShellModeUser)
 		}
-		if !filepath.IsAbs(envShell) {
-			return "", "", fmt.Errorf("shell mode %q requires absolute $SHELL path, got %q", ShellModeUser, envShell)
+		if err := validateShellPath(envShell, "$SHELL"); err != nil {
+			return "", "", err
 		}
-		shellName := filepath.Base(envShell)
-		if !allowedUserShells[shellName] {
-			return "", "", fmt.Errorf("shell %q from $SHELL is not allowed", shellName)
-		}
-		info, err := os.Stat(envShell)
-		if err != nil {
-			return "", "", fmt.Errorf("shell from $SHELL not found: %w", err)
+		shellPath = envShell
+	case ShellModeCustom:
+		if customPath == "" {
+			return "", "", fmt.Errorf("shell mode %q requires shell.path to be set", ShellModeCustom)
 		}
-		if info.IsDir() || info.Mode()&0o111 == 0 {
-			return "", "", fmt.Errorf("shell from $SHELL is not executable: %q", envShell)
+		if err := validateShellPath(customPath, "shell.path"); err != nil {
+			return "", "", err
 		}
-		shellPath = envShell
+		shellPath = customPath
 	default:
-		return "", "", fmt.Errorf("invalid shell mode %q (expected %q or %q)", mode, ShellModeDefault, ShellModeUser)
+		return "", "", fmt.Errorf("invalid shell mode %q (expected %q, %q or %q)", mode, ShellModeDefault, ShellModeUser, ShellModeCustom)
 	}
 
-	shellFlag := "-c"
-	if login {
-		shellFlag = "-lc"
+	shellFlag, err := shellCommandFlag(filepath.Base(shellPath), login)
+	if err != nil {
+		return "", "", err
 	}
 
 	return shellPath, shellFlag, nil
 }
+
+// validateShellPath checks that path, taken from source, is an absolute path
+// to an executable with the name of an allowed shell.
+func validateShellPath(path, source string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("shell from %s must be an absolute path, got %q", source, path)
+	}
+	shellName := filepath.Base(path)
+	if !allowedUserShells[shellName] {
+		return fmt.Errorf("shell %q from %s is not allowed", shellName, source)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("shell from %s not found: %w", source, err)
+	}
+	if info.IsDir() || info.Mode()&0o111 == 0 {
+		return fmt.Errorf("shell from %s is not executable: %q", source, path)
+	}
+	return nil
+}
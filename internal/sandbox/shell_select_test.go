@@ -1,13 +1,14 @@
 package sandbox
 
 import (
+	"os"
 	"os/exec"
 	"path/filepath"
 	"testing"
 )
 
 func TestResolveExecutionShell_Default(t *testing.T) {
-	path, flag, err := ResolveExecutionShell(ShellModeDefault, false)
+	path, flag, err := ResolveExecutionShell(ShellModeDefault, "", false)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -20,7 +21,7 @@ func TestResolveExecutionShell_Default(t *testing.T) {
 }
 
 func TestResolveExecutionShell_DefaultLogin(t *testing.T) {
-	_, flag, err := ResolveExecutionShell(ShellModeDefault, true)
+	_, flag, err := ResolveExecutionShell(ShellModeDefault, "", true)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -36,7 +37,7 @@ func TestResolveExecutionShell_User(t *testing.T) {
 	}
 	t.Setenv("SHELL", bashPath)
 
-	path, flag, err := ResolveExecutionShell(ShellModeUser, false)
+	path, flag, err := ResolveExecutionShell(ShellModeUser, "", false)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -50,13 +51,102 @@ func TestResolveExecutionShell_User(t *testing.T) {
 
 func TestResolveExecutionShell_UserRequiresAbsoluteShell(t *testing.T) {
 	t.Setenv("SHELL", "bash")
-	if _, _, err := ResolveExecutionShell(ShellModeUser, false); err == nil {
+	if _, _, err := ResolveExecutionShell(ShellModeUser, "", false); err == nil {
 		t.Fatal("expected error for non-absolute $SHELL")
 	}
 }
 
 func TestResolveExecutionShell_RejectsUnsupportedMode(t *testing.T) {
-	if _, _, err := ResolveExecutionShell("custom", false); err == nil {
+	if _, _, err := ResolveExecutionShell("other", "", false); err == nil {
 		t.Fatal("expected error for unsupported shell mode")
 	}
 }
+
+func TestResolveExecutionShell_UserNonPOSIXShells(t *testing.T) {
+	tests := []struct {
+		shell     string
+		login     bool
+		wantFlag  string
+		wantError bool
+	}{
+		{"nu", false, "--commands", false},
+		{"nu", true, "", true},
+		{"elvish", false, "-c", false},
+		{"elvish", true, "", true},
+		{"tcsh", false, "-c", false},
+		{"csh", false, "-c", false},
+		{"fish", true, "-lc", false},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		shellPath := filepath.Join(dir, tt.shell)
+		if err := os.WriteFile(shellPath, []byte("#!/bin/sh\n"), 0o700); err != nil { //nolint:gosec // fake shell
+			t.Fatal(err)
+		}
+		t.Setenv("SHELL", shellPath)
+
+		path, flag, err := ResolveExecutionShell(ShellModeUser, "", tt.login)
+		if tt.wantError {
+			if err == nil {
+				t.Errorf("ResolveExecutionShell(%s, login=%v) expected error", tt.shell, tt.login)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ResolveExecutionShell(%s, login=%v) error: %v", tt.shell, tt.login, err)
+			continue
+		}
+		if path != shellPath || flag != tt.wantFlag {
+			t.Errorf("ResolveExecutionShell(%s, login=%v) = (%q, %q), want (%q, %q)", tt.shell, tt.login, path, flag, shellPath, tt.wantFlag)
+		}
+	}
+}
+
+func TestIsPOSIXShell(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/bin/bash":           true,
+		"/bin/sh":             true,
+		"/usr/bin/zsh":        true,
+		"/usr/bin/nu":         false,
+		"/usr/local/bin/fish": false,
+		"/bin/tcsh":           false,
+	} {
+		if got := isPOSIXShell(path); got != want {
+			t.Errorf("isPOSIXShell(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestResolveExecutionShell_Custom(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "opt", "homebrew", "bin")
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"bash", "evilsh"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o700); err != nil { //nolint:gosec // fake shell
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("SHELL", "")
+
+	bashPath := filepath.Join(dir, "bash")
+	path, flag, err := ResolveExecutionShell(ShellModeCustom, bashPath, true)
+	if err != nil {
+		t.Fatalf("expected custom bash to be accepted, got %v", err)
+	}
+	if path != bashPath || flag != "-lc" {
+		t.Fatalf("ResolveExecutionShell(custom) = (%q, %q), want (%q, %q)", path, flag, bashPath, "-lc")
+	}
+
+	for _, bad := range []string{
+		"",                                 // unset
+		"bash",                             // not absolute
+		filepath.Join(dir, "evilsh"),       // unknown shell name
+		filepath.Join(t.TempDir(), "bash"), // does not exist
+	} {
+		if _, _, err := ResolveExecutionShell(ShellModeCustom, bad, false); err == nil {
+			t.Errorf("ResolveExecutionShell(custom, %q) expected error", bad)
+		}
+	}
+}
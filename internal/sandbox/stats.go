@@ -0,0 +1,82 @@
+package sandbox
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats summarizes the sandbox decisions made during a run.
+type Stats struct {
+	NetworkRequestsAllowed  int           `json:"networkRequestsAllowed"`
+	NetworkRequestsBlocked  int           `json:"networkRequestsBlocked"`
+	FilesystemWritesAllowed int           `json:"filesystemWritesAllowed"`
+	FilesystemWritesBlocked int           `json:"filesystemWritesBlocked"`
+	CommandExecsAllowed     int           `json:"commandExecsAllowed"`
+	CommandExecsBlocked     int           `json:"commandExecsBlocked"`
+	ViolationCount          int           `json:"violationCount"`
+	Duration                time.Duration `json:"durationNs"`
+}
+
+// StatsCollector is an AuditLogger that counts events. It is safe for
+// concurrent use.
+type StatsCollector struct {
+	start                   time.Time
+	networkRequestsAllowed  atomic.Int64
+	networkRequestsBlocked  atomic.Int64
+	filesystemWritesAllowed atomic.Int64
+	filesystemWritesBlocked atomic.Int64
+	commandExecsAllowed     atomic.Int64
+	commandExecsBlocked     atomic.Int64
+	violations              atomic.Int64
+}
+
+// NewStatsCollector creates a collector. The run duration is measured from
+// this call.
+func NewStatsCollector() *StatsCollector {
+	return &StatsCollector{start: time.Now()}
+}
+
+// LogNetworkRequest implements AuditLogger.
+func (c *StatsCollector) LogNetworkRequest(_, _ string, allowed bool) {
+	count(allowed, &c.networkRequestsAllowed, &c.networkRequestsBlocked)
+}
+
+// LogFilesystemAccess implements AuditLogger. Only writes are counted.
+func (c *StatsCollector) LogFilesystemAccess(_, op string, allowed bool) {
+	if op == "write" {
+		count(allowed, &c.filesystemWritesAllowed, &c.filesystemWritesBlocked)
+	}
+}
+
+// LogCommandExec implements AuditLogger.
+func (c *StatsCollector) LogCommandExec(_ string, allowed bool) {
+	count(allowed, &c.commandExecsAllowed, &c.commandExecsBlocked)
+}
+
+// LogViolation implements AuditLogger.
+func (c *StatsCollector) LogViolation(_, _ string) {
+	c.violations.Add(1)
+}
+
+func count(allowed bool, allowedCounter, blockedCounter *atomic.Int64) {
+	if allowed {
+		allowedCounter.Add(1)
+	} else {
+		blockedCounter.Add(1)
+	}
+}
+
+// Stats returns the counts so far and the time since the collector was
+// created.
+func (c *StatsCollector) Stats() Stats {
+	return Stats{
+		NetworkRequestsAllowed:  int(c.networkRequestsAllowed.Load()),
+		NetworkRequestsBlocked:  int(c.networkRequestsBlocked.Load()),
+		FilesystemWritesAllowed: int(c.filesystemWritesAllowed.Load()),
+		FilesystemWritesBlocked: int(c.filesystemWritesBlocked.Load()),
+		CommandExecsAllowed:     int(c.commandExecsAllowed.Load()),
+		CommandExecsBlocked:     int(c.commandExecsBlocked.Load()),
+		ViolationCount:          int(c.violations.Load()),
+		Duration:                time.Since(c.start),
+	}
+}
@@ -0,0 +1,69 @@
+package sandbox
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestStatsCollector(t *testing.T) {
+	c := NewStatsCollector()
+	c.LogNetworkRequest("example.com", "443", true)
+	c.LogNetworkRequest("example.com", "443", true)
+	c.LogNetworkRequest("evil.example", "443", false)
+	c.LogFilesystemAccess("/tmp/out", "write", true)
+	c.LogFilesystemAccess("/etc/hosts", "write", false)
+	c.LogFilesystemAccess("/etc/shadow", "read", false) // Reads are not counted
+	c.LogCommandExec("ls", true)
+	c.LogCommandExec("git push", false)
+	c.LogViolation("git push", "blocked")
+
+	got := c.Stats()
+	if got.Duration <= 0 {
+		t.Errorf("Duration = %v, want > 0", got.Duration)
+	}
+	got.Duration = 0
+	want := Stats{
+		NetworkRequestsAllowed:  2,
+		NetworkRequestsBlocked:  1,
+		FilesystemWritesAllowed: 1,
+		FilesystemWritesBlocked: 1,
+		CommandExecsAllowed:     1,
+		CommandExecsBlocked:     1,
+		ViolationCount:          1,
+	}
+	if got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestStatsCollectorConcurrent(t *testing.T) {
+	c := NewStatsCollector()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.LogNetworkRequest("example.com", "443", false)
+			c.LogViolation("rule", "detail")
+		}()
+	}
+	wg.Wait()
+
+	got := c.Stats()
+	if got.NetworkRequestsBlocked != 50 || got.ViolationCount != 50 {
+		t.Errorf("Stats() = %+v, want 50 blocked requests and 50 violations", got)
+	}
+}
+
+func TestCombineAuditLoggers(t *testing.T) {
+	a, b := NewStatsCollector(), NewStatsCollector()
+	if combineAuditLoggers(nil, b) != AuditLogger(b) || combineAuditLoggers(a, nil) != AuditLogger(a) {
+		t.Error("combining with nil should return the other logger")
+	}
+
+	combined := combineAuditLoggers(a, b)
+	combined.LogCommandExec("ls", true)
+	if a.Stats().CommandExecsAllowed != 1 || b.Stats().CommandExecsAllowed != 1 {
+		t.Error("combined logger should send events to both loggers")
+	}
+}
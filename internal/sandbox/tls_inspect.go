@@ -0,0 +1,59 @@
+package sandbox
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// systemCABundlePaths are the trust stores checked, in order, when building
+// the CA bundle handed to sandboxed processes for TLS inspection.
+var systemCABundlePaths = []string{
+	"/etc/ssl/certs/ca-certificates.crt", // Debian/Ubuntu/Alpine
+	"/etc/pki/tls/certs/ca-bundle.crt",   // Fedora/RHEL
+	"/etc/ssl/ca-bundle.pem",             // openSUSE
+	"/etc/ssl/cert.pem",                  // macOS
+}
+
+// caBundleFileName is the name of the generated bundle inside the sandbox TMPDIR.
+const caBundleFileName = "fence-ca-bundle.pem"
+
+// WriteTLSInspectionCABundle writes the system trust store followed by the
+// inspection CA certificate to dir, so sandboxed tools trust both real
+// servers and the certificates minted by the inspecting proxy.
+func WriteTLSInspectionCABundle(caCertPath, dir string) (string, error) {
+	caPEM, err := os.ReadFile(caCertPath) //nolint:gosec // user-configured CA path
+	if err != nil {
+		return "", fmt.Errorf("failed to read TLS inspection CA: %w", err)
+	}
+
+	var bundle bytes.Buffer
+	for _, path := range systemCABundlePaths {
+		if data, err := os.ReadFile(path); err == nil { //nolint:gosec // fixed system paths
+			bundle.Write(data)
+			if !bytes.HasSuffix(data, []byte("\n")) {
+				bundle.WriteByte('\n')
+			}
+			break
+		}
+	}
+	bundle.Write(caPEM)
+
+	bundlePath := filepath.Join(dir, caBundleFileName)
+	if err := os.WriteFile(bundlePath, bundle.Bytes(), 0o644); err != nil { //nolint:gosec // certificates are public
+		return "", fmt.Errorf("failed to write CA bundle: %w", err)
+	}
+	return bundlePath, nil
+}
+
+// TLSInspectionEnvVars points common TLS stacks at the CA bundle.
+func TLSInspectionEnvVars(bundlePath string) []string {
+	return []string{
+		"SSL_CERT_FILE=" + bundlePath,
+		"NODE_EXTRA_CA_CERTS=" + bundlePath,
+		"REQUESTS_CA_BUNDLE=" + bundlePath,
+		"CURL_CA_BUNDLE=" + bundlePath,
+		"GIT_SSL_CAINFO=" + bundlePath,
+	}
+}
@@ -0,0 +1,70 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Use-Tusk/fence/internal/config"
+)
+
+func TestWriteTLSInspectionCABundle(t *testing.T) {
+	dir := t.TempDir()
+	caPEM := "-----BEGIN CERTIFICATE-----\nZmVuY2U=\n-----END CERTIFICATE-----\n"
+	caPath := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caPath, []byte(caPEM), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	bundlePath, err := WriteTLSInspectionCABundle(caPath, dir)
+	if err != nil {
+		t.Fatalf("WriteTLSInspectionCABundle() error = %v", err)
+	}
+	data, err := os.ReadFile(bundlePath) //nolint:gosec // test file
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(data), caPEM) {
+		t.Errorf("bundle should end with the inspection CA, got %q", data)
+	}
+
+	if _, err := WriteTLSInspectionCABundle(filepath.Join(dir, "missing.pem"), dir); err == nil {
+		t.Error("WriteTLSInspectionCABundle() with a missing CA should fail")
+	}
+}
+
+func TestTLSInspectionEnvVars(t *testing.T) {
+	vars := TLSInspectionEnvVars("/tmp/fence/bundle.pem")
+	for _, want := range []string{"SSL_CERT_FILE=/tmp/fence/bundle.pem", "NODE_EXTRA_CA_CERTS=/tmp/fence/bundle.pem"} {
+		found := false
+		for _, v := range vars {
+			if v == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("TLSInspectionEnvVars() missing %q", want)
+		}
+	}
+}
+
+func TestEffectiveDenyReadIncludesCAKey(t *testing.T) {
+	cfg := config.Default()
+	cfg.Filesystem.DenyRead = []string{"/secrets"}
+	cfg.Network.InspectTLSCAKey = "/etc/fence/ca-key.pem"
+
+	if got := effectiveDenyRead(cfg); slices.Contains(got, "/etc/fence/ca-key.pem") {
+		t.Errorf("effectiveDenyRead() = %v, want no CA key without inspectTLS", got)
+	}
+
+	cfg.Network.InspectTLS = true
+	got := effectiveDenyRead(cfg)
+	if !slices.Contains(got, "/secrets") || !slices.Contains(got, "/etc/fence/ca-key.pem") {
+		t.Errorf("effectiveDenyRead() = %v, want denyRead and the CA key", got)
+	}
+	if len(cfg.Filesystem.DenyRead) != 1 {
+		t.Errorf("effectiveDenyRead modified filesystem.denyRead: %v", cfg.Filesystem.DenyRead)
+	}
+}
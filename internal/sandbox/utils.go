@@ -4,6 +4,8 @@ import (
 	"encoding/base64"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -23,16 +25,76 @@ func RemoveTrailingGlobSuffix(pattern string) string {
 	return strings.TrimSuffix(pattern, "/**")
 }
 
+// pathVariablePattern matches the environment variables NormalizePath
+// expands, written as $NAME or ${NAME}.
+var pathVariablePattern = regexp.MustCompile(`\$(?:\{(HOME|XDG_CONFIG_HOME|XDG_CACHE_HOME|XDG_DATA_HOME|XDG_STATE_HOME|XDG_RUNTIME_DIR)\}|(HOME|XDG_CONFIG_HOME|XDG_CACHE_HOME|XDG_DATA_HOME|XDG_STATE_HOME|XDG_RUNTIME_DIR)\b)`)
+
+// xdgDefaults are the home-relative directories the XDG Base Directory
+// Specification prescribes when a variable is unset or empty.
+var xdgDefaults = map[string]string{
+	"XDG_CONFIG_HOME": ".config",
+	"XDG_DATA_HOME":   ".local/share",
+	"XDG_CACHE_HOME":  ".cache",
+	"XDG_STATE_HOME":  ".local/state",
+}
+
+// pathVariableValue returns the value $name expands to in a path, falling
+// back to the XDG default for unset base directories. It returns "" when
+// the variable cannot be resolved.
+func pathVariableValue(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	if rel, ok := xdgDefaults[name]; ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rel)
+		}
+	}
+	return ""
+}
+
+// expandPathVariables replaces $HOME and the XDG base directory variables in
+// path with their values. References that cannot be resolved ($HOME or
+// $XDG_RUNTIME_DIR unset) are left as they are; see unresolvedPathVariables.
+func expandPathVariables(path string) string {
+	if !strings.Contains(path, "$") {
+		return path
+	}
+	return pathVariablePattern.ReplaceAllStringFunc(path, func(ref string) string {
+		if value := pathVariableValue(strings.Trim(ref, "${}")); value != "" {
+			return value
+		}
+		return ref
+	})
+}
+
+// unresolvedPathVariables returns the variables in path that NormalizePath
+// cannot expand, so the entry would be used as a literal path.
+func unresolvedPathVariables(path string) []string {
+	var names []string
+	for _, ref := range pathVariablePattern.FindAllString(path, -1) {
+		if name := strings.Trim(ref, "${}"); pathVariableValue(name) == "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 // NormalizePath normalizes a path for sandbox configuration.
-// Handles tilde expansion and relative paths.
+// Handles $HOME and XDG variable expansion, tilde expansion and relative
+// paths.
 func NormalizePath(pathPattern string) string {
 	home, _ := os.UserHomeDir()
 	cwd, _ := os.Getwd()
 
+	pathPattern = expandPathVariables(pathPattern)
 	normalized := pathPattern
 
 	// Expand ~ and relative paths
 	switch {
+	case strings.HasPrefix(pathPattern, "$"):
+		// Starts with an unset variable, so there is nothing to resolve
+		return pathPattern
 	case pathPattern == "~":
 		normalized = home
 	case strings.HasPrefix(pathPattern, "~/"):
@@ -54,7 +116,9 @@ func NormalizePath(pathPattern string) string {
 }
 
 // GenerateProxyEnvVars creates environment variables for proxy configuration.
-func GenerateProxyEnvVars(httpPort, socksPort int) []string {
+// tools selects the tool-specific proxy variables to add (see ProxyEnvTools);
+// nil adds all of them.
+func GenerateProxyEnvVars(httpPort, socksPort int, tools []string) []string {
 	tmpDir := ensureSandboxTMPDIR()
 
 	envVars := []string{
@@ -92,6 +156,16 @@ func GenerateProxyEnvVars(httpPort, socksPort int) []string {
 			"http_proxy="+proxyURL,
 			"https_proxy="+proxyURL,
 		)
+		for _, env := range toolProxyEnvVars("localhost", httpPort, tools) {
+			// Keep the caller's own JVM options
+			key, value, _ := strings.Cut(env, "=")
+			if strings.HasSuffix(key, "_OPTS") {
+				if existing := os.Getenv(key); existing != "" {
+					env = key + "=" + existing + " " + value
+				}
+			}
+			envVars = append(envVars, env)
+		}
 	}
 
 	if socksPort > 0 {
@@ -111,6 +185,46 @@ func GenerateProxyEnvVars(httpPort, socksPort int) []string {
 	return envVars
 }
 
+// ProxyEnvTools lists the tools GenerateProxyEnvVars can set proxy
+// variables for, beyond the standard HTTP_PROXY family. Go is not listed:
+// the go command already honors HTTPS_PROXY.
+var ProxyEnvTools = []string{"cargo", "npm", "pip", "java", "gradle"}
+
+// toolProxyEnvVars returns the proxy variables for each of tools (nil means
+// ProxyEnvTools) that reads its own settings instead of, or as well as,
+// HTTP_PROXY and HTTPS_PROXY.
+func toolProxyEnvVars(host string, port int, tools []string) []string {
+	if tools == nil {
+		tools = ProxyEnvTools
+	}
+	proxyURL := "http://" + host + ":" + itoa(port)
+	// The JVM ignores HTTP_PROXY and only reads system properties
+	jvmProps := strings.Join([]string{
+		"-Dhttp.proxyHost=" + host,
+		"-Dhttp.proxyPort=" + itoa(port),
+		"-Dhttps.proxyHost=" + host,
+		"-Dhttps.proxyPort=" + itoa(port),
+		"-Dhttp.nonProxyHosts=localhost|127.0.0.1|[::1]",
+	}, " ")
+
+	var envVars []string
+	for _, tool := range tools {
+		switch tool {
+		case "cargo":
+			envVars = append(envVars, "CARGO_HTTP_PROXY="+proxyURL)
+		case "npm":
+			envVars = append(envVars, "NPM_CONFIG_PROXY="+proxyURL, "NPM_CONFIG_HTTPS_PROXY="+proxyURL)
+		case "pip":
+			envVars = append(envVars, "PIP_PROXY="+proxyURL)
+		case "java":
+			envVars = append(envVars, "JAVA_OPTS="+jvmProps)
+		case "gradle":
+			envVars = append(envVars, "GRADLE_OPTS="+jvmProps)
+		}
+	}
+	return envVars
+}
+
 // ensureSandboxTMPDIR ensures the dedicated sandbox TMPDIR exists and is usable.
 // Falls back to /tmp if the dedicated directory cannot be created.
 func ensureSandboxTMPDIR() string {
@@ -3,6 +3,7 @@ package sandbox
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -123,6 +124,92 @@ func TestNormalizePath(t *testing.T) {
 	}
 }
 
+func TestNormalizePathExpandsVariables(t *testing.T) {
+	dir := t.TempDir()
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := []string{"HOME", "XDG_CONFIG_HOME", "XDG_CACHE_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME", "XDG_RUNTIME_DIR"}
+
+	for _, name := range vars {
+		t.Run(name+" set", func(t *testing.T) {
+			t.Setenv(name, dir)
+			if got := NormalizePath("$" + name); got != resolved {
+				t.Errorf("NormalizePath($%s) = %q, want %q", name, got, resolved)
+			}
+			want := filepath.Join(dir, "fence", "config.json")
+			if got := NormalizePath("${" + name + "}/fence/config.json"); got != want {
+				t.Errorf("NormalizePath(${%s}/fence/config.json) = %q, want %q", name, got, want)
+			}
+		})
+		t.Run(name+" unset", func(t *testing.T) {
+			t.Setenv("HOME", dir)
+			t.Setenv(name, "")
+			input := "$" + name + "/fence"
+			got := NormalizePath(input)
+			if rel, ok := xdgDefaults[name]; ok {
+				// Unset XDG base directories fall back to the spec defaults
+				if want := filepath.Join(dir, rel, "fence"); got != want {
+					t.Errorf("NormalizePath(%q) = %q, want %q", input, got, want)
+				}
+				if vars := unresolvedPathVariables(input); len(vars) != 0 {
+					t.Errorf("unresolvedPathVariables(%q) = %v, want none", input, vars)
+				}
+				return
+			}
+			if name == "HOME" {
+				return // Covered by "HOME unset" below
+			}
+			if got != input {
+				t.Errorf("NormalizePath(%q) = %q, want it unchanged", input, got)
+			}
+			if vars := unresolvedPathVariables(input); !slices.Equal(vars, []string{name}) {
+				t.Errorf("unresolvedPathVariables(%q) = %v, want [%s]", input, vars, name)
+			}
+		})
+	}
+
+	t.Run("HOME unset", func(t *testing.T) {
+		t.Setenv("HOME", "")
+		t.Setenv("XDG_CONFIG_HOME", "")
+		input := "$HOME/.ssh/$XDG_CONFIG_HOME"
+		if got := NormalizePath(input); got != input {
+			t.Errorf("NormalizePath(%q) = %q, want it unchanged", input, got)
+		}
+		if vars := unresolvedPathVariables(input); !slices.Equal(vars, []string{"HOME", "XDG_CONFIG_HOME"}) {
+			t.Errorf("unresolvedPathVariables(%q) = %v, want [HOME XDG_CONFIG_HOME]", input, vars)
+		}
+	})
+
+	t.Run("partial expansion", func(t *testing.T) {
+		t.Setenv("HOME", dir)
+		t.Setenv("XDG_CACHE_HOME", "")
+		t.Setenv("XDG_RUNTIME_DIR", "")
+		want := dir + "/.config/" + dir + "/.cache/$XDG_RUNTIME_DIR"
+		if got := NormalizePath("$HOME/.config/$XDG_CACHE_HOME/$XDG_RUNTIME_DIR"); got != want {
+			t.Errorf("NormalizePath() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("other variables untouched", func(t *testing.T) {
+		t.Setenv("HOME", dir)
+		t.Setenv("HOMEDIR", "/elsewhere")
+		want := filepath.Join(dir, "$HOMEDIR", "$PWD")
+		if got := NormalizePath("$HOME/$HOMEDIR/$PWD"); got != want {
+			t.Errorf("NormalizePath() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("glob after variable", func(t *testing.T) {
+		t.Setenv("XDG_DATA_HOME", dir)
+		want := dir + "/**/*.db"
+		if got := NormalizePath("$XDG_DATA_HOME/**/*.db"); got != want {
+			t.Errorf("NormalizePath() = %q, want %q", got, want)
+		}
+	})
+}
+
 func TestGenerateProxyEnvVars(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -194,7 +281,7 @@ func TestGenerateProxyEnvVars(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := GenerateProxyEnvVars(tt.httpPort, tt.socksPort)
+			got := GenerateProxyEnvVars(tt.httpPort, tt.socksPort, nil)
 
 			// Check expected env vars are present
 			for _, want := range tt.wantEnvs {
@@ -238,6 +325,50 @@ func TestGenerateProxyEnvVars(t *testing.T) {
 	}
 }
 
+func TestGenerateProxyEnvVarsTools(t *testing.T) {
+	t.Setenv("JAVA_OPTS", "")
+	t.Setenv("GRADLE_OPTS", "-Xmx2g")
+	jvmProps := "-Dhttp.proxyHost=localhost -Dhttp.proxyPort=8080 -Dhttps.proxyHost=localhost -Dhttps.proxyPort=8080"
+
+	got := GenerateProxyEnvVars(8080, 1080, nil)
+	for _, want := range []string{
+		"CARGO_HTTP_PROXY=http://localhost:8080",
+		"NPM_CONFIG_PROXY=http://localhost:8080",
+		"NPM_CONFIG_HTTPS_PROXY=http://localhost:8080",
+		"PIP_PROXY=http://localhost:8080",
+		"JAVA_OPTS=" + jvmProps,
+		"GRADLE_OPTS=-Xmx2g " + jvmProps,
+	} {
+		found := false
+		for _, env := range got {
+			if strings.HasPrefix(env, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("GenerateProxyEnvVars() missing %q, got %v", want, got)
+		}
+	}
+
+	got = GenerateProxyEnvVars(8080, 1080, []string{"pip"})
+	for _, env := range got {
+		if strings.HasPrefix(env, "CARGO_HTTP_PROXY=") || strings.HasPrefix(env, "JAVA_OPTS=") {
+			t.Errorf("GenerateProxyEnvVars(pip) should not contain %q", env)
+		}
+	}
+	if !slices.Contains(got, "PIP_PROXY=http://localhost:8080") {
+		t.Errorf("GenerateProxyEnvVars(pip) missing PIP_PROXY, got %v", got)
+	}
+
+	// Without an HTTP proxy there is nothing to point the tools at
+	for _, env := range GenerateProxyEnvVars(0, 1080, nil) {
+		if strings.HasPrefix(env, "PIP_PROXY=") {
+			t.Errorf("GenerateProxyEnvVars(0, 1080) should not contain %q", env)
+		}
+	}
+}
+
 func TestEnsureSandboxTMPDIRPathRejectsSymlink(t *testing.T) {
 	root := t.TempDir()
 	target := filepath.Join(root, "real-target")
@@ -0,0 +1,178 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// webhookTimeout bounds each POST; failed deliveries are not retried.
+	webhookTimeout = 5 * time.Second
+	// webhookQueueSize is how many violations can wait for delivery before
+	// new ones are dropped.
+	webhookQueueSize = 64
+	// webhookCloseTimeout bounds how long Close waits for queued deliveries.
+	webhookCloseTimeout = 10 * time.Second
+)
+
+// WebhookPayload is the JSON body POSTed for each violation.
+type WebhookPayload struct {
+	Type      string    `json:"type"` // Always AuditEventViolation
+	Rule      string    `json:"rule"`
+	Detail    string    `json:"detail"`
+	Timestamp time.Time `json:"timestamp"`
+	Command   string    `json:"command"` // Command being run when the violation occurred
+}
+
+// WebhookAuditLogger is an AuditLogger that POSTs each violation to a URL.
+// Deliveries happen on a background goroutine so logging never blocks the
+// sandboxed command; other events only update the reported command.
+type WebhookAuditLogger struct {
+	url    string
+	client *http.Client
+	warn   io.Writer
+
+	mu      sync.Mutex
+	command string
+	closed  bool
+
+	queue        chan WebhookPayload
+	done         chan struct{}
+	ctx          context.Context
+	cancel       context.CancelFunc
+	closeTimeout time.Duration
+}
+
+// NewWebhookAuditLogger starts a logger that delivers violations to url.
+// Call Close to wait for queued deliveries.
+func NewWebhookAuditLogger(url string) *WebhookAuditLogger {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &WebhookAuditLogger{
+		url:          url,
+		client:       &http.Client{Timeout: webhookTimeout},
+		warn:         os.Stderr,
+		queue:        make(chan WebhookPayload, webhookQueueSize),
+		done:         make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+		closeTimeout: webhookCloseTimeout,
+	}
+	go l.run()
+	return l
+}
+
+// SetCommand sets the command reported with later violations.
+func (l *WebhookAuditLogger) SetCommand(cmd string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.command = cmd
+}
+
+// LogNetworkRequest implements AuditLogger.
+func (l *WebhookAuditLogger) LogNetworkRequest(_, _ string, _ bool) {}
+
+// LogFilesystemAccess implements AuditLogger.
+func (l *WebhookAuditLogger) LogFilesystemAccess(_, _ string, _ bool) {}
+
+// LogCommandExec implements AuditLogger by recording cmd for later
+// violations.
+func (l *WebhookAuditLogger) LogCommandExec(cmd string, _ bool) {
+	l.SetCommand(cmd)
+}
+
+// LogViolation implements AuditLogger. The violation is queued for delivery,
+// or dropped with a warning if the queue is full.
+func (l *WebhookAuditLogger) LogViolation(rule, detail string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+
+	payload := WebhookPayload{
+		Type:      AuditEventViolation,
+		Rule:      rule,
+		Detail:    detail,
+		Timestamp: time.Now().UTC(),
+		Command:   l.command,
+	}
+	select {
+	case l.queue <- payload:
+	default:
+		fmt.Fprintf(l.warn, "[fence] Warning: webhook queue full, dropped violation of %s\n", rule)
+	}
+}
+
+// Close stops accepting violations and waits for queued ones to be
+// delivered. After webhookCloseTimeout the delivery in flight is aborted and
+// the violations still queued are dropped with a warning, so a slow endpoint
+// cannot hold up fence's exit.
+func (l *WebhookAuditLogger) Close() error {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.queue)
+	}
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.closeTimeout)
+	defer timer.Stop()
+	select {
+	case <-l.done:
+	case <-timer.C:
+		l.cancel()
+		<-l.done
+	}
+	l.cancel()
+	return nil
+}
+
+func (l *WebhookAuditLogger) run() {
+	defer close(l.done)
+	dropped := 0
+	for payload := range l.queue {
+		if l.ctx.Err() != nil {
+			dropped++
+			continue
+		}
+		if err := l.post(payload); err != nil {
+			if l.ctx.Err() != nil {
+				dropped++
+				continue
+			}
+			fmt.Fprintf(l.warn, "[fence] Warning: webhook notification failed: %v\n", err)
+		}
+	}
+	if dropped > 0 {
+		fmt.Fprintf(l.warn, "[fence] Warning: webhook delivery timed out, dropped %d violation(s)\n", dropped)
+	}
+}
+
+func (l *WebhookAuditLogger) post(payload WebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(l.ctx, http.MethodPost, l.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := l.client.Do(req) //nolint:gosec // URL comes from the user's config
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", l.url, resp.Status)
+	}
+	return nil
+}
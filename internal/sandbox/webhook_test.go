@@ -0,0 +1,138 @@
+package sandbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookAuditLoggerPayload(t *testing.T) {
+	received := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with Content-Type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("body is not JSON: %v", err)
+		}
+		received <- body
+	}))
+	defer server.Close()
+
+	logger := NewWebhookAuditLogger(server.URL)
+	logger.LogNetworkRequest("example.com", "443", false) // Only violations are sent
+	logger.LogCommandExec("git push origin main", false)
+	logger.LogViolation("git push", "command blocked by command.deny")
+	_ = logger.Close()
+
+	body := <-received
+	for key, want := range map[string]string{
+		"type":    "violation",
+		"rule":    "git push",
+		"detail":  "command blocked by command.deny",
+		"command": "git push origin main",
+	} {
+		if body[key] != want {
+			t.Errorf("%s = %v, want %q", key, body[key], want)
+		}
+	}
+	if ts, _ := body["timestamp"].(string); ts == "" {
+		t.Error("payload has no timestamp")
+	} else if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+		t.Errorf("timestamp %q is not RFC 3339: %v", ts, err)
+	}
+	if len(body) != 5 {
+		t.Errorf("payload = %v, want exactly type, rule, detail, timestamp and command", body)
+	}
+}
+
+func TestWebhookAuditLoggerNonBlocking(t *testing.T) {
+	release := make(chan struct{})
+	requests := make(chan struct{}, webhookQueueSize+10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+
+	logger := NewWebhookAuditLogger(server.URL)
+	var warnings bytes.Buffer
+	logger.warn = &warnings
+
+	// The server holds the first request, so later violations wait in the
+	// queue and the ones beyond it are dropped, without LogViolation blocking.
+	start := time.Now()
+	for i := 0; i < webhookQueueSize+5; i++ {
+		logger.LogViolation("rule", "detail")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("LogViolation blocked for %v", elapsed)
+	}
+
+	close(release)
+	_ = logger.Close()
+	if n := len(requests); n < webhookQueueSize || n > webhookQueueSize+1 {
+		t.Errorf("server got %d requests, want the queue size (%d) plus at most the one in flight", n, webhookQueueSize)
+	}
+	if !strings.Contains(warnings.String(), "webhook queue full") {
+		t.Errorf("expected a dropped-violation warning, got %q", warnings.String())
+	}
+
+	// Violations after Close are ignored
+	logger.LogViolation("rule", "late")
+}
+
+func TestWebhookAuditLoggerWarnsOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	logger := NewWebhookAuditLogger(server.URL)
+	var warnings bytes.Buffer
+	logger.warn = &warnings
+	logger.LogViolation("rule", "detail")
+	_ = logger.Close()
+
+	if !strings.Contains(warnings.String(), "500 Internal Server Error") {
+		t.Errorf("warning = %q, want the failing status", warnings.String())
+	}
+}
+
+func TestWebhookAuditLoggerCloseTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	logger := NewWebhookAuditLogger(server.URL)
+	logger.closeTimeout = 50 * time.Millisecond
+	var warnings bytes.Buffer
+	logger.warn = &warnings
+	for i := 0; i < 3; i++ {
+		logger.LogViolation("rule", "detail")
+	}
+
+	// The server never answers, so Close gives up on the request in flight
+	// and drops it along with the two still queued.
+	start := time.Now()
+	_ = logger.Close()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Close took %v, want it bounded by the close timeout", elapsed)
+	}
+	if !strings.Contains(warnings.String(), "dropped 3 violation(s)") {
+		t.Errorf("warning = %q, want a count of the dropped violations", warnings.String())
+	}
+}
@@ -26,6 +26,12 @@ func isPath(s string) bool {
 //go:embed *.json
 var templatesFS embed.FS
 
+func init() {
+	// Lets config.Validate check extends without importing this package
+	config.TemplateExists = Exists
+	config.TemplateNames = Names
+}
+
 // Template represents a named configuration template.
 type Template struct {
 	Name        string
@@ -73,6 +79,15 @@ func List() []Template {
 	return templates
 }
 
+// Names returns all available template names sorted alphabetically.
+func Names() []string {
+	var names []string
+	for _, t := range List() {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
 // Load loads a template by name and returns the parsed config.
 // If the template uses "extends", the inheritance chain is resolved.
 func Load(name string) (*config.Config, error) {
@@ -240,14 +255,14 @@ func loadConfigFile(path, baseDir string, seen map[string]bool) (*config.Config,
 		return nil, "", fmt.Errorf("extends file is empty: %q", path)
 	}
 
-	var cfg config.Config
-	if err := json.Unmarshal(jsonc.ToJSON(data), &cfg); err != nil {
-		return nil, "", fmt.Errorf("invalid JSON in extends file %q: %w", path, err)
+	cfg, err := config.ParseConfig(resolvedPath, data)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid config in extends file %q: %w", path, err)
 	}
 
 	if err := cfg.Validate(); err != nil {
 		return nil, "", fmt.Errorf("invalid configuration in extends file %q: %w", path, err)
 	}
 
-	return &cfg, filepath.Dir(resolvedPath), nil
+	return cfg, filepath.Dir(resolvedPath), nil
 }
@@ -88,6 +88,33 @@ func TestExists(t *testing.T) {
 	}
 }
 
+func TestBuiltinTemplateNames(t *testing.T) {
+	names := config.BuiltinTemplateNames()
+	if len(names) != len(List()) {
+		t.Fatalf("BuiltinTemplateNames() = %v, want one name per template", names)
+	}
+	for _, name := range names {
+		if !Exists(name) {
+			t.Errorf("template %q listed but does not exist", name)
+		}
+	}
+}
+
+func TestTemplatesPassValidate(t *testing.T) {
+	for _, tmpl := range List() {
+		cfg, err := Load(tmpl.Name)
+		if err != nil {
+			t.Fatalf("Load(%q) error = %v", tmpl.Name, err)
+		}
+		for _, e := range config.Validate(cfg) {
+			t.Errorf("template %q: %v", tmpl.Name, e)
+		}
+	}
+	if errs := config.Validate(&config.Config{Extends: "no-such-template"}); len(errs) != 1 || errs[0].Code != config.CodeUnknownTemplate {
+		t.Errorf("Validate() with unknown extends = %v, want one unknown_template error", errs)
+	}
+}
+
 func TestCodeTemplate(t *testing.T) {
 	cfg, err := Load("code")
 	if err != nil {
@@ -472,6 +499,26 @@ func TestExtendsFilePath(t *testing.T) {
 		}
 	})
 
+	t.Run("extends YAML file", func(t *testing.T) {
+		yamlPath := filepath.Join(tmpDir, "base.yaml")
+		content := "# shared base\nnetwork:\n  allowedDomains:\n    - yaml.example.com\n"
+		if err := os.WriteFile(yamlPath, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write YAML config: %v", err)
+		}
+
+		cfg := &config.Config{
+			Extends: yamlPath,
+		}
+
+		result, err := ResolveExtendsWithBaseDir(cfg, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Network.AllowedDomains) != 1 || result.Network.AllowedDomains[0] != "yaml.example.com" {
+			t.Errorf("expected domain from YAML base, got %v", result.Network.AllowedDomains)
+		}
+	})
+
 	t.Run("extends file with invalid config", func(t *testing.T) {
 		// Create config with invalid domain pattern
 		invalidContent := `{
@@ -2,6 +2,8 @@
 package fence
 
 import (
+	"io"
+
 	"github.com/Use-Tusk/fence/internal/config"
 	"github.com/Use-Tusk/fence/internal/platform"
 	"github.com/Use-Tusk/fence/internal/sandbox"
@@ -24,6 +26,29 @@ type FilesystemConfig = config.FilesystemConfig
 // Manager handles sandbox initialization and command wrapping.
 type Manager = sandbox.Manager
 
+// AuditLogger receives sandbox decisions; see Manager.SetAuditLogger.
+type AuditLogger = sandbox.AuditLogger
+
+// FileAuditLogger is an AuditLogger that writes JSON lines to a file.
+type FileAuditLogger = sandbox.FileAuditLogger
+
+// NewFileAuditLogger opens path for appending JSON-lines audit events.
+func NewFileAuditLogger(path string) (*FileAuditLogger, error) {
+	return sandbox.NewFileAuditLogger(path)
+}
+
+// StatsCollector is an AuditLogger that counts sandbox decisions.
+type StatsCollector = sandbox.StatsCollector
+
+// Stats holds the counts from a StatsCollector.
+type Stats = sandbox.Stats
+
+// NewStatsCollector creates a StatsCollector; pass it to
+// Manager.SetAuditLogger and read Stats after the command exits.
+func NewStatsCollector() *StatsCollector {
+	return sandbox.NewStatsCollector()
+}
+
 // NewManager creates a new sandbox manager.
 // If debug is true, verbose logging is enabled.
 // If monitor is true, only violations (blocked requests) are logged.
@@ -31,6 +56,14 @@ func NewManager(cfg *Config, debug, monitor bool) *Manager {
 	return sandbox.NewManager(cfg, debug, monitor)
 }
 
+// VerifyDependencies checks that the programs the sandbox runs (bwrap and
+// socat on Linux, sandbox-exec on macOS) are installed for cfg and the ports
+// passed to Manager.SetExposedPorts, and returns an error listing every
+// missing one with an installation hint.
+func VerifyDependencies(cfg *Config, exposedPorts []int) error {
+	return sandbox.VerifyDependencies(cfg, exposedPorts)
+}
+
 // DefaultConfig returns the default configuration with all network blocked.
 func DefaultConfig() *Config {
 	return config.Default()
@@ -41,6 +74,17 @@ func LoadConfig(path string) (*Config, error) {
 	return config.Load(path)
 }
 
+// LoadConfigBytes loads configuration from JSON (JSONC) data, such as a
+// config embedded with go:embed.
+func LoadConfigBytes(data []byte) (*Config, error) {
+	return config.LoadConfigBytes(data)
+}
+
+// LoadConfigReader loads JSON (JSONC) configuration from r.
+func LoadConfigReader(r io.Reader) (*Config, error) {
+	return config.LoadConfigReader(r)
+}
+
 // DefaultConfigPath returns the default config file path.
 func DefaultConfigPath() string {
 	return config.DefaultConfigPath()
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Use-Tusk/fence/internal/configschema"
+)
+
+func main() {
+	data, err := configschema.GenerateTypeScript()
+	if err != nil {
+		fail("generate config types", err)
+	}
+
+	outputPath := filepath.Join("docs", "schema", "fence.d.ts")
+	if err := os.WriteFile(outputPath, data, 0o600); err != nil {
+		fail("write config types", err)
+	}
+}
+
+func fail(step string, err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "failed to %s: %v\n", step, err)
+	os.Exit(1)
+}